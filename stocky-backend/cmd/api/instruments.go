@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/mhatrejeets/stocky-ms/internal/infra"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/mhatrejeets/stocky-ms/internal/service"
+)
+
+const instrumentsUsage = "usage: stocky instruments import <file.csv> [NSE|BSE]"
+
+// runInstruments implements the `instruments` subcommand and returns the exit code.
+func runInstruments(args []string) int {
+	if len(args) < 2 || args[0] != "import" {
+		fmt.Fprintln(os.Stderr, instrumentsUsage)
+		return 2
+	}
+	exchange := model.ExchangeNSE
+	if len(args) > 2 {
+		exchange = args[2]
+	}
+	f, err := os.Open(args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer f.Close()
+	db, err := infra.NewDB()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to connect to DB: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+	svc := &service.InstrumentService{Repo: &repo.InstrumentRepositoryImpl{DB: db}}
+	n, err := svc.Import(context.Background(), f, exchange)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import failed: %v\n", err)
+		return 1
+	}
+	fmt.Printf("imported %d instruments\n", n)
+	return 0
+}
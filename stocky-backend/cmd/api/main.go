@@ -1,16 +1,31 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/mhatrejeets/stocky-ms/internal/api"
 	"github.com/mhatrejeets/stocky-ms/internal/auth"
+	"github.com/mhatrejeets/stocky-ms/internal/events"
+	"github.com/mhatrejeets/stocky-ms/internal/health"
 	"github.com/mhatrejeets/stocky-ms/internal/infra"
+	"github.com/mhatrejeets/stocky-ms/internal/market"
+	"github.com/mhatrejeets/stocky-ms/internal/middleware"
+	"github.com/mhatrejeets/stocky-ms/internal/migrate"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
 	"github.com/mhatrejeets/stocky-ms/internal/repo"
 	"github.com/mhatrejeets/stocky-ms/internal/service"
 
-	"github.com/IBM/sarama"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
 	"github.com/sirupsen/logrus"
 )
 
@@ -19,6 +34,16 @@ func main() {
 	logrus.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
 	logrus.SetLevel(logrus.InfoLevel)
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "instruments" {
+		os.Exit(runInstruments(os.Args[2:]))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Initialize DB
 	db, err := infra.NewDB()
 	if err != nil {
@@ -26,6 +51,17 @@ func main() {
 	}
 	defer db.Close()
 
+	// Optionally apply migrations; the advisory lock keeps replicas from racing
+	if infra.GetEnvBool("MIGRATE_ON_START", false) {
+		migrator, err := migrate.New(db)
+		if err != nil {
+			logrus.Fatalf("Failed to load migrations: %v", err)
+		}
+		if err := migrator.Up(ctx); err != nil {
+			logrus.Fatalf("Failed to run migrations: %v", err)
+		}
+	}
+
 	// Initialize Redis
 	redisClient := infra.NewRedisClient()
 	defer redisClient.Close()
@@ -36,37 +72,471 @@ func main() {
 	}
 
 	r := gin.Default()
+	middleware.InitMetrics(health.StalePriceRatio, service.PriceTicksTotal, service.PricesQuarantinedTotal, infra.PriceCacheLookups, service.PortfolioStreamsOpen,
+		service.AlertsFiredTotal, service.AlertDeliveriesTotal, service.NotificationDeliveriesTotal, service.OutboxPublishesTotal)
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
-	})
+	// Trading calendar and price staleness rules
+	hours, err := market.ParseHours(infra.GetEnv("MARKET_TZ", "Asia/Kolkata"), infra.GetEnv("MARKET_OPEN", "09:15"), infra.GetEnv("MARKET_CLOSE", "15:30"))
+	if err != nil {
+		logrus.Fatalf("Invalid market hours: %v", err)
+	}
+	holidays, err := market.LoadHolidayFile(os.Getenv("MARKET_HOLIDAY_FILE"))
+	if err != nil {
+		logrus.Fatalf("Failed to load market holidays: %v", err)
+	}
+	calendar := market.NewCalendar(infra.GetEnv("MARKET_EXCHANGE", "NSE"), hours, holidays)
+	staleness := &market.StalenessPolicy{Calendar: calendar, Exchanges: market.ExchangeCalendars(holidays), MaxAge: infra.GetEnvDuration("PRICE_STALE_AFTER", 15*time.Minute)}
+
+	// CloudEvents encoding, validated against the local schema registry
+	schemas, err := events.LoadSchemaRegistry(os.Getenv("SCHEMA_REGISTRY_DIR"))
+	if err != nil {
+		logrus.Fatalf("Failed to load event schemas: %v", err)
+	}
+	encoder := &events.Encoder{
+		Source:   infra.GetEnv("EVENT_SOURCE", "/stocky-backend"),
+		Encoding: infra.GetEnv("EVENT_ENCODING", events.EncodingJSON),
+		Schemas:  schemas,
+	}
 
-	//Kafka implementation``
-	kafkaConfig := sarama.NewConfig()
-	kafkaConfig.Producer.Return.Successes = true
-	brokers := []string{os.Getenv("KAFKA_BROKERS")}
-	producer, err := sarama.NewSyncProducer(brokers, kafkaConfig)
+	// Event publisher (Kafka, noop, memory or file; see EVENT_PUBLISHER)
+	brokers := infra.GetEnvList("KAFKA_BROKERS")
+	publisher, err := infra.NewEventPublisher(brokers)
+	if err != nil {
+		logrus.Fatalf("Failed to create event publisher: %v", err)
+	}
+	defer publisher.Close()
+	_, kafkaEnabled := publisher.(*infra.KafkaProducer)
+
+	// Holdings projection (read model for portfolio and stats). Without Kafka
+	// delivering events back, the projector is fed in-process on publish.
+	holdingsRepo := &repo.HoldingsRepositoryImpl{DB: db}
+	portfolioNotifier := &infra.RedisPortfolioNotifier{Client: redisClient}
+	projector := &service.PortfolioProjector{Holdings: holdingsRepo, Notifier: portfolioNotifier}
+	projectionRegistry := events.NewRegistry()
+	projector.Register(projectionRegistry)
+
+	// Reward, reversal and dividend notifications: the service queues them
+	// as events arrive and the dispatcher sends and retries them
+	notificationsEnabled := infra.GetEnvBool("NOTIFICATIONS_ENABLED", true)
+	notificationRepo := &repo.NotificationRepositoryImpl{DB: db}
+	notificationTemplates, err := service.LoadNotificationTemplates(os.Getenv("NOTIFY_TEMPLATE_DIR"))
+	if err != nil {
+		logrus.Fatalf("Failed to load notification templates: %v", err)
+	}
+	notificationTemplates.DefaultLocale = infra.GetEnv("NOTIFY_DEFAULT_LOCALE", "en")
+	if !notificationTemplates.HasLocale(notificationTemplates.DefaultLocale) {
+		logrus.Fatalf("No notification templates for NOTIFY_DEFAULT_LOCALE %q", notificationTemplates.DefaultLocale)
+	}
+	notificationSenders, err := infra.NewNotificationSendersFromEnv()
 	if err != nil {
-		logrus.Fatalf("Failed to initialize Kafka producer: %v", err)
+		logrus.Fatalf("Invalid notification sender config: %v", err)
+	}
+	notificationService := &service.NotificationService{
+		Repo:             notificationRepo,
+		Holders:          holdingsRepo,
+		Templates:        notificationTemplates,
+		Channels:         []string{},
+		Location:         calendar.Hours.Location,
+		DividendInterval: infra.GetEnvDuration("NOTIFY_DIVIDEND_INTERVAL", time.Minute),
+	}
+	notificationDispatcher := &service.NotificationDispatcher{
+		Repo:        notificationRepo,
+		Senders:     make(map[string]service.NotificationSender),
+		Interval:    infra.GetEnvDuration("NOTIFY_DISPATCH_INTERVAL", 2*time.Second),
+		MaxAttempts: infra.GetEnvInt("NOTIFY_MAX_ATTEMPTS", 5),
+		Backoff:     infra.GetEnvDuration("NOTIFY_RETRY_BACKOFF", 30*time.Second),
+		MaxBackoff:  infra.GetEnvDuration("NOTIFY_RETRY_MAX_BACKOFF", time.Hour),
+		SendTimeout: infra.GetEnvDuration("NOTIFY_SEND_TIMEOUT", 30*time.Second),
+	}
+	for channel, sender := range notificationSenders {
+		notificationService.Channels = append(notificationService.Channels, channel)
+		notificationDispatcher.Senders[channel] = sender
+	}
+	notificationRegistry := events.NewRegistry()
+	notificationService.Register(notificationRegistry)
+
+	if !kafkaEnabled {
+		publisher = &events.DispatchingPublisher{Publisher: publisher, Registry: projectionRegistry}
+		if notificationsEnabled {
+			publisher = &events.DispatchingPublisher{Publisher: publisher, Registry: notificationRegistry}
+		}
+	}
+	deadLetterRepo := &repo.DeadLetterRepositoryImpl{DB: db}
+	// Each consumer group retries and dead-letters on topics of its own
+	retryRouter := func(group string) *events.RetryRouter {
+		return &events.RetryRouter{Publisher: publisher, Group: group, Tiers: events.DefaultRetryTiers, Sink: deadLetterRepo}
+	}
+	concurrency := infra.GetEnvInt("CONSUMER_CONCURRENCY", 8)
+
+	// Liveness and readiness probes
+	checkTimeout := infra.GetEnvDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second)
+	healthRegistry := health.NewRegistry(checkTimeout, infra.GetEnvDuration("HEALTH_CACHE_TTL", 5*time.Second))
+	healthRegistry.Register(&health.DBChecker{DB: db}, true)
+	healthRegistry.Register(&health.RedisChecker{Client: redisClient}, true)
+	if len(brokers) > 0 {
+		healthRegistry.Register(&health.KafkaChecker{Brokers: brokers, Timeout: checkTimeout}, false)
 	}
-	kafkaProducer := &infra.KafkaProducer{Producer: producer}
+	healthRegistry.Register(&health.PriceFreshnessChecker{DB: db, MaxAge: infra.GetEnvDuration("PRICE_MAX_AGE", 2*time.Hour)}, false)
+	stalePrices := &health.StalePricesChecker{
+		DB:       db,
+		Policy:   staleness,
+		MaxRatio: infra.GetEnvFloat("STALE_PRICE_MAX_RATIO", 0.2),
+		Interval: infra.GetEnvDuration("STALE_PRICE_GAUGE_INTERVAL", 30*time.Second),
+	}
+	healthRegistry.Register(stalePrices, false)
+	go runWorker(ctx, "Stale price gauge", stalePrices)
+	healthHandler := &api.HealthHandler{Registry: healthRegistry}
+	healthHandler.RegisterRoutes(r)
+
+	// Price sources in priority order, falling back to stock_prices
+	prices := infra.NewChainPriceProvider(db, redisClient)
+	// Valuations read through an in-process LRU and Redis in front of the chain
+	var valuationPrices repo.PriceSource = prices
+	var cachedPrices *infra.CachedPriceProvider
+	if infra.GetEnvBool("PRICE_CACHE_ENABLED", true) {
+		cachedPrices = infra.NewCachedPriceProviderFromEnv(prices, redisClient)
+		valuationPrices = cachedPrices
+	}
+	fxRepo := &repo.FXRepositoryImpl{DB: db}
 
 	// Redis idempotency implementation
 	redisIdem := &infra.RedisIdempotencyStoreImpl{Client: redisClient}
+	taxLots := &repo.TaxLotRepositoryImpl{DB: db}
+	instrumentRepo := &repo.InstrumentRepositoryImpl{DB: db}
 
 	repoImpl := &repo.RewardRepositoryImpl{
-		DB:            db,
-		Redis:         redisIdem,
-		KafkaProducer: kafkaProducer,
+		DB:        db,
+		Redis:     redisIdem,
+		Events:    publisher,
+		Encoder:   encoder,
+		Holdings:  holdingsRepo,
+		Prices:    valuationPrices,
+		FX:        fxRepo,
+		Listings:  instrumentRepo,
+		Staleness: staleness,
+		Lots:      taxLots,
+	}
+
+	// Reward events are written with the reward; the relay publishes any the
+	// request could not
+	outboxRelay := &service.OutboxRelay{
+		Repo:       &repo.OutboxRepositoryImpl{DB: db},
+		Events:     publisher,
+		Interval:   infra.GetEnvDuration("OUTBOX_RELAY_INTERVAL", time.Second),
+		Backoff:    infra.GetEnvDuration("OUTBOX_RETRY_BACKOFF", 5*time.Second),
+		MaxBackoff: infra.GetEnvDuration("OUTBOX_RETRY_MAX_BACKOFF", 5*time.Minute),
 	}
+	go runWorker(ctx, "Outbox relay", outboxRelay)
+	go runWorker(ctx, "Outbox pruner", &service.OutboxPruner{
+		Repo:      &repo.OutboxRepositoryImpl{DB: db},
+		Retention: infra.GetEnvDuration("OUTBOX_RETENTION", 7*24*time.Hour),
+		Interval:  infra.GetEnvDuration("OUTBOX_PRUNE_INTERVAL", time.Hour),
+	})
 
-	rewardService := &service.RewardService{Repo: repoImpl}
+	instrumentService := &service.InstrumentService{Repo: instrumentRepo}
+	rewardService := &service.RewardService{Repo: repoImpl, Lots: taxLots}
+	priceRepo := &repo.PriceRepositoryImpl{DB: db, Location: calendar.Hours.Location}
+	candleService := &service.CandleService{Repo: priceRepo}
+	if infra.GetEnvBool("INSTRUMENT_VALIDATION", true) {
+		rewardService.Instruments = instrumentService
+		candleService.Instruments = instrumentService
+	}
 	rewardHandler := &api.RewardHandler{Service: rewardService}
 	// API JWT middleware
 	jwtSecret := os.Getenv("JWT_SECRET")
 	v1 := r.Group("/api/v1", auth.JWT(jwtSecret))
 	rewardHandler.RegisterRoutes(v1)
+	instrumentHandler := &api.InstrumentHandler{Service: instrumentService}
+	instrumentHandler.RegisterRoutes(v1)
+	priceHandler := &api.PriceHandler{Candles: candleService}
+	priceHandler.RegisterRoutes(v1)
 
-	logrus.Infof("Starting server on port %s", port)
-	r.Run(":" + port)
+	// Live portfolio over SSE/WebSocket, woken by price and holdings changes
+	portfolioStreams := &service.PortfolioStreams{
+		Portfolios:  rewardService,
+		MinInterval: infra.GetEnvDuration("STREAM_MIN_INTERVAL", time.Second),
+		Heartbeat:   infra.GetEnvDuration("STREAM_HEARTBEAT", 15*time.Second),
+		MaxPerUser:  infra.GetEnvInt("STREAM_MAX_PER_USER", 5),
+	}
+	streamHandler := &api.PortfolioStreamHandler{Streams: portfolioStreams, WriteTimeout: infra.GetEnvDuration("STREAM_WRITE_TIMEOUT", 10*time.Second)}
+	streamHandler.RegisterRoutes(v1)
+
+	// Alert rules, checked against the same price and holdings changes
+	alertRepo := &repo.AlertRepositoryImpl{DB: db}
+	alertService := &service.AlertService{
+		Repo:            alertRepo,
+		MaxRules:        infra.GetEnvInt("ALERT_MAX_RULES", 50),
+		DefaultCooldown: int(infra.GetEnvDuration("ALERT_DEFAULT_COOLDOWN", time.Hour).Seconds()),
+	}
+	if infra.GetEnvBool("INSTRUMENT_VALIDATION", true) {
+		alertService.Instruments = instrumentService
+	}
+	alertHandler := &api.AlertHandler{Service: alertService}
+	alertHandler.RegisterRoutes(v1)
+	var alertEvaluator *service.AlertEvaluator
+	if infra.GetEnvBool("ALERTS_ENABLED", true) {
+		alertNotifier, err := infra.NewAlertNotifierFromEnv()
+		if err != nil {
+			logrus.Fatalf("Invalid alert notifier config: %v", err)
+		}
+		alertEvaluator = &service.AlertEvaluator{
+			Rules:      alertRepo,
+			Portfolios: rewardService,
+			Closes:     priceRepo,
+			Notifier:   alertNotifier,
+			Location:   calendar.Hours.Location,
+			Interval:   infra.GetEnvDuration("ALERT_EVAL_INTERVAL", 2*time.Second),
+		}
+		go runWorker(ctx, "Alert evaluator", alertEvaluator)
+	}
+
+	// Notification preferences and delivery history
+	notificationHandler := &api.NotificationHandler{Service: notificationService}
+	notificationHandler.RegisterRoutes(v1)
+	if notificationsEnabled {
+		go runWorker(ctx, "Notification dispatcher", notificationDispatcher)
+		go runWorker(ctx, "Dividend notifier", notificationService)
+	}
+
+	// Admin endpoints
+	admin := v1.Group("/admin", auth.RequireRole("admin"))
+	deadLetterHandler := &api.DeadLetterHandler{Service: &service.DeadLetterService{Repo: deadLetterRepo, Events: publisher, Tiers: events.DefaultRetryTiers}}
+	deadLetterHandler.RegisterRoutes(admin)
+	portfolioAdminHandler := &api.PortfolioAdminHandler{
+		Rewards:          rewardService,
+		CorporateActions: &service.CorporateActionService{Events: &repo.OutboxPublisher{DB: db, Events: publisher}, Encoder: encoder},
+		Projector:        projector,
+	}
+	portfolioAdminHandler.RegisterRoutes(admin)
+	instrumentHandler.RegisterAdminRoutes(admin)
+
+	// Every price write passes the bad-tick guard; outliers wait for review
+	priceCache := &infra.RedisPriceCache{Client: redisClient, TTL: infra.GetEnvDuration("PRICE_CACHE_TTL", 2*time.Hour)}
+	priceNotifier := &infra.RedisPriceNotifier{Client: redisClient}
+	bands, err := service.ParsePriceBands(infra.GetEnv("PRICE_BAND_PCT", "20"), infra.GetEnvList("PRICE_BANDS"))
+	if err != nil {
+		logrus.Fatalf("Invalid price bands: %v", err)
+	}
+	priceGuard := &service.PriceGuard{
+		Reference:  &infra.DBPriceProvider{DB: db},
+		Quarantine: &repo.PriceQuarantineRepositoryImpl{DB: db, Prices: priceRepo},
+		Bands:      bands,
+		Cache:      priceCache,
+		Notifier:   priceNotifier,
+	}
+	guardEnabled := infra.GetEnvBool("PRICE_GUARD_ENABLED", true)
+	// One subscription so the cache has a price before streams revalue with it
+	go func() {
+		err := priceNotifier.Subscribe(ctx, func(q model.Quote) {
+			if cachedPrices != nil {
+				cachedPrices.Observe(q)
+			}
+			portfolioStreams.OnPrice(q)
+			if alertEvaluator != nil {
+				alertEvaluator.OnPrice(q)
+			}
+		})
+		if err != nil {
+			logrus.WithError(err).Warn("Price notifications unavailable, caches rely on expiry")
+		}
+	}()
+	go func() {
+		err := portfolioNotifier.Subscribe(ctx, func(change model.PortfolioChange) {
+			portfolioStreams.OnChange(change)
+			if alertEvaluator != nil {
+				alertEvaluator.OnChange(change)
+			}
+		})
+		if err != nil {
+			logrus.WithError(err).Warn("Portfolio notifications unavailable, streams and alerts update on prices only")
+		}
+	}()
+
+	// Scheduled price refresh; one replica at a time via the leader lock
+	symbolSources := []service.SymbolSource{service.SymbolSourceFunc(priceRepo.HeldSymbols)}
+	if infra.GetEnvBool("PRICE_UPDATE_ALL_INSTRUMENTS", true) {
+		symbolSources = append(symbolSources, instrumentService)
+	}
+	priceUpdater := newPriceUpdater(db, redisClient, priceRepo, prices, symbolSources)
+	priceUpdater.Calendar = calendar
+	priceUpdater.Cache, priceUpdater.Notifier = priceCache, priceNotifier
+	if guardEnabled {
+		priceUpdater.Guard = priceGuard
+	}
+	priceAdminHandler := &api.PriceAdminHandler{Updater: priceUpdater, Guard: priceGuard}
+	priceAdminHandler.RegisterRoutes(admin)
+	if infra.GetEnvBool("PRICE_UPDATER_ENABLED", true) {
+		go runWorker(ctx, "Price updater", priceUpdater)
+	}
+
+	// INR rates for foreign-currency holdings
+	if infra.GetEnvBool("FX_UPDATER_ENABLED", true) {
+		fxProvider, err := infra.NewFXProviderFromEnv()
+		if err != nil {
+			logrus.Fatalf("Invalid FX config: %v", err)
+		}
+		go runWorker(ctx, "FX updater", &service.FXUpdater{
+			Provider:   fxProvider,
+			Store:      fxRepo,
+			Currencies: infra.GetEnvList("FX_CURRENCIES"),
+			Interval:   infra.GetEnvDuration("FX_UPDATE_INTERVAL", 15*time.Minute),
+		})
+	}
+
+	// Holdings projection consumer
+	if kafkaEnabled && infra.GetEnvBool("PROJECTION_ENABLED", true) {
+		group := infra.GetEnv("PROJECTION_GROUP_ID", "stocky-portfolio-projection")
+		go runWorker(ctx, "Holdings projection", &infra.ConsumerGroupWorker{
+			Brokers:     brokers,
+			GroupID:     group,
+			Topics:      []string{events.TopicRewardEvents, events.TopicCorporateActions},
+			Registry:    projectionRegistry,
+			Retry:       retryRouter(group),
+			Concurrency: concurrency,
+		})
+	}
+
+	// Notifications consumer
+	if kafkaEnabled && notificationsEnabled {
+		group := infra.GetEnv("NOTIFY_GROUP_ID", "stocky-notifications")
+		go runWorker(ctx, "Notification consumer", &infra.ConsumerGroupWorker{
+			Brokers:     brokers,
+			GroupID:     group,
+			Topics:      []string{events.TopicRewardEvents, events.TopicCorporateActions},
+			Registry:    notificationRegistry,
+			Retry:       retryRouter(group),
+			Concurrency: concurrency,
+		})
+	}
+
+	// Kafka consumer group for reward events
+	if kafkaEnabled && infra.GetEnvBool("CONSUMER_ENABLED", false) {
+		registry := events.NewRegistry()
+		registry.Register(events.TypeRewardCreated, func(ctx context.Context, msg events.Message) error {
+			env, err := events.Decode(msg)
+			if err != nil {
+				return err
+			}
+			logrus.WithFields(logrus.Fields{"id": env.ID, "subject": env.Subject, "correlation_id": msg.Headers["correlation_id"]}).Info("Received reward created event")
+			return nil
+		})
+		group := infra.GetEnv("CONSUMER_GROUP_ID", "stocky-reward-consumers")
+		go runWorker(ctx, "Reward event consumer", &infra.ConsumerGroupWorker{
+			Brokers:     brokers,
+			GroupID:     group,
+			Topics:      []string{events.TopicRewardEvents},
+			Registry:    registry,
+			Retry:       retryRouter(group),
+			Concurrency: concurrency,
+		})
+	}
+
+	// Asynchronous reward ingestion from partners
+	if kafkaEnabled && infra.GetEnvBool("REWARD_COMMANDS_ENABLED", false) {
+		commandHandler := &service.RewardCommandHandler{
+			Rewards: rewardService,
+			Events:  publisher,
+			Encoder: encoder,
+			Limiter: newPartnerLimiter(redisClient),
+		}
+		registry := events.NewRegistry()
+		registry.Register(events.TypeRewardCreate, commandHandler.Handle)
+		group := infra.GetEnv("REWARD_COMMANDS_GROUP_ID", "stocky-reward-commands")
+		go runWorker(ctx, "Reward command consumer", &infra.ConsumerGroupWorker{
+			Brokers:     brokers,
+			GroupID:     group,
+			Topics:      []string{events.TopicRewardCommands},
+			Registry:    registry,
+			Retry:       retryRouter(group),
+			Concurrency: concurrency,
+		})
+	}
+
+	// Exchange ticks from the price-updates topic
+	if kafkaEnabled && infra.GetEnvBool("PRICE_TICKS_ENABLED", false) {
+		ingester := &service.PriceTickIngester{
+			Store:    priceRepo,
+			Cache:    priceCache,
+			Notifier: priceNotifier,
+			MaxSkew:  infra.GetEnvDuration("PRICE_TICK_MAX_SKEW", 5*time.Second),
+		}
+		if guardEnabled {
+			ingester.Guard = priceGuard
+		}
+		if infra.GetEnvBool("INSTRUMENT_VALIDATION", true) {
+			ingester.Instruments = instrumentService
+		}
+		registry := events.NewRegistry()
+		ingester.Register(registry)
+		group := infra.GetEnv("PRICE_TICKS_GROUP_ID", "stocky-price-ticks")
+		go runWorker(ctx, "Price tick consumer", &infra.ConsumerGroupWorker{
+			Brokers:     brokers,
+			GroupID:     group,
+			Topics:      []string{events.TopicPriceUpdates},
+			Registry:    registry,
+			Retry:       retryRouter(group),
+			Concurrency: concurrency,
+		})
+	}
+
+	srv := &http.Server{Addr: ":" + port, Handler: r}
+	// Shutdown waits for requests to finish, which streams never do
+	srv.RegisterOnShutdown(portfolioStreams.Shutdown)
+	go func() {
+		logrus.Infof("Starting server on port %s", port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.Fatalf("Server failed: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	logrus.Info("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logrus.WithError(err).Error("Graceful shutdown failed")
+	}
+}
+
+func runWorker(ctx context.Context, name string, worker interface{ Run(context.Context) error }) {
+	if err := worker.Run(ctx); err != nil {
+		logrus.WithError(err).Errorf("%s stopped", name)
+	}
+}
+
+// newPartnerLimiter caps each partner's command rate across every replica
+// through Redis, or per replica with PARTNER_COMMAND_LIMITER=local.
+func newPartnerLimiter(rdb *redis.Client) service.PartnerLimiter {
+	rate := float64(infra.GetEnvInt("PARTNER_COMMAND_RATE", 50))
+	burst := infra.GetEnvInt("PARTNER_COMMAND_BURST", 100)
+	if infra.GetEnv("PARTNER_COMMAND_LIMITER", "redis") == "local" {
+		return infra.NewKeyedLimiter(rate, burst)
+	}
+	return &infra.RedisKeyedLimiter{Client: rdb, Prefix: "ratelimit:partner:", Rate: rate, Burst: burst}
+}
+
+// newPriceUpdater refreshes symbols from the live price sources only; the
+// stock_prices fallback is what it writes, so it is left out.
+func newPriceUpdater(db *sql.DB, rdb *redis.Client, store *repo.PriceRepositoryImpl, prices *infra.ChainPriceProvider, symbols []service.SymbolSource) *service.PriceUpdater {
+	interval := infra.GetEnvDuration("PRICE_UPDATE_INTERVAL", time.Hour)
+	jitter := infra.GetEnvDuration("PRICE_UPDATE_JITTER", time.Minute)
+	hostname, _ := os.Hostname()
+	holder := hostname + "-" + uuid.NewString()[:8]
+	// Leadership is renewed every third of the lease, independent of runs
+	lease := infra.GetEnvDuration("PRICE_UPDATER_LEASE_TTL", 30*time.Second)
+	var lock service.LeaderLock = &infra.AdvisoryLeaderLock{DB: db, Key: 727274102}
+	if infra.GetEnv("PRICE_UPDATER_LOCK", "postgres") == "redis" {
+		lock = &infra.RedisLeaderLock{Client: rdb, Key: "lock:price-updater", Token: holder, TTL: lease}
+	}
+	return &service.PriceUpdater{
+		Sources:   symbols,
+		Prices:    &infra.ChainPriceProvider{Sources: prices.Sources},
+		Store:     store,
+		Lock:      lock,
+		Holder:    holder,
+		Interval:  interval,
+		Jitter:    jitter,
+		Heartbeat: lease / 3,
+	}
 }
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/mhatrejeets/stocky-ms/internal/infra"
+	"github.com/mhatrejeets/stocky-ms/internal/migrate"
+)
+
+const migrateUsage = "usage: stocky migrate up | down [N] | status | force <version>"
+
+// runMigrate implements the `migrate` subcommand and returns the exit code.
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	db, err := infra.NewDB()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to connect to DB: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+	m, err := migrate.New(db)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load migrations: %v\n", err)
+		return 1
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		err = m.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				fmt.Fprintln(os.Stderr, migrateUsage)
+				return 2
+			}
+		}
+		err = m.Down(ctx, steps)
+	case "status":
+		var statuses []migrate.Status
+		var version int64
+		var dirty bool
+		statuses, version, dirty, err = m.Status(ctx)
+		if err == nil {
+			fmt.Printf("current version: %d (dirty: %t)\n", version, dirty)
+			for _, s := range statuses {
+				state := "pending"
+				if s.Applied {
+					state = "applied"
+				}
+				fmt.Printf("%04d  %-8s %s\n", s.Version, state, s.Name)
+			}
+		}
+	case "force":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+		version, perr := strconv.ParseInt(args[1], 10, 64)
+		if perr != nil {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+		err = m.Force(ctx, version)
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate %s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,179 @@
+// Command quoteserver is a local stub of the exchange quote API. It replays
+// prices from a CSV file (timestamp,symbol,price) on a shifted clock, or with
+// -sim serves the deterministic random-walk simulator, so the whole price
+// pipeline can be exercised offline.
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/infra"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+type tick struct {
+	at     time.Time
+	symbol string
+	price  decimal.Decimal
+}
+
+type quote struct {
+	Symbol string          `json:"symbol"`
+	Price  decimal.Decimal `json:"price"`
+	AsOf   time.Time       `json:"as_of"`
+}
+
+// replayer maps recorded tick times onto wall-clock time:
+// a tick recorded at t becomes current at start + (t - first) / speed.
+type replayer struct {
+	ticks []tick
+	start time.Time
+	speed float64
+	loop  bool
+}
+
+func loadTicks(r io.Reader) ([]tick, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var ticks []tick
+	for i, row := range rows {
+		if len(row) != 3 {
+			return nil, fmt.Errorf("line %d: want timestamp,symbol,price", i+1)
+		}
+		if i == 0 && row[0] == "timestamp" {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, strings.TrimSpace(row[0]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		price, err := decimal.NewFromString(strings.TrimSpace(row[2]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		ticks = append(ticks, tick{at: at, symbol: strings.ToUpper(strings.TrimSpace(row[1])), price: price})
+	}
+	if len(ticks) == 0 {
+		return nil, fmt.Errorf("no ticks")
+	}
+	sort.SliceStable(ticks, func(i, j int) bool { return ticks[i].at.Before(ticks[j].at) })
+	return ticks, nil
+}
+
+// current returns the latest replayed quote per symbol as of now.
+func (r *replayer) current(now time.Time) map[string]quote {
+	first := r.ticks[0].at
+	// Recorded time covered by one pass, plus a beat before looping.
+	cycle := r.ticks[len(r.ticks)-1].at.Sub(first) + time.Second
+	elapsed := time.Duration(float64(now.Sub(r.start)) * r.speed)
+	var passStart time.Duration
+	if r.loop {
+		passStart = elapsed / cycle * cycle
+		elapsed -= passStart
+	}
+	quotes := make(map[string]quote)
+	for _, t := range r.ticks {
+		offset := t.at.Sub(first)
+		if offset > elapsed {
+			break
+		}
+		asOf := r.start.Add(time.Duration(float64(passStart+offset) / r.speed))
+		quotes[t.symbol] = quote{Symbol: t.symbol, Price: t.price, AsOf: asOf.UTC()}
+	}
+	return quotes
+}
+
+// quoteSource returns the current quotes for the requested symbols.
+type quoteSource func(symbols []string) map[string]quote
+
+func (r *replayer) quotes(symbols []string) map[string]quote {
+	return r.current(time.Now())
+}
+
+func simulatorQuotes(sim *infra.PriceSimulator) quoteSource {
+	return func(symbols []string) map[string]quote {
+		quotes, _ := sim.GetPrices(context.Background(), symbols)
+		out := make(map[string]quote, len(quotes))
+		for symbol, q := range quotes {
+			out[symbol] = quote{Symbol: symbol, Price: q.Price, AsOf: q.AsOf.UTC()}
+		}
+		return out
+	}
+}
+
+func handleQuotes(source quoteSource) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var symbols []string
+		for _, s := range strings.Split(req.URL.Query().Get("symbols"), ",") {
+			if s = strings.ToUpper(strings.TrimSpace(s)); s != "" {
+				symbols = append(symbols, s)
+			}
+		}
+		current := source(symbols)
+		resp := struct {
+			Quotes []quote `json:"quotes"`
+		}{Quotes: []quote{}}
+		for _, s := range symbols {
+			if q, ok := current[s]; ok {
+				resp.Quotes = append(resp.Quotes, q)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func main() {
+	addr := flag.String("addr", ":8090", "listen address")
+	csvPath := flag.String("csv", "scripts/sample_quotes.csv", "CSV of timestamp,symbol,price rows")
+	speed := flag.Float64("speed", 1, "replay speed multiplier")
+	loop := flag.Bool("loop", true, "restart the replay when the CSV is exhausted")
+	sim := flag.Bool("sim", false, "serve simulated prices (configured by SIM_* env) instead of the CSV")
+	flag.Parse()
+
+	var source quoteSource
+	if *sim {
+		simulator, err := infra.NewPriceSimulatorFromEnv()
+		if err != nil {
+			logrus.Fatalf("Invalid simulator config: %v", err)
+		}
+		source = simulatorQuotes(simulator)
+		logrus.Infof("Serving simulated prices (seed %d) on %s", simulator.Seed, *addr)
+	} else {
+		f, err := os.Open(*csvPath)
+		if err != nil {
+			logrus.Fatalf("Failed to open CSV: %v", err)
+		}
+		ticks, err := loadTicks(f)
+		f.Close()
+		if err != nil {
+			logrus.Fatalf("Failed to load ticks: %v", err)
+		}
+		if *speed <= 0 {
+			*speed = 1
+		}
+		r := &replayer{ticks: ticks, start: time.Now(), speed: *speed, loop: *loop}
+		source = r.quotes
+		logrus.Infof("Replaying %d ticks on %s", len(ticks), *addr)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/quotes", handleQuotes(source))
+	mux.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte(`{"status":"ok"}`)) })
+	if err := http.ListenAndServe(*addr, mux); err != nil {
+		logrus.Fatal(err)
+	}
+}
@@ -10,6 +10,7 @@ require (
 	github.com/go-playground/validator/v10 v10.11.2
 	github.com/golang-jwt/jwt/v5 v5.3.0
 	github.com/google/uuid v1.6.0
+	github.com/gorilla/websocket v1.5.3
 	github.com/prometheus/client_golang v1.23.2
 	github.com/redis/go-redis/v9 v9.14.0
 	github.com/shopspring/decimal v1.4.0
@@ -18,7 +19,10 @@ require (
 	github.com/testcontainers/testcontainers-go v0.39.0
 )
 
-require github.com/rcrowley/go-metrics v0.0.0-20250401214520-65e299d6c5c9 // indirect
+require (
+	github.com/kylelemons/godebug v1.1.0 // indirect
+	github.com/rcrowley/go-metrics v0.0.0-20250401214520-65e299d6c5c9 // indirect
+)
 
 require (
 	dario.cat/mergo v1.0.2 // indirect
@@ -110,6 +114,6 @@ require (
 	golang.org/x/net v0.44.0 // indirect
 	golang.org/x/sys v0.36.0 // indirect
 	golang.org/x/text v0.29.0 // indirect
-	google.golang.org/protobuf v1.36.8 // indirect
+	google.golang.org/protobuf v1.36.8
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,16 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requireUser answers 403 unless the token's subject is userID or an admin.
+func requireUser(c *gin.Context, userID string) bool {
+	if c.GetString("user_id") == userID || c.GetString("role") == "admin" {
+		return true
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+	return false
+}
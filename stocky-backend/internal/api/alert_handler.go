@@ -0,0 +1,129 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/mhatrejeets/stocky-ms/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AlertHandler serves a user's alert rules and fired alerts. Users see only
+// their own; admins see anyone's.
+type AlertHandler struct {
+	Service *service.AlertService
+}
+
+func (h *AlertHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/alerts/:userId/rules", h.ListRules)
+	rg.POST("/alerts/:userId/rules", h.CreateRule)
+	rg.GET("/alerts/:userId/rules/:id", h.GetRule)
+	rg.PUT("/alerts/:userId/rules/:id", h.UpdateRule)
+	rg.DELETE("/alerts/:userId/rules/:id", h.DeleteRule)
+	rg.GET("/alerts/:userId/events", h.ListEvents)
+}
+
+func (h *AlertHandler) ListRules(c *gin.Context) {
+	userID := c.Param("userId")
+	if !requireUser(c, userID) {
+		return
+	}
+	rules, err := h.Service.List(c.Request.Context(), userID)
+	if err != nil {
+		alertError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rules": rules})
+}
+
+func (h *AlertHandler) CreateRule(c *gin.Context) {
+	userID := c.Param("userId")
+	if !requireUser(c, userID) {
+		return
+	}
+	var req model.AlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed"})
+		return
+	}
+	rule, err := h.Service.Create(c.Request.Context(), userID, req)
+	if err != nil {
+		alertError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"rule": rule})
+}
+
+func (h *AlertHandler) GetRule(c *gin.Context) {
+	userID := c.Param("userId")
+	if !requireUser(c, userID) {
+		return
+	}
+	rule, err := h.Service.Get(c.Request.Context(), userID, c.Param("id"))
+	if err != nil {
+		alertError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rule": rule})
+}
+
+// UpdateRule changes only the fields present in the body.
+func (h *AlertHandler) UpdateRule(c *gin.Context) {
+	userID := c.Param("userId")
+	if !requireUser(c, userID) {
+		return
+	}
+	var req model.AlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed"})
+		return
+	}
+	rule, err := h.Service.Update(c.Request.Context(), userID, c.Param("id"), req)
+	if err != nil {
+		alertError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rule": rule})
+}
+
+func (h *AlertHandler) DeleteRule(c *gin.Context) {
+	userID := c.Param("userId")
+	if !requireUser(c, userID) {
+		return
+	}
+	if err := h.Service.Delete(c.Request.Context(), userID, c.Param("id")); err != nil {
+		alertError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *AlertHandler) ListEvents(c *gin.Context) {
+	userID := c.Param("userId")
+	if !requireUser(c, userID) {
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	alerts, err := h.Service.Events(c.Request.Context(), userID, limit)
+	if err != nil {
+		alertError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"alerts": alerts})
+}
+
+func alertError(c *gin.Context, err error) {
+	var invalid *service.ValidationError
+	switch {
+	case errors.Is(err, repo.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "alert rule not found"})
+	case errors.As(err, &invalid):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/mhatrejeets/stocky-ms/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DeadLetterHandler struct {
+	Service *service.DeadLetterService
+}
+
+// RegisterRoutes expects an admin-only group.
+func (h *DeadLetterHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/dlq", h.List)
+	rg.GET("/dlq/:id", h.Get)
+	rg.POST("/dlq/:id/replay", h.Replay)
+	rg.DELETE("/dlq/:id", h.Discard)
+}
+
+func (h *DeadLetterHandler) List(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	letters, err := h.Service.List(c.Request.Context(), c.DefaultQuery("status", "pending"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"dead_letters": letters})
+}
+
+func (h *DeadLetterHandler) Get(c *gin.Context) {
+	dl, err := h.Service.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		deadLetterError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"dead_letter": dl})
+}
+
+func (h *DeadLetterHandler) Replay(c *gin.Context) {
+	if err := h.Service.Replay(c.Request.Context(), c.Param("id")); err != nil {
+		deadLetterError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "replayed"})
+}
+
+func (h *DeadLetterHandler) Discard(c *gin.Context) {
+	if err := h.Service.Discard(c.Request.Context(), c.Param("id")); err != nil {
+		deadLetterError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "discarded"})
+}
+
+func deadLetterError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repo.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "dead letter not found"})
+	case errors.Is(err, service.ErrDeadLetterResolved):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/mhatrejeets/stocky-ms/internal/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct {
+	Registry *health.Registry
+}
+
+func (h *HealthHandler) RegisterRoutes(r *gin.Engine) {
+	r.GET("/health", h.Livez)
+	r.GET("/livez", h.Livez)
+	r.GET("/readyz", h.Readyz)
+}
+
+// Livez only reports that the process is serving requests; dependency
+// outages must not cause the orchestrator to restart the pod.
+func (h *HealthHandler) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusOK})
+}
+
+// Readyz runs the dependency checks and returns 503 when a critical one fails.
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	report := h.Registry.Run(c.Request.Context())
+	code := http.StatusOK
+	if report.Status == health.StatusFail {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, report)
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type InstrumentHandler struct {
+	Service *service.InstrumentService
+}
+
+func (h *InstrumentHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/instruments", h.Search)
+	rg.GET("/instruments/:code", h.Get)
+}
+
+// RegisterAdminRoutes expects an admin-only group.
+func (h *InstrumentHandler) RegisterAdminRoutes(rg *gin.RouterGroup) {
+	rg.POST("/instruments/import", h.Import)
+}
+
+func (h *InstrumentHandler) Search(c *gin.Context) {
+	q := c.Query("q")
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing q"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	instruments, err := h.Service.Search(c.Request.Context(), q, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"instruments": instruments})
+}
+
+// Get looks up a symbol or ISIN. Inactive listings are still returned.
+func (h *InstrumentHandler) Get(c *gin.Context) {
+	instrument, err := h.Service.Resolve(c.Request.Context(), c.Param("code"))
+	switch {
+	case errors.Is(err, service.ErrUnknownInstrument):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil && !errors.Is(err, service.ErrInactiveInstrument):
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, gin.H{"instrument": instrument})
+	}
+}
+
+// Import accepts a listing CSV as the request body; ?exchange= sets the
+// exchange for files without an EXCHANGE column.
+func (h *InstrumentHandler) Import(c *gin.Context) {
+	n, err := h.Service.Import(c.Request.Context(), c.Request.Body, c.DefaultQuery("exchange", model.ExchangeNSE))
+	var invalid *service.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, gin.H{"imported": n})
+	}
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/mhatrejeets/stocky-ms/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NotificationHandler serves a user's notification preferences and the
+// delivery status of their notifications. Users see only their own; admins
+// see anyone's.
+type NotificationHandler struct {
+	Service *service.NotificationService
+}
+
+func (h *NotificationHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/notifications/:userId/preferences", h.ListPreferences)
+	rg.PUT("/notifications/:userId/preferences/:channel", h.SetPreference)
+	rg.DELETE("/notifications/:userId/preferences/:channel", h.DeletePreference)
+	rg.GET("/notifications/:userId/history", h.History)
+}
+
+func (h *NotificationHandler) ListPreferences(c *gin.Context) {
+	userID := c.Param("userId")
+	if !requireUser(c, userID) {
+		return
+	}
+	prefs, err := h.Service.Preferences(c.Request.Context(), userID)
+	if err != nil {
+		notificationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"preferences": prefs})
+}
+
+// SetPreference creates or updates one channel, changing only the fields
+// present in the body.
+func (h *NotificationHandler) SetPreference(c *gin.Context) {
+	userID := c.Param("userId")
+	if !requireUser(c, userID) {
+		return
+	}
+	var req model.NotificationPreferenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed"})
+		return
+	}
+	pref, err := h.Service.SetPreference(c.Request.Context(), userID, c.Param("channel"), req)
+	if err != nil {
+		notificationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"preference": pref})
+}
+
+func (h *NotificationHandler) DeletePreference(c *gin.Context) {
+	userID := c.Param("userId")
+	if !requireUser(c, userID) {
+		return
+	}
+	if err := h.Service.DeletePreference(c.Request.Context(), userID, c.Param("channel")); err != nil {
+		notificationError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *NotificationHandler) History(c *gin.Context) {
+	userID := c.Param("userId")
+	if !requireUser(c, userID) {
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	notifications, err := h.Service.History(c.Request.Context(), userID, limit)
+	if err != nil {
+		notificationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"notifications": notifications})
+}
+
+func notificationError(c *gin.Context, err error) {
+	var invalid *service.ValidationError
+	switch {
+	case errors.Is(err, repo.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "notification preference not found"})
+	case errors.As(err, &invalid):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/mhatrejeets/stocky-ms/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PortfolioAdminHandler exposes operator actions that change holdings:
+// reward reversals, corporate actions, dividends and rebuilding the holdings projection.
+type PortfolioAdminHandler struct {
+	Rewards          *service.RewardService
+	CorporateActions *service.CorporateActionService
+	Projector        *service.PortfolioProjector
+}
+
+// RegisterRoutes expects an admin-only group.
+func (h *PortfolioAdminHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.POST("/rewards/:id/reverse", h.ReverseReward)
+	rg.POST("/corporate-actions", h.AnnounceCorporateAction)
+	rg.POST("/dividends", h.DeclareDividend)
+	rg.POST("/projections/holdings/rebuild", h.RebuildHoldings)
+}
+
+func (h *PortfolioAdminHandler) ReverseReward(c *gin.Context) {
+	var req model.ReverseRewardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed"})
+		return
+	}
+	reward, err := h.Rewards.ReverseReward(c.Request.Context(), c.Param("id"), req)
+	var invalid *service.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, repo.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "reward not found"})
+	case errors.Is(err, repo.ErrAlreadyReversed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, gin.H{"status": "reversed", "reward": reward})
+	}
+}
+
+func (h *PortfolioAdminHandler) AnnounceCorporateAction(c *gin.Context) {
+	var req model.CorporateActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed"})
+		return
+	}
+	action, err := h.CorporateActions.Announce(c.Request.Context(), req)
+	var invalid *service.ValidationError
+	if errors.As(err, &invalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"corporate_action": action})
+}
+
+func (h *PortfolioAdminHandler) DeclareDividend(c *gin.Context) {
+	var req model.DividendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed"})
+		return
+	}
+	dividend, err := h.CorporateActions.DeclareDividend(c.Request.Context(), req)
+	var invalid *service.ValidationError
+	if errors.As(err, &invalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"dividend": dividend})
+}
+
+func (h *PortfolioAdminHandler) RebuildHoldings(c *gin.Context) {
+	if err := h.Projector.Rebuild(c.Request.Context()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "rebuilt"})
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+// PortfolioStreamHandler pushes a user's portfolio as it changes, over
+// Server-Sent Events or, when the request asks to upgrade, a WebSocket.
+// A client that cannot take a message within WriteTimeout is disconnected.
+type PortfolioStreamHandler struct {
+	Streams      *service.PortfolioStreams
+	WriteTimeout time.Duration
+	Upgrader     websocket.Upgrader
+}
+
+// portfolioMessage is the WebSocket frame for a snapshot.
+type portfolioMessage struct {
+	Type      string          `json:"type"`
+	Portfolio model.Portfolio `json:"portfolio"`
+}
+
+func (h *PortfolioStreamHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/portfolio/:userId/stream", h.Stream)
+}
+
+func (h *PortfolioStreamHandler) writeTimeout() time.Duration {
+	if h.WriteTimeout > 0 {
+		return h.WriteTimeout
+	}
+	return 10 * time.Second
+}
+
+// Stream serves the caller's own portfolio; admins may stream anyone's.
+func (h *PortfolioStreamHandler) Stream(c *gin.Context) {
+	userID := c.Param("userId")
+	if !requireUser(c, userID) {
+		return
+	}
+	stream, err := h.Streams.Open(userID)
+	if err != nil {
+		streamError(c, err)
+		return
+	}
+	defer stream.Close()
+	if websocket.IsWebSocketUpgrade(c.Request) {
+		h.serveWebSocket(c, stream)
+		return
+	}
+	h.serveSSE(c, stream)
+}
+
+func (h *PortfolioStreamHandler) serveSSE(c *gin.Context, stream *service.PortfolioStream) {
+	rc := http.NewResponseController(c.Writer)
+	defer rc.SetWriteDeadline(time.Time{})
+	started := false
+	seq := 0
+	write := func(frame string) error {
+		// Not every writer supports deadlines (e.g. in tests)
+		_ = rc.SetWriteDeadline(time.Now().Add(h.writeTimeout()))
+		if _, err := io.WriteString(c.Writer, frame); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+	send := func(p model.Portfolio) error {
+		data, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		if !started {
+			c.Header("Content-Type", "text/event-stream")
+			c.Header("Cache-Control", "no-cache")
+			c.Header("Connection", "keep-alive")
+			c.Header("X-Accel-Buffering", "no")
+			c.Status(http.StatusOK)
+			started = true
+		}
+		seq++
+		return write(fmt.Sprintf("id: %d\nevent: portfolio\ndata: %s\n\n", seq, data))
+	}
+	ping := func() error { return write(": ping\n\n") }
+	err := stream.Serve(c.Request.Context(), send, ping)
+	if err != nil && !started {
+		streamError(c, err)
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", c.Param("userId")).Debug("Portfolio stream closed")
+	}
+}
+
+func (h *PortfolioStreamHandler) serveWebSocket(c *gin.Context, stream *service.PortfolioStream) {
+	conn, err := h.Upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade has already replied
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	timeout := h.writeTimeout()
+	idle := 2*h.Streams.HeartbeatInterval() + timeout
+	conn.SetReadLimit(512)
+	_ = conn.SetReadDeadline(time.Now().Add(idle))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(idle))
+	})
+	// Clients only send control frames; reading handles pongs and close
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(p model.Portfolio) error {
+		_ = conn.SetWriteDeadline(time.Now().Add(timeout))
+		return conn.WriteJSON(portfolioMessage{Type: "portfolio", Portfolio: p})
+	}
+	ping := func() error {
+		return conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(timeout))
+	}
+	code, reason := websocket.CloseNormalClosure, ""
+	switch err := stream.Serve(ctx, send, ping); {
+	case errors.Is(err, service.ErrStreamsClosed):
+		code, reason = websocket.CloseGoingAway, "server shutting down"
+	case err != nil:
+		code, reason = websocket.CloseInternalServerErr, "stream failed"
+		logrus.WithError(err).WithField("user_id", c.Param("userId")).Debug("Portfolio stream closed")
+	}
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(timeout))
+}
+
+func streamError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrTooManyStreams):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrStreamsClosed):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
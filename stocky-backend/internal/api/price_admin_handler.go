@@ -0,0 +1,104 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/mhatrejeets/stocky-ms/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PriceAdminHandler struct {
+	Updater *service.PriceUpdater
+	Guard   *service.PriceGuard
+}
+
+// RegisterRoutes expects an admin-only group.
+func (h *PriceAdminHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/prices/runs", h.ListRuns)
+	rg.POST("/prices/update", h.Update)
+	rg.GET("/prices/quarantine", h.ListQuarantined)
+	rg.GET("/prices/quarantine/:id", h.GetQuarantined)
+	rg.POST("/prices/quarantine/:id/approve", h.Approve)
+	rg.POST("/prices/quarantine/:id/reject", h.Reject)
+}
+
+func (h *PriceAdminHandler) ListRuns(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	runs, err := h.Updater.Runs(c.Request.Context(), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"runs": runs})
+}
+
+// Update triggers a run now. It is a no-op with 409 when another replica
+// holds the updater lock.
+func (h *PriceAdminHandler) Update(c *gin.Context) {
+	run, err := h.Updater.Tick(c.Request.Context())
+	if run == nil && err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "another replica is the price updater leader"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "run": run})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"run": run})
+}
+
+func (h *PriceAdminHandler) ListQuarantined(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	prices, err := h.Guard.List(c.Request.Context(), c.DefaultQuery("status", "pending"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"quarantined": prices})
+}
+
+func (h *PriceAdminHandler) GetQuarantined(c *gin.Context) {
+	qp, err := h.Guard.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		quarantineError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"quarantined": qp})
+}
+
+// Approve applies the held price; "applied" is false when a newer price
+// had already been stored.
+func (h *PriceAdminHandler) Approve(c *gin.Context) {
+	applied, err := h.Guard.Approve(c.Request.Context(), c.Param("id"), c.GetString("user_id"))
+	if err != nil {
+		quarantineError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "approved", "applied": applied})
+}
+
+func (h *PriceAdminHandler) Reject(c *gin.Context) {
+	if err := h.Guard.Reject(c.Request.Context(), c.Param("id"), c.GetString("user_id")); err != nil {
+		quarantineError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "rejected"})
+}
+
+func quarantineError(c *gin.Context, err error) {
+	var invalid *service.ValidationError
+	switch {
+	case errors.Is(err, repo.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "quarantined price not found"})
+	case errors.Is(err, service.ErrQuarantineResolved):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.As(err, &invalid):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
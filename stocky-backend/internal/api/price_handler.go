@@ -0,0 +1,58 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PriceHandler struct {
+	Candles *service.CandleService
+}
+
+func (h *PriceHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/prices/:symbol/candles", h.ListCandles)
+}
+
+// ListCandles serves ?interval=1m|1h|1d (default 1d) candles starting in
+// [from, to), given as RFC 3339 times or YYYY-MM-DD dates in UTC.
+func (h *PriceHandler) ListCandles(c *gin.Context) {
+	q := service.CandleQuery{Symbol: c.Param("symbol"), Interval: c.Query("interval")}
+	var err error
+	if q.From, err = parseTimeParam(c.Query("from")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from"})
+		return
+	}
+	if q.To, err = parseTimeParam(c.Query("to")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to"})
+		return
+	}
+	q.Limit, _ = strconv.Atoi(c.Query("limit"))
+	candles, err := h.Candles.Candles(c.Request.Context(), q)
+	var invalid *service.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrUnknownInstrument):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, gin.H{"candles": candles})
+	}
+}
+
+func parseTimeParam(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
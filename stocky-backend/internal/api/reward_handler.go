@@ -20,6 +20,7 @@ func (h *RewardHandler) RegisterRoutes(rg *gin.RouterGroup) {
 	rg.GET("/historical-inr/:userId", h.GetHistoricalINR)
 	rg.GET("/stats/:userId", h.GetStats)
 	rg.GET("/portfolio/:userId", h.GetPortfolio)
+	rg.GET("/portfolio/:userId/lots", h.GetLots)
 }
 
 func (h *RewardHandler) CreateReward(c *gin.Context) {
@@ -96,3 +97,16 @@ func (h *RewardHandler) GetPortfolio(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"portfolio": portfolio})
 }
+
+func (h *RewardHandler) GetLots(c *gin.Context) {
+	userID := c.Param("userId")
+	if !requireUser(c, userID) {
+		return
+	}
+	lots, err := h.Service.ListLots(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"lots": lots})
+}
@@ -0,0 +1,159 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	SpecVersion = "1.0"
+
+	// ContentTypeCloudEvents marks a structured-mode CloudEvents message.
+	ContentTypeCloudEvents = "application/cloudevents+json"
+	ContentTypeJSON        = "application/json"
+	ContentTypeProtobuf    = "application/protobuf"
+
+	EncodingJSON     = "json"
+	EncodingProtobuf = "protobuf"
+)
+
+// Envelope is a CloudEvents 1.0 event in the structured JSON format. Binary
+// payloads (protobuf) travel base64 encoded in data_base64.
+type Envelope struct {
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	SpecVersion     string          `json:"specversion"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	DataSchema      string          `json:"dataschema,omitempty"`
+	Subject         string          `json:"subject,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+	DataBase64      []byte          `json:"data_base64,omitempty"`
+}
+
+// Encoder wraps payloads in CloudEvents envelopes after validating them
+// against the schema registry.
+type Encoder struct {
+	Source   string
+	Encoding string
+	Schemas  *SchemaRegistry
+}
+
+// ErrNoEncoder is returned when a component publishing events was built
+// without an Encoder.
+var ErrNoEncoder = errors.New("no event encoder configured")
+
+// NewDefaultEncoder returns a JSON encoder validating against the schemas
+// embedded in the binary.
+func NewDefaultEncoder() (*Encoder, error) {
+	schemas, err := LoadSchemaRegistry("")
+	if err != nil {
+		return nil, err
+	}
+	return &Encoder{Source: "/stocky-backend", Encoding: EncodingJSON, Schemas: schemas}, nil
+}
+
+// Encode validates payload against version of eventType and returns the
+// enveloped message ready to publish.
+func (e *Encoder) Encode(topic, key, eventType string, version int, subject string, payload interface{}) (Message, error) {
+	if e == nil {
+		return Message{}, ErrNoEncoder
+	}
+	schema, ok := e.Schemas.Get(eventType, version)
+	if !ok {
+		return Message{}, fmt.Errorf("no schema registered for %s v%d", eventType, version)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Message{}, err
+	}
+	if err := schema.Validate(data); err != nil {
+		return Message{}, err
+	}
+	env := Envelope{
+		ID:              uuid.NewString(),
+		Source:          e.Source,
+		Type:            eventType,
+		SpecVersion:     SpecVersion,
+		Time:            time.Now().UTC(),
+		DataContentType: ContentTypeJSON,
+		DataSchema:      schema.URI(),
+		Subject:         subject,
+		Data:            data,
+	}
+	if e.Encoding == EncodingProtobuf {
+		if env.DataBase64, err = jsonToProtobuf(data); err != nil {
+			return Message{}, err
+		}
+		env.DataContentType = ContentTypeProtobuf
+		env.Data = nil
+	}
+	value, err := json.Marshal(env)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{
+		Topic:   topic,
+		Key:     key,
+		Type:    eventType,
+		Headers: map[string]string{"content-type": ContentTypeCloudEvents},
+		Value:   value,
+	}, nil
+}
+
+// Decode parses the envelope carried by msg.
+func Decode(msg Message) (Envelope, error) {
+	var env Envelope
+	if err := json.Unmarshal(msg.Value, &env); err != nil {
+		return Envelope{}, err
+	}
+	if env.SpecVersion != SpecVersion {
+		return Envelope{}, fmt.Errorf("unsupported specversion %q", env.SpecVersion)
+	}
+	return env, nil
+}
+
+// DecodeData unmarshals the payload into v regardless of its encoding.
+func (env Envelope) DecodeData(v interface{}) error {
+	switch env.DataContentType {
+	case ContentTypeJSON, "":
+		return json.Unmarshal(env.Data, v)
+	case ContentTypeProtobuf:
+		data, err := protobufToJSON(env.DataBase64)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(data, v)
+	default:
+		return errors.New("unsupported datacontenttype " + env.DataContentType)
+	}
+}
+
+// Protobuf payloads are encoded as google.protobuf.Struct so that schemas
+// stay defined in the registry rather than in generated code.
+func jsonToProtobuf(data []byte) ([]byte, error) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+	st, err := structpb.NewStruct(obj)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(st)
+}
+
+func protobufToJSON(data []byte) ([]byte, error) {
+	var st structpb.Struct
+	if err := proto.Unmarshal(data, &st); err != nil {
+		return nil, err
+	}
+	return json.Marshal(st.AsMap())
+}
@@ -0,0 +1,94 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+)
+
+const (
+	TopicRewardEvents         = "reward-events"
+	TopicRewardCommands       = "reward-commands"
+	TopicRewardCommandResults = "reward-command-results"
+	TopicCorporateActions     = "corporate-actions"
+	TopicPriceUpdates         = "price-updates"
+
+	// CloudEvents types; payload versions are tracked by the schema registry.
+	TypeRewardCreated         = "com.stocky.reward.created"
+	TypeRewardReversed        = "com.stocky.reward.reversed"
+	TypeCorporateAction       = "com.stocky.corporate-action"
+	TypeDividendDeclared      = "com.stocky.dividend.declared"
+	TypeRewardCreate          = "com.stocky.reward.create"
+	TypeRewardCommandAccepted = "com.stocky.reward.command.accepted"
+	TypeRewardCommandRejected = "com.stocky.reward.command.rejected"
+	TypePriceTick             = "com.stocky.price.tick"
+)
+
+// Message is a transport-neutral event. Backends map it onto their own wire
+// format (a Kafka record, a JSONL line, an in-memory value). Key is the
+// partition key; messages sharing a key are delivered in order.
+type Message struct {
+	Topic   string            `json:"topic"`
+	Key     string            `json:"key,omitempty"`
+	Type    string            `json:"type"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Value   json.RawMessage   `json:"value"`
+}
+
+// EventPublisher is the only dependency the domain has on event delivery;
+// the concrete backend is chosen by configuration at startup.
+type EventPublisher interface {
+	Publish(ctx context.Context, msg Message) error
+	Close() error
+}
+
+// RewardCreatedMessage builds the reward-events message for a new reward.
+func (e *Encoder) RewardCreatedMessage(event model.RewardCreatedEvent) (Message, error) {
+	msg, err := e.Encode(TopicRewardEvents, event.UserID, TypeRewardCreated, 1, event.RewardID, event)
+	if err != nil {
+		return Message{}, err
+	}
+	msg.Headers["correlation_id"] = event.CorrelationID
+	return msg, nil
+}
+
+// RewardReversedMessage builds the reward-events message for a reversal.
+func (e *Encoder) RewardReversedMessage(event model.RewardReversedEvent) (Message, error) {
+	return e.Encode(TopicRewardEvents, event.UserID, TypeRewardReversed, 1, event.RewardID, event)
+}
+
+// CorporateActionMessage builds a corporate-actions message, keyed by
+// symbol.
+func (e *Encoder) CorporateActionMessage(event model.CorporateActionEvent) (Message, error) {
+	return e.Encode(TopicCorporateActions, event.Symbol, TypeCorporateAction, 1, event.ActionID, event)
+}
+
+// DividendDeclaredMessage builds a corporate-actions message for a cash
+// dividend, keyed by symbol.
+func (e *Encoder) DividendDeclaredMessage(event model.DividendEvent) (Message, error) {
+	return e.Encode(TopicCorporateActions, event.Symbol, TypeDividendDeclared, 1, event.DividendID, event)
+}
+
+// RewardCommandMessage builds a reward-commands message, keyed by user so
+// commands for one user are applied in order.
+func (e *Encoder) RewardCommandMessage(cmd model.RewardCommand) (Message, error) {
+	return e.Encode(TopicRewardCommands, cmd.UserID, TypeRewardCreate, 1, cmd.CommandID, cmd)
+}
+
+// RewardCommandResultMessage builds the accepted/rejected outcome of an
+// asynchronous reward command, keyed by partner so each partner reads its
+// results in order.
+func (e *Encoder) RewardCommandResultMessage(result model.RewardCommandResult) (Message, error) {
+	eventType := TypeRewardCommandAccepted
+	if result.Status == model.CommandRejected {
+		eventType = TypeRewardCommandRejected
+	}
+	return e.Encode(TopicRewardCommandResults, result.PartnerID, eventType, 1, result.CommandID, result)
+}
+
+// PriceTickMessage builds a price-updates message, keyed by symbol so a
+// symbol's ticks stay in order on one partition.
+func (e *Encoder) PriceTickMessage(tick model.PriceTick) (Message, error) {
+	return e.Encode(TopicPriceUpdates, tick.Symbol, TypePriceTick, 1, tick.Symbol, tick)
+}
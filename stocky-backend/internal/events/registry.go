@@ -0,0 +1,62 @@
+package events
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Handler processes one consumed message. Returning an error marks the
+// message as failed.
+type Handler func(ctx context.Context, msg Message) error
+
+// Registry routes consumed messages to handlers by event type.
+type Registry struct {
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+func NewRegistry() *Registry {
+	return &Registry{handlers: make(map[string]Handler)}
+}
+
+// Register sets the handler for eventType, replacing any previous one.
+func (r *Registry) Register(eventType string, h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[eventType] = h
+}
+
+// Dispatch runs the handler registered for msg.Type. Messages without a
+// handler are logged and skipped so unknown types don't block a partition.
+func (r *Registry) Dispatch(ctx context.Context, msg Message) error {
+	r.mu.RLock()
+	h, ok := r.handlers[msg.Type]
+	r.mu.RUnlock()
+	if !ok {
+		logrus.WithFields(logrus.Fields{"topic": msg.Topic, "type": msg.Type}).Debug("No handler for event type, skipping")
+		return nil
+	}
+	return h(ctx, msg)
+}
+
+// DispatchingPublisher publishes and then hands the message straight to a
+// local registry. It lets in-process consumers (such as projections) keep up
+// when no broker is delivering events back to us. A failing handler fails
+// Publish, so a writer's outbox publishes the message again later.
+type DispatchingPublisher struct {
+	Publisher EventPublisher
+	Registry  *Registry
+}
+
+func (p *DispatchingPublisher) Publish(ctx context.Context, msg Message) error {
+	if err := p.Publisher.Publish(ctx, msg); err != nil {
+		return err
+	}
+	return p.Registry.Dispatch(ctx, msg)
+}
+
+func (p *DispatchingPublisher) Close() error {
+	return p.Publisher.Close()
+}
@@ -0,0 +1,219 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Headers added when a failed message is forwarded to a retry tier or the DLQ.
+// The original headers are kept alongside them.
+const (
+	HeaderOriginalTopic = "x-original-topic"
+	HeaderAttempt       = "x-attempt"
+	HeaderError         = "x-error"
+	HeaderFailedAt      = "x-failed-at"
+	HeaderRetryAt       = "x-retry-at"
+	HeaderGroup         = "x-consumer-group"
+)
+
+// RetryTier is one delayed retry topic, named <topic>.<group><Suffix>.
+type RetryTier struct {
+	Suffix string
+	Delay  time.Duration
+}
+
+// DefaultRetryTiers retries after one minute, then after ten.
+var DefaultRetryTiers = []RetryTier{
+	{Suffix: ".retry.1m", Delay: time.Minute},
+	{Suffix: ".retry.10m", Delay: 10 * time.Minute},
+}
+
+const DLQSuffix = ".dlq"
+
+// DeadLetterSink records messages that exhausted their retries so they can be
+// inspected, replayed or discarded later.
+type DeadLetterSink interface {
+	RecordDeadLetter(ctx context.Context, msg Message) error
+}
+
+// RetryRouter forwards failed messages through the retry tiers with growing
+// delays and finally to the <topic>.<group>.dlq dead-letter topic. Each
+// consumer group needs its own router: several groups read the same topic,
+// and a message one of them failed must not be handled again by the others.
+type RetryRouter struct {
+	Publisher EventPublisher
+	Group     string
+	Tiers     []RetryTier
+	Sink      DeadLetterSink
+}
+
+// GroupTopic names the retry or dead-letter topic with the given suffix that
+// belongs to group. Without a group it is <topic><suffix>.
+func GroupTopic(topic, group, suffix string) string {
+	if group == "" {
+		return topic + suffix
+	}
+	return topic + "." + group + suffix
+}
+
+// Topics returns the retry topics that must be consumed alongside base.
+func (r *RetryRouter) Topics(base string) []string {
+	topics := make([]string, 0, len(r.Tiers))
+	for _, tier := range r.Tiers {
+		topics = append(topics, GroupTopic(base, r.Group, tier.Suffix))
+	}
+	return topics
+}
+
+// Route publishes msg to the next retry tier, or to the DLQ once every tier
+// has been tried. It returns an error if the message could not be forwarded,
+// or was dead-lettered but not recorded in the Sink, in which case the
+// caller must not commit it. Routing it again may put a second copy on the
+// DLQ topic, but records it once.
+func (r *RetryRouter) Route(ctx context.Context, msg Message, cause error) error {
+	original := OriginalTopic(msg)
+	attempt := Attempt(msg) + 1
+	now := time.Now().UTC()
+
+	out := Message{Topic: GroupTopic(original, r.Group, DLQSuffix), Key: msg.Key, Type: msg.Type, Value: msg.Value, Headers: make(map[string]string, len(msg.Headers)+6)}
+	for k, v := range msg.Headers {
+		out.Headers[k] = v
+	}
+	out.Headers[HeaderOriginalTopic] = original
+	r.setGroup(out)
+	out.Headers[HeaderAttempt] = strconv.Itoa(attempt)
+	out.Headers[HeaderError] = cause.Error()
+	out.Headers[HeaderFailedAt] = now.Format(time.RFC3339)
+	delete(out.Headers, HeaderRetryAt)
+
+	if attempt <= len(r.Tiers) {
+		tier := r.Tiers[attempt-1]
+		out.Topic = GroupTopic(original, r.Group, tier.Suffix)
+		out.Headers[HeaderRetryAt] = now.Add(tier.Delay).Format(time.RFC3339)
+	}
+
+	if err := r.Publisher.Publish(ctx, out); err != nil {
+		return err
+	}
+	fields := logrus.Fields{"topic": out.Topic, "attempt": attempt, "type": msg.Type}
+	if strings.HasSuffix(out.Topic, DLQSuffix) {
+		logrus.WithFields(fields).WithError(cause).Warn("Event moved to dead-letter queue")
+		if r.Sink != nil {
+			if err := r.Sink.RecordDeadLetter(ctx, out); err != nil {
+				return fmt.Errorf("record dead letter: %w", err)
+			}
+		}
+		return nil
+	}
+	logrus.WithFields(fields).WithError(cause).Info("Event scheduled for retry")
+	return nil
+}
+
+// DeferredError asks for a message to be handled again at Until without
+// counting as a failed attempt, e.g. because its sender is over its rate.
+type DeferredError struct {
+	Until  time.Time
+	Reason string
+}
+
+func (e *DeferredError) Error() string {
+	return "deferred until " + e.Until.UTC().Format(time.RFC3339) + ": " + e.Reason
+}
+
+// Defer parks msg on the first retry tier until the given time. Unlike Route
+// it leaves the attempt count alone, so a deferred message never reaches the
+// DLQ by being deferred.
+func (r *RetryRouter) Defer(ctx context.Context, msg Message, until time.Time) error {
+	if len(r.Tiers) == 0 {
+		return errors.New("no retry tier to defer to")
+	}
+	original := OriginalTopic(msg)
+	out := Message{Topic: GroupTopic(original, r.Group, r.Tiers[0].Suffix), Key: msg.Key, Type: msg.Type, Value: msg.Value, Headers: make(map[string]string, len(msg.Headers)+3)}
+	for k, v := range msg.Headers {
+		out.Headers[k] = v
+	}
+	out.Headers[HeaderOriginalTopic] = original
+	r.setGroup(out)
+	out.Headers[HeaderRetryAt] = until.UTC().Format(time.RFC3339)
+	return r.Publisher.Publish(ctx, out)
+}
+
+func (r *RetryRouter) setGroup(msg Message) {
+	if r.Group != "" {
+		msg.Headers[HeaderGroup] = r.Group
+	}
+}
+
+// ReplayTo returns a dead letter as it was originally published, due now on
+// group's first retry tier, so only the group that gave up on it handles it
+// again, with a fresh set of attempts. Without a group or tier it goes back
+// to its original topic.
+func ReplayTo(msg Message, group string, tiers []RetryTier) Message {
+	out := StripRetryHeaders(msg)
+	if group == "" || len(tiers) == 0 {
+		return out
+	}
+	out.Topic = GroupTopic(out.Topic, group, tiers[0].Suffix)
+	out.Headers[HeaderOriginalTopic] = OriginalTopic(msg)
+	out.Headers[HeaderGroup] = group
+	out.Headers[HeaderRetryAt] = time.Now().UTC().Format(time.RFC3339)
+	return out
+}
+
+// WaitUntilDue blocks until a retry message's x-retry-at time has passed.
+func WaitUntilDue(ctx context.Context, msg Message) error {
+	retryAt, err := time.Parse(time.RFC3339, msg.Headers[HeaderRetryAt])
+	if err != nil {
+		return nil
+	}
+	return WaitUntil(ctx, retryAt)
+}
+
+// WaitUntil blocks until t or until ctx is done.
+func WaitUntil(ctx context.Context, t time.Time) error {
+	wait := time.Until(t)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// OriginalTopic is the topic a message was first published to.
+func OriginalTopic(msg Message) string {
+	if t := msg.Headers[HeaderOriginalTopic]; t != "" {
+		return t
+	}
+	return msg.Topic
+}
+
+// Attempt is how many times handling msg has already failed.
+func Attempt(msg Message) int {
+	n, _ := strconv.Atoi(msg.Headers[HeaderAttempt])
+	return n
+}
+
+// StripRetryHeaders returns msg as it was originally published, for replay.
+func StripRetryHeaders(msg Message) Message {
+	out := msg
+	out.Topic = OriginalTopic(msg)
+	out.Headers = make(map[string]string, len(msg.Headers))
+	for k, v := range msg.Headers {
+		if !strings.HasPrefix(k, "x-") {
+			out.Headers[k] = v
+		}
+	}
+	return out
+}
@@ -0,0 +1,226 @@
+package events
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//go:embed schemas
+var embeddedSchemas embed.FS
+
+// Field types understood by the registry, mirroring JSON value kinds.
+const (
+	FieldString  = "string"
+	FieldNumber  = "number"
+	FieldBoolean = "boolean"
+	FieldObject  = "object"
+	FieldArray   = "array"
+)
+
+type SchemaField struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Required bool   `json:"required,omitempty"`
+}
+
+// Schema describes one version of an event payload.
+type Schema struct {
+	Type    string        `json:"type"`
+	Version int           `json:"version"`
+	Fields  []SchemaField `json:"fields"`
+}
+
+// URI identifies the schema in an envelope's dataschema attribute.
+func (s Schema) URI() string {
+	return fmt.Sprintf("urn:stocky:schema:%s:v%d", s.Type, s.Version)
+}
+
+// SchemaRegistry is a file-backed stand-in for a schema registry. Schemas
+// live at <dir>/<event type>/v<N>.json; every new version must be backward
+// compatible with the one before it.
+type SchemaRegistry struct {
+	mu      sync.RWMutex
+	schemas map[string][]Schema // ordered by version
+}
+
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{schemas: make(map[string][]Schema)}
+}
+
+// LoadSchemaRegistry loads the schemas bundled with the binary, then any
+// found in dir (if non-empty), which may add versions or event types.
+func LoadSchemaRegistry(dir string) (*SchemaRegistry, error) {
+	r := NewSchemaRegistry()
+	sub, err := fs.Sub(embeddedSchemas, "schemas")
+	if err != nil {
+		return nil, err
+	}
+	if err := r.LoadFS(sub); err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		if err := r.LoadFS(os.DirFS(dir)); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// LoadFS registers every <type>/v<N>.json file in fsys in version order.
+func (r *SchemaRegistry) LoadFS(fsys fs.FS) error {
+	paths, err := fs.Glob(fsys, "*/v*.json")
+	if err != nil {
+		return err
+	}
+	var loaded []Schema
+	for _, p := range paths {
+		body, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		var s Schema
+		if err := json.Unmarshal(body, &s); err != nil {
+			return fmt.Errorf("schema %s: %w", p, err)
+		}
+		if dir := strings.Split(p, "/")[0]; s.Type != dir {
+			return fmt.Errorf("schema %s: type %q does not match directory", p, s.Type)
+		}
+		loaded = append(loaded, s)
+	}
+	sort.Slice(loaded, func(i, j int) bool { return loaded[i].Version < loaded[j].Version })
+	for _, s := range loaded {
+		if existing, ok := r.Get(s.Type, s.Version); ok && sameSchema(existing, s) {
+			continue
+		}
+		if err := r.Register(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Register adds the next version of a schema after checking it is backward
+// compatible with the latest registered version.
+func (r *SchemaRegistry) Register(s Schema) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	versions := r.schemas[s.Type]
+	if len(versions) > 0 {
+		latest := versions[len(versions)-1]
+		if s.Version != latest.Version+1 {
+			return fmt.Errorf("schema %s: version %d must follow %d", s.Type, s.Version, latest.Version)
+		}
+		if err := CheckCompatible(latest, s); err != nil {
+			return err
+		}
+	} else if s.Version != 1 {
+		return fmt.Errorf("schema %s: first version must be 1", s.Type)
+	}
+	r.schemas[s.Type] = append(versions, s)
+	return nil
+}
+
+func (r *SchemaRegistry) Get(eventType string, version int) (Schema, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, s := range r.schemas[eventType] {
+		if s.Version == version {
+			return s, true
+		}
+	}
+	return Schema{}, false
+}
+
+func (r *SchemaRegistry) Latest(eventType string) (Schema, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	versions := r.schemas[eventType]
+	if len(versions) == 0 {
+		return Schema{}, false
+	}
+	return versions[len(versions)-1], true
+}
+
+// CheckCompatible reports whether next can be read by consumers of prev:
+// fields may be added only as optional, required fields may not be removed,
+// and no field may change type.
+func CheckCompatible(prev, next Schema) error {
+	prevFields := make(map[string]SchemaField, len(prev.Fields))
+	for _, f := range prev.Fields {
+		prevFields[f.Name] = f
+	}
+	nextFields := make(map[string]SchemaField, len(next.Fields))
+	for _, f := range next.Fields {
+		nextFields[f.Name] = f
+		old, ok := prevFields[f.Name]
+		if !ok && f.Required {
+			return fmt.Errorf("schema %s v%d: new field %q must be optional", next.Type, next.Version, f.Name)
+		}
+		if ok && old.Type != f.Type {
+			return fmt.Errorf("schema %s v%d: field %q changed type %s -> %s", next.Type, next.Version, f.Name, old.Type, f.Type)
+		}
+	}
+	for _, f := range prev.Fields {
+		if _, ok := nextFields[f.Name]; !ok && f.Required {
+			return fmt.Errorf("schema %s v%d: required field %q removed", next.Type, next.Version, f.Name)
+		}
+	}
+	return nil
+}
+
+// Validate checks a JSON payload against the schema: required fields must be
+// present, types must match and undeclared fields are rejected, so a payload
+// change can't ship without a registered schema version.
+func (s Schema) Validate(payload []byte) error {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(payload, &obj); err != nil {
+		return fmt.Errorf("schema %s v%d: payload is not an object: %w", s.Type, s.Version, err)
+	}
+	declared := make(map[string]bool, len(s.Fields))
+	for _, f := range s.Fields {
+		declared[f.Name] = true
+		v, ok := obj[f.Name]
+		if !ok || v == nil {
+			if f.Required {
+				return fmt.Errorf("schema %s v%d: missing required field %q", s.Type, s.Version, f.Name)
+			}
+			continue
+		}
+		if kind := jsonKind(v); kind != f.Type {
+			return fmt.Errorf("schema %s v%d: field %q is %s, want %s", s.Type, s.Version, f.Name, kind, f.Type)
+		}
+	}
+	for name := range obj {
+		if !declared[name] {
+			return fmt.Errorf("schema %s v%d: undeclared field %q", s.Type, s.Version, name)
+		}
+	}
+	return nil
+}
+
+func jsonKind(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return FieldString
+	case float64:
+		return FieldNumber
+	case bool:
+		return FieldBoolean
+	case []interface{}:
+		return FieldArray
+	default:
+		return FieldObject
+	}
+}
+
+func sameSchema(a, b Schema) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return string(x) == string(y)
+}
@@ -0,0 +1,175 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/mhatrejeets/stocky-ms/internal/market"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// DBChecker pings Postgres.
+type DBChecker struct{ DB *sql.DB }
+
+func (c *DBChecker) Name() string { return "postgres" }
+
+func (c *DBChecker) Check(ctx context.Context) error {
+	return c.DB.PingContext(ctx)
+}
+
+// RedisChecker issues a PING.
+type RedisChecker struct{ Client *redis.Client }
+
+func (c *RedisChecker) Name() string { return "redis" }
+
+func (c *RedisChecker) Check(ctx context.Context) error {
+	return c.Client.Ping(ctx).Err()
+}
+
+// KafkaChecker fetches cluster metadata and verifies at least one broker is
+// reachable. A fresh client is used per check so a wedged connection from the
+// producer can't mask an outage.
+type KafkaChecker struct {
+	Brokers []string
+	Timeout time.Duration
+}
+
+func (c *KafkaChecker) Name() string { return "kafka" }
+
+func (c *KafkaChecker) Check(ctx context.Context) error {
+	cfg := sarama.NewConfig()
+	cfg.Net.DialTimeout = c.Timeout
+	cfg.Net.ReadTimeout = c.Timeout
+	cfg.Net.WriteTimeout = c.Timeout
+	cfg.Metadata.Retry.Max = 0
+	client, err := sarama.NewClient(c.Brokers, cfg)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if err := client.RefreshMetadata(); err != nil {
+		return err
+	}
+	if len(client.Brokers()) == 0 {
+		return errors.New("no brokers in cluster metadata")
+	}
+	return nil
+}
+
+// PriceFreshnessChecker fails when the newest row in stock_prices is older
+// than MaxAge, i.e. the price pipeline has stalled.
+type PriceFreshnessChecker struct {
+	DB     *sql.DB
+	MaxAge time.Duration
+}
+
+func (c *PriceFreshnessChecker) Name() string { return "price_freshness" }
+
+func (c *PriceFreshnessChecker) Check(ctx context.Context) error {
+	var latest sql.NullTime
+	if err := c.DB.QueryRowContext(ctx, `SELECT MAX(updated_at) FROM stock_prices`).Scan(&latest); err != nil {
+		return err
+	}
+	if !latest.Valid {
+		return errors.New("no prices recorded")
+	}
+	if age := time.Since(latest.Time); age > c.MaxAge {
+		return fmt.Errorf("latest price is %s old (max %s)", age.Round(time.Second), c.MaxAge)
+	}
+	return nil
+}
+
+// StalePriceRatio is the share of held symbols whose price is stale, as of
+// the last StalePricesChecker run.
+var StalePriceRatio = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "stocky_stale_price_ratio",
+	Help: "Fraction of held symbols whose latest price is stale",
+})
+
+// StalePricesChecker fails when more than MaxRatio of the symbols users hold
+// have a stale or missing price under Policy. Unlike PriceFreshnessChecker it
+// understands market hours, so a quiet weekend doesn't trip it. Run keeps
+// StalePriceRatio current between probes.
+type StalePricesChecker struct {
+	DB       *sql.DB
+	Policy   *market.StalenessPolicy
+	MaxRatio float64
+	// Interval is how often Run refreshes the gauge; 0 means every 30s
+	Interval time.Duration
+}
+
+func (c *StalePricesChecker) Name() string { return "stale_prices" }
+
+func (c *StalePricesChecker) Check(ctx context.Context) error {
+	stale, total, err := c.measure(ctx)
+	if err != nil {
+		return err
+	}
+	if total > 0 && float64(stale)/float64(total) > c.MaxRatio {
+		return fmt.Errorf("%d of %d held symbols have stale prices", stale, total)
+	}
+	return nil
+}
+
+// Run refreshes StalePriceRatio every Interval until ctx is cancelled, so
+// the metric moves even when nothing probes readiness.
+func (c *StalePricesChecker) Run(ctx context.Context) error {
+	interval := c.Interval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, _, err := c.measure(ctx); err != nil && ctx.Err() == nil {
+			logrus.WithError(err).Warn("Failed to measure stale prices")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// measure counts held symbols and those with stale prices, and sets
+// StalePriceRatio from them.
+func (c *StalePricesChecker) measure(ctx context.Context) (stale, total int, err error) {
+	// Each symbol is judged on the calendar of the listing it is priced on,
+	// NSE first as prices are
+	rows, err := c.DB.QueryContext(ctx, `SELECT h.stock_symbol, sp.updated_at, COALESCE(i.exchange, '')
+		FROM (SELECT DISTINCT stock_symbol FROM user_holdings WHERE shares <> 0) h
+		LEFT JOIN stock_prices sp ON sp.symbol = h.stock_symbol
+		LEFT JOIN LATERAL (SELECT exchange FROM instruments WHERE symbol = h.stock_symbol
+			ORDER BY CASE exchange WHEN 'NSE' THEN 0 WHEN 'BSE' THEN 1 ELSE 2 END, exchange LIMIT 1) i ON true`)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var symbol, exchange string
+		var updatedAt sql.NullTime
+		if err := rows.Scan(&symbol, &updatedAt, &exchange); err != nil {
+			return 0, 0, err
+		}
+		total++
+		if !updatedAt.Valid || c.Policy.IsStaleOn(exchange, updatedAt.Time) {
+			stale++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+	if total == 0 {
+		StalePriceRatio.Set(0)
+		return 0, 0, nil
+	}
+	StalePriceRatio.Set(float64(stale) / float64(total))
+	return stale, total, nil
+}
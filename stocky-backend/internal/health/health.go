@@ -0,0 +1,175 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+	StatusFail     = "fail"
+)
+
+// Checker probes a single dependency. Check should honour ctx cancellation.
+type Checker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a plain function to the Checker interface.
+type CheckerFunc struct {
+	CheckName string
+	Fn        func(ctx context.Context) error
+}
+
+func (f CheckerFunc) Name() string                    { return f.CheckName }
+func (f CheckerFunc) Check(ctx context.Context) error { return f.Fn(ctx) }
+
+// Result is the outcome of one checker run.
+type Result struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Critical  bool      `json:"critical"`
+	LatencyMS float64   `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Report aggregates all results. Status is "fail" if any critical check
+// failed, "degraded" if only non-critical checks failed, otherwise "ok".
+type Report struct {
+	Status    string    `json:"status"`
+	Checks    []Result  `json:"checks"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+type registration struct {
+	checker  Checker
+	critical bool
+}
+
+// Registry runs registered checkers concurrently with a per-check timeout and
+// caches each result for CacheTTL so probes don't hammer dependencies.
+type Registry struct {
+	Timeout  time.Duration
+	CacheTTL time.Duration
+
+	mu      sync.Mutex
+	checks  []registration
+	cache   map[string]Result
+	running map[string]chan struct{}
+}
+
+func NewRegistry(timeout, cacheTTL time.Duration) *Registry {
+	return &Registry{
+		Timeout:  timeout,
+		CacheTTL: cacheTTL,
+		cache:    make(map[string]Result),
+		running:  make(map[string]chan struct{}),
+	}
+}
+
+// Register adds a checker. Critical checkers make the service not ready when
+// they fail; non-critical ones only degrade the report.
+func (r *Registry) Register(c Checker, critical bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, registration{checker: c, critical: critical})
+}
+
+// Run evaluates every checker, serving cached results that are still fresh.
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.Lock()
+	checks := make([]registration, len(r.checks))
+	copy(checks, r.checks)
+	r.mu.Unlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, reg := range checks {
+		wg.Add(1)
+		go func(i int, reg registration) {
+			defer wg.Done()
+			results[i] = r.result(ctx, reg)
+		}(i, reg)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: results, CheckedAt: time.Now().UTC()}
+	for _, res := range results {
+		if res.Status == StatusOK {
+			continue
+		}
+		if res.Critical {
+			report.Status = StatusFail
+		} else if report.Status == StatusOK {
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+func (r *Registry) result(ctx context.Context, reg registration) Result {
+	name := reg.checker.Name()
+	for {
+		r.mu.Lock()
+		if res, ok := r.cache[name]; ok && time.Since(res.CheckedAt) < r.CacheTTL {
+			r.mu.Unlock()
+			return res
+		}
+		// Another probe is already checking this dependency; wait for it.
+		if done, ok := r.running[name]; ok {
+			r.mu.Unlock()
+			select {
+			case <-done:
+				continue
+			case <-ctx.Done():
+				return Result{Name: name, Status: StatusFail, Critical: reg.critical, Error: ctx.Err().Error(), CheckedAt: time.Now().UTC()}
+			}
+		}
+		done := make(chan struct{})
+		r.running[name] = done
+		r.mu.Unlock()
+
+		res := r.check(reg)
+
+		r.mu.Lock()
+		r.cache[name] = res
+		delete(r.running, name)
+		r.mu.Unlock()
+		close(done)
+		return res
+	}
+}
+
+// check runs detached from the caller's context so an impatient probe can't
+// cache a failure for a dependency that is actually healthy.
+func (r *Registry) check(reg registration) Result {
+	ctx, cancel := context.WithTimeout(context.Background(), r.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() { errCh <- reg.checker.Check(ctx) }()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	res := Result{
+		Name:      reg.checker.Name(),
+		Status:    StatusOK,
+		Critical:  reg.critical,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt: time.Now().UTC(),
+	}
+	if err != nil {
+		res.Status = StatusFail
+		res.Error = err.Error()
+	}
+	return res
+}
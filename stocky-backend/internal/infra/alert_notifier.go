@@ -0,0 +1,84 @@
+package infra
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+// LogAlertNotifier writes alerts to the log; useful until a real channel is
+// configured.
+type LogAlertNotifier struct{}
+
+func (LogAlertNotifier) NotifyAlert(ctx context.Context, alert model.AlertEvent) error {
+	logrus.WithFields(logrus.Fields{"alert_id": alert.ID, "user_id": alert.UserID, "kind": alert.Kind, "symbol": alert.Symbol}).Info(alert.Message)
+	return nil
+}
+
+// WebhookAlertNotifier POSTs each alert as JSON to URL. With a Secret, the
+// body's HMAC-SHA256 is sent as X-Stocky-Signature: sha256=<hex>. Any
+// non-2xx response is a failed delivery.
+type WebhookAlertNotifier struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+func (n *WebhookAlertNotifier) NotifyAlert(ctx context.Context, alert model.AlertEvent) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(n.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Stocky-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("alert webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// NewAlertNotifierFromEnv builds the notifier named by ALERT_NOTIFIER:
+// "webhook" for ALERT_WEBHOOK_URL, or "log" (the default).
+func NewAlertNotifierFromEnv() (service.AlertNotifier, error) {
+	switch kind := GetEnv("ALERT_NOTIFIER", "log"); kind {
+	case "log":
+		return LogAlertNotifier{}, nil
+	case "webhook":
+		url := GetEnv("ALERT_WEBHOOK_URL", "")
+		if url == "" {
+			return nil, fmt.Errorf("ALERT_WEBHOOK_URL is required for the webhook notifier")
+		}
+		return &WebhookAlertNotifier{
+			URL:    url,
+			Secret: GetEnv("ALERT_WEBHOOK_SECRET", ""),
+			Client: &http.Client{Timeout: GetEnvDuration("ALERT_WEBHOOK_TIMEOUT", 5*time.Second)},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown ALERT_NOTIFIER %q", kind)
+	}
+}
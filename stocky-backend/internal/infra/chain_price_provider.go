@@ -0,0 +1,140 @@
+package infra
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+// SourceLastKnownGood marks quotes served from the stock_prices fallback.
+const SourceLastKnownGood = "last_known_good"
+
+// ErrPriceUnavailable is returned when no source has a price for a symbol.
+var ErrPriceUnavailable = errors.New("price unavailable")
+
+// NamedPriceSource is one entry in a ChainPriceProvider.
+type NamedPriceSource struct {
+	Name     string
+	Provider PriceProvider
+	Breaker  *CircuitBreaker
+}
+
+// ChainPriceProvider asks sources in priority order, each guarded by its own
+// circuit breaker, and fills whatever is still missing from Fallback. Quotes
+// carry the name of the source that served them; fallback quotes are marked
+// degraded.
+type ChainPriceProvider struct {
+	Sources  []NamedPriceSource
+	Fallback PriceProvider
+}
+
+// NewChainPriceProvider builds a chain from PRICE_SOURCES (e.g. "http,sim")
+// with stock_prices as the last-known-good fallback.
+func NewChainPriceProvider(db *sql.DB, rdb *redis.Client) *ChainPriceProvider {
+	threshold := GetEnvInt("PRICE_BREAKER_FAILURES", 3)
+	cooldown := GetEnvDuration("PRICE_BREAKER_COOLDOWN", 30*time.Second)
+	chain := &ChainPriceProvider{Fallback: &DBPriceProvider{DB: db}}
+	for _, name := range GetEnvList("PRICE_SOURCES") {
+		var p PriceProvider
+		switch name {
+		case "http":
+			p = NewHTTPPriceProvider(os.Getenv("QUOTE_API_URL"))
+		case "mock":
+			p = &MockPriceProvider{Redis: rdb}
+		case "db":
+			p = &DBPriceProvider{DB: db}
+		case SourceSimulator:
+			sim, err := NewPriceSimulatorFromEnv()
+			if err != nil {
+				logrus.WithError(err).Warn("Invalid price simulator config, skipping")
+				continue
+			}
+			p = sim
+		default:
+			logrus.WithField("source", name).Warn("Unknown price source, skipping")
+			continue
+		}
+		chain.Sources = append(chain.Sources, NamedPriceSource{Name: name, Provider: p, Breaker: NewCircuitBreaker(threshold, cooldown)})
+	}
+	return chain
+}
+
+func (c *ChainPriceProvider) GetPrice(ctx context.Context, symbol string) (decimal.Decimal, time.Time, error) {
+	quotes, err := c.GetPrices(ctx, []string{symbol})
+	if err != nil {
+		return decimal.Zero, time.Time{}, err
+	}
+	q, ok := quotes[symbol]
+	if !ok {
+		return decimal.Zero, time.Time{}, fmt.Errorf("%s: %w", symbol, ErrPriceUnavailable)
+	}
+	return q.Price, q.AsOf, nil
+}
+
+// GetPrices returns an error only when every source and the fallback failed
+// outright; symbols no source could price are simply absent.
+func (c *ChainPriceProvider) GetPrices(ctx context.Context, symbols []string) (map[string]model.Quote, error) {
+	quotes := make(map[string]model.Quote, len(symbols))
+	remaining := symbols
+	var errs []error
+	for _, src := range c.Sources {
+		if len(remaining) == 0 {
+			break
+		}
+		if src.Breaker != nil && !src.Breaker.Allow() {
+			continue
+		}
+		got, err := src.Provider.GetPrices(ctx, remaining)
+		if err != nil {
+			if src.Breaker != nil {
+				src.Breaker.Failure()
+			}
+			logrus.WithError(err).WithField("source", src.Name).Warn("Price source failed")
+			errs = append(errs, fmt.Errorf("%s: %w", src.Name, err))
+			continue
+		}
+		if src.Breaker != nil {
+			src.Breaker.Success()
+		}
+		remaining = collect(quotes, got, remaining, src.Name, false)
+	}
+	if len(remaining) > 0 && c.Fallback != nil {
+		got, err := c.Fallback.GetPrices(ctx, remaining)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", SourceLastKnownGood, err))
+		} else {
+			// Only a degraded read when a live source was expected to answer
+			remaining = collect(quotes, got, remaining, SourceLastKnownGood, len(c.Sources) > 0)
+		}
+	}
+	if len(quotes) == 0 && len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return quotes, nil
+}
+
+// collect copies the quotes found for pending into quotes, tagging them with
+// source, and returns the symbols still missing.
+func collect(quotes, got map[string]model.Quote, pending []string, source string, degraded bool) []string {
+	var missing []string
+	for _, symbol := range pending {
+		q, ok := got[symbol]
+		if !ok {
+			missing = append(missing, symbol)
+			continue
+		}
+		q.Symbol = symbol
+		q.Source = source
+		q.Degraded = degraded
+		quotes[symbol] = q
+	}
+	return missing
+}
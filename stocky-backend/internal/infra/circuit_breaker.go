@@ -0,0 +1,98 @@
+package infra
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned when a call is short-circuited by an open breaker.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// BreakerState is the state of a CircuitBreaker.
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// CircuitBreaker stops calling a failing dependency. After Threshold
+// consecutive failures it opens for Cooldown, then lets a single trial call
+// through (half-open); success closes it again, failure re-opens it.
+type CircuitBreaker struct {
+	Threshold int
+	Cooldown  time.Duration
+	Now       func() time.Time
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	state    BreakerState
+	trial    bool
+}
+
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{Threshold: threshold, Cooldown: cooldown}
+}
+
+func (b *CircuitBreaker) now() time.Time {
+	if b.Now != nil {
+		return b.Now()
+	}
+	return time.Now()
+}
+
+// Allow reports whether a call may proceed. Every allowed call must be
+// followed by Success or Failure.
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.currentState() {
+	case BreakerOpen:
+		return false
+	case BreakerHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+	}
+	return true
+}
+
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.trial = false
+	b.state = BreakerClosed
+}
+
+func (b *CircuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.trial || b.failures >= b.Threshold {
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+	}
+	b.trial = false
+}
+
+// State returns the breaker state, moving an expired open breaker to half-open.
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.currentState()
+}
+
+func (b *CircuitBreaker) currentState() BreakerState {
+	if b.state == "" {
+		b.state = BreakerClosed
+	}
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.Cooldown {
+		b.state = BreakerHalfOpen
+	}
+	return b.state
+}
@@ -1,13 +1,31 @@
 package infra
 
 import (
+	"context"
 	"database/sql"
 	"os"
+	"time"
 
 	_ "github.com/lib/pq"
 )
 
+// NewDB opens the Postgres pool and pings it so an unreachable database is
+// reported at startup instead of on the first query.
 func NewDB() (*sql.DB, error) {
 	dsn := os.Getenv("DATABASE_URL")
-	return sql.Open("postgres", dsn)
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(GetEnvInt("DB_MAX_OPEN_CONNS", 25))
+	db.SetMaxIdleConns(GetEnvInt("DB_MAX_IDLE_CONNS", 5))
+	db.SetConnMaxLifetime(GetEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute))
+
+	ctx, cancel := context.WithTimeout(context.Background(), GetEnvDuration("DB_CONNECT_TIMEOUT", 5*time.Second))
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
 }
@@ -0,0 +1,51 @@
+package infra
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/shopspring/decimal"
+)
+
+// DBPriceProvider reads the latest prices from the stock_prices table.
+type DBPriceProvider struct {
+	DB *sql.DB
+}
+
+func (p *DBPriceProvider) GetPrice(ctx context.Context, symbol string) (decimal.Decimal, time.Time, error) {
+	var priceStr string
+	var updatedAt time.Time
+	err := p.DB.QueryRowContext(ctx, `SELECT price, updated_at FROM stock_prices WHERE symbol = $1`, symbol).Scan(&priceStr, &updatedAt)
+	if err != nil {
+		return decimal.Zero, time.Time{}, err
+	}
+	price, err := decimal.NewFromString(priceStr)
+	return price, updatedAt, err
+}
+
+func (p *DBPriceProvider) GetPrices(ctx context.Context, symbols []string) (map[string]model.Quote, error) {
+	quotes := make(map[string]model.Quote, len(symbols))
+	if len(symbols) == 0 {
+		return quotes, nil
+	}
+	rows, err := p.DB.QueryContext(ctx, `SELECT symbol, price, currency, updated_at FROM stock_prices WHERE symbol = ANY($1)`, pq.Array(symbols))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var q model.Quote
+		var priceStr string
+		if err := rows.Scan(&q.Symbol, &priceStr, &q.Currency, &q.AsOf); err != nil {
+			return nil, err
+		}
+		if q.Price, err = decimal.NewFromString(priceStr); err != nil {
+			return nil, err
+		}
+		quotes[q.Symbol] = q
+	}
+	return quotes, rows.Err()
+}
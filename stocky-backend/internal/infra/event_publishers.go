@@ -0,0 +1,172 @@
+package infra
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/mhatrejeets/stocky-ms/internal/events"
+	"github.com/sirupsen/logrus"
+)
+
+// NewEventPublisher selects the backend from EVENT_PUBLISHER
+// (kafka|noop|memory|file). Kafka is the default when brokers are configured
+// and noop otherwise. A backend that was asked for but cannot be opened is an
+// error rather than a quiet noop, which would mark every outbox event
+// published while dropping it.
+func NewEventPublisher(brokers []string) (events.EventPublisher, error) {
+	backend := GetEnv("EVENT_PUBLISHER", "")
+	if backend == "" {
+		backend = "noop"
+		if len(brokers) > 0 {
+			backend = "kafka"
+		}
+	}
+	switch backend {
+	case "kafka":
+		producer, err := NewKafkaProducer(brokers)
+		if err != nil {
+			return nil, fmt.Errorf("kafka unavailable: %w", err)
+		}
+		return producer, nil
+	case "memory":
+		return NewMemoryBus(), nil
+	case "file":
+		path := GetEnv("EVENT_FILE_PATH", "events.jsonl")
+		publisher, err := NewFilePublisher(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot open event file: %w", err)
+		}
+		return publisher, nil
+	case "noop":
+		return &NoopPublisher{}, nil
+	default:
+		return nil, fmt.Errorf("unknown EVENT_PUBLISHER %q", backend)
+	}
+}
+
+// NoopPublisher only logs events; used when no broker is configured.
+type NoopPublisher struct{}
+
+func (p *NoopPublisher) Publish(ctx context.Context, msg events.Message) error {
+	logrus.WithFields(logrus.Fields{"topic": msg.Topic, "type": msg.Type}).Debug("Noop publish")
+	return nil
+}
+
+func (p *NoopPublisher) Close() error { return nil }
+
+// ErrBusClosed is returned by Publish once the bus is closed.
+var ErrBusClosed = errors.New("memory bus closed")
+
+// MemoryBus keeps published messages in process and fans them out to
+// subscribers. Intended for tests and single-process local development.
+type MemoryBus struct {
+	mu          sync.Mutex
+	messages    map[string][]events.Message
+	subscribers map[string][]chan events.Message
+	// sendMu is held for reading while a message is handed to subscribers
+	// and for writing by Close, so no channel is closed mid-send
+	sendMu sync.RWMutex
+	closed bool
+}
+
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{
+		messages:    make(map[string][]events.Message),
+		subscribers: make(map[string][]chan events.Message),
+	}
+}
+
+func (b *MemoryBus) Publish(ctx context.Context, msg events.Message) error {
+	b.sendMu.RLock()
+	defer b.sendMu.RUnlock()
+	if b.closed {
+		return ErrBusClosed
+	}
+	b.mu.Lock()
+	b.messages[msg.Topic] = append(b.messages[msg.Topic], msg)
+	subs := b.subscribers[msg.Topic]
+	b.mu.Unlock()
+	for _, ch := range subs {
+		select {
+		case ch <- msg:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Subscribe returns a channel receiving every later message on topic. On a
+// closed bus the channel is already closed.
+func (b *MemoryBus) Subscribe(topic string, buffer int) <-chan events.Message {
+	ch := make(chan events.Message, buffer)
+	b.sendMu.RLock()
+	defer b.sendMu.RUnlock()
+	if b.closed {
+		close(ch)
+		return ch
+	}
+	b.mu.Lock()
+	b.subscribers[topic] = append(b.subscribers[topic], ch)
+	b.mu.Unlock()
+	return ch
+}
+
+// Messages returns a copy of everything published to topic so far.
+func (b *MemoryBus) Messages(topic string) []events.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]events.Message(nil), b.messages[topic]...)
+}
+
+// Close waits for in-flight publishes, then closes every subscriber channel.
+// Later publishes fail with ErrBusClosed.
+func (b *MemoryBus) Close() error {
+	b.sendMu.Lock()
+	defer b.sendMu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for topic, subs := range b.subscribers {
+		for _, ch := range subs {
+			close(ch)
+		}
+		delete(b.subscribers, topic)
+	}
+	return nil
+}
+
+// FilePublisher appends each message as one JSON line, handy for inspecting
+// the event stream locally or replaying it later.
+type FilePublisher struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+func NewFilePublisher(path string) (*FilePublisher, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FilePublisher{file: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (p *FilePublisher) Publish(ctx context.Context, msg events.Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.enc.Encode(msg)
+}
+
+func (p *FilePublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.file.Close()
+}
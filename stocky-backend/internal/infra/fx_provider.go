@@ -0,0 +1,174 @@
+package infra
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	SourceFXStatic = "static"
+	SourceFXHTTP   = "http"
+)
+
+// FXProvider looks up the INR value of one unit of each currency.
+// Currencies without a rate are omitted rather than reported as errors.
+type FXProvider interface {
+	GetRates(ctx context.Context, currencies []string) (map[string]model.FXRate, error)
+}
+
+// StaticFXProvider serves fixed rates, stamped with the time of the lookup.
+type StaticFXProvider struct {
+	Rates map[string]decimal.Decimal
+	Now   func() time.Time
+}
+
+func (p *StaticFXProvider) GetRates(ctx context.Context, currencies []string) (map[string]model.FXRate, error) {
+	now := time.Now()
+	if p.Now != nil {
+		now = p.Now()
+	}
+	rates := make(map[string]model.FXRate, len(currencies))
+	for _, c := range currencies {
+		if rate, ok := p.Rates[c]; ok {
+			rates[c] = model.FXRate{Currency: c, Rate: rate, AsOf: now, Source: SourceFXStatic}
+		}
+	}
+	return rates, nil
+}
+
+// ParseFXRates parses "CURRENCY=rate" pairs separated by commas.
+func ParseFXRates(spec string) (map[string]decimal.Decimal, error) {
+	out := make(map[string]decimal.Decimal)
+	for _, part := range strings.Split(spec, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		currency, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("fx rate %q: want CURRENCY=rate", part)
+		}
+		rate, err := decimal.NewFromString(strings.TrimSpace(value))
+		if err != nil || !rate.IsPositive() {
+			return nil, fmt.Errorf("fx rate %q: invalid rate", part)
+		}
+		out[strings.ToUpper(strings.TrimSpace(currency))] = rate
+	}
+	return out, nil
+}
+
+// RateResponse is the wire format of the FX API:
+//
+//	GET <base>/rates?currencies=USD,EUR
+//	{"rates":[{"currency":"USD","rate":"83.2150","as_of":"2025-09-25T10:00:00Z"}]}
+//
+// Rates are INR per unit of currency.
+type RateResponse struct {
+	Rates []RateDTO `json:"rates"`
+}
+
+type RateDTO struct {
+	Currency string          `json:"currency"`
+	Rate     decimal.Decimal `json:"rate"`
+	AsOf     time.Time       `json:"as_of"`
+}
+
+// HTTPFXProvider fetches rates from an HTTP FX API and drops any that are
+// non-positive, undated, dated in the future or for unrequested currencies.
+type HTTPFXProvider struct {
+	BaseURL string
+	APIKey  string
+	Client  *http.Client
+}
+
+func NewHTTPFXProvider(baseURL string) *HTTPFXProvider {
+	return &HTTPFXProvider{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		APIKey:  GetEnv("FX_API_KEY", ""),
+		Client:  &http.Client{Timeout: GetEnvDuration("FX_API_TIMEOUT", 3*time.Second)},
+	}
+}
+
+func (p *HTTPFXProvider) GetRates(ctx context.Context, currencies []string) (map[string]model.FXRate, error) {
+	rates := make(map[string]model.FXRate, len(currencies))
+	if len(currencies) == 0 {
+		return rates, nil
+	}
+	u := p.BaseURL + "/rates?currencies=" + url.QueryEscape(strings.Join(currencies, ","))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if p.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return nil, fmt.Errorf("FX API returned status %d", resp.StatusCode)
+	}
+	var body RateResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decode FX response: %w", err)
+	}
+	requested := make(map[string]bool, len(currencies))
+	for _, c := range currencies {
+		requested[c] = true
+	}
+	now := time.Now()
+	for _, dto := range body.Rates {
+		dto.Currency = strings.ToUpper(dto.Currency)
+		if err := validateRate(dto, requested, now); err != nil {
+			logrus.WithError(err).WithField("currency", dto.Currency).Warn("Dropping invalid FX rate")
+			continue
+		}
+		rates[dto.Currency] = model.FXRate{Currency: dto.Currency, Rate: dto.Rate, AsOf: dto.AsOf, Source: SourceFXHTTP}
+	}
+	return rates, nil
+}
+
+func validateRate(r RateDTO, requested map[string]bool, now time.Time) error {
+	switch {
+	case !requested[r.Currency]:
+		return errors.New("unrequested currency")
+	case !r.Rate.IsPositive():
+		return errors.New("non-positive rate")
+	case r.AsOf.IsZero():
+		return errors.New("missing as_of")
+	case r.AsOf.After(now.Add(time.Minute)):
+		return errors.New("as_of in the future")
+	}
+	return nil
+}
+
+// NewFXProviderFromEnv builds the provider named by FX_SOURCE: "http" for
+// the API at FX_API_URL, or "static" (the default) for FX_RATES.
+func NewFXProviderFromEnv() (FXProvider, error) {
+	switch source := GetEnv("FX_SOURCE", SourceFXStatic); source {
+	case SourceFXHTTP:
+		return NewHTTPFXProvider(GetEnv("FX_API_URL", "")), nil
+	case SourceFXStatic:
+		rates, err := ParseFXRates(GetEnv("FX_RATES", ""))
+		if err != nil {
+			return nil, err
+		}
+		return &StaticFXProvider{Rates: rates}, nil
+	default:
+		return nil, fmt.Errorf("unknown FX_SOURCE %q", source)
+	}
+}
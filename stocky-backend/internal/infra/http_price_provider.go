@@ -0,0 +1,172 @@
+package infra
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+// QuoteResponse is the wire format of the quote API:
+//
+//	GET <base>/quotes?symbols=TCS,INFY
+//	{"quotes":[{"symbol":"TCS","price":"3500.10","as_of":"2025-09-25T10:00:00Z"}]}
+//
+// Quotes may carry a "currency"; without one the instrument's is assumed.
+type QuoteResponse struct {
+	Quotes []QuoteDTO `json:"quotes"`
+}
+
+type QuoteDTO struct {
+	Symbol   string          `json:"symbol"`
+	Price    decimal.Decimal `json:"price"`
+	Currency string          `json:"currency,omitempty"`
+	AsOf     time.Time       `json:"as_of"`
+}
+
+// HTTPPriceProvider fetches quotes from an HTTP quote API in batches, with a
+// per-attempt timeout, bounded retries and validation of every quote.
+type HTTPPriceProvider struct {
+	BaseURL   string
+	APIKey    string
+	Client    *http.Client
+	BatchSize int
+	Retries   int
+	Backoff   time.Duration
+	// MaxAge rejects quotes older than this; zero disables the check.
+	MaxAge time.Duration
+}
+
+func NewHTTPPriceProvider(baseURL string) *HTTPPriceProvider {
+	return &HTTPPriceProvider{
+		BaseURL:   strings.TrimRight(baseURL, "/"),
+		APIKey:    GetEnv("QUOTE_API_KEY", ""),
+		Client:    &http.Client{Timeout: GetEnvDuration("QUOTE_API_TIMEOUT", 3*time.Second)},
+		BatchSize: GetEnvInt("QUOTE_API_BATCH_SIZE", 50),
+		Retries:   GetEnvInt("QUOTE_API_RETRIES", 2),
+		Backoff:   GetEnvDuration("QUOTE_API_BACKOFF", 200*time.Millisecond),
+		MaxAge:    GetEnvDuration("QUOTE_API_MAX_AGE", 0),
+	}
+}
+
+var errRetryable = errors.New("retryable quote API error")
+
+func (p *HTTPPriceProvider) GetPrice(ctx context.Context, symbol string) (decimal.Decimal, time.Time, error) {
+	quotes, err := p.GetPrices(ctx, []string{symbol})
+	if err != nil {
+		return decimal.Zero, time.Time{}, err
+	}
+	q, ok := quotes[symbol]
+	if !ok {
+		return decimal.Zero, time.Time{}, fmt.Errorf("no quote for %s", symbol)
+	}
+	return q.Price, q.AsOf, nil
+}
+
+func (p *HTTPPriceProvider) GetPrices(ctx context.Context, symbols []string) (map[string]model.Quote, error) {
+	quotes := make(map[string]model.Quote, len(symbols))
+	batch := p.BatchSize
+	if batch < 1 {
+		batch = len(symbols)
+	}
+	for start := 0; start < len(symbols); start += batch {
+		end := start + batch
+		if end > len(symbols) {
+			end = len(symbols)
+		}
+		if err := p.fetchWithRetry(ctx, symbols[start:end], quotes); err != nil {
+			return nil, err
+		}
+	}
+	return quotes, nil
+}
+
+func (p *HTTPPriceProvider) fetchWithRetry(ctx context.Context, symbols []string, into map[string]model.Quote) error {
+	var err error
+	backoff := p.Backoff
+	for attempt := 0; attempt <= p.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			backoff *= 2
+		}
+		if err = p.fetch(ctx, symbols, into); err == nil || !errors.Is(err, errRetryable) {
+			return err
+		}
+		logrus.WithError(err).WithField("attempt", attempt+1).Warn("Quote API request failed")
+	}
+	return err
+}
+
+func (p *HTTPPriceProvider) fetch(ctx context.Context, symbols []string, into map[string]model.Quote) error {
+	u := p.BaseURL + "/quotes?symbols=" + url.QueryEscape(strings.Join(symbols, ","))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if p.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%w: %v", errRetryable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("%w: status %d", errRetryable, resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("quote API returned status %d", resp.StatusCode)
+	}
+	var body QuoteResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(&body); err != nil {
+		return fmt.Errorf("%w: decode: %v", errRetryable, err)
+	}
+
+	requested := make(map[string]bool, len(symbols))
+	for _, s := range symbols {
+		requested[s] = true
+	}
+	now := time.Now()
+	for _, dto := range body.Quotes {
+		if err := p.validate(dto, requested, now); err != nil {
+			logrus.WithError(err).WithField("symbol", dto.Symbol).Warn("Dropping invalid quote")
+			continue
+		}
+		into[dto.Symbol] = model.Quote{Symbol: dto.Symbol, Price: dto.Price, Currency: strings.ToUpper(dto.Currency), AsOf: dto.AsOf}
+	}
+	return nil
+}
+
+func (p *HTTPPriceProvider) validate(q QuoteDTO, requested map[string]bool, now time.Time) error {
+	switch {
+	case !requested[q.Symbol]:
+		return errors.New("unrequested symbol")
+	case !q.Price.IsPositive():
+		return errors.New("non-positive price")
+	case q.AsOf.IsZero():
+		return errors.New("missing as_of")
+	case q.AsOf.After(now.Add(time.Minute)):
+		return errors.New("as_of in the future")
+	case p.MaxAge > 0 && now.Sub(q.AsOf) > p.MaxAge:
+		return errors.New("quote too old")
+	}
+	return nil
+}
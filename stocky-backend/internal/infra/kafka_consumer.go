@@ -2,34 +2,258 @@ package infra
 
 import (
 	"context"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"time"
 
 	"github.com/IBM/sarama"
+	"github.com/mhatrejeets/stocky-ms/internal/events"
 	"github.com/sirupsen/logrus"
 )
 
-type RewardConsumer struct{}
+// ConsumerGroupWorker consumes topics as part of a Kafka consumer group and
+// dispatches messages through a handler registry. Offsets are committed so
+// nothing is lost across restarts, and partitions are rebalanced between
+// replicas sharing GroupID.
+//
+// Within a claimed partition, messages are fanned out to Concurrency lanes by
+// hashing the message key, so events for the same user are processed in
+// order while unrelated users proceed in parallel.
+//
+// When Retry is set, failed messages are forwarded to delayed retry topics and
+// finally the dead-letter topic. The retry topics are consumed by this worker
+// too, each message being held until its retry time. Retry must be a router
+// for GroupID, so other groups on the same topics never see its retries.
+type ConsumerGroupWorker struct {
+	Brokers     []string
+	GroupID     string
+	Topics      []string
+	Registry    *events.Registry
+	Retry       *events.RetryRouter
+	Concurrency int
+}
 
-func (rc *RewardConsumer) Start(ctx context.Context, brokers []string) {
+// Run blocks until ctx is cancelled, rejoining the group after each rebalance.
+func (w *ConsumerGroupWorker) Run(ctx context.Context) error {
 	config := sarama.NewConfig()
 	config.Consumer.Return.Errors = true
-	client, err := sarama.NewConsumer(brokers, config)
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	config.Consumer.Offsets.AutoCommit.Enable = true
+	config.Consumer.Offsets.AutoCommit.Interval = time.Second
+	config.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategySticky()}
+
+	group, err := sarama.NewConsumerGroup(w.Brokers, w.GroupID, config)
 	if err != nil {
-		logrus.WithError(err).Error("Failed to start Kafka consumer")
-		return
+		return err
 	}
-	defer client.Close()
-	partitionConsumer, err := client.ConsumePartition("reward-events", 0, sarama.OffsetNewest)
-	if err != nil {
-		logrus.WithError(err).Error("Failed to consume partition")
-		return
+	defer group.Close()
+
+	go func() {
+		for err := range group.Errors() {
+			logrus.WithError(err).WithField("group", w.GroupID).Error("Kafka consumer group error")
+		}
+	}()
+
+	topics := append([]string(nil), w.Topics...)
+	if w.Retry != nil {
+		for _, topic := range w.Topics {
+			topics = append(topics, w.Retry.Topics(topic)...)
+		}
 	}
-	defer partitionConsumer.Close()
+	handler := w.Handler()
+	logrus.WithFields(logrus.Fields{"group": w.GroupID, "topics": topics}).Info("Starting Kafka consumer group")
+	for {
+		if err := group.Consume(ctx, topics, handler); err != nil {
+			if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+				return nil
+			}
+			logrus.WithError(err).Error("Kafka consume failed, retrying")
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// Handler returns the sarama handler Run consumes with, so the lanes and
+// offset tracking can be driven without a broker.
+func (w *ConsumerGroupWorker) Handler() sarama.ConsumerGroupHandler {
+	lanes := w.Concurrency
+	if lanes < 1 {
+		lanes = 1
+	}
+	return &groupHandler{registry: w.Registry, retry: w.Retry, lanes: lanes}
+}
+
+type groupHandler struct {
+	registry *events.Registry
+	retry    *events.RetryRouter
+	lanes    int
+}
+
+func (h *groupHandler) Setup(sess sarama.ConsumerGroupSession) error {
+	logrus.WithField("claims", sess.Claims()).Info("Kafka partitions assigned")
+	return nil
+}
+
+func (h *groupHandler) Cleanup(sess sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (h *groupHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	tracker := newOffsetTracker(sess)
+	lanes := make([]chan *sarama.ConsumerMessage, h.lanes)
+	var wg sync.WaitGroup
+	for i := range lanes {
+		lanes[i] = make(chan *sarama.ConsumerMessage, 64)
+		wg.Add(1)
+		go func(in <-chan *sarama.ConsumerMessage) {
+			defer wg.Done()
+			for msg := range in {
+				// A message we failed to handle or forward stays uncommitted
+				// and is redelivered after the next rebalance.
+				if h.handle(sess.Context(), msg) {
+					tracker.done(msg)
+				}
+			}
+		}(lanes[i])
+	}
+	defer func() {
+		for _, lane := range lanes {
+			close(lane)
+		}
+		wg.Wait()
+	}()
+
 	for {
 		select {
-		case msg := <-partitionConsumer.Messages():
-			logrus.Infof("Received event: %s", string(msg.Value))
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			tracker.track(msg)
+			lanes[LaneFor(msg.Key, h.lanes)] <- msg
+		case <-sess.Context().Done():
+			return nil
+		}
+	}
+}
+
+// handle dispatches one record and reports whether its offset may be
+// committed.
+func (h *groupHandler) handle(ctx context.Context, cm *sarama.ConsumerMessage) bool {
+	msg := messageFromRecord(cm)
+	if err := events.WaitUntilDue(ctx, msg); err != nil {
+		return false
+	}
+	err := h.registry.Dispatch(ctx, msg)
+	var deferred *events.DeferredError
+	// Without retry topics there is nowhere to park a deferred message, so
+	// it waits in its lane
+	for h.retry == nil && errors.As(err, &deferred) {
+		if events.WaitUntil(ctx, deferred.Until) != nil {
+			return false
+		}
+		err = h.registry.Dispatch(ctx, msg)
+	}
+	if err == nil {
+		return true
+	}
+	log := logrus.WithError(err).WithFields(logrus.Fields{
+		"topic": cm.Topic, "partition": cm.Partition, "offset": cm.Offset, "type": msg.Type,
+	})
+	if h.retry == nil {
+		log.Error("Event handler failed")
+		return true
+	}
+	route := func() error { return h.retry.Route(ctx, msg, err) }
+	if errors.As(err, &deferred) {
+		route = func() error { return h.retry.Defer(ctx, msg, deferred.Until) }
+	}
+	for backoff := 100 * time.Millisecond; ; backoff *= 2 {
+		routeErr := route()
+		if routeErr == nil {
+			return true
+		}
+		log.WithField("route_error", routeErr.Error()).Error("Failed to forward event for retry")
+		if backoff > 10*time.Second {
+			backoff = 10 * time.Second
+		}
+		select {
+		case <-time.After(backoff):
 		case <-ctx.Done():
-			return
+			return false
+		}
+	}
+}
+
+func messageFromRecord(cm *sarama.ConsumerMessage) events.Message {
+	msg := events.Message{
+		Topic:   cm.Topic,
+		Key:     string(cm.Key),
+		Headers: make(map[string]string, len(cm.Headers)),
+		Value:   cm.Value,
+	}
+	for _, hdr := range cm.Headers {
+		if hdr == nil {
+			continue
 		}
+		if string(hdr.Key) == "event_type" {
+			msg.Type = string(hdr.Value)
+			continue
+		}
+		msg.Headers[string(hdr.Key)] = string(hdr.Value)
+	}
+	return msg
+}
+
+// LaneFor picks which of lanes handles messages with key, the same one for
+// every message with that key.
+func LaneFor(key []byte, lanes int) int {
+	if lanes == 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write(key)
+	return int(h.Sum32() % uint32(lanes))
+}
+
+// offsetTracker marks offsets only once every earlier message in the
+// partition has completed, so a crash never skips an unprocessed message
+// even though lanes finish out of order.
+type offsetTracker struct {
+	sess     sarama.ConsumerGroupSession
+	mu       sync.Mutex
+	inflight []*sarama.ConsumerMessage
+	finished map[int64]bool
+}
+
+func newOffsetTracker(sess sarama.ConsumerGroupSession) *offsetTracker {
+	return &offsetTracker{sess: sess, finished: make(map[int64]bool)}
+}
+
+func (t *offsetTracker) track(msg *sarama.ConsumerMessage) {
+	t.mu.Lock()
+	t.inflight = append(t.inflight, msg)
+	t.mu.Unlock()
+}
+
+func (t *offsetTracker) done(msg *sarama.ConsumerMessage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.finished[msg.Offset] = true
+	var last *sarama.ConsumerMessage
+	for len(t.inflight) > 0 && t.finished[t.inflight[0].Offset] {
+		last = t.inflight[0]
+		delete(t.finished, last.Offset)
+		t.inflight = t.inflight[1:]
+	}
+	if last != nil {
+		t.sess.MarkMessage(last, "")
 	}
 }
@@ -2,41 +2,51 @@ package infra
 
 import (
 	"context"
-	"encoding/json"
 
 	"github.com/IBM/sarama"
-	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/events"
 	"github.com/sirupsen/logrus"
 )
 
+// KafkaProducer publishes events to Kafka. The event type travels in the
+// event_type header so consumers can route without decoding the body.
 type KafkaProducer struct {
 	Producer sarama.SyncProducer
 }
 
-
-func (kp *KafkaProducer) PublishRewardCreated(ctx context.Context, event model.RewardCreatedEvent) error {
-	msgBytes, err := json.Marshal(event)
+func NewKafkaProducer(brokers []string) (*KafkaProducer, error) {
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	// Hash the key so all events for a user land on the same partition
+	config.Producer.Partitioner = sarama.NewHashPartitioner
+	producer, err := sarama.NewSyncProducer(brokers, config)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	return &KafkaProducer{Producer: producer}, nil
+}
+
+func (kp *KafkaProducer) Publish(ctx context.Context, event events.Message) error {
+	headers := []sarama.RecordHeader{{Key: []byte("event_type"), Value: []byte(event.Type)}}
+	for k, v := range event.Headers {
+		headers = append(headers, sarama.RecordHeader{Key: []byte(k), Value: []byte(v)})
 	}
-	correlationID := event.CorrelationID
 	msg := &sarama.ProducerMessage{
-		Topic: "reward-events",
-		Value: sarama.ByteEncoder(msgBytes),
-		Headers: []sarama.RecordHeader{{
-			Key:   []byte("correlation_id"),
-			Value: []byte(correlationID),
-		}},
+		Topic:   event.Topic,
+		Value:   sarama.ByteEncoder(event.Value),
+		Headers: headers,
+	}
+	if event.Key != "" {
+		msg.Key = sarama.StringEncoder(event.Key)
 	}
-	_, _, err = kp.Producer.SendMessage(msg)
+	_, _, err := kp.Producer.SendMessage(msg)
 	if err != nil {
-		logrus.WithError(err).Error("Failed to publish reward event")
+		logrus.WithError(err).WithField("topic", event.Topic).Error("Failed to publish event")
 	}
 	return err
 }
 
-// Noop fallback
-func NoopPublishRewardCreated(ctx context.Context, event model.RewardCreatedEvent) error {
-	logrus.Info("Noop publish: ", event)
-	return nil
+func (kp *KafkaProducer) Close() error {
+	return kp.Producer.Close()
 }
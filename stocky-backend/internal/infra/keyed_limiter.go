@@ -0,0 +1,109 @@
+package infra
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// KeyedLimiter and RedisKeyedLimiter implement service.PartnerLimiter. Both
+// are token buckets per key (e.g. per partner): Rate is tokens per second and
+// Burst the bucket size. A non-positive Rate disables limiting.
+
+// KeyedLimiter keeps its buckets in process, so each replica allows the full
+// rate; with N replicas the effective limit is N times Rate.
+type KeyedLimiter struct {
+	Rate  float64
+	Burst int
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewKeyedLimiter(rate float64, burst int) *KeyedLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &KeyedLimiter{Rate: rate, Burst: burst, buckets: make(map[string]*bucket)}
+}
+
+// Reserve takes a token for key if one is available and returns zero,
+// otherwise it takes nothing and returns how long until one will be.
+func (l *KeyedLimiter) Reserve(ctx context.Context, key string) (time.Duration, error) {
+	if l.Rate <= 0 {
+		return 0, nil
+	}
+	return l.reserve(key, time.Now()), nil
+}
+
+func (l *KeyedLimiter) reserve(key string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * l.Rate
+	if b.tokens > float64(l.Burst) {
+		b.tokens = float64(l.Burst)
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / l.Rate * float64(time.Second))
+}
+
+// RedisKeyedLimiter shares its buckets between replicas through Redis, so
+// Rate holds across the whole deployment. Each key is one string holding the
+// bucket's theoretical arrival time (GCRA), timed by the Redis server clock.
+type RedisKeyedLimiter struct {
+	Client *redis.Client
+	Prefix string
+	Rate   float64
+	Burst  int
+}
+
+// gcraScript admits a request if the bucket has room and returns 0, or
+// returns the microseconds until it will have room without taking any.
+var gcraScript = redis.NewScript(`
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local tat = tonumber(redis.call("GET", KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+local next_tat = tat + interval
+local wait = next_tat - now - burst * interval
+if wait > 0 then
+	return math.ceil(wait)
+end
+redis.call("SET", KEYS[1], string.format("%.0f", next_tat), "PX", math.ceil((next_tat - now) / 1000) + 1)
+return 0`)
+
+func (l *RedisKeyedLimiter) Reserve(ctx context.Context, key string) (time.Duration, error) {
+	if l.Rate <= 0 {
+		return 0, nil
+	}
+	burst := l.Burst
+	if burst < 1 {
+		burst = 1
+	}
+	interval := float64(time.Second/time.Microsecond) / l.Rate
+	wait, err := gcraScript.Run(ctx, l.Client, []string{l.Prefix + key}, interval, burst).Int64()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(wait) * time.Microsecond, nil
+}
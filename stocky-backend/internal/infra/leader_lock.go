@@ -0,0 +1,99 @@
+package infra
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisLeaderLock and AdvisoryLeaderLock implement service.LeaderLock.
+
+// RedisLeaderLock is a lease on Key that expires after TTL unless renewed.
+// Token identifies this replica so it never extends or deletes another
+// holder's lease.
+type RedisLeaderLock struct {
+	Client *redis.Client
+	Key    string
+	Token  string
+	TTL    time.Duration
+}
+
+// renewScript extends the lease only if we still hold it.
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+func (l *RedisLeaderLock) TryAcquire(ctx context.Context) (bool, error) {
+	ok, err := l.Client.SetNX(ctx, l.Key, l.Token, l.TTL).Result()
+	if err != nil || ok {
+		return ok, err
+	}
+	renewed, err := renewScript.Run(ctx, l.Client, []string{l.Key}, l.Token, l.TTL.Milliseconds()).Int()
+	return renewed == 1, err
+}
+
+func (l *RedisLeaderLock) Release(ctx context.Context) error {
+	return releaseScript.Run(ctx, l.Client, []string{l.Key}, l.Token).Err()
+}
+
+// AdvisoryLeaderLock holds a Postgres session-level advisory lock on a
+// dedicated connection. Leadership lasts as long as that connection, so a
+// crashed replica releases it automatically.
+type AdvisoryLeaderLock struct {
+	DB  *sql.DB
+	Key int64
+
+	mu   sync.Mutex
+	conn *sql.Conn
+}
+
+func (l *AdvisoryLeaderLock) TryAcquire(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn != nil {
+		// Still leader as long as the session that holds the lock is alive
+		if err := l.conn.PingContext(ctx); err == nil {
+			return true, nil
+		}
+		l.conn.Close()
+		l.conn = nil
+	}
+	conn, err := l.DB.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	var ok bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, l.Key).Scan(&ok); err != nil {
+		conn.Close()
+		return false, err
+	}
+	if !ok {
+		conn.Close()
+		return false, nil
+	}
+	l.conn = conn
+	return true, nil
+}
+
+func (l *AdvisoryLeaderLock) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn == nil {
+		return nil
+	}
+	defer func() { l.conn = nil }()
+	_, err := l.conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, l.Key)
+	return errors.Join(err, l.conn.Close())
+}
@@ -2,32 +2,73 @@ package infra
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math/rand"
 	"strings"
 	"time"
 
-	"database/sql"
-
+	"github.com/mhatrejeets/stocky-ms/internal/model"
 	"github.com/redis/go-redis/v9"
 	"github.com/shopspring/decimal"
 )
 
-
+// ErrMalformedCachedPrice is returned when a cached price cannot be decoded.
+var ErrMalformedCachedPrice = errors.New("malformed cached price")
 
 type MockPriceProvider struct {
 	Redis *redis.Client
 }
 
+// GetPrice serves the cached price, generating one only when the key is
+// missing. Redis and decode failures are returned rather than papered over.
 func (m *MockPriceProvider) GetPrice(ctx context.Context, symbol string) (decimal.Decimal, time.Time, error) {
 	price, updatedAt, err := GetCachedPrice(ctx, m.Redis, symbol)
 	if err == nil {
 		return price, updatedAt, nil
 	}
-	// Generate random price
+	if !errors.Is(err, redis.Nil) {
+		return decimal.Zero, time.Time{}, err
+	}
+	return m.generate(ctx, symbol)
+}
+
+// GetPrices reads cached prices with one MGET and generates the misses.
+func (m *MockPriceProvider) GetPrices(ctx context.Context, symbols []string) (map[string]model.Quote, error) {
+	quotes := make(map[string]model.Quote, len(symbols))
+	if len(symbols) == 0 {
+		return quotes, nil
+	}
+	keys := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		keys[i] = "price:" + symbol
+	}
+	cached, err := m.Redis.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, symbol := range symbols {
+		var price decimal.Decimal
+		var updatedAt time.Time
+		if raw, ok := cached[i].(string); ok {
+			if price, updatedAt, err = parseCachedPrice(raw); err != nil {
+				return nil, fmt.Errorf("%s: %w", symbol, err)
+			}
+		} else if price, updatedAt, err = m.generate(ctx, symbol); err != nil {
+			return nil, err
+		}
+		quotes[symbol] = model.Quote{Symbol: symbol, Price: price, AsOf: updatedAt}
+	}
+	return quotes, nil
+}
+
+// generate makes up a random price and caches it for two hours.
+func (m *MockPriceProvider) generate(ctx context.Context, symbol string) (decimal.Decimal, time.Time, error) {
 	val := decimal.NewFromFloat(rand.Float64()*1000 + 100)
 	updated := time.Now()
-	// Cache in Redis
-	m.Redis.Set(ctx, "price:"+symbol, val.String()+","+updated.Format(time.RFC3339), 2*time.Hour)
+	if err := SetCachedPrice(ctx, m.Redis, symbol, val, updated, 2*time.Hour); err != nil {
+		return decimal.Zero, time.Time{}, err
+	}
 	return val, updated, nil
 }
 
@@ -36,35 +77,28 @@ func GetCachedPrice(ctx context.Context, rdb *redis.Client, symbol string) (deci
 	if err != nil {
 		return decimal.Zero, time.Time{}, err
 	}
+	return parseCachedPrice(res)
+}
+
+// SetCachedPrice writes symbol's price in the price:<symbol> format
+// GetCachedPrice reads.
+func SetCachedPrice(ctx context.Context, rdb *redis.Client, symbol string, price decimal.Decimal, at time.Time, ttl time.Duration) error {
+	return rdb.Set(ctx, "price:"+symbol, price.String()+","+at.UTC().Format(time.RFC3339), ttl).Err()
+}
+
+// parseCachedPrice decodes "<price>,<RFC3339 time>".
+func parseCachedPrice(res string) (decimal.Decimal, time.Time, error) {
 	parts := strings.Split(res, ",")
 	if len(parts) != 2 {
-		return decimal.Zero, time.Time{}, err
+		return decimal.Zero, time.Time{}, fmt.Errorf("%w: %q", ErrMalformedCachedPrice, res)
 	}
 	price, err := decimal.NewFromString(parts[0])
 	if err != nil {
-		return decimal.Zero, time.Time{}, err
+		return decimal.Zero, time.Time{}, fmt.Errorf("%w: %v", ErrMalformedCachedPrice, err)
 	}
 	updatedAt, err := time.Parse(time.RFC3339, parts[1])
 	if err != nil {
-		return decimal.Zero, time.Time{}, err
+		return decimal.Zero, time.Time{}, fmt.Errorf("%w: %v", ErrMalformedCachedPrice, err)
 	}
 	return price, updatedAt, nil
 }
-
-func StartHourlyPriceUpdater(ctx context.Context, rdb *redis.Client, symbols []string) {
-	go func() {
-		for {
-			for _, symbol := range symbols {
-				val := decimal.NewFromFloat(rand.Float64()*1000 + 100)
-				updated := time.Now()
-				rdb.Set(ctx, "price:"+symbol, val.String()+","+updated.Format(time.RFC3339), 2*time.Hour)
-				// Update DB stock_prices table
-				if db, ok := ctx.Value("db").(*sql.DB); ok && db != nil {
-					_, _ = db.ExecContext(ctx, `INSERT INTO stock_prices (symbol, price, updated_at) VALUES ($1, $2, $3)
-						ON CONFLICT (symbol) DO UPDATE SET price = EXCLUDED.price, updated_at = EXCLUDED.updated_at`, symbol, val.String(), updated)
-				}
-			}
-			time.Sleep(time.Hour)
-		}
-	}()
-}
@@ -0,0 +1,248 @@
+package infra
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+// LogNotificationSender writes notifications to the log; useful until a
+// channel's real sender is configured.
+type LogNotificationSender struct{}
+
+func (LogNotificationSender) Send(ctx context.Context, n model.Notification) error {
+	logrus.WithFields(logrus.Fields{"notification_id": n.ID, "user_id": n.UserID, "channel": n.Channel, "kind": n.Kind, "subject": n.Subject}).Info(n.Body)
+	return nil
+}
+
+// SMTPNotificationSender mails notifications through the server at Addr,
+// upgrading to TLS when the server offers STARTTLS. Username enables PLAIN
+// auth, which net/smtp only allows over TLS or to localhost.
+type SMTPNotificationSender struct {
+	Addr     string // host:port
+	From     string // an address, optionally with a display name
+	Username string
+	Password string
+	Hostname string // sent in EHLO; "localhost" when empty
+}
+
+func (s *SMTPNotificationSender) Send(ctx context.Context, n model.Notification) error {
+	host, _, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return err
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if s.Hostname != "" {
+		if err := c.Hello(s.Hostname); err != nil {
+			return err
+		}
+	}
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			return err
+		}
+	}
+	from, err := mail.ParseAddress(s.From)
+	if err != nil {
+		return err
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return smtpError(err)
+	}
+	if err := c.Rcpt(n.Address); err != nil {
+		return smtpError(err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return smtpError(err)
+	}
+	if _, err := w.Write(s.message(n)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return smtpError(err)
+	}
+	return c.Quit()
+}
+
+// message builds a plain-text UTF-8 mail, quoted-printable so it survives
+// servers without 8BITMIME.
+func (s *SMTPNotificationSender) message(n model.Notification) []byte {
+	subject := strings.NewReplacer("\r", " ", "\n", " ").Replace(n.Subject)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", s.From)
+	fmt.Fprintf(&b, "To: %s\r\n", n.Address)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	if n.ID != "" {
+		fmt.Fprintf(&b, "Message-ID: <%s@stocky>\r\n", n.ID)
+	}
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	qp := quotedprintable.NewWriter(&b)
+	qp.Write([]byte(n.Body))
+	qp.Close()
+	return b.Bytes()
+}
+
+// smtpError marks permanent (5xx) replies, such as an unknown mailbox, as
+// undeliverable.
+func smtpError(err error) error {
+	var reply *textproto.Error
+	if errors.As(err, &reply) && reply.Code >= 500 {
+		return fmt.Errorf("%w: %v", model.ErrUndeliverable, err)
+	}
+	return err
+}
+
+// SMSGatewaySender posts {"to", "from", "message"} to an HTTP SMS gateway.
+type SMSGatewaySender struct {
+	URL      string
+	APIKey   string
+	SenderID string
+	Client   *http.Client
+}
+
+func (s *SMSGatewaySender) Send(ctx context.Context, n model.Notification) error {
+	return postNotification(ctx, s.Client, s.URL, s.APIKey, n.ID, map[string]string{
+		"to":      n.Address,
+		"from":    s.SenderID,
+		"message": n.Body,
+	})
+}
+
+// PushGatewaySender posts {"token", "title", "body", "data"} to an HTTP push
+// gateway that fans out to the device platforms.
+type PushGatewaySender struct {
+	URL    string
+	APIKey string
+	Client *http.Client
+}
+
+func (s *PushGatewaySender) Send(ctx context.Context, n model.Notification) error {
+	return postNotification(ctx, s.Client, s.URL, s.APIKey, n.ID, map[string]interface{}{
+		"token": n.Address,
+		"title": n.Subject,
+		"body":  n.Body,
+		"data":  map[string]string{"kind": n.Kind, "notification_id": n.ID},
+	})
+}
+
+// postNotification sends payload as JSON with a bearer apiKey. The
+// notification id goes in Idempotency-Key so gateways can drop a retry of a
+// send that did go through. 429 and 5xx responses are retried; other
+// non-2xx responses are undeliverable.
+func postNotification(ctx context.Context, client *http.Client, url, apiKey, id string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	if id != "" {
+		req.Header.Set("Idempotency-Key", id)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("notification gateway returned status %d", resp.StatusCode)
+	default:
+		return fmt.Errorf("%w: notification gateway returned status %d", model.ErrUndeliverable, resp.StatusCode)
+	}
+}
+
+// NewNotificationSendersFromEnv builds a sender for each channel not
+// switched off: NOTIFY_EMAIL is "smtp", "log" or "off", and NOTIFY_SMS and
+// NOTIFY_PUSH are "http", "log" or "off". All default to "log".
+func NewNotificationSendersFromEnv() (map[string]service.NotificationSender, error) {
+	client := &http.Client{Timeout: GetEnvDuration("NOTIFY_HTTP_TIMEOUT", 10*time.Second)}
+	senders := make(map[string]service.NotificationSender)
+	for _, channel := range []string{model.ChannelEmail, model.ChannelSMS, model.ChannelPush} {
+		env := "NOTIFY_" + strings.ToUpper(channel)
+		kind := GetEnv(env, "log")
+		var sender service.NotificationSender
+		switch {
+		case kind == "off":
+			continue
+		case kind == "log":
+			sender = LogNotificationSender{}
+		case kind == "smtp" && channel == model.ChannelEmail:
+			from := GetEnv("SMTP_FROM", "")
+			if _, err := mail.ParseAddress(from); err != nil {
+				return nil, fmt.Errorf("SMTP_FROM must be an email address for the smtp sender: %v", err)
+			}
+			sender = &SMTPNotificationSender{
+				Addr:     GetEnv("SMTP_ADDR", "localhost:1025"),
+				From:     from,
+				Username: GetEnv("SMTP_USERNAME", ""),
+				Password: GetEnv("SMTP_PASSWORD", ""),
+				Hostname: GetEnv("SMTP_HELO", ""),
+			}
+		case kind == "http" && channel == model.ChannelSMS:
+			url := GetEnv("SMS_GATEWAY_URL", "")
+			if url == "" {
+				return nil, fmt.Errorf("SMS_GATEWAY_URL is required for the http SMS sender")
+			}
+			sender = &SMSGatewaySender{URL: url, APIKey: GetEnv("SMS_GATEWAY_API_KEY", ""), SenderID: GetEnv("SMS_SENDER_ID", "STOCKY"), Client: client}
+		case kind == "http" && channel == model.ChannelPush:
+			url := GetEnv("PUSH_GATEWAY_URL", "")
+			if url == "" {
+				return nil, fmt.Errorf("PUSH_GATEWAY_URL is required for the http push sender")
+			}
+			sender = &PushGatewaySender{URL: url, APIKey: GetEnv("PUSH_GATEWAY_API_KEY", ""), Client: client}
+		default:
+			return nil, fmt.Errorf("unknown %s %q", env, kind)
+		}
+		senders[channel] = sender
+	}
+	return senders, nil
+}
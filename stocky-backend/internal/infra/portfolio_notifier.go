@@ -0,0 +1,32 @@
+package infra
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+// PortfolioChannel is the Redis pub/sub channel announcing holdings changes.
+const PortfolioChannel = "portfolios:updated"
+
+// RedisPortfolioNotifier fans holdings changes out to every replica so the
+// one holding a user's stream hears about rewards projected elsewhere.
+// Delivery is best effort, like RedisPriceNotifier.
+type RedisPortfolioNotifier struct {
+	Client *redis.Client
+}
+
+func (n *RedisPortfolioNotifier) NotifyPortfolio(ctx context.Context, change model.PortfolioChange) error {
+	payload, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	return n.Client.Publish(ctx, PortfolioChannel, payload).Err()
+}
+
+// Subscribe calls handler for every change announced until ctx is cancelled.
+func (n *RedisPortfolioNotifier) Subscribe(ctx context.Context, handler func(model.PortfolioChange)) error {
+	return subscribeJSON(ctx, n.Client, PortfolioChannel, handler)
+}
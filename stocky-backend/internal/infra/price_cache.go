@@ -0,0 +1,450 @@
+package infra
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+// Cache layers, as reported by PriceCacheLookups.
+const (
+	CacheLayerLocal    = "local"
+	CacheLayerShared   = "shared"
+	CacheLayerProvider = "provider"
+)
+
+// PriceCacheLookups counts lookups per layer and outcome (hit, stale, miss,
+// malformed, error, ok).
+var PriceCacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "stocky_price_cache_lookups_total",
+	Help: "Price cache lookups by layer and result.",
+}, []string{"layer", "result"})
+
+// CachedQuote is a quote plus when it was fetched from the provider, which is
+// what freshness is measured against (AsOf can be old for a closed market).
+type CachedQuote struct {
+	Quote     model.Quote `json:"quote"`
+	FetchedAt time.Time   `json:"fetched_at"`
+}
+
+// QuoteStore is the cache layer shared between replicas. Entries that fail
+// to decode are left out of the result and reported through an error
+// wrapping ErrMalformedCachedPrice; the rest are still returned.
+type QuoteStore interface {
+	GetQuotes(ctx context.Context, symbols []string) (map[string]CachedQuote, error)
+	SetQuotes(ctx context.Context, entries []CachedQuote, ttl time.Duration) error
+}
+
+// RedisQuoteStore keeps cached quotes as JSON under pricecache:<symbol>.
+type RedisQuoteStore struct {
+	Client *redis.Client
+}
+
+func quoteCacheKey(symbol string) string {
+	return "pricecache:" + symbol
+}
+
+func (s *RedisQuoteStore) GetQuotes(ctx context.Context, symbols []string) (map[string]CachedQuote, error) {
+	entries := make(map[string]CachedQuote, len(symbols))
+	if len(symbols) == 0 {
+		return entries, nil
+	}
+	keys := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		keys[i] = quoteCacheKey(symbol)
+	}
+	raw, err := s.Client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for i, symbol := range symbols {
+		value, ok := raw[i].(string)
+		if !ok {
+			continue
+		}
+		entry, err := decodeCachedQuote(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", symbol, err))
+			continue
+		}
+		entries[symbol] = entry
+	}
+	return entries, errors.Join(errs...)
+}
+
+func decodeCachedQuote(value string) (CachedQuote, error) {
+	var entry CachedQuote
+	if err := json.Unmarshal([]byte(value), &entry); err != nil {
+		return CachedQuote{}, fmt.Errorf("%w: %v", ErrMalformedCachedPrice, err)
+	}
+	if entry.Quote.Symbol == "" || entry.FetchedAt.IsZero() {
+		return CachedQuote{}, fmt.Errorf("%w: %q", ErrMalformedCachedPrice, value)
+	}
+	return entry, nil
+}
+
+func (s *RedisQuoteStore) SetQuotes(ctx context.Context, entries []CachedQuote, ttl time.Duration) error {
+	pipe := s.Client.Pipeline()
+	for _, entry := range entries {
+		payload, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		pipe.Set(ctx, quoteCacheKey(entry.Quote.Symbol), payload, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// CachedPriceProvider is a read-through cache in front of Provider: an
+// in-process LRU, then the Shared store, then the provider itself.
+//
+// Entries younger than FreshFor are served as is. Older ones are served
+// until MaxStale while a background refresh replaces them. Concurrent misses
+// for a symbol share one provider call, so an expiring hot symbol costs one
+// lookup rather than one per request. Degraded (fallback) quotes are passed
+// through but never cached.
+type CachedPriceProvider struct {
+	Provider       PriceProvider
+	Shared         QuoteStore
+	FreshFor       time.Duration
+	MaxStale       time.Duration
+	RefreshTimeout time.Duration
+	Now            func() time.Time
+
+	local *quoteLRU
+
+	mu       sync.Mutex
+	inflight map[string]*quoteFetch
+}
+
+// quoteFetch is one in-flight provider lookup that other callers can wait on.
+type quoteFetch struct {
+	done  chan struct{}
+	quote model.Quote
+	ok    bool
+	err   error
+}
+
+func NewCachedPriceProvider(provider PriceProvider, shared QuoteStore, size int, freshFor, maxStale time.Duration) *CachedPriceProvider {
+	if maxStale < freshFor {
+		maxStale = freshFor
+	}
+	return &CachedPriceProvider{
+		Provider:       provider,
+		Shared:         shared,
+		FreshFor:       freshFor,
+		MaxStale:       maxStale,
+		RefreshTimeout: 10 * time.Second,
+		local:          newQuoteLRU(size),
+		inflight:       make(map[string]*quoteFetch),
+	}
+}
+
+// NewCachedPriceProviderFromEnv wraps provider using the PRICE_CACHE_* settings.
+func NewCachedPriceProviderFromEnv(provider PriceProvider, rdb *redis.Client) *CachedPriceProvider {
+	c := NewCachedPriceProvider(provider, &RedisQuoteStore{Client: rdb},
+		GetEnvInt("PRICE_CACHE_SIZE", 10000),
+		GetEnvDuration("PRICE_CACHE_FRESH", time.Minute),
+		GetEnvDuration("PRICE_CACHE_MAX_STALE", 15*time.Minute))
+	c.RefreshTimeout = GetEnvDuration("PRICE_CACHE_REFRESH_TIMEOUT", 10*time.Second)
+	return c
+}
+
+func (c *CachedPriceProvider) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+func (c *CachedPriceProvider) GetPrice(ctx context.Context, symbol string) (decimal.Decimal, time.Time, error) {
+	quotes, err := c.GetPrices(ctx, []string{symbol})
+	if err != nil {
+		return decimal.Zero, time.Time{}, err
+	}
+	q, ok := quotes[symbol]
+	if !ok {
+		return decimal.Zero, time.Time{}, fmt.Errorf("%s: %w", symbol, ErrPriceUnavailable)
+	}
+	return q.Price, q.AsOf, nil
+}
+
+func (c *CachedPriceProvider) GetPrices(ctx context.Context, symbols []string) (map[string]model.Quote, error) {
+	quotes := make(map[string]model.Quote, len(symbols))
+	var missing, stale []string
+	seen := make(map[string]bool, len(symbols))
+	now := c.now()
+	for _, symbol := range symbols {
+		if seen[symbol] {
+			continue
+		}
+		seen[symbol] = true
+		entry, ok := c.local.get(symbol)
+		if !ok {
+			PriceCacheLookups.WithLabelValues(CacheLayerLocal, "miss").Inc()
+			missing = append(missing, symbol)
+			continue
+		}
+		switch c.age(entry, now) {
+		case "hit":
+			PriceCacheLookups.WithLabelValues(CacheLayerLocal, "hit").Inc()
+			quotes[symbol] = entry.Quote
+		case "stale":
+			PriceCacheLookups.WithLabelValues(CacheLayerLocal, "stale").Inc()
+			quotes[symbol] = entry.Quote
+			stale = append(stale, symbol)
+		default:
+			PriceCacheLookups.WithLabelValues(CacheLayerLocal, "miss").Inc()
+			c.local.remove(symbol)
+			missing = append(missing, symbol)
+		}
+	}
+
+	if len(missing) > 0 && c.Shared != nil {
+		missing, stale = c.readShared(ctx, missing, stale, quotes, now)
+	}
+	if len(stale) > 0 {
+		c.refresh(ctx, stale)
+	}
+	if len(missing) == 0 {
+		return quotes, nil
+	}
+	loaded, err := c.load(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	for symbol, q := range loaded {
+		quotes[symbol] = q
+	}
+	return quotes, nil
+}
+
+// readShared fills quotes from the shared store, promoting what it finds
+// into the LRU, and returns the symbols still missing and those now stale.
+func (c *CachedPriceProvider) readShared(ctx context.Context, missing, stale []string, quotes map[string]model.Quote, now time.Time) ([]string, []string) {
+	entries, err := c.Shared.GetQuotes(ctx, missing)
+	if err != nil {
+		if errors.Is(err, ErrMalformedCachedPrice) {
+			PriceCacheLookups.WithLabelValues(CacheLayerShared, "malformed").Inc()
+			logrus.WithError(err).Error("Malformed entries in shared price cache, reloading from provider")
+		} else {
+			PriceCacheLookups.WithLabelValues(CacheLayerShared, "error").Inc()
+			logrus.WithError(err).Warn("Shared price cache unavailable, reading through to provider")
+		}
+	}
+	var rest []string
+	for _, symbol := range missing {
+		entry, ok := entries[symbol]
+		if !ok {
+			PriceCacheLookups.WithLabelValues(CacheLayerShared, "miss").Inc()
+			rest = append(rest, symbol)
+			continue
+		}
+		switch c.age(entry, now) {
+		case "hit":
+			PriceCacheLookups.WithLabelValues(CacheLayerShared, "hit").Inc()
+		case "stale":
+			PriceCacheLookups.WithLabelValues(CacheLayerShared, "stale").Inc()
+			stale = append(stale, symbol)
+		default:
+			PriceCacheLookups.WithLabelValues(CacheLayerShared, "miss").Inc()
+			rest = append(rest, symbol)
+			continue
+		}
+		c.local.put(entry)
+		quotes[symbol] = entry.Quote
+	}
+	return rest, stale
+}
+
+// age classifies an entry as "hit" (fresh), "stale" (servable while it is
+// refreshed) or "expired".
+func (c *CachedPriceProvider) age(entry CachedQuote, now time.Time) string {
+	switch age := now.Sub(entry.FetchedAt); {
+	case age <= c.FreshFor:
+		return "hit"
+	case age <= c.MaxStale:
+		return "stale"
+	default:
+		return "expired"
+	}
+}
+
+// load waits for symbols from the provider, joining lookups already in
+// flight and starting one for the rest. The lookup itself is detached from
+// ctx so a caller giving up does not fail everyone waiting on it.
+func (c *CachedPriceProvider) load(ctx context.Context, symbols []string) (map[string]model.Quote, error) {
+	lead, fetches := c.claim(symbols)
+	if len(lead) > 0 {
+		go c.fetch(ctx, lead, fetches)
+	}
+	quotes := make(map[string]model.Quote, len(symbols))
+	for symbol, f := range fetches {
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if f.err != nil {
+			return nil, f.err
+		}
+		if f.ok {
+			quotes[symbol] = f.quote
+		}
+	}
+	return quotes, nil
+}
+
+// refresh re-fetches stale symbols in the background, skipping any that are
+// already being fetched.
+func (c *CachedPriceProvider) refresh(ctx context.Context, symbols []string) {
+	lead, fetches := c.claim(symbols)
+	if len(lead) > 0 {
+		go c.fetch(ctx, lead, fetches)
+	}
+}
+
+// claim returns the in-flight lookup for every symbol, registering new ones
+// for the symbols returned in lead, which the caller must fetch.
+func (c *CachedPriceProvider) claim(symbols []string) ([]string, map[string]*quoteFetch) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var lead []string
+	fetches := make(map[string]*quoteFetch, len(symbols))
+	for _, symbol := range symbols {
+		f, ok := c.inflight[symbol]
+		if !ok {
+			f = &quoteFetch{done: make(chan struct{})}
+			c.inflight[symbol] = f
+			lead = append(lead, symbol)
+		}
+		fetches[symbol] = f
+	}
+	return lead, fetches
+}
+
+// fetch asks the provider for lead, caches the results in both layers and
+// releases everyone waiting on them.
+func (c *CachedPriceProvider) fetch(parent context.Context, lead []string, fetches map[string]*quoteFetch) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(parent), c.RefreshTimeout)
+	defer cancel()
+	quotes, err := c.Provider.GetPrices(ctx, lead)
+	if err != nil {
+		PriceCacheLookups.WithLabelValues(CacheLayerProvider, "error").Inc()
+	} else {
+		PriceCacheLookups.WithLabelValues(CacheLayerProvider, "ok").Inc()
+		now := c.now()
+		var entries []CachedQuote
+		for _, symbol := range lead {
+			q, ok := quotes[symbol]
+			if !ok || q.Degraded {
+				continue
+			}
+			entry := CachedQuote{Quote: q, FetchedAt: now}
+			c.local.put(entry)
+			entries = append(entries, entry)
+		}
+		if len(entries) > 0 && c.Shared != nil {
+			if err := c.Shared.SetQuotes(ctx, entries, c.MaxStale); err != nil {
+				logrus.WithError(err).Warn("Failed to write shared price cache")
+			}
+		}
+	}
+
+	c.mu.Lock()
+	for _, symbol := range lead {
+		f := fetches[symbol]
+		f.quote, f.ok = quotes[symbol]
+		f.err = err
+		delete(c.inflight, symbol)
+	}
+	c.mu.Unlock()
+	for _, symbol := range lead {
+		close(fetches[symbol].done)
+	}
+}
+
+// Observe takes a price announced by another replica (see
+// RedisPriceNotifier) into the LRU when it is newer than the cached one.
+// Symbols not already cached are ignored.
+func (c *CachedPriceProvider) Observe(q model.Quote) {
+	c.local.update(q.Symbol, func(entry *CachedQuote) {
+		if q.AsOf.After(entry.Quote.AsOf) {
+			*entry = CachedQuote{Quote: q, FetchedAt: c.now()}
+		}
+	})
+}
+
+// quoteLRU is a size-bounded, least-recently-used set of cached quotes.
+type quoteLRU struct {
+	size int
+
+	mu    sync.Mutex
+	order *list.List
+	items map[string]*list.Element
+}
+
+func newQuoteLRU(size int) *quoteLRU {
+	if size < 1 {
+		size = 1
+	}
+	return &quoteLRU{size: size, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (l *quoteLRU) get(symbol string) (CachedQuote, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	el, ok := l.items[symbol]
+	if !ok {
+		return CachedQuote{}, false
+	}
+	l.order.MoveToFront(el)
+	return el.Value.(CachedQuote), true
+}
+
+func (l *quoteLRU) put(entry CachedQuote) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.items[entry.Quote.Symbol]; ok {
+		el.Value = entry
+		l.order.MoveToFront(el)
+		return
+	}
+	l.items[entry.Quote.Symbol] = l.order.PushFront(entry)
+	for l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(CachedQuote).Quote.Symbol)
+	}
+}
+
+func (l *quoteLRU) update(symbol string, fn func(*CachedQuote)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.items[symbol]; ok {
+		entry := el.Value.(CachedQuote)
+		fn(&entry)
+		el.Value = entry
+	}
+}
+
+func (l *quoteLRU) remove(symbol string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.items[symbol]; ok {
+		l.order.Remove(el)
+		delete(l.items, symbol)
+	}
+}
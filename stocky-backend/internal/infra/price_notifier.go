@@ -0,0 +1,71 @@
+package infra
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// PriceChannel is the Redis pub/sub channel announcing applied prices.
+const PriceChannel = "prices:updated"
+
+// RedisPriceCache keeps the price:<symbol> keys in step with ingested ticks.
+type RedisPriceCache struct {
+	Client *redis.Client
+	TTL    time.Duration
+}
+
+func (c *RedisPriceCache) SetQuote(ctx context.Context, q model.Quote) error {
+	return SetCachedPrice(ctx, c.Client, q.Symbol, q.Price, q.AsOf, c.TTL)
+}
+
+// RedisPriceNotifier fans applied prices out to every replica over Redis
+// pub/sub. Delivery is best effort: a replica that is down misses updates
+// and should treat its caches as cold when it comes back.
+type RedisPriceNotifier struct {
+	Client *redis.Client
+}
+
+func (n *RedisPriceNotifier) NotifyPrice(ctx context.Context, q model.Quote) error {
+	payload, err := json.Marshal(q)
+	if err != nil {
+		return err
+	}
+	return n.Client.Publish(ctx, PriceChannel, payload).Err()
+}
+
+// Subscribe calls handler for every price announced until ctx is cancelled.
+func (n *RedisPriceNotifier) Subscribe(ctx context.Context, handler func(model.Quote)) error {
+	return subscribeJSON(ctx, n.Client, PriceChannel, handler)
+}
+
+// subscribeJSON decodes every message on channel into T and calls handler
+// until ctx is cancelled. Messages that fail to decode are logged and skipped.
+func subscribeJSON[T any](ctx context.Context, client *redis.Client, channel string, handler func(T)) error {
+	sub := client.Subscribe(ctx, channel)
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		return err
+	}
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case m, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			var v T
+			if err := json.Unmarshal([]byte(m.Payload), &v); err != nil {
+				logrus.WithError(err).WithField("channel", channel).Warn("Ignoring malformed notification")
+				continue
+			}
+			handler(v)
+		}
+	}
+}
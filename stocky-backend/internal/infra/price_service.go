@@ -4,9 +4,13 @@ import (
 	"context"
 	"time"
 
+	"github.com/mhatrejeets/stocky-ms/internal/model"
 	"github.com/shopspring/decimal"
 )
 
 type PriceProvider interface {
 	GetPrice(ctx context.Context, symbol string) (decimal.Decimal, time.Time, error)
+	// GetPrices looks up many symbols in one round trip. Symbols without a
+	// price are omitted from the result rather than reported as errors.
+	GetPrices(ctx context.Context, symbols []string) (map[string]model.Quote, error)
 }
@@ -0,0 +1,351 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/shopspring/decimal"
+)
+
+// SourceSimulator names quotes produced by PriceSimulator.
+const SourceSimulator = "sim"
+
+// Clock tells the simulator what time it is.
+type Clock interface {
+	Now() time.Time
+}
+
+// VirtualClock is a manually advanced Clock for tests and demos.
+type VirtualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewVirtualClock(start time.Time) *VirtualClock { return &VirtualClock{now: start} }
+
+func (c *VirtualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *VirtualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// ScaledClock runs Speed times faster than wall time from Origin, so a
+// local run can cover days of simulated prices in minutes. A non-positive
+// Speed runs at wall-clock speed.
+type ScaledClock struct {
+	Origin time.Time
+	Speed  float64
+	start  time.Time
+}
+
+func NewScaledClock(origin time.Time, speed float64) *ScaledClock {
+	return &ScaledClock{Origin: origin, Speed: speed, start: time.Now()}
+}
+
+func (c *ScaledClock) Now() time.Time {
+	return c.Origin.Add(time.Duration(float64(time.Since(c.start)) * c.speed()))
+}
+
+// Wall maps a simulated time back to the wall-clock time it was reached.
+func (c *ScaledClock) Wall(t time.Time) time.Time {
+	return c.start.Add(time.Duration(float64(t.Sub(c.Origin)) / c.speed()))
+}
+
+func (c *ScaledClock) speed() float64 {
+	if c.Speed > 0 {
+		return c.Speed
+	}
+	return 1
+}
+
+// SimSymbol configures one symbol's walk. Volatility and Drift are
+// annualized; zero values fall back to the simulator defaults.
+type SimSymbol struct {
+	Start      float64
+	Volatility float64
+	Drift      float64
+}
+
+// SimGap freezes a symbol's quote (all symbols when Symbol is empty) from
+// From until To, as if the feed stopped updating.
+type SimGap struct {
+	Symbol   string
+	From, To time.Time
+}
+
+// SimSpike multiplies a symbol's price by Factor for Duration from At, to
+// imitate a bad tick.
+type SimSpike struct {
+	Symbol   string
+	At       time.Time
+	Duration time.Duration
+	Factor   float64
+}
+
+// PriceSimulator is a deterministic PriceProvider. Each symbol follows a
+// geometric Brownian motion sampled every Step from Epoch; the shock for a
+// given step depends only on Seed, the symbol and the step number, so the
+// same clock reading always yields the same price regardless of query order.
+// A non-positive Step falls back to DefaultSimStep. Walks are memoized for
+// the MaxWalks (default DefaultSimMaxWalks) most recently quoted symbols;
+// an evicted walk is recomputed identically on its next quote.
+type PriceSimulator struct {
+	Seed       int64
+	Epoch      time.Time
+	Step       time.Duration
+	Clock      Clock
+	Symbols    map[string]SimSymbol
+	Volatility float64
+	Gaps       []SimGap
+	Spikes     []SimSpike
+	MaxWalks   int
+
+	mu    sync.Mutex
+	walks map[string]*simWalk
+	uses  uint64
+}
+
+// simWalk is one symbol's memoized path of cumulative log returns by step.
+type simWalk struct {
+	path    []float64
+	lastUse uint64
+}
+
+const (
+	DefaultSimStep     = time.Minute
+	DefaultSimMaxWalks = 1024
+)
+
+// NewPriceSimulatorFromEnv configures a simulator from SIM_SEED, SIM_EPOCH
+// (RFC 3339, default today's midnight UTC), SIM_STEP, SIM_VOLATILITY,
+// SIM_SPEED and SIM_PRICES ("TCS=3500,INFY=1500:0.2"). SIM_STEP and
+// SIM_SPEED must be positive and SIM_VOLATILITY must not be negative.
+func NewPriceSimulatorFromEnv() (*PriceSimulator, error) {
+	symbols, err := ParseSimPrices(GetEnv("SIM_PRICES", ""))
+	if err != nil {
+		return nil, err
+	}
+	epoch := time.Now().UTC().Truncate(24 * time.Hour)
+	if v := GetEnv("SIM_EPOCH", ""); v != "" {
+		if epoch, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, fmt.Errorf("SIM_EPOCH: %w", err)
+		}
+	}
+	step := GetEnvDuration("SIM_STEP", DefaultSimStep)
+	if step <= 0 {
+		return nil, fmt.Errorf("SIM_STEP: must be positive, got %s", step)
+	}
+	speed := GetEnvFloat("SIM_SPEED", 1)
+	if speed <= 0 || math.IsInf(speed, 0) || math.IsNaN(speed) {
+		return nil, fmt.Errorf("SIM_SPEED: must be positive, got %v", speed)
+	}
+	volatility := GetEnvFloat("SIM_VOLATILITY", 0.3)
+	if volatility < 0 || math.IsNaN(volatility) {
+		return nil, fmt.Errorf("SIM_VOLATILITY: must not be negative, got %v", volatility)
+	}
+	return &PriceSimulator{
+		Seed:       int64(GetEnvInt("SIM_SEED", 42)),
+		Epoch:      epoch,
+		Step:       step,
+		Clock:      NewScaledClock(time.Now(), speed),
+		Symbols:    symbols,
+		Volatility: volatility,
+	}, nil
+}
+
+// ParseSimPrices parses "SYMBOL=start[:volatility]" pairs separated by commas.
+func ParseSimPrices(spec string) (map[string]SimSymbol, error) {
+	out := make(map[string]SimSymbol)
+	for _, part := range strings.Split(spec, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("sim price %q: want SYMBOL=price", part)
+		}
+		var cfg SimSymbol
+		start, vol, hasVol := strings.Cut(value, ":")
+		var err error
+		if cfg.Start, err = strconv.ParseFloat(start, 64); err != nil || cfg.Start <= 0 {
+			return nil, fmt.Errorf("sim price %q: invalid start price", part)
+		}
+		if hasVol {
+			if cfg.Volatility, err = strconv.ParseFloat(vol, 64); err != nil || cfg.Volatility < 0 {
+				return nil, fmt.Errorf("sim price %q: invalid volatility", part)
+			}
+		}
+		out[strings.ToUpper(strings.TrimSpace(name))] = cfg
+	}
+	return out, nil
+}
+
+func (s *PriceSimulator) now() time.Time {
+	if s.Clock != nil {
+		return s.Clock.Now()
+	}
+	return time.Now()
+}
+
+func (s *PriceSimulator) GetPrice(ctx context.Context, symbol string) (decimal.Decimal, time.Time, error) {
+	q := s.current(symbol, s.now())
+	return q.Price, q.AsOf, nil
+}
+
+func (s *PriceSimulator) GetPrices(ctx context.Context, symbols []string) (map[string]model.Quote, error) {
+	now := s.now()
+	quotes := make(map[string]model.Quote, len(symbols))
+	for _, symbol := range symbols {
+		quotes[symbol] = s.current(symbol, now)
+	}
+	return quotes, nil
+}
+
+// current is QuoteAt with AsOf reported in wall-clock time when the clock
+// runs fast, so fast-forwarded quotes never look like they're from the future.
+func (s *PriceSimulator) current(symbol string, now time.Time) model.Quote {
+	q := s.QuoteAt(symbol, now)
+	if wall, ok := s.Clock.(interface{ Wall(time.Time) time.Time }); ok {
+		q.AsOf = wall.Wall(q.AsOf)
+	}
+	return q
+}
+
+// QuoteAt returns the simulated quote for symbol as of t, honouring gaps and
+// spikes. Times before Epoch return the starting price.
+func (s *PriceSimulator) QuoteAt(symbol string, t time.Time) model.Quote {
+	for _, g := range s.Gaps {
+		if (g.Symbol == "" || g.Symbol == symbol) && !t.Before(g.From) && t.Before(g.To) {
+			t = g.From
+		}
+	}
+	size := s.step()
+	step := int(0)
+	if t.After(s.Epoch) {
+		step = int(t.Sub(s.Epoch) / size)
+	}
+	cfg := s.config(symbol)
+	price := cfg.Start * math.Exp(s.walk(symbol, cfg, step))
+	asOf := s.Epoch.Add(time.Duration(step) * size)
+	for _, sp := range s.Spikes {
+		if sp.Symbol == symbol && !asOf.Before(sp.At) && asOf.Before(sp.At.Add(sp.Duration)) {
+			price *= sp.Factor
+		}
+	}
+	return model.Quote{
+		Symbol: symbol,
+		Price:  decimal.NewFromFloat(price).Round(2),
+		AsOf:   asOf,
+		Source: SourceSimulator,
+	}
+}
+
+func (s *PriceSimulator) step() time.Duration {
+	if s.Step > 0 {
+		return s.Step
+	}
+	return DefaultSimStep
+}
+
+// config returns the symbol's settings, deriving a stable start price in
+// [100, 1100) for symbols that were not configured.
+func (s *PriceSimulator) config(symbol string) SimSymbol {
+	cfg, ok := s.Symbols[symbol]
+	if !ok {
+		cfg.Start = 100 + float64(s.symbolKey(symbol)%100000)/100
+	}
+	if cfg.Volatility == 0 {
+		cfg.Volatility = s.Volatility
+	}
+	return cfg
+}
+
+// walk returns the cumulative log return after step steps, extending the
+// memoized path as needed and evicting the least recently used walk once
+// more than MaxWalks symbols are memoized.
+func (s *PriceSimulator) walk(symbol string, cfg SimSymbol, step int) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.walks == nil {
+		s.walks = make(map[string]*simWalk)
+	}
+	w, ok := s.walks[symbol]
+	if !ok {
+		s.evictWalks()
+		w = &simWalk{path: []float64{0}}
+		s.walks[symbol] = w
+	}
+	s.uses++
+	w.lastUse = s.uses
+	dt := s.step().Hours() / (365 * 24)
+	key := s.symbolKey(symbol)
+	for i := len(w.path); i <= step; i++ {
+		shock := (cfg.Drift-cfg.Volatility*cfg.Volatility/2)*dt + cfg.Volatility*math.Sqrt(dt)*normal(key, i)
+		w.path = append(w.path, w.path[i-1]+shock)
+	}
+	return w.path[step]
+}
+
+// evictWalks drops least recently used walks until one more fits under
+// MaxWalks. Callers hold s.mu.
+func (s *PriceSimulator) evictWalks() {
+	limit := s.MaxWalks
+	if limit <= 0 {
+		limit = DefaultSimMaxWalks
+	}
+	for len(s.walks) >= limit {
+		oldest, oldestUse := "", uint64(math.MaxUint64)
+		for symbol, w := range s.walks {
+			if w.lastUse < oldestUse {
+				oldest, oldestUse = symbol, w.lastUse
+			}
+		}
+		delete(s.walks, oldest)
+	}
+}
+
+// Walks reports how many symbol walks are memoized.
+func (s *PriceSimulator) Walks() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.walks)
+}
+
+// normal draws a standard normal for step with Box-Muller over two uniforms
+// derived from key.
+func normal(key uint64, step int) float64 {
+	u1 := (float64(mix(key+uint64(2*step)*0x9e3779b97f4a7c15)>>11) + 0.5) / (1 << 53)
+	u2 := (float64(mix(key+uint64(2*step+1)*0x9e3779b97f4a7c15)>>11) + 0.5) / (1 << 53)
+	return math.Sqrt(-2*math.Log(u1)) * math.Cos(2*math.Pi*u2)
+}
+
+// symbolKey hashes the seed and symbol into the base of its random stream.
+func (s *PriceSimulator) symbolKey(symbol string) uint64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d/%s", s.Seed, symbol)
+	return mix(h.Sum64())
+}
+
+// mix is the splitmix64 finalizer.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,159 @@
+package market
+
+import (
+	"embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+//go:embed holidays.csv
+var holidayFS embed.FS
+
+const dateLayout = "2006-01-02"
+
+// Holidays maps exchange to ISO date to the holiday's description.
+type Holidays map[string]map[string]string
+
+// LoadHolidays reads a holiday file with exchange,date,description rows.
+// Blank lines and lines starting with # are ignored.
+func LoadHolidays(r io.Reader) (Holidays, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	out := make(Holidays)
+	for line := 1; ; line++ {
+		rec, err := reader.Read()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rec) < 2 || strings.EqualFold(rec[0], "exchange") {
+			continue
+		}
+		exchange := strings.ToUpper(strings.TrimSpace(rec[0]))
+		date, err := time.Parse(dateLayout, strings.TrimSpace(rec[1]))
+		if err != nil {
+			return nil, fmt.Errorf("holiday %d: %w", line, err)
+		}
+		if out[exchange] == nil {
+			out[exchange] = make(map[string]string)
+		}
+		desc := ""
+		if len(rec) > 2 {
+			desc = strings.TrimSpace(rec[2])
+		}
+		out[exchange][date.Format(dateLayout)] = desc
+	}
+}
+
+// LoadHolidayFile reads path, or the bundled NSE/BSE list when path is empty.
+func LoadHolidayFile(path string) (Holidays, error) {
+	var r io.ReadCloser
+	var err error
+	if path == "" {
+		r, err = holidayFS.Open("holidays.csv")
+	} else {
+		r, err = os.Open(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return LoadHolidays(r)
+}
+
+// Calendar answers trading-day questions for one exchange: sessions run
+// Monday to Friday in Hours, except on listed holidays.
+type Calendar struct {
+	Exchange string
+	Hours    Hours
+	holidays map[string]string
+}
+
+func NewCalendar(exchange string, hours Hours, holidays Holidays) *Calendar {
+	exchange = strings.ToUpper(exchange)
+	return &Calendar{Exchange: exchange, Hours: hours, holidays: holidays[exchange]}
+}
+
+// ExchangeCalendars returns a calendar for every exchange ExchangeHours
+// knows, each with its own holidays.
+func ExchangeCalendars(holidays Holidays) map[string]*Calendar {
+	calendars := make(map[string]*Calendar)
+	for _, exchange := range []string{"NSE", "BSE", "NYSE", "NASDAQ"} {
+		hours, _ := ExchangeHours(exchange)
+		calendars[exchange] = NewCalendar(exchange, hours, holidays)
+	}
+	return calendars
+}
+
+// Holiday returns the description of the holiday on t's local date, if any.
+func (c *Calendar) Holiday(t time.Time) (string, bool) {
+	desc, ok := c.holidays[t.In(c.Hours.Location).Format(dateLayout)]
+	return desc, ok
+}
+
+// IsTradingDay reports whether the local date of t has a session.
+func (c *Calendar) IsTradingDay(t time.Time) bool {
+	wd := t.In(c.Hours.Location).Weekday()
+	if wd == time.Saturday || wd == time.Sunday {
+		return false
+	}
+	_, holiday := c.Holiday(t)
+	return !holiday
+}
+
+// IsOpen reports whether t falls inside a trading session.
+func (c *Calendar) IsOpen(t time.Time) bool {
+	if !c.IsTradingDay(t) {
+		return false
+	}
+	local := t.In(c.Hours.Location)
+	since := local.Sub(midnight(local))
+	return since >= c.Hours.Open && since < c.Hours.Close
+}
+
+// LastClose returns the most recent session close at or before t.
+func (c *Calendar) LastClose(t time.Time) time.Time {
+	day := midnight(t.In(c.Hours.Location))
+	// No exchange closes for more than a few consecutive days
+	for i := 0; i < 31; i++ {
+		if c.IsTradingDay(day) {
+			if close := day.Add(c.Hours.Close); !close.After(t) {
+				return close
+			}
+		}
+		day = day.AddDate(0, 0, -1)
+	}
+	return time.Time{}
+}
+
+// PreviousCloseDate returns the trading date, at local midnight, whose close
+// is the last one at or before t.
+func (c *Calendar) PreviousCloseDate(t time.Time) time.Time {
+	close := c.LastClose(t)
+	if close.IsZero() {
+		return close
+	}
+	return midnight(close)
+}
+
+// NextOpen returns the first session open after t.
+func (c *Calendar) NextOpen(t time.Time) time.Time {
+	day := midnight(t.In(c.Hours.Location))
+	for i := 0; i < 31; i++ {
+		if c.IsTradingDay(day) {
+			if open := day.Add(c.Hours.Open); open.After(t) {
+				return open
+			}
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return time.Time{}
+}
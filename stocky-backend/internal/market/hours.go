@@ -0,0 +1,75 @@
+// Package market models exchange trading hours, the holiday calendar and the
+// staleness rules that depend on them.
+package market
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // exchange time zones must resolve in minimal containers
+)
+
+// Hours is a daily trading session in the exchange's time zone. Open and
+// Close are offsets from local midnight.
+type Hours struct {
+	Location *time.Location
+	Open     time.Duration
+	Close    time.Duration
+}
+
+// NSE is the regular National Stock Exchange session, 09:15-15:30 IST.
+func NSE() Hours {
+	loc, _ := time.LoadLocation("Asia/Kolkata")
+	return Hours{Location: loc, Open: 9*time.Hour + 15*time.Minute, Close: 15*time.Hour + 30*time.Minute}
+}
+
+// USRegular is the regular NYSE and NASDAQ session, 09:30-16:00 New York
+// time.
+func USRegular() Hours {
+	loc, _ := time.LoadLocation("America/New_York")
+	return Hours{Location: loc, Open: 9*time.Hour + 30*time.Minute, Close: 16 * time.Hour}
+}
+
+// ExchangeHours returns the regular session of an exchange instruments may
+// be listed on.
+func ExchangeHours(exchange string) (Hours, bool) {
+	switch strings.ToUpper(exchange) {
+	case "NSE", "BSE":
+		return NSE(), true
+	case "NYSE", "NASDAQ":
+		return USRegular(), true
+	}
+	return Hours{}, false
+}
+
+// ParseHours builds a session from an IANA time zone and HH:MM open and
+// close times.
+func ParseHours(tz, open, close string) (Hours, error) {
+	var h Hours
+	var err error
+	if h.Location, err = time.LoadLocation(tz); err != nil {
+		return h, fmt.Errorf("time zone: %w", err)
+	}
+	if h.Open, err = parseClock(open); err != nil {
+		return h, fmt.Errorf("open: %w", err)
+	}
+	if h.Close, err = parseClock(close); err != nil {
+		return h, fmt.Errorf("close: %w", err)
+	}
+	if h.Close <= h.Open {
+		return h, fmt.Errorf("market close %s is not after open %s", h.Close, h.Open)
+	}
+	return h, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,60 @@
+package market
+
+import (
+	"strings"
+	"time"
+)
+
+// StalenessPolicy decides whether a price is too old to trust. While the
+// market is open a price is stale once it is older than MaxAge. Outside
+// trading hours, weekends and holidays included, prices don't move, so a
+// price is only stale if it predates the last close by more than MaxAge.
+// Each price is judged by the calendar of the exchange it is listed on.
+type StalenessPolicy struct {
+	// Calendar is the default exchange's, used for prices whose exchange is
+	// unknown or has no entry in Exchanges
+	Calendar  *Calendar
+	Exchanges map[string]*Calendar
+	MaxAge    time.Duration
+	Now       func() time.Time
+}
+
+// CalendarFor returns the calendar of exchange. The default Calendar wins
+// for its own exchange, so its configured hours apply there.
+func (p *StalenessPolicy) CalendarFor(exchange string) *Calendar {
+	exchange = strings.ToUpper(exchange)
+	if exchange == "" || p.Calendar.Exchange == exchange {
+		return p.Calendar
+	}
+	if cal, ok := p.Exchanges[exchange]; ok {
+		return cal
+	}
+	return p.Calendar
+}
+
+func (p *StalenessPolicy) now() time.Time {
+	if p.Now != nil {
+		return p.Now()
+	}
+	return time.Now()
+}
+
+// IsStale reports whether a price as of asOf is stale right now on the
+// default calendar. A zero asOf is always stale.
+func (p *StalenessPolicy) IsStale(asOf time.Time) bool {
+	return p.IsStaleOn("", asOf)
+}
+
+// IsStaleOn reports whether a price as of asOf from exchange is stale right
+// now.
+func (p *StalenessPolicy) IsStaleOn(exchange string, asOf time.Time) bool {
+	if asOf.IsZero() {
+		return true
+	}
+	cal := p.CalendarFor(exchange)
+	now := p.now()
+	if cal.IsOpen(now) {
+		return now.Sub(asOf) > p.MaxAge
+	}
+	return asOf.Before(cal.LastClose(now).Add(-p.MaxAge))
+}
@@ -26,9 +26,11 @@ var (
 	)
 )
 
-func InitMetrics() {
+// InitMetrics registers the HTTP metrics along with any extra collectors.
+func InitMetrics(extra ...prometheus.Collector) {
 	prometheus.MustRegister(httpRequestsTotal)
 	prometheus.MustRegister(httpRequestDuration)
+	prometheus.MustRegister(extra...)
 }
 
 func Logging() gin.HandlerFunc {
@@ -0,0 +1,260 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+//go:embed migrations/*.sql
+var embedded embed.FS
+
+// lockID is the pg advisory lock key shared by every replica, so only one
+// of them applies migrations at a time.
+const lockID int64 = 727274101
+
+var ErrDirty = errors.New("database is dirty; fix the failed migration and run `migrate force <version>`")
+
+// Migration is one versioned step, read from NNNN_name.up.sql / .down.sql.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes whether a migration has been applied.
+type Status struct {
+	Version int64  `json:"version"`
+	Name    string `json:"name"`
+	Applied bool   `json:"applied"`
+}
+
+// Migrator applies the embedded migrations. The bookkeeping table matches
+// golang-migrate's schema_migrations layout so either tool can be used.
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration
+}
+
+// New returns a Migrator loaded with the migrations embedded in the binary.
+func New(db *sql.DB) (*Migrator, error) {
+	migrations, err := Load(embedded, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{DB: db, Migrations: migrations}, nil
+}
+
+// Load reads and pairs up/down files from dir, ordered by version.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, e := range entries {
+		name := e.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		prefix, label, _ := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version prefix", name)
+		}
+		body, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: label}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d: missing up file", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		current, dirty, err := m.version(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return ErrDirty
+		}
+		for _, mig := range m.Migrations {
+			if mig.Version <= current {
+				continue
+			}
+			logrus.WithField("version", mig.Version).Infof("Applying migration %s", mig.Name)
+			if err := m.apply(ctx, conn, mig.Up, mig.Version); err != nil {
+				return fmt.Errorf("migration %d up: %w", mig.Version, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Down rolls back the latest steps migrations.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		current, dirty, err := m.version(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return ErrDirty
+		}
+		for i := len(m.Migrations) - 1; i >= 0 && steps > 0; i-- {
+			mig := m.Migrations[i]
+			if mig.Version > current {
+				continue
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("migration %d has no down file", mig.Version)
+			}
+			var target int64
+			if i > 0 {
+				target = m.Migrations[i-1].Version
+			}
+			logrus.WithField("version", mig.Version).Infof("Reverting migration %s", mig.Name)
+			if err := m.apply(ctx, conn, mig.Down, target); err != nil {
+				return fmt.Errorf("migration %d down: %w", mig.Version, err)
+			}
+			current = target
+			steps--
+		}
+		return nil
+	})
+}
+
+// Status lists every known migration and whether it has been applied, along
+// with the recorded version and dirty flag.
+func (m *Migrator) Status(ctx context.Context) ([]Status, int64, bool, error) {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	defer conn.Close()
+	current, dirty, err := m.version(ctx, conn)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	statuses := make([]Status, 0, len(m.Migrations))
+	for _, mig := range m.Migrations {
+		statuses = append(statuses, Status{Version: mig.Version, Name: mig.Name, Applied: mig.Version <= current})
+	}
+	return statuses, current, dirty, nil
+}
+
+// Force records version as applied and clears the dirty flag without running
+// any SQL. It is the escape hatch after a failed migration was fixed by hand.
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		return m.setVersion(ctx, conn, version, false)
+	})
+}
+
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// Advisory locks are session scoped, so everything runs on this conn.
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID); err != nil {
+			logrus.WithError(err).Error("Failed to release migration lock")
+		}
+	}()
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)`); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func (m *Migrator) version(ctx context.Context, conn *sql.Conn) (int64, bool, error) {
+	var version int64
+	var dirty bool
+	err := conn.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil && strings.Contains(err.Error(), "does not exist") {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+func (m *Migrator) setVersion(ctx context.Context, conn *sql.Conn, version int64, dirty bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations`); err != nil {
+		return err
+	}
+	if version > 0 || dirty {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2)`, version, dirty); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// apply marks the target version dirty, runs the statements in a transaction
+// and then records the target as clean. A failure leaves the dirty flag set.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, body string, target int64) error {
+	if err := m.setVersion(ctx, conn, target, true); err != nil {
+		return err
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, body); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return m.setVersion(ctx, conn, target, false)
+}
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_ledger_user;
+DROP INDEX IF EXISTS idx_rewards_user_date;
+DROP TABLE IF EXISTS ledger_entries;
+DROP TABLE IF EXISTS stock_prices;
+DROP TABLE IF EXISTS rewards;
@@ -0,0 +1,16 @@
+-- Messages that exhausted their retry tiers, indexed for admin inspection
+CREATE TABLE IF NOT EXISTS dead_letters (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    original_topic VARCHAR(128) NOT NULL,
+    event_type VARCHAR(64) NOT NULL,
+    message_key VARCHAR(128),
+    headers JSONB NOT NULL DEFAULT '{}',
+    payload BYTEA,
+    error TEXT NOT NULL,
+    attempts INT NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending', -- pending, replayed, discarded
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_dead_letters_status ON dead_letters (status, created_at);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_corporate_actions_effective;
+DROP TABLE IF EXISTS corporate_actions;
+DROP TABLE IF EXISTS projection_events;
+DROP INDEX IF EXISTS idx_user_holdings_symbol;
+DROP TABLE IF EXISTS user_holdings;
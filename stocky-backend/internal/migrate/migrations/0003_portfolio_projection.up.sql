@@ -0,0 +1,36 @@
+-- Per-user holdings maintained incrementally from reward events
+CREATE TABLE IF NOT EXISTS user_holdings (
+    user_id VARCHAR(64) NOT NULL,
+    stock_symbol VARCHAR(16) NOT NULL,
+    shares NUMERIC(18,6) NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, stock_symbol)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_holdings_symbol ON user_holdings (stock_symbol);
+
+-- Dedup keys of events already folded into the projection
+CREATE TABLE IF NOT EXISTS projection_events (
+    event_key VARCHAR(128) PRIMARY KEY,
+    applied_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- Splits, bonus issues and consolidations, kept so the projection can be rebuilt
+CREATE TABLE IF NOT EXISTS corporate_actions (
+    id UUID PRIMARY KEY,
+    symbol VARCHAR(16) NOT NULL,
+    action_type VARCHAR(16) NOT NULL,
+    ratio NUMERIC(18,6) NOT NULL,
+    effective_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_corporate_actions_effective ON corporate_actions (effective_at);
+
+-- Backfill from existing rewards
+INSERT INTO user_holdings (user_id, stock_symbol, shares)
+SELECT user_id, stock_symbol, SUM(shares) FROM rewards WHERE status = 'active' GROUP BY user_id, stock_symbol
+ON CONFLICT DO NOTHING;
+
+INSERT INTO projection_events (event_key)
+SELECT 'reward.created:' || id FROM rewards
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_price_update_runs_started;
+DROP TABLE IF EXISTS price_update_runs;
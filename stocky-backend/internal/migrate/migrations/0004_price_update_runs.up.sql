@@ -0,0 +1,13 @@
+-- One row per scheduled price update, written by whichever replica held the lock
+CREATE TABLE IF NOT EXISTS price_update_runs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    holder VARCHAR(128) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'running', -- running, succeeded, partial, failed
+    symbols_total INT NOT NULL DEFAULT 0,
+    symbols_updated INT NOT NULL DEFAULT 0,
+    error TEXT,
+    started_at TIMESTAMP NOT NULL DEFAULT now(),
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_price_update_runs_started ON price_update_runs (started_at DESC);
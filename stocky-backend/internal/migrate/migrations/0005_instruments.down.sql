@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_instruments_isin;
+DROP INDEX IF EXISTS idx_instruments_symbol;
+DROP TABLE IF EXISTS instruments;
@@ -0,0 +1,16 @@
+-- Instrument master: every tradable symbol rewards may reference
+CREATE TABLE IF NOT EXISTS instruments (
+    symbol VARCHAR(16) NOT NULL,
+    exchange VARCHAR(8) NOT NULL, -- NSE, BSE
+    isin CHAR(12) NOT NULL,
+    name VARCHAR(256) NOT NULL,
+    sector VARCHAR(64),
+    lot_size INT NOT NULL DEFAULT 1,
+    tick_size NUMERIC(10,4) NOT NULL DEFAULT 0.05,
+    status VARCHAR(16) NOT NULL DEFAULT 'active', -- active, suspended, delisted
+    updated_at TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (exchange, symbol)
+);
+
+CREATE INDEX IF NOT EXISTS idx_instruments_symbol ON instruments (symbol);
+CREATE INDEX IF NOT EXISTS idx_instruments_isin ON instruments (isin);
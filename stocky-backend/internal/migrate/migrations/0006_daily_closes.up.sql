@@ -0,0 +1,8 @@
+-- Closing price per symbol per trading day, recorded by the price updater
+CREATE TABLE IF NOT EXISTS daily_closes (
+    symbol VARCHAR(16) NOT NULL,
+    trade_date DATE NOT NULL,
+    close NUMERIC(18,4) NOT NULL,
+    as_of TIMESTAMP NOT NULL,
+    PRIMARY KEY (symbol, trade_date)
+);
@@ -0,0 +1,10 @@
+-- Every applied price, one row per symbol and timestamp
+CREATE TABLE IF NOT EXISTS price_history (
+    symbol VARCHAR(16) NOT NULL,
+    as_of TIMESTAMP NOT NULL,
+    price NUMERIC(18,4) NOT NULL,
+    volume BIGINT,
+    source VARCHAR(32) NOT NULL,
+    recorded_at TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (symbol, as_of)
+);
@@ -0,0 +1,19 @@
+-- Prices the bad-tick guard refused to apply, held for admin review
+CREATE TABLE IF NOT EXISTS price_quarantine (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    symbol VARCHAR(16) NOT NULL,
+    price NUMERIC(18,4) NOT NULL,
+    as_of TIMESTAMP NOT NULL,
+    volume BIGINT,
+    source VARCHAR(32) NOT NULL,
+    reason VARCHAR(32) NOT NULL, -- non_positive, outside_band
+    previous_price NUMERIC(18,4),
+    change_pct NUMERIC(12,4),
+    band_pct NUMERIC(6,2) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending', -- pending, approved, rejected
+    reviewed_by VARCHAR(64),
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    reviewed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_price_quarantine_status ON price_quarantine (status, created_at);
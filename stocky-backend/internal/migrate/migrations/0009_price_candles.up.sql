@@ -0,0 +1,14 @@
+-- OHLCV candles folded from price_history as prices are applied
+CREATE TABLE IF NOT EXISTS price_candles (
+    symbol VARCHAR(16) NOT NULL,
+    interval VARCHAR(4) NOT NULL, -- 1m, 1h, 1d
+    bucket_start TIMESTAMP NOT NULL,
+    open NUMERIC(18,4) NOT NULL,
+    high NUMERIC(18,4) NOT NULL,
+    low NUMERIC(18,4) NOT NULL,
+    close NUMERIC(18,4) NOT NULL,
+    volume BIGINT NOT NULL DEFAULT 0,
+    ticks INT NOT NULL DEFAULT 1,
+    last_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (symbol, interval, bucket_start)
+);
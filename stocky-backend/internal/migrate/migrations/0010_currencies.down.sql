@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS fx_rates;
+ALTER TABLE ledger_entries DROP COLUMN IF EXISTS fx_rate;
+ALTER TABLE ledger_entries DROP COLUMN IF EXISTS native_amount;
+ALTER TABLE ledger_entries DROP COLUMN IF EXISTS currency;
+ALTER TABLE stock_prices DROP COLUMN IF EXISTS currency;
+ALTER TABLE instruments DROP COLUMN IF EXISTS currency;
@@ -0,0 +1,18 @@
+-- Quote currencies for listings and prices; existing rows are INR
+ALTER TABLE instruments ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'INR';
+ALTER TABLE stock_prices ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'INR';
+
+-- Ledger amounts in the instrument's currency, alongside INR
+ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS currency CHAR(3);
+ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS native_amount NUMERIC(18,4);
+ALTER TABLE ledger_entries ADD COLUMN IF NOT EXISTS fx_rate NUMERIC(18,8);
+
+-- INR value of one unit of each currency over time
+CREATE TABLE IF NOT EXISTS fx_rates (
+    currency CHAR(3) NOT NULL,
+    as_of TIMESTAMP NOT NULL,
+    rate NUMERIC(18,8) NOT NULL,
+    source VARCHAR(32) NOT NULL,
+    recorded_at TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (currency, as_of)
+);
@@ -0,0 +1,36 @@
+-- Per-user alert rules and the alerts they fired
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id VARCHAR(64) NOT NULL,
+    kind VARCHAR(32) NOT NULL, -- portfolio_above, portfolio_below, daily_move
+    symbol VARCHAR(16) NOT NULL DEFAULT '', -- daily_move only; empty means any held symbol
+    threshold NUMERIC(18,4) NOT NULL,
+    cooldown_seconds INTEGER NOT NULL DEFAULT 3600,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    armed BOOLEAN NOT NULL DEFAULT TRUE,
+    last_fired_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_rules_user ON alert_rules (user_id);
+CREATE INDEX IF NOT EXISTS idx_alert_rules_symbol ON alert_rules (symbol) WHERE active;
+
+CREATE TABLE IF NOT EXISTS alert_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    rule_id UUID REFERENCES alert_rules (id) ON DELETE SET NULL,
+    user_id VARCHAR(64) NOT NULL,
+    kind VARCHAR(32) NOT NULL,
+    symbol VARCHAR(16) NOT NULL DEFAULT '',
+    value NUMERIC(18,4) NOT NULL,
+    threshold NUMERIC(18,4) NOT NULL,
+    message TEXT NOT NULL,
+    dedup_key VARCHAR(64),
+    fired_at TIMESTAMP NOT NULL,
+    delivered_at TIMESTAMP,
+    delivery_error TEXT
+);
+
+-- One event per rule and occurrence; NULL keys never collide
+CREATE UNIQUE INDEX IF NOT EXISTS idx_alert_events_dedup ON alert_events (rule_id, dedup_key);
+CREATE INDEX IF NOT EXISTS idx_alert_events_user ON alert_events (user_id, fired_at);
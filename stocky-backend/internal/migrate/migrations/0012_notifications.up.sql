@@ -0,0 +1,33 @@
+-- Per-user notification channels and the notifications queued on them
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id VARCHAR(64) NOT NULL,
+    channel VARCHAR(16) NOT NULL, -- email, sms, push
+    address TEXT NOT NULL,
+    locale VARCHAR(16) NOT NULL DEFAULT 'en',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    kinds TEXT[] NOT NULL DEFAULT '{}', -- empty means every kind
+    updated_at TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, channel)
+);
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id VARCHAR(64) NOT NULL,
+    channel VARCHAR(16) NOT NULL,
+    kind VARCHAR(32) NOT NULL,
+    address TEXT NOT NULL,
+    subject TEXT NOT NULL DEFAULT '',
+    body TEXT NOT NULL,
+    dedup_key VARCHAR(128) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending', -- pending, sent, failed
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP,
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    sent_at TIMESTAMP
+);
+
+-- One notification per user, channel and event
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notifications_dedup ON notifications (user_id, channel, dedup_key);
+CREATE INDEX IF NOT EXISTS idx_notifications_due ON notifications (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications (user_id, created_at);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS tax_lot_disposals;
+DROP TABLE IF EXISTS tax_lots;
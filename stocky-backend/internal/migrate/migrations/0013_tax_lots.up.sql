@@ -0,0 +1,75 @@
+-- Shares acquired per reward at their fair value on the reward date
+CREATE TABLE IF NOT EXISTS tax_lots (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    reward_id UUID NOT NULL UNIQUE,
+    user_id VARCHAR(64) NOT NULL,
+    symbol VARCHAR(16) NOT NULL,
+    acquired_at TIMESTAMP NOT NULL,
+    shares NUMERIC(18,6) NOT NULL,
+    remaining_shares NUMERIC(18,6) NOT NULL,
+    currency CHAR(3) NOT NULL DEFAULT 'INR',
+    cost_per_share NUMERIC(24,8), -- NULL when no price was known
+    fx_rate NUMERIC(18,8), -- INR per unit of currency at acquired_at
+    cost_source VARCHAR(16) NOT NULL, -- price_history, daily_close, current_price, unknown
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- Open lots in FIFO order
+CREATE INDEX IF NOT EXISTS idx_tax_lots_open ON tax_lots (user_id, symbol, acquired_at) WHERE remaining_shares > 0;
+CREATE INDEX IF NOT EXISTS idx_tax_lots_symbol ON tax_lots (symbol) WHERE remaining_shares > 0;
+
+-- Shares taken out of a lot by a reversal or sale
+CREATE TABLE IF NOT EXISTS tax_lot_disposals (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    lot_id UUID NOT NULL REFERENCES tax_lots (id),
+    shares NUMERIC(18,6) NOT NULL,
+    reason VARCHAR(16) NOT NULL, -- reversal, sale
+    reference VARCHAR(64) NOT NULL,
+    disposed_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_tax_lot_disposals_lot ON tax_lot_disposals (lot_id);
+
+-- Backfill one lot per active reward, priced from the last recorded price at
+-- or before the reward, then the last close, then the current price.
+-- Reversed rewards get no lot, so their shares are not consumed from others.
+INSERT INTO tax_lots (reward_id, user_id, symbol, acquired_at, shares, remaining_shares, currency, cost_per_share, fx_rate, cost_source)
+SELECT r.id, r.user_id, r.stock_symbol, r.rewarded_at, r.shares, r.shares, c.currency, p.price,
+       CASE WHEN c.currency = 'INR' THEN 1 ELSE fx.rate END,
+       CASE WHEN p.price IS NULL THEN 'unknown' ELSE p.source END
+FROM rewards r
+CROSS JOIN LATERAL (
+    SELECT COALESCE((SELECT sp.currency FROM stock_prices sp WHERE sp.symbol = r.stock_symbol), 'INR') AS currency
+) c
+LEFT JOIN LATERAL (
+    SELECT price, source FROM (
+        (SELECT ph.price, 'price_history' AS source, 1 AS rank FROM price_history ph
+         WHERE ph.symbol = r.stock_symbol AND ph.as_of <= r.rewarded_at ORDER BY ph.as_of DESC LIMIT 1)
+        UNION ALL
+        (SELECT dc.close, 'daily_close', 2 FROM daily_closes dc
+         WHERE dc.symbol = r.stock_symbol AND dc.trade_date <= r.rewarded_at::date ORDER BY dc.trade_date DESC LIMIT 1)
+        UNION ALL
+        (SELECT sp.price, 'current_price', 3 FROM stock_prices sp WHERE sp.symbol = r.stock_symbol)
+    ) candidates ORDER BY rank LIMIT 1
+) p ON TRUE
+LEFT JOIN LATERAL (
+    SELECT f.rate FROM fx_rates f WHERE f.currency = c.currency AND f.as_of <= r.rewarded_at ORDER BY f.as_of DESC LIMIT 1
+) fx ON TRUE
+WHERE r.status = 'active'
+ON CONFLICT (reward_id) DO NOTHING;
+
+-- Restate backfilled lots for corporate actions effective after they were
+-- acquired; the total cost of a lot is unchanged.
+DO $$
+DECLARE
+    ca RECORD;
+BEGIN
+    FOR ca IN SELECT symbol, ratio, effective_at FROM corporate_actions ORDER BY effective_at LOOP
+        UPDATE tax_lots
+        SET shares = shares * ca.ratio,
+            remaining_shares = remaining_shares * ca.ratio,
+            cost_per_share = cost_per_share / ca.ratio
+        WHERE symbol = ca.symbol AND acquired_at < ca.effective_at;
+    END LOOP;
+END $$;
@@ -0,0 +1,17 @@
+-- Events written in the same transaction as the change they describe and
+-- published after it commits, by the writer or else the outbox relay
+CREATE TABLE IF NOT EXISTS event_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    topic VARCHAR(128) NOT NULL,
+    msg_key VARCHAR(128) NOT NULL DEFAULT '',
+    type VARCHAR(128) NOT NULL,
+    headers JSONB NOT NULL DEFAULT '{}',
+    value BYTEA NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    published_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_outbox_due ON event_outbox (next_attempt_at) WHERE published_at IS NULL;
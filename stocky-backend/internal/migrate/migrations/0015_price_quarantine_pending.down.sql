@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_price_quarantine_pending_symbol;
+ALTER TABLE price_quarantine DROP COLUMN IF EXISTS ticks;
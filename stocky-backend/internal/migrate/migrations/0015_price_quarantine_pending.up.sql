@@ -0,0 +1,11 @@
+-- One pending review per symbol: repeats update it to the newest price
+ALTER TABLE price_quarantine ADD COLUMN IF NOT EXISTS ticks INTEGER NOT NULL DEFAULT 1;
+
+UPDATE price_quarantine q SET status = 'rejected', reviewed_by = 'superseded', reviewed_at = now()
+WHERE q.status = 'pending' AND EXISTS (
+    SELECT 1 FROM price_quarantine n
+    WHERE n.symbol = q.symbol AND n.status = 'pending'
+      AND (n.as_of, n.created_at, n.id) > (q.as_of, q.created_at, q.id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_price_quarantine_pending_symbol ON price_quarantine (symbol) WHERE status = 'pending';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS dividends;
+ALTER TABLE rewards DROP COLUMN IF EXISTS reversed_at;
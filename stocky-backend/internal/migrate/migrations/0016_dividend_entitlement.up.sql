@@ -0,0 +1,20 @@
+-- When each reward was reversed, so holdings can be replayed as of a past
+-- instant. Rewards reversed before this column existed have no time and
+-- count as never held.
+ALTER TABLE rewards ADD COLUMN IF NOT EXISTS reversed_at TIMESTAMP;
+
+-- Declared dividends; holders are notified once entitlement settles at the
+-- end of the record date
+CREATE TABLE IF NOT EXISTS dividends (
+    dividend_id VARCHAR(64) PRIMARY KEY,
+    symbol VARCHAR(16) NOT NULL,
+    amount_per_share NUMERIC NOT NULL,
+    currency CHAR(3) NOT NULL,
+    record_date DATE NOT NULL,
+    pay_date DATE NOT NULL,
+    entitled_at TIMESTAMP NOT NULL,
+    notified_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_dividends_due ON dividends (entitled_at) WHERE notified_at IS NULL;
@@ -0,0 +1,3 @@
+-- The replayed reversals stay: later disposals may have consumed what they
+-- left open, so they cannot be told apart and undone. 0013's down drops both
+-- tables.
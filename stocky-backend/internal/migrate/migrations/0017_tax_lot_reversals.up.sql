@@ -0,0 +1,62 @@
+-- 0013 gave reversed rewards no lot, while a reversal consumes the user's
+-- oldest open lots in the symbol. Give each such reward its lot, priced and
+-- restated like 0013's, and replay its reversal through that FIFO rule.
+-- When these rewards were reversed was not recorded, so like 0016 they count
+-- as never held: each reversal is replayed at its reward date, consuming in
+-- today's shares from the lots open by then, as far as they reach.
+DO $$
+DECLARE
+    rv RECORD;
+    ca RECORD;
+    open_lot RECORD;
+    factor NUMERIC;
+    owed NUMERIC;
+    taken NUMERIC;
+BEGIN
+    FOR rv IN
+        SELECT r.id, r.user_id, r.stock_symbol, r.shares, r.rewarded_at FROM rewards r
+        WHERE r.status <> 'active' AND NOT EXISTS (SELECT 1 FROM tax_lots l WHERE l.reward_id = r.id)
+        ORDER BY r.rewarded_at, r.created_at
+    LOOP
+        factor := 1;
+        FOR ca IN SELECT c.ratio FROM corporate_actions c WHERE c.symbol = rv.stock_symbol AND c.effective_at > rv.rewarded_at LOOP
+            factor := factor * ca.ratio;
+        END LOOP;
+
+        INSERT INTO tax_lots (reward_id, user_id, symbol, acquired_at, shares, remaining_shares, currency, cost_per_share, fx_rate, cost_source)
+        SELECT rv.id, rv.user_id, rv.stock_symbol, rv.rewarded_at, rv.shares * factor, rv.shares * factor, c.currency, p.price / factor,
+               CASE WHEN c.currency = 'INR' THEN 1 ELSE fx.rate END,
+               CASE WHEN p.price IS NULL THEN 'unknown' ELSE p.source END
+        FROM (
+            SELECT COALESCE((SELECT sp.currency FROM stock_prices sp WHERE sp.symbol = rv.stock_symbol), 'INR') AS currency
+        ) c
+        LEFT JOIN LATERAL (
+            SELECT price, source FROM (
+                (SELECT ph.price, 'price_history' AS source, 1 AS rank FROM price_history ph
+                 WHERE ph.symbol = rv.stock_symbol AND ph.as_of <= rv.rewarded_at ORDER BY ph.as_of DESC LIMIT 1)
+                UNION ALL
+                (SELECT dc.close, 'daily_close', 2 FROM daily_closes dc
+                 WHERE dc.symbol = rv.stock_symbol AND dc.trade_date <= rv.rewarded_at::date ORDER BY dc.trade_date DESC LIMIT 1)
+                UNION ALL
+                (SELECT sp.price, 'current_price', 3 FROM stock_prices sp WHERE sp.symbol = rv.stock_symbol)
+            ) candidates ORDER BY rank LIMIT 1
+        ) p ON TRUE
+        LEFT JOIN LATERAL (
+            SELECT f.rate FROM fx_rates f WHERE f.currency = c.currency AND f.as_of <= rv.rewarded_at ORDER BY f.as_of DESC LIMIT 1
+        ) fx ON TRUE;
+
+        owed := rv.shares * factor;
+        FOR open_lot IN
+            SELECT l.id, l.remaining_shares FROM tax_lots l
+            WHERE l.user_id = rv.user_id AND l.symbol = rv.stock_symbol AND l.remaining_shares > 0 AND l.acquired_at <= rv.rewarded_at
+            ORDER BY l.acquired_at, l.created_at, l.id
+        LOOP
+            EXIT WHEN owed <= 0;
+            taken := LEAST(open_lot.remaining_shares, owed);
+            UPDATE tax_lots SET remaining_shares = remaining_shares - taken WHERE id = open_lot.id;
+            INSERT INTO tax_lot_disposals (lot_id, shares, reason, reference, disposed_at)
+            VALUES (open_lot.id, taken, 'reversal', rv.id::text, rv.rewarded_at);
+            owed := owed - taken;
+        END LOOP;
+    END LOOP;
+END $$;
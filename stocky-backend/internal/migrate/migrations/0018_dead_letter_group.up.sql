@@ -0,0 +1,4 @@
+-- The consumer group that gave up on each dead letter, so a replay goes back
+-- through that group's retry path only. Older rows have none and replay to
+-- their original topic.
+ALTER TABLE dead_letters ADD COLUMN IF NOT EXISTS consumer_group VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- Published events are pruned once they are past retention
+CREATE INDEX IF NOT EXISTS idx_event_outbox_published ON event_outbox (published_at) WHERE published_at IS NOT NULL;
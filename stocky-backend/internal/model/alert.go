@@ -0,0 +1,61 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Alert rule kinds. Portfolio thresholds are INR totals; daily moves are
+// percentages of the previous close, in either direction.
+const (
+	AlertPortfolioAbove = "portfolio_above"
+	AlertPortfolioBelow = "portfolio_below"
+	AlertDailyMove      = "daily_move"
+)
+
+// AlertRule is one user's alert. A daily_move rule with no Symbol watches
+// every stock the user holds. Armed is cleared when a portfolio threshold
+// fires and set again once the value is back on the other side, so a
+// crossing alerts once rather than on every price while it stays crossed.
+type AlertRule struct {
+	ID              string          `json:"id"`
+	UserID          string          `json:"user_id"`
+	Kind            string          `json:"kind"`
+	Symbol          string          `json:"symbol,omitempty"`
+	Threshold       decimal.Decimal `json:"threshold"`
+	CooldownSeconds int             `json:"cooldown_seconds"`
+	Active          bool            `json:"active"`
+	Armed           bool            `json:"armed"`
+	LastFiredAt     *time.Time      `json:"last_fired_at,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+}
+
+// AlertRuleRequest creates a rule or, with unset fields left alone,
+// updates one.
+type AlertRuleRequest struct {
+	Kind            string           `json:"kind"`
+	Symbol          *string          `json:"symbol"`
+	Threshold       *decimal.Decimal `json:"threshold"`
+	CooldownSeconds *int             `json:"cooldown_seconds"`
+	Active          *bool            `json:"active"`
+}
+
+// AlertEvent is a fired alert. Value is the portfolio total or the percent
+// move that tripped the rule. DedupKey makes repeat firings for the same
+// occurrence (e.g. a symbol's move on one day) no-ops.
+type AlertEvent struct {
+	ID            string          `json:"id"`
+	RuleID        string          `json:"rule_id"`
+	UserID        string          `json:"user_id"`
+	Kind          string          `json:"kind"`
+	Symbol        string          `json:"symbol,omitempty"`
+	Value         decimal.Decimal `json:"value"`
+	Threshold     decimal.Decimal `json:"threshold"`
+	Message       string          `json:"message"`
+	DedupKey      string          `json:"-"`
+	FiredAt       time.Time       `json:"fired_at"`
+	DeliveredAt   *time.Time      `json:"delivered_at,omitempty"`
+	DeliveryError string          `json:"delivery_error,omitempty"`
+}
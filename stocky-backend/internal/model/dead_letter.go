@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+const (
+	DeadLetterPending   = "pending"
+	DeadLetterReplayed  = "replayed"
+	DeadLetterDiscarded = "discarded"
+)
+
+type DeadLetter struct {
+	ID            string            `json:"id"`
+	OriginalTopic string            `json:"original_topic"`
+	Group         string            `json:"consumer_group"`
+	EventType     string            `json:"event_type"`
+	Key           string            `json:"key"`
+	Headers       map[string]string `json:"headers"`
+	Payload       []byte            `json:"payload"`
+	Error         string            `json:"error"`
+	Attempts      int               `json:"attempts"`
+	Status        string            `json:"status"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	ExchangeNSE    = "NSE"
+	ExchangeBSE    = "BSE"
+	ExchangeNYSE   = "NYSE"
+	ExchangeNASDAQ = "NASDAQ"
+
+	InstrumentActive    = "active"
+	InstrumentSuspended = "suspended"
+	InstrumentDelisted  = "delisted"
+)
+
+// Instrument is one listing of a security on an exchange.
+type Instrument struct {
+	Symbol    string          `json:"symbol"`
+	Exchange  string          `json:"exchange"`
+	ISIN      string          `json:"isin"`
+	Name      string          `json:"name"`
+	Sector    string          `json:"sector,omitempty"`
+	LotSize   int             `json:"lot_size"`
+	TickSize  decimal.Decimal `json:"tick_size"`
+	Currency  string          `json:"currency"`
+	Status    string          `json:"status"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// ExchangeCurrency is the currency an exchange quotes in.
+func ExchangeCurrency(exchange string) string {
+	switch exchange {
+	case ExchangeNYSE, ExchangeNASDAQ:
+		return CurrencyUSD
+	}
+	return CurrencyINR
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+// Notification channels.
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+	ChannelPush  = "push"
+)
+
+// Notification kinds, one per event users are told about.
+const (
+	NotificationRewardCreated  = "reward_created"
+	NotificationRewardReversed = "reward_reversed"
+	NotificationDividend       = "dividend"
+)
+
+// Notification delivery statuses. A pending notification is retried until
+// it is sent or runs out of attempts.
+const (
+	NotificationPending = "pending"
+	NotificationSent    = "sent"
+	NotificationFailed  = "failed"
+)
+
+// ErrUndeliverable marks delivery failures that retrying cannot fix, such
+// as a rejected address. Senders wrap it.
+var ErrUndeliverable = errors.New("notification undeliverable")
+
+// NotificationPreference is how one user wants to hear on one channel.
+// Address is an email address, an E.164 phone number or a push token.
+// Kinds limits the channel to those notifications; empty means all.
+type NotificationPreference struct {
+	UserID    string    `json:"user_id"`
+	Channel   string    `json:"channel"`
+	Address   string    `json:"address"`
+	Locale    string    `json:"locale"`
+	Enabled   bool      `json:"enabled"`
+	Kinds     []string  `json:"kinds,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NotificationPreferenceRequest sets a channel's preference; unset fields
+// keep their current value.
+type NotificationPreferenceRequest struct {
+	Address *string   `json:"address"`
+	Locale  *string   `json:"locale"`
+	Enabled *bool     `json:"enabled"`
+	Kinds   *[]string `json:"kinds"`
+}
+
+// Notification is one rendered message for one channel. DedupKey names the
+// event it reports so a redelivered event queues nothing new.
+type Notification struct {
+	ID            string     `json:"id"`
+	UserID        string     `json:"user_id"`
+	Channel       string     `json:"channel"`
+	Kind          string     `json:"kind"`
+	Address       string     `json:"address"`
+	Subject       string     `json:"subject,omitempty"`
+	Body          string     `json:"body"`
+	DedupKey      string     `json:"-"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+}
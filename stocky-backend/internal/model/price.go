@@ -0,0 +1,128 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	CurrencyINR = "INR"
+	CurrencyUSD = "USD"
+)
+
+// Quote is a price for one symbol as of a point in time, in Currency (INR
+// when empty). Source names the provider that served it; Degraded is set
+// when it came from a fallback rather than a live source.
+type Quote struct {
+	Symbol   string          `json:"symbol"`
+	Price    decimal.Decimal `json:"price"`
+	Currency string          `json:"currency,omitempty"`
+	AsOf     time.Time       `json:"as_of"`
+	Source   string          `json:"source,omitempty"`
+	Degraded bool            `json:"degraded,omitempty"`
+}
+
+// QuoteCurrency returns the currency the quote is priced in.
+func (q Quote) QuoteCurrency() string {
+	if q.Currency == "" {
+		return CurrencyINR
+	}
+	return q.Currency
+}
+
+// FXRate is the INR value of one unit of Currency as of a point in time.
+type FXRate struct {
+	Currency string          `json:"currency"`
+	Rate     decimal.Decimal `json:"rate"`
+	AsOf     time.Time       `json:"as_of"`
+	Source   string          `json:"source,omitempty"`
+}
+
+// PriceTick is one exchange print on the price-updates topic.
+type PriceTick struct {
+	Symbol    string `json:"symbol"`
+	Exchange  string `json:"exchange,omitempty"`
+	Price     string `json:"price"`
+	Volume    int64  `json:"volume,omitempty"`
+	Timestamp string `json:"timestamp"`
+}
+
+const (
+	PriceRunRunning   = "running"
+	PriceRunSucceeded = "succeeded"
+	PriceRunPartial   = "partial"
+	PriceRunFailed    = "failed"
+)
+
+// PriceUpdateRun records one scheduled price refresh.
+type PriceUpdateRun struct {
+	ID             string     `json:"id"`
+	Holder         string     `json:"holder"`
+	Status         string     `json:"status"`
+	SymbolsTotal   int        `json:"symbols_total"`
+	SymbolsUpdated int        `json:"symbols_updated"`
+	Error          string     `json:"error,omitempty"`
+	StartedAt      time.Time  `json:"started_at"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+}
+
+const (
+	QuarantinePending  = "pending"
+	QuarantineApproved = "approved"
+	QuarantineRejected = "rejected"
+)
+
+// Reasons a price is held for review instead of being applied.
+const (
+	QuarantineNonPositive = "non_positive"
+	QuarantineOutsideBand = "outside_band"
+)
+
+// QuarantinedPrice is a price the bad-tick guard refused to apply, kept
+// for an admin to approve or reject. PreviousPrice is the last good price
+// it was compared with, and ChangePct the move against it in percent.
+// Ticks counts the prices the entry stands for: a symbol keeps one pending
+// entry, which repeats update to the newest price.
+type QuarantinedPrice struct {
+	ID            string           `json:"id"`
+	Symbol        string           `json:"symbol"`
+	Price         decimal.Decimal  `json:"price"`
+	AsOf          time.Time        `json:"as_of"`
+	Volume        int64            `json:"volume,omitempty"`
+	Source        string           `json:"source"`
+	Reason        string           `json:"reason"`
+	PreviousPrice *decimal.Decimal `json:"previous_price,omitempty"`
+	ChangePct     *decimal.Decimal `json:"change_pct,omitempty"`
+	BandPct       decimal.Decimal  `json:"band_pct"`
+	Ticks         int              `json:"ticks"`
+	Status        string           `json:"status"`
+	ReviewedBy    string           `json:"reviewed_by,omitempty"`
+	CreatedAt     time.Time        `json:"created_at"`
+	ReviewedAt    *time.Time       `json:"reviewed_at,omitempty"`
+}
+
+// Candle intervals, bucketed in exchange local time.
+const (
+	CandleMinute = "1m"
+	CandleHour   = "1h"
+	CandleDay    = "1d"
+)
+
+// CandleIntervals lists every interval candles are kept for.
+var CandleIntervals = []string{CandleMinute, CandleHour, CandleDay}
+
+// Candle is the OHLCV summary of the prices applied to one symbol within
+// one interval starting at Start. LastAt is the time of the closing price.
+type Candle struct {
+	Symbol   string          `json:"symbol"`
+	Interval string          `json:"interval"`
+	Start    time.Time       `json:"start"`
+	Open     decimal.Decimal `json:"open"`
+	High     decimal.Decimal `json:"high"`
+	Low      decimal.Decimal `json:"low"`
+	Close    decimal.Decimal `json:"close"`
+	Volume   int64           `json:"volume"`
+	Ticks    int             `json:"ticks"`
+	LastAt   time.Time       `json:"last_at"`
+}
@@ -6,27 +6,81 @@ import (
 	"github.com/shopspring/decimal"
 )
 
+// HistoricalINR is one day's value. PriceAsOf is the oldest price used and
+// IsStale is set when any of them is stale or missing.
 type HistoricalINR struct {
-	Date     string          `json:"date"`
-	INRValue decimal.Decimal `json:"inr_value"`
-	IsStale  bool            `json:"is_stale"`
+	Date      string          `json:"date"`
+	INRValue  decimal.Decimal `json:"inr_value"`
+	PriceAsOf *time.Time      `json:"price_as_of,omitempty"`
+	IsStale   bool            `json:"is_stale"`
 }
 
+// Degraded is set on valuations where at least one price came from a
+// fallback source or was unavailable, or could not be converted to INR.
+// PriceAsOf is the oldest price used. ValueByCurrency totals holdings in
+// their quote currency and FXRates lists the rates used to convert them.
 type Stats struct {
 	TodayTotalBySymbol map[string]decimal.Decimal `json:"today_total_by_symbol"`
 	PortfolioValueINR  decimal.Decimal            `json:"portfolio_value_inr"`
+	ValueByCurrency    map[string]decimal.Decimal `json:"value_by_currency"`
+	FXRates            map[string]decimal.Decimal `json:"fx_rates,omitempty"`
+	Degraded           bool                       `json:"degraded"`
+	PriceAsOf          *time.Time                 `json:"price_as_of,omitempty"`
+	IsStale            bool                       `json:"is_stale"`
 }
 
+// InvestedTotalINR is the cost basis of every holding with a known one and
+// UnrealizedPnLINR what those holdings have gained or lost since.
 type Portfolio struct {
-	Holdings          []Holding       `json:"holdings"`
-	PortfolioTotalINR decimal.Decimal `json:"portfolio_total_inr"`
+	Holdings          []Holding                  `json:"holdings"`
+	PortfolioTotalINR decimal.Decimal            `json:"portfolio_total_inr"`
+	InvestedTotalINR  decimal.Decimal            `json:"invested_total_inr"`
+	UnrealizedPnLINR  decimal.Decimal            `json:"unrealized_pnl_inr"`
+	TotalByCurrency   map[string]decimal.Decimal `json:"total_by_currency"`
+	FXRates           map[string]decimal.Decimal `json:"fx_rates,omitempty"`
+	Degraded          bool                       `json:"degraded"`
 }
 
+// PortfolioChange announces that holdings changed, for one user or, with
+// only Symbol set, for everyone holding that symbol.
+type PortfolioChange struct {
+	UserID string `json:"user_id,omitempty"`
+	Symbol string `json:"symbol,omitempty"`
+}
+
+// Holding is one symbol valued at CurrentPrice in Currency. TotalValue is in
+// that currency and TotalValueINR converts it at FXRate, which is 1 for INR.
+// A holding with no price at all has PriceUnavailable set and a zero value
+// rather than an error; one with no FX rate has FXUnavailable set and a
+// zero INR value.
+//
+// AverageCost and InvestedValue come from the open tax lots, in Currency;
+// InvestedValueINR converts each lot at the rate on its reward date.
+// UnrealizedPnL compares them with the current value. When the lots do not
+// price every share, CostBasisUnavailable is set and the cost fields are
+// zero.
 type Holding struct {
-	Symbol        string          `json:"symbol"`
-	TotalShares   decimal.Decimal `json:"total_shares"`
-	CurrentPrice  decimal.Decimal `json:"current_price"`
-	TotalValueINR decimal.Decimal `json:"total_value_inr"`
+	Symbol           string          `json:"symbol"`
+	TotalShares      decimal.Decimal `json:"total_shares"`
+	Currency         string          `json:"currency"`
+	CurrentPrice     decimal.Decimal `json:"current_price"`
+	TotalValue       decimal.Decimal `json:"total_value"`
+	FXRate           decimal.Decimal `json:"fx_rate"`
+	TotalValueINR    decimal.Decimal `json:"total_value_inr"`
+	PriceSource      string          `json:"price_source,omitempty"`
+	PriceAsOf        *time.Time      `json:"price_as_of,omitempty"`
+	FXAsOf           *time.Time      `json:"fx_as_of,omitempty"`
+	PriceDegraded    bool            `json:"price_degraded"`
+	PriceUnavailable bool            `json:"price_unavailable"`
+	FXUnavailable    bool            `json:"fx_unavailable"`
+	IsStale          bool            `json:"is_stale"`
+
+	AverageCost          decimal.Decimal `json:"average_cost"`
+	InvestedValue        decimal.Decimal `json:"invested_value"`
+	InvestedValueINR     decimal.Decimal `json:"invested_value_inr"`
+	UnrealizedPnL        decimal.Decimal `json:"unrealized_pnl"`
+	UnrealizedPnLINR     decimal.Decimal `json:"unrealized_pnl_inr"`
+	CostBasisUnavailable bool            `json:"cost_basis_unavailable"`
 }
 
 type Reward struct {
@@ -55,3 +109,102 @@ type RewardCreatedEvent struct {
 	RewardedAt    string `json:"rewarded_at"`
 	CorrelationID string `json:"correlation_id"`
 }
+
+const (
+	RewardStatusActive   = "active"
+	RewardStatusReversed = "reversed"
+)
+
+type ReverseRewardRequest struct {
+	Reason string `json:"reason" validate:"required"`
+}
+
+// RewardReversedEvent announces a reversal. Shares is what the reward held
+// when reversed, its original shares restated by later corporate actions.
+type RewardReversedEvent struct {
+	RewardID    string `json:"reward_id"`
+	UserID      string `json:"user_id"`
+	StockSymbol string `json:"stock_symbol"`
+	Shares      string `json:"shares"`
+	ReversedAt  string `json:"reversed_at"`
+	Reason      string `json:"reason"`
+}
+
+// CorporateAction multiplies the shares of Symbol acquired before EffectiveAt
+// by Ratio, e.g. a 2:1 split has ratio 2 and a 1:2 bonus issue has ratio 1.5.
+type CorporateAction struct {
+	ID          string          `json:"id"`
+	Symbol      string          `json:"symbol"`
+	ActionType  string          `json:"action_type"`
+	Ratio       decimal.Decimal `json:"ratio"`
+	EffectiveAt time.Time       `json:"effective_at"`
+}
+
+type CorporateActionRequest struct {
+	Symbol      string `json:"symbol" validate:"required"`
+	ActionType  string `json:"action_type" validate:"required,oneof=split bonus consolidation"`
+	Ratio       string `json:"ratio" validate:"required,numeric"`
+	EffectiveAt string `json:"effective_at" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
+}
+
+type CorporateActionEvent struct {
+	ActionID    string `json:"action_id"`
+	Symbol      string `json:"symbol"`
+	ActionType  string `json:"action_type"`
+	Ratio       string `json:"ratio"`
+	EffectiveAt string `json:"effective_at"`
+}
+
+// DividendRequest declares a cash dividend of AmountPerShare on Symbol. It
+// does not change holdings; holders are told what they will receive.
+type DividendRequest struct {
+	Symbol         string `json:"symbol" validate:"required"`
+	AmountPerShare string `json:"amount_per_share" validate:"required,numeric"`
+	Currency       string `json:"currency" validate:"omitempty,len=3,alpha"`
+	RecordDate     string `json:"record_date" validate:"required,datetime=2006-01-02"`
+	PayDate        string `json:"pay_date" validate:"required,datetime=2006-01-02"`
+}
+
+type DividendEvent struct {
+	DividendID     string `json:"dividend_id"`
+	Symbol         string `json:"symbol"`
+	AmountPerShare string `json:"amount_per_share"`
+	Currency       string `json:"currency"`
+	RecordDate     string `json:"record_date"`
+	PayDate        string `json:"pay_date"`
+}
+
+// ScheduledDividend is a declared dividend awaiting its holders'
+// notifications. Entitlement settles at EntitledAt, the end of the record
+// date.
+type ScheduledDividend struct {
+	DividendEvent
+	EntitledAt time.Time
+}
+
+// RewardCommand asks for a reward to be created asynchronously via the
+// reward-commands topic.
+type RewardCommand struct {
+	CommandID      string `json:"command_id"`
+	PartnerID      string `json:"partner_id"`
+	UserID         string `json:"user_id"`
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+	StockSymbol    string `json:"stock_symbol"`
+	Shares         string `json:"shares"`
+	RewardedAt     string `json:"rewarded_at"`
+}
+
+const (
+	CommandAccepted = "accepted"
+	CommandRejected = "rejected"
+)
+
+type RewardCommandResult struct {
+	CommandID      string `json:"command_id"`
+	PartnerID      string `json:"partner_id"`
+	UserID         string `json:"user_id"`
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+	RewardID       string `json:"reward_id,omitempty"`
+	Status         string `json:"status"`
+	Reason         string `json:"reason,omitempty"`
+}
@@ -0,0 +1,62 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Where a lot's cost per share came from, most to least exact.
+const (
+	CostSourcePriceHistory = "price_history"
+	CostSourceDailyClose   = "daily_close"
+	CostSourceCurrentPrice = "current_price"
+	CostSourceUnknown      = "unknown"
+)
+
+// Why shares left a lot.
+const (
+	DisposalReversal = "reversal"
+	DisposalSale     = "sale"
+)
+
+// TaxLot is the shares one reward acquired, at the fair value on the reward
+// date. CostPerShare is in Currency and FXRate converts it to INR as of
+// AcquiredAt; either is nil when no price or rate was known. Reversals and
+// sales consume RemainingShares oldest lot first.
+type TaxLot struct {
+	ID              string           `json:"id"`
+	RewardID        string           `json:"reward_id"`
+	UserID          string           `json:"user_id"`
+	Symbol          string           `json:"symbol"`
+	AcquiredAt      time.Time        `json:"acquired_at"`
+	Shares          decimal.Decimal  `json:"shares"`
+	RemainingShares decimal.Decimal  `json:"remaining_shares"`
+	Currency        string           `json:"currency"`
+	CostPerShare    *decimal.Decimal `json:"cost_per_share"`
+	FXRate          *decimal.Decimal `json:"fx_rate"`
+	CostSource      string           `json:"cost_source"`
+}
+
+// LotDisposal records Shares taken out of one lot, at that lot's cost.
+// Reference is the reward or order that caused it.
+type LotDisposal struct {
+	LotID        string           `json:"lot_id"`
+	Shares       decimal.Decimal  `json:"shares"`
+	CostPerShare *decimal.Decimal `json:"cost_per_share"`
+	FXRate       *decimal.Decimal `json:"fx_rate"`
+	Reason       string           `json:"reason"`
+	Reference    string           `json:"reference"`
+	DisposedAt   time.Time        `json:"disposed_at"`
+}
+
+// CostBasis sums one symbol's open lots. Invested is in Currency and
+// InvestedINR at each lot's acquisition rate. Unknown is set when some open
+// lot has no cost or rate, in which case the sums leave it out.
+type CostBasis struct {
+	Shares      decimal.Decimal
+	Invested    decimal.Decimal
+	InvestedINR decimal.Decimal
+	Currency    string
+	Unknown     bool
+}
@@ -0,0 +1,199 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+)
+
+type AlertRepository interface {
+	CreateAlertRule(ctx context.Context, rule model.AlertRule) (model.AlertRule, error)
+	ListAlertRules(ctx context.Context, userID string) ([]model.AlertRule, error)
+	CountAlertRules(ctx context.Context, userID string) (int, error)
+	// GetAlertRule, UpdateAlertRule and DeleteAlertRule return ErrNotFound
+	// unless userID owns the rule.
+	GetAlertRule(ctx context.Context, userID, id string) (model.AlertRule, error)
+	UpdateAlertRule(ctx context.Context, rule model.AlertRule) (model.AlertRule, error)
+	DeleteAlertRule(ctx context.Context, userID, id string) error
+
+	// ListActiveAlertRules returns the active rules a change can affect:
+	// those of users, those on symbols, and the portfolio and any-symbol
+	// rules of everyone holding one of symbols.
+	ListActiveAlertRules(ctx context.Context, symbols, users []string) ([]model.AlertRule, error)
+	// FireAlert records event for its rule unless the rule is inactive,
+	// disarmed, cooling down or already fired with the same dedup key,
+	// reporting whether it fired. disarm clears the rule's armed flag.
+	FireAlert(ctx context.Context, event model.AlertEvent, disarm bool) (model.AlertEvent, bool, error)
+	RearmAlertRule(ctx context.Context, id string) error
+	MarkAlertDelivered(ctx context.Context, id string, deliveryErr string) error
+	ListAlertEvents(ctx context.Context, userID string, limit int) ([]model.AlertEvent, error)
+}
+
+type AlertRepositoryImpl struct {
+	DB *sql.DB
+}
+
+const alertRuleColumns = `id, user_id, kind, symbol, threshold, cooldown_seconds, active, armed, last_fired_at, created_at, updated_at`
+
+const alertEventColumns = `id, COALESCE(rule_id::text, ''), user_id, kind, symbol, value, threshold, message, fired_at, delivered_at, COALESCE(delivery_error, '')`
+
+func (r *AlertRepositoryImpl) CreateAlertRule(ctx context.Context, rule model.AlertRule) (model.AlertRule, error) {
+	row := r.DB.QueryRowContext(ctx, `INSERT INTO alert_rules (user_id, kind, symbol, threshold, cooldown_seconds, active)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING `+alertRuleColumns,
+		rule.UserID, rule.Kind, rule.Symbol, rule.Threshold.String(), rule.CooldownSeconds, rule.Active)
+	return scanAlertRule(row)
+}
+
+func (r *AlertRepositoryImpl) ListAlertRules(ctx context.Context, userID string) ([]model.AlertRule, error) {
+	return r.queryAlertRules(ctx, `SELECT `+alertRuleColumns+` FROM alert_rules WHERE user_id = $1 ORDER BY created_at`, userID)
+}
+
+func (r *AlertRepositoryImpl) CountAlertRules(ctx context.Context, userID string) (int, error) {
+	var n int
+	err := r.DB.QueryRowContext(ctx, `SELECT count(*) FROM alert_rules WHERE user_id = $1`, userID).Scan(&n)
+	return n, err
+}
+
+func (r *AlertRepositoryImpl) GetAlertRule(ctx context.Context, userID, id string) (model.AlertRule, error) {
+	row := r.DB.QueryRowContext(ctx, `SELECT `+alertRuleColumns+` FROM alert_rules WHERE id = $1 AND user_id = $2`, id, userID)
+	rule, err := scanAlertRule(row)
+	if err == sql.ErrNoRows {
+		return model.AlertRule{}, ErrNotFound
+	}
+	return rule, err
+}
+
+// UpdateAlertRule also re-arms the rule, since its threshold may have moved.
+func (r *AlertRepositoryImpl) UpdateAlertRule(ctx context.Context, rule model.AlertRule) (model.AlertRule, error) {
+	row := r.DB.QueryRowContext(ctx, `UPDATE alert_rules SET kind = $3, symbol = $4, threshold = $5, cooldown_seconds = $6,
+		active = $7, armed = TRUE, updated_at = now()
+		WHERE id = $1 AND user_id = $2 RETURNING `+alertRuleColumns,
+		rule.ID, rule.UserID, rule.Kind, rule.Symbol, rule.Threshold.String(), rule.CooldownSeconds, rule.Active)
+	updated, err := scanAlertRule(row)
+	if err == sql.ErrNoRows {
+		return model.AlertRule{}, ErrNotFound
+	}
+	return updated, err
+}
+
+func (r *AlertRepositoryImpl) DeleteAlertRule(ctx context.Context, userID, id string) error {
+	res, err := r.DB.ExecContext(ctx, `DELETE FROM alert_rules WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *AlertRepositoryImpl) ListActiveAlertRules(ctx context.Context, symbols, users []string) ([]model.AlertRule, error) {
+	if len(symbols) == 0 && len(users) == 0 {
+		return nil, nil
+	}
+	return r.queryAlertRules(ctx, `SELECT `+alertRuleColumns+` FROM alert_rules r
+		WHERE r.active AND (r.user_id = ANY($2) OR r.symbol = ANY($1) OR (r.symbol = '' AND EXISTS (
+			SELECT 1 FROM user_holdings h WHERE h.user_id = r.user_id AND h.stock_symbol = ANY($1) AND h.shares > 0)))
+		ORDER BY r.user_id, r.created_at`, pq.Array(symbols), pq.Array(users))
+}
+
+func (r *AlertRepositoryImpl) FireAlert(ctx context.Context, event model.AlertEvent, disarm bool) (model.AlertEvent, bool, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return event, false, err
+	}
+	defer tx.Rollback()
+
+	// Concurrent evaluators serialize on the rule row; the loser sees the
+	// winner's last_fired_at and matches nothing.
+	res, err := tx.ExecContext(ctx, `UPDATE alert_rules SET last_fired_at = $2::timestamp, armed = armed AND NOT $3
+		WHERE id = $1 AND active AND armed
+		AND (last_fired_at IS NULL OR last_fired_at <= $2::timestamp - cooldown_seconds * interval '1 second')`,
+		event.RuleID, event.FiredAt.UTC(), disarm)
+	if err != nil {
+		return event, false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return event, false, nil
+	}
+	err = tx.QueryRowContext(ctx, `INSERT INTO alert_events (rule_id, user_id, kind, symbol, value, threshold, message, dedup_key, fired_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9)
+		ON CONFLICT (rule_id, dedup_key) DO NOTHING RETURNING id`,
+		event.RuleID, event.UserID, event.Kind, event.Symbol, event.Value.String(), event.Threshold.String(),
+		event.Message, event.DedupKey, event.FiredAt.UTC()).Scan(&event.ID)
+	if err == sql.ErrNoRows {
+		return event, false, nil
+	}
+	if err != nil {
+		return event, false, err
+	}
+	return event, true, tx.Commit()
+}
+
+func (r *AlertRepositoryImpl) RearmAlertRule(ctx context.Context, id string) error {
+	_, err := r.DB.ExecContext(ctx, `UPDATE alert_rules SET armed = TRUE WHERE id = $1 AND NOT armed`, id)
+	return err
+}
+
+func (r *AlertRepositoryImpl) MarkAlertDelivered(ctx context.Context, id string, deliveryErr string) error {
+	_, err := r.DB.ExecContext(ctx, `UPDATE alert_events SET delivered_at = CASE WHEN $2 = '' THEN $3::timestamp END, delivery_error = NULLIF($2, '')
+		WHERE id = $1`, id, deliveryErr, time.Now().UTC())
+	return err
+}
+
+func (r *AlertRepositoryImpl) ListAlertEvents(ctx context.Context, userID string, limit int) ([]model.AlertEvent, error) {
+	rows, err := r.DB.QueryContext(ctx, `SELECT `+alertEventColumns+` FROM alert_events WHERE user_id = $1
+		ORDER BY fired_at DESC LIMIT $2`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var alerts []model.AlertEvent
+	for rows.Next() {
+		var e model.AlertEvent
+		var delivered sql.NullTime
+		if err := rows.Scan(&e.ID, &e.RuleID, &e.UserID, &e.Kind, &e.Symbol, &e.Value, &e.Threshold, &e.Message,
+			&e.FiredAt, &delivered, &e.DeliveryError); err != nil {
+			return nil, err
+		}
+		if delivered.Valid {
+			e.DeliveredAt = &delivered.Time
+		}
+		alerts = append(alerts, e)
+	}
+	return alerts, rows.Err()
+}
+
+func (r *AlertRepositoryImpl) queryAlertRules(ctx context.Context, query string, args ...interface{}) ([]model.AlertRule, error) {
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var rules []model.AlertRule
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func scanAlertRule(row rowScanner) (model.AlertRule, error) {
+	var rule model.AlertRule
+	var fired sql.NullTime
+	err := row.Scan(&rule.ID, &rule.UserID, &rule.Kind, &rule.Symbol, &rule.Threshold, &rule.CooldownSeconds,
+		&rule.Active, &rule.Armed, &fired, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		return model.AlertRule{}, err
+	}
+	if fired.Valid {
+		rule.LastFiredAt = &fired.Time
+	}
+	return rule, nil
+}
@@ -0,0 +1,112 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/shopspring/decimal"
+)
+
+// SourceDailyClose marks quotes taken from daily_closes.
+const SourceDailyClose = "daily_close"
+
+// closeSeries holds each symbol's closes in ascending trade date order.
+// Dates are YYYY-MM-DD strings, which sort chronologically.
+type closeSeries map[string][]dailyClose
+
+type dailyClose struct {
+	date  string
+	quote model.Quote
+}
+
+// loadCloses reads closes for symbols up to to, reaching back a month before
+// from so the first days in range can use an earlier close.
+func loadCloses(ctx context.Context, db *sql.DB, symbols []string, from, to string) (closeSeries, error) {
+	series := make(closeSeries)
+	if len(symbols) == 0 {
+		return series, nil
+	}
+	rows, err := db.QueryContext(ctx, `SELECT symbol, to_char(trade_date, 'YYYY-MM-DD'), close, as_of FROM daily_closes
+		WHERE symbol = ANY($1) AND trade_date >= $2::date - 31 AND trade_date <= $3::date
+		ORDER BY symbol, trade_date`, pq.Array(symbols), from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c dailyClose
+		var price string
+		if err := rows.Scan(&c.quote.Symbol, &c.date, &price, &c.quote.AsOf); err != nil {
+			return nil, err
+		}
+		if c.quote.Price, err = decimal.NewFromString(price); err != nil {
+			return nil, err
+		}
+		c.quote.Source = SourceDailyClose
+		series[c.quote.Symbol] = append(series[c.quote.Symbol], c)
+	}
+	return series, rows.Err()
+}
+
+// at returns the latest close on or before date.
+func (s closeSeries) at(symbol, date string) (dailyClose, bool) {
+	closes := s[symbol]
+	i := sort.Search(len(closes), func(i int) bool { return closes[i].date > date })
+	if i == 0 {
+		return dailyClose{}, false
+	}
+	return closes[i-1], true
+}
+
+// RecordCloses stores quotes as the closing prices of tradeDate.
+func (r *PriceRepositoryImpl) RecordCloses(ctx context.Context, tradeDate time.Time, quotes []model.Quote) error {
+	if len(quotes) == 0 {
+		return nil
+	}
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO daily_closes (symbol, trade_date, close, as_of) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (symbol, trade_date) DO UPDATE SET close = EXCLUDED.close, as_of = EXCLUDED.as_of
+		WHERE daily_closes.as_of <= EXCLUDED.as_of`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	date := tradeDate.Format("2006-01-02")
+	for _, q := range quotes {
+		if _, err := stmt.ExecContext(ctx, q.Symbol, date, q.Price.String(), q.AsOf.UTC()); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// PreviousCloses returns each symbol's latest close before date (YYYY-MM-DD).
+func (r *PriceRepositoryImpl) PreviousCloses(ctx context.Context, symbols []string, date string) (map[string]decimal.Decimal, error) {
+	closes := make(map[string]decimal.Decimal, len(symbols))
+	if len(symbols) == 0 {
+		return closes, nil
+	}
+	rows, err := r.DB.QueryContext(ctx, `SELECT DISTINCT ON (symbol) symbol, close FROM daily_closes
+		WHERE symbol = ANY($1) AND trade_date < $2::date ORDER BY symbol, trade_date DESC`, pq.Array(symbols), date)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var symbol string
+		var close decimal.Decimal
+		if err := rows.Scan(&symbol, &close); err != nil {
+			return nil, err
+		}
+		closes[symbol] = close
+	}
+	return closes, rows.Err()
+}
@@ -0,0 +1,108 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/mhatrejeets/stocky-ms/internal/events"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+)
+
+var ErrNotFound = errors.New("not found")
+
+type DeadLetterRepository interface {
+	RecordDeadLetter(ctx context.Context, msg events.Message) error
+	ListDeadLetters(ctx context.Context, status string, limit int) ([]model.DeadLetter, error)
+	GetDeadLetter(ctx context.Context, id string) (model.DeadLetter, error)
+	UpdateDeadLetterStatus(ctx context.Context, id, status string) error
+	// ResolveDeadLetter moves a pending dead letter to status and returns
+	// it, or ErrNotFound if there is no pending one with that id. Of two
+	// concurrent calls, only one gets it.
+	ResolveDeadLetter(ctx context.Context, id, status string) (model.DeadLetter, error)
+}
+
+type DeadLetterRepositoryImpl struct {
+	DB *sql.DB
+}
+
+const deadLetterColumns = `id, original_topic, consumer_group, event_type, COALESCE(message_key, ''), headers, payload, error, attempts, status, created_at, updated_at`
+
+// RecordDeadLetter implements events.DeadLetterSink.
+func (r *DeadLetterRepositoryImpl) RecordDeadLetter(ctx context.Context, msg events.Message) error {
+	headers, err := json.Marshal(msg.Headers)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO dead_letters (original_topic, consumer_group, event_type, message_key, headers, payload, error, attempts)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err = r.DB.ExecContext(ctx, query,
+		events.OriginalTopic(msg), msg.Headers[events.HeaderGroup], msg.Type, msg.Key, headers, []byte(msg.Value), msg.Headers[events.HeaderError], events.Attempt(msg))
+	return err
+}
+
+func (r *DeadLetterRepositoryImpl) ListDeadLetters(ctx context.Context, status string, limit int) ([]model.DeadLetter, error) {
+	query := `SELECT ` + deadLetterColumns + ` FROM dead_letters WHERE ($1 = '' OR status = $1) ORDER BY created_at DESC LIMIT $2`
+	rows, err := r.DB.QueryContext(ctx, query, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var letters []model.DeadLetter
+	for rows.Next() {
+		dl, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+		letters = append(letters, dl)
+	}
+	return letters, rows.Err()
+}
+
+func (r *DeadLetterRepositoryImpl) GetDeadLetter(ctx context.Context, id string) (model.DeadLetter, error) {
+	row := r.DB.QueryRowContext(ctx, `SELECT `+deadLetterColumns+` FROM dead_letters WHERE id = $1`, id)
+	dl, err := scanDeadLetter(row)
+	if err == sql.ErrNoRows {
+		return model.DeadLetter{}, ErrNotFound
+	}
+	return dl, err
+}
+
+func (r *DeadLetterRepositoryImpl) UpdateDeadLetterStatus(ctx context.Context, id, status string) error {
+	res, err := r.DB.ExecContext(ctx, `UPDATE dead_letters SET status = $2, updated_at = now() WHERE id = $1`, id, status)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *DeadLetterRepositoryImpl) ResolveDeadLetter(ctx context.Context, id, status string) (model.DeadLetter, error) {
+	row := r.DB.QueryRowContext(ctx, `UPDATE dead_letters SET status = $2, updated_at = now()
+		WHERE id = $1 AND status = 'pending' RETURNING `+deadLetterColumns, id, status)
+	dl, err := scanDeadLetter(row)
+	if err == sql.ErrNoRows {
+		return model.DeadLetter{}, ErrNotFound
+	}
+	return dl, err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanDeadLetter(row rowScanner) (model.DeadLetter, error) {
+	var dl model.DeadLetter
+	var headers []byte
+	err := row.Scan(&dl.ID, &dl.OriginalTopic, &dl.Group, &dl.EventType, &dl.Key, &headers, &dl.Payload, &dl.Error, &dl.Attempts, &dl.Status, &dl.CreatedAt, &dl.UpdatedAt)
+	if err != nil {
+		return model.DeadLetter{}, err
+	}
+	if err := json.Unmarshal(headers, &dl.Headers); err != nil {
+		return model.DeadLetter{}, err
+	}
+	return dl, nil
+}
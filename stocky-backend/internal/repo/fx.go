@@ -0,0 +1,88 @@
+package repo
+
+import (
+	"context"
+	"sort"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+// ledgerPlaceholderPrice values rewards in the ledger when no price is
+// available yet.
+var ledgerPlaceholderPrice = decimal.NewFromInt(3000)
+
+// fxRates returns INR rates for every currency quotes are in, INR itself at
+// 1. Like quotes, a failed lookup is logged and leaves the affected holdings
+// without a rate, so the valuation is flagged degraded instead of failing.
+func (r *RewardRepositoryImpl) fxRates(ctx context.Context, quotes map[string]model.Quote) map[string]model.FXRate {
+	rates := map[string]model.FXRate{model.CurrencyINR: {Currency: model.CurrencyINR, Rate: decimal.NewFromInt(1)}}
+	foreign := foreignCurrencies(quotes)
+	if len(foreign) == 0 || r.FX == nil {
+		return rates
+	}
+	found, err := r.FX.GetRates(ctx, foreign)
+	if err != nil {
+		logrus.WithError(err).Warn("FX rate lookup failed, valuing without rates")
+		return rates
+	}
+	for currency, rate := range found {
+		rates[currency] = rate
+	}
+	return rates
+}
+
+// foreignCurrencies lists the non-INR currencies quotes are in.
+func foreignCurrencies(quotes map[string]model.Quote) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, q := range quotes {
+		if c := q.QuoteCurrency(); c != model.CurrencyINR && !seen[c] {
+			seen[c] = true
+			out = append(out, c)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ledgerAmount is a ledger value in its native currency with the INR rate
+// it converts at; rate is nil when no rate is known.
+type ledgerAmount struct {
+	currency string
+	native   decimal.Decimal
+	rate     *decimal.Decimal
+}
+
+func (a ledgerAmount) inr() *decimal.Decimal {
+	if a.rate == nil {
+		return nil
+	}
+	inr := a.native.Mul(*a.rate)
+	return &inr
+}
+
+func (a ledgerAmount) scale(f decimal.Decimal) ledgerAmount {
+	a.native = a.native.Mul(f)
+	return a
+}
+
+// ledgerValue values shares of symbol at the current price for the ledger.
+func (r *RewardRepositoryImpl) ledgerValue(ctx context.Context, symbol string, shares decimal.Decimal) ledgerAmount {
+	one := decimal.NewFromInt(1)
+	placeholder := ledgerAmount{currency: model.CurrencyINR, native: shares.Mul(ledgerPlaceholderPrice), rate: &one}
+	if r.Prices == nil {
+		return placeholder
+	}
+	quotes := r.quotes(ctx, []string{symbol}, r.listings(ctx, []string{symbol}))
+	q, ok := quotes[symbol]
+	if !ok {
+		return placeholder
+	}
+	amount := ledgerAmount{currency: q.QuoteCurrency(), native: shares.Mul(q.Price)}
+	if rate, found := r.fxRates(ctx, quotes)[amount.currency]; found {
+		amount.rate = &rate.Rate
+	}
+	return amount
+}
@@ -0,0 +1,97 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+)
+
+// FXSource returns the latest INR rate for each requested currency.
+type FXSource interface {
+	GetRates(ctx context.Context, currencies []string) (map[string]model.FXRate, error)
+}
+
+type FXRepository interface {
+	FXSource
+	// RecordFXRates appends rates to the stored history, ignoring any
+	// already recorded for the same currency and time.
+	RecordFXRates(ctx context.Context, rates []model.FXRate) (int, error)
+}
+
+type FXRepositoryImpl struct {
+	DB *sql.DB
+}
+
+func (r *FXRepositoryImpl) RecordFXRates(ctx context.Context, rates []model.FXRate) (int, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO fx_rates (currency, as_of, rate, source) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (currency, as_of) DO NOTHING`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	recorded := 0
+	for _, rate := range rates {
+		res, err := stmt.ExecContext(ctx, rate.Currency, rate.AsOf.UTC(), rate.Rate.String(), rate.Source)
+		if err != nil {
+			return 0, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			recorded++
+		}
+	}
+	return recorded, tx.Commit()
+}
+
+// GetRates implements FXSource with the latest stored rates.
+func (r *FXRepositoryImpl) GetRates(ctx context.Context, currencies []string) (map[string]model.FXRate, error) {
+	rates := make(map[string]model.FXRate, len(currencies))
+	if len(currencies) == 0 {
+		return rates, nil
+	}
+	rows, err := r.DB.QueryContext(ctx, `SELECT DISTINCT ON (currency) currency, rate, as_of, source FROM fx_rates
+		WHERE currency = ANY($1) ORDER BY currency, as_of DESC`, pq.Array(currencies))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rate model.FXRate
+		if err := rows.Scan(&rate.Currency, &rate.Rate, &rate.AsOf, &rate.Source); err != nil {
+			return nil, err
+		}
+		rates[rate.Currency] = rate
+	}
+	return rates, rows.Err()
+}
+
+// loadFXCloses reads the last rate of each day for currencies, in the same
+// shape and window as loadCloses, so historical values convert at the rate
+// of their own day. Days are taken in UTC.
+func loadFXCloses(ctx context.Context, db *sql.DB, currencies []string, from, to string) (closeSeries, error) {
+	series := make(closeSeries)
+	if len(currencies) == 0 {
+		return series, nil
+	}
+	rows, err := db.QueryContext(ctx, `SELECT DISTINCT ON (currency, as_of::date) currency, to_char(as_of, 'YYYY-MM-DD'), rate, as_of FROM fx_rates
+		WHERE currency = ANY($1) AND as_of >= $2::date - 31 AND as_of < $3::date + 1
+		ORDER BY currency, as_of::date, as_of DESC`, pq.Array(currencies), from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c dailyClose
+		if err := rows.Scan(&c.quote.Symbol, &c.date, &c.quote.Price, &c.quote.AsOf); err != nil {
+			return nil, err
+		}
+		series[c.quote.Symbol] = append(series[c.quote.Symbol], c)
+	}
+	return series, rows.Err()
+}
@@ -0,0 +1,337 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/shopspring/decimal"
+)
+
+// HoldingsRepository stores the user_holdings read model. Every write is
+// guarded by an event key in projection_events so redelivered events are
+// applied exactly once.
+//
+// A reward counts towards its holding once its creation is applied and until
+// its reversal is, restated by every applied corporate action that took effect
+// after it was rewarded. Writes for one symbol are serialised so each sees
+// the others' rewards and actions.
+type HoldingsRepository interface {
+	// ApplyRewardCreated adds the reward's restated shares to its holding.
+	ApplyRewardCreated(ctx context.Context, reward model.Reward) (bool, error)
+	// ApplyRewardReversed takes out what the reward adds to its holding now.
+	// A reversal seen before the creation settles both, adding nothing.
+	ApplyRewardReversed(ctx context.Context, rewardID string) (bool, error)
+	// ApplyCorporateAction restates the shares acquired before the action
+	// took effect.
+	ApplyCorporateAction(ctx context.Context, eventKey string, action model.CorporateAction) (bool, error)
+	ListHoldings(ctx context.Context, userID string) (map[string]decimal.Decimal, error)
+	Rebuild(ctx context.Context) error
+}
+
+type HoldingsRepositoryImpl struct {
+	DB *sql.DB
+}
+
+// Event keys are derived from the domain ids rather than envelope ids so a
+// rebuild and a late redelivery agree on what has been applied.
+func RewardCreatedKey(rewardID string) string   { return "reward.created:" + rewardID }
+func RewardReversedKey(rewardID string) string  { return "reward.reversed:" + rewardID }
+func CorporateActionKey(actionID string) string { return "corporate_action:" + actionID }
+
+func (r *HoldingsRepositoryImpl) ApplyRewardCreated(ctx context.Context, reward model.Reward) (bool, error) {
+	return r.once(ctx, RewardCreatedKey(reward.ID), func(tx *sql.Tx) error {
+		if err := lockSymbol(ctx, tx, reward.StockSymbol); err != nil {
+			return err
+		}
+		actions, err := loadAdjustments(ctx, tx, reward.StockSymbol)
+		if err != nil {
+			return err
+		}
+		return addShares(ctx, tx, reward.UserID, reward.StockSymbol, actions.restate(reward.Shares, reward.RewardedAt))
+	})
+}
+
+func (r *HoldingsRepositoryImpl) ApplyRewardReversed(ctx context.Context, rewardID string) (bool, error) {
+	return r.once(ctx, RewardReversedKey(rewardID), func(tx *sql.Tx) error {
+		var rw model.Reward
+		if err := tx.QueryRowContext(ctx, `SELECT user_id, stock_symbol, shares, rewarded_at FROM rewards WHERE id = $1`, rewardID).
+			Scan(&rw.UserID, &rw.StockSymbol, &rw.Shares, &rw.RewardedAt); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrNotFound
+			}
+			return err
+		}
+		// Claim the creation before the symbol lock, in the order the
+		// creation takes them. Claiming it here means it was never added.
+		res, err := tx.ExecContext(ctx, `INSERT INTO projection_events (event_key) VALUES ($1) ON CONFLICT DO NOTHING`, RewardCreatedKey(rewardID))
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			return nil
+		}
+		if err := lockSymbol(ctx, tx, rw.StockSymbol); err != nil {
+			return err
+		}
+		actions, err := loadAdjustments(ctx, tx, rw.StockSymbol)
+		if err != nil {
+			return err
+		}
+		return addShares(ctx, tx, rw.UserID, rw.StockSymbol, actions.restate(rw.Shares, rw.RewardedAt).Neg())
+	})
+}
+
+func (r *HoldingsRepositoryImpl) ApplyCorporateAction(ctx context.Context, eventKey string, action model.CorporateAction) (bool, error) {
+	return r.once(ctx, eventKey, func(tx *sql.Tx) error {
+		if err := lockSymbol(ctx, tx, action.Symbol); err != nil {
+			return err
+		}
+		actions, err := loadAdjustments(ctx, tx, action.Symbol)
+		if err != nil {
+			return err
+		}
+		// Each holding grows by what its rewards from before the action
+		// add to it today, times the ratio less one.
+		rows, err := tx.QueryContext(ctx, `SELECT r.user_id, r.shares, r.rewarded_at FROM rewards r
+			WHERE r.stock_symbol = $1 AND r.rewarded_at < $2
+			AND EXISTS (SELECT 1 FROM projection_events WHERE event_key = 'reward.created:' || r.id)
+			AND NOT EXISTS (SELECT 1 FROM projection_events WHERE event_key = 'reward.reversed:' || r.id)`,
+			action.Symbol, action.EffectiveAt.UTC())
+		if err != nil {
+			return err
+		}
+		held := make(map[string]decimal.Decimal)
+		for rows.Next() {
+			var userID string
+			var shares decimal.Decimal
+			var rewardedAt time.Time
+			if err := rows.Scan(&userID, &shares, &rewardedAt); err != nil {
+				rows.Close()
+				return err
+			}
+			held[userID] = held[userID].Add(actions.restate(shares, rewardedAt))
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for userID, shares := range held {
+			if err := addShares(ctx, tx, userID, action.Symbol, shares.Mul(action.Ratio.Sub(decimal.NewFromInt(1)))); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO corporate_actions (id, symbol, action_type, ratio, effective_at) VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (id) DO NOTHING`, action.ID, action.Symbol, action.ActionType, action.Ratio.String(), action.EffectiveAt.UTC()); err != nil {
+			return err
+		}
+		// Open tax lots keep their total cost over more or fewer shares
+		_, err = tx.ExecContext(ctx, `UPDATE tax_lots SET shares = shares * $2, remaining_shares = remaining_shares * $2,
+			cost_per_share = cost_per_share / $2 WHERE symbol = $1 AND acquired_at < $3 AND remaining_shares > 0`,
+			action.Symbol, action.Ratio.String(), action.EffectiveAt.UTC())
+		return err
+	})
+}
+
+func (r *HoldingsRepositoryImpl) ListHoldings(ctx context.Context, userID string) (map[string]decimal.Decimal, error) {
+	rows, err := r.DB.QueryContext(ctx, `SELECT stock_symbol, shares FROM user_holdings WHERE user_id = $1 AND shares <> 0`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	holdings := make(map[string]decimal.Decimal)
+	for rows.Next() {
+		var symbol, sharesStr string
+		if err := rows.Scan(&symbol, &sharesStr); err != nil {
+			return nil, err
+		}
+		holdings[symbol], _ = decimal.NewFromString(sharesStr)
+	}
+	return holdings, rows.Err()
+}
+
+// ListHoldersAt returns the shares of symbol each user held just before at,
+// replayed from the rewards: those rewarded before at and not reversed by
+// then, restated by the corporate actions that took effect in between.
+func (r *HoldingsRepositoryImpl) ListHoldersAt(ctx context.Context, symbol string, at time.Time) (map[string]decimal.Decimal, error) {
+	actions, err := loadAdjustments(ctx, r.DB, symbol)
+	if err != nil {
+		return nil, err
+	}
+	actions = actions.before(at)
+	rows, err := r.DB.QueryContext(ctx, `SELECT user_id, shares, rewarded_at FROM rewards
+		WHERE stock_symbol = $1 AND rewarded_at < $2 AND (status = $3 OR reversed_at >= $2)`,
+		symbol, at.UTC(), model.RewardStatusActive)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	holders := make(map[string]decimal.Decimal)
+	for rows.Next() {
+		var userID string
+		var shares decimal.Decimal
+		var rewardedAt time.Time
+		if err := rows.Scan(&userID, &shares, &rewardedAt); err != nil {
+			return nil, err
+		}
+		holders[userID] = holders[userID].Add(actions.restate(shares, rewardedAt))
+	}
+	return holders, rows.Err()
+}
+
+func (r *HoldingsRepositoryImpl) once(ctx context.Context, eventKey string, apply func(tx *sql.Tx) error) (bool, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, `INSERT INTO projection_events (event_key) VALUES ($1) ON CONFLICT DO NOTHING`, eventKey)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if err := apply(tx); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// lockSymbol serialises projection writes for symbol until tx ends.
+func lockSymbol(ctx context.Context, tx *sql.Tx, symbol string) error {
+	_, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('holdings:' || $1))`, symbol)
+	return err
+}
+
+func addShares(ctx context.Context, tx *sql.Tx, userID, symbol string, delta decimal.Decimal) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO user_holdings (user_id, stock_symbol, shares, updated_at) VALUES ($1, $2, $3, now())
+		ON CONFLICT (user_id, stock_symbol) DO UPDATE SET shares = user_holdings.shares + EXCLUDED.shares, updated_at = now()`,
+		userID, symbol, delta.String())
+	return err
+}
+
+// adjustments are the corporate actions applied to one symbol.
+type adjustments []model.CorporateAction
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func loadAdjustments(ctx context.Context, q querier, symbol string) (adjustments, error) {
+	rows, err := q.QueryContext(ctx, `SELECT id, symbol, action_type, ratio, effective_at FROM corporate_actions WHERE symbol = $1`, symbol)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var actions adjustments
+	for rows.Next() {
+		var ca model.CorporateAction
+		if err := rows.Scan(&ca.ID, &ca.Symbol, &ca.ActionType, &ca.Ratio, &ca.EffectiveAt); err != nil {
+			return nil, err
+		}
+		actions = append(actions, ca)
+	}
+	return actions, rows.Err()
+}
+
+// restate converts shares acquired at into today's shares: every action
+// effective after at multiplies them by its ratio. Shares acquired at the
+// same instant as an action are taken to be acquired after it.
+func (a adjustments) restate(shares decimal.Decimal, at time.Time) decimal.Decimal {
+	for _, ca := range a {
+		if at.Before(ca.EffectiveAt) {
+			shares = shares.Mul(ca.Ratio)
+		}
+	}
+	return shares
+}
+
+// before keeps the actions that took effect before at.
+func (a adjustments) before(at time.Time) adjustments {
+	var kept adjustments
+	for _, ca := range a {
+		if ca.EffectiveAt.Before(at) {
+			kept = append(kept, ca)
+		}
+	}
+	return kept
+}
+
+// Rebuild recomputes user_holdings from scratch from the active rewards and
+// corporate actions, by the same rule the incremental writes follow. It runs
+// in one transaction, so readers see either the old or the new projection.
+func (r *HoldingsRepositoryImpl) Rebuild(ctx context.Context) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// Block concurrent projection writes until the rebuild commits.
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE user_holdings, projection_events IN EXCLUSIVE MODE`); err != nil {
+		return err
+	}
+
+	var keys []string
+	actions := make(map[string]adjustments)
+	rows, err := tx.QueryContext(ctx, `SELECT id, symbol, action_type, ratio, effective_at FROM corporate_actions ORDER BY effective_at`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var ca model.CorporateAction
+		if err := rows.Scan(&ca.ID, &ca.Symbol, &ca.ActionType, &ca.Ratio, &ca.EffectiveAt); err != nil {
+			rows.Close()
+			return err
+		}
+		keys = append(keys, CorporateActionKey(ca.ID))
+		actions[ca.Symbol] = append(actions[ca.Symbol], ca)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = tx.QueryContext(ctx, `SELECT id, user_id, stock_symbol, shares, rewarded_at, status FROM rewards`)
+	if err != nil {
+		return err
+	}
+	holdings := make(map[[2]string]decimal.Decimal)
+	for rows.Next() {
+		var rw model.Reward
+		if err := rows.Scan(&rw.ID, &rw.UserID, &rw.StockSymbol, &rw.Shares, &rw.RewardedAt, &rw.Status); err != nil {
+			rows.Close()
+			return err
+		}
+		keys = append(keys, RewardCreatedKey(rw.ID))
+		if rw.Status == model.RewardStatusReversed {
+			keys = append(keys, RewardReversedKey(rw.ID))
+			continue
+		}
+		k := [2]string{rw.UserID, rw.StockSymbol}
+		holdings[k] = holdings[k].Add(actions[rw.StockSymbol].restate(rw.Shares, rw.RewardedAt))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_holdings`); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM projection_events`); err != nil {
+		return err
+	}
+	for k, shares := range holdings {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO user_holdings (user_id, stock_symbol, shares) VALUES ($1, $2, $3)`, k[0], k[1], shares.String()); err != nil {
+			return err
+		}
+	}
+	for _, key := range keys {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO projection_events (event_key) VALUES ($1) ON CONFLICT DO NOTHING`, key); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,142 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/shopspring/decimal"
+)
+
+type InstrumentRepository interface {
+	UpsertInstruments(ctx context.Context, instruments []model.Instrument) error
+	// FindInstruments returns every listing whose symbol or ISIN equals code.
+	FindInstruments(ctx context.Context, code string) ([]model.Instrument, error)
+	SearchInstruments(ctx context.Context, query string, limit int) ([]model.Instrument, error)
+	ActiveSymbols(ctx context.Context) ([]string, error)
+	// AnyInstruments reports whether any listing has been imported.
+	AnyInstruments(ctx context.Context) (bool, error)
+}
+
+// Listing is the exchange a symbol is priced on and the currency it trades
+// in there.
+type Listing struct {
+	Exchange string
+	Currency string
+}
+
+// ListingSource returns the listing each symbol is priced on, for judging
+// its prices by that exchange's calendar and valuing them in its currency.
+// Symbols it does not know are left out.
+type ListingSource interface {
+	SymbolListings(ctx context.Context, symbols []string) (map[string]Listing, error)
+}
+
+type InstrumentRepositoryImpl struct {
+	DB *sql.DB
+}
+
+const instrumentColumns = `symbol, exchange, isin, name, COALESCE(sector, ''), lot_size, tick_size, currency, status, updated_at`
+
+// exchangePreference orders listings of the same security NSE first, then
+// BSE, then foreign exchanges.
+const exchangePreference = `CASE exchange WHEN 'NSE' THEN 0 WHEN 'BSE' THEN 1 ELSE 2 END, exchange`
+
+func (r *InstrumentRepositoryImpl) UpsertInstruments(ctx context.Context, instruments []model.Instrument) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO instruments (symbol, exchange, isin, name, sector, lot_size, tick_size, currency, status, updated_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, $9, now())
+		ON CONFLICT (exchange, symbol) DO UPDATE SET isin = EXCLUDED.isin, name = EXCLUDED.name, sector = EXCLUDED.sector,
+			lot_size = EXCLUDED.lot_size, tick_size = EXCLUDED.tick_size, currency = EXCLUDED.currency, status = EXCLUDED.status, updated_at = now()`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, in := range instruments {
+		if _, err := stmt.ExecContext(ctx, in.Symbol, in.Exchange, in.ISIN, in.Name, in.Sector, in.LotSize, in.TickSize.String(), in.Currency, in.Status); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *InstrumentRepositoryImpl) FindInstruments(ctx context.Context, code string) ([]model.Instrument, error) {
+	return r.query(ctx, `SELECT `+instrumentColumns+` FROM instruments WHERE symbol = $1 OR isin = $1 ORDER BY `+exchangePreference, code)
+}
+
+// SearchInstruments matches symbol prefixes and name substrings, exact
+// symbols first.
+func (r *InstrumentRepositoryImpl) SearchInstruments(ctx context.Context, query string, limit int) ([]model.Instrument, error) {
+	return r.query(ctx, `SELECT `+instrumentColumns+` FROM instruments
+		WHERE symbol ILIKE $1 || '%' OR name ILIKE '%' || $1 || '%' OR isin = UPPER($1)
+		ORDER BY (UPPER(symbol) = UPPER($1)) DESC, symbol, `+exchangePreference+` LIMIT $2`, query, limit)
+}
+
+func (r *InstrumentRepositoryImpl) ActiveSymbols(ctx context.Context) ([]string, error) {
+	rows, err := r.DB.QueryContext(ctx, `SELECT DISTINCT symbol FROM instruments WHERE status = 'active' ORDER BY symbol`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var symbols []string
+	for rows.Next() {
+		var symbol string
+		if err := rows.Scan(&symbol); err != nil {
+			return nil, err
+		}
+		symbols = append(symbols, symbol)
+	}
+	return symbols, rows.Err()
+}
+
+func (r *InstrumentRepositoryImpl) AnyInstruments(ctx context.Context) (bool, error) {
+	var imported bool
+	err := r.DB.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM instruments)`).Scan(&imported)
+	return imported, err
+}
+
+// SymbolListings prefers the NSE listing, as prices do.
+func (r *InstrumentRepositoryImpl) SymbolListings(ctx context.Context, symbols []string) (map[string]Listing, error) {
+	rows, err := r.DB.QueryContext(ctx, `SELECT DISTINCT ON (symbol) symbol, exchange, currency FROM instruments
+		WHERE symbol = ANY($1) ORDER BY symbol, `+exchangePreference, pq.Array(symbols))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	listings := make(map[string]Listing, len(symbols))
+	for rows.Next() {
+		var symbol string
+		var l Listing
+		if err := rows.Scan(&symbol, &l.Exchange, &l.Currency); err != nil {
+			return nil, err
+		}
+		listings[symbol] = l
+	}
+	return listings, rows.Err()
+}
+
+func (r *InstrumentRepositoryImpl) query(ctx context.Context, query string, args ...interface{}) ([]model.Instrument, error) {
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []model.Instrument
+	for rows.Next() {
+		var in model.Instrument
+		var tick string
+		if err := rows.Scan(&in.Symbol, &in.Exchange, &in.ISIN, &in.Name, &in.Sector, &in.LotSize, &tick, &in.Currency, &in.Status, &in.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if in.TickSize, err = decimal.NewFromString(tick); err != nil {
+			return nil, err
+		}
+		out = append(out, in)
+	}
+	return out, rows.Err()
+}
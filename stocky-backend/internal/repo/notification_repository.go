@@ -0,0 +1,220 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+)
+
+type NotificationRepository interface {
+	ListPreferences(ctx context.Context, userID string) ([]model.NotificationPreference, error)
+	// ListEnabledPreferences returns the enabled channels of every user in
+	// userIDs.
+	ListEnabledPreferences(ctx context.Context, userIDs []string) ([]model.NotificationPreference, error)
+	UpsertPreference(ctx context.Context, pref model.NotificationPreference) (model.NotificationPreference, error)
+	DeletePreference(ctx context.Context, userID, channel string) error
+
+	// EnqueueNotifications stores notifications as pending and due now,
+	// skipping any already queued for the same user, channel and dedup key.
+	// It returns how many were new.
+	EnqueueNotifications(ctx context.Context, notifications []model.Notification) (int, error)
+	// ClaimDueNotifications leases up to limit pending notifications due by
+	// now, counting an attempt on each. A claim not settled before the lease
+	// ends is picked up again.
+	ClaimDueNotifications(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.Notification, error)
+	MarkNotificationSent(ctx context.Context, id string, at time.Time) error
+	// MarkNotificationFailed records a failed attempt, retrying at retryAt
+	// or, when nil, giving up.
+	MarkNotificationFailed(ctx context.Context, id string, deliveryErr string, retryAt *time.Time) error
+	ListNotifications(ctx context.Context, userID string, limit int) ([]model.Notification, error)
+
+	// ScheduleDividend stores a declared dividend until its holders are
+	// notified. Storing one again keeps the first.
+	ScheduleDividend(ctx context.Context, dividend model.ScheduledDividend) error
+	// ListDueDividends returns up to limit dividends entitled by now whose
+	// holders are not yet notified, earliest first.
+	ListDueDividends(ctx context.Context, now time.Time, limit int) ([]model.ScheduledDividend, error)
+	MarkDividendNotified(ctx context.Context, dividendID string, at time.Time) error
+}
+
+type NotificationRepositoryImpl struct {
+	DB *sql.DB
+}
+
+const preferenceColumns = `user_id, channel, address, locale, enabled, kinds, updated_at`
+
+const notificationColumns = `id, user_id, channel, kind, address, subject, body, dedup_key, status, attempts, next_attempt_at,
+	COALESCE(last_error, ''), created_at, sent_at`
+
+func (r *NotificationRepositoryImpl) ListPreferences(ctx context.Context, userID string) ([]model.NotificationPreference, error) {
+	return r.queryPreferences(ctx, `SELECT `+preferenceColumns+` FROM notification_preferences WHERE user_id = $1 ORDER BY channel`, userID)
+}
+
+func (r *NotificationRepositoryImpl) ListEnabledPreferences(ctx context.Context, userIDs []string) ([]model.NotificationPreference, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+	return r.queryPreferences(ctx, `SELECT `+preferenceColumns+` FROM notification_preferences
+		WHERE user_id = ANY($1) AND enabled ORDER BY user_id, channel`, pq.Array(userIDs))
+}
+
+func (r *NotificationRepositoryImpl) UpsertPreference(ctx context.Context, pref model.NotificationPreference) (model.NotificationPreference, error) {
+	kinds := pref.Kinds
+	if kinds == nil {
+		kinds = []string{}
+	}
+	row := r.DB.QueryRowContext(ctx, `INSERT INTO notification_preferences (user_id, channel, address, locale, enabled, kinds, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, now())
+		ON CONFLICT (user_id, channel) DO UPDATE SET address = EXCLUDED.address, locale = EXCLUDED.locale,
+			enabled = EXCLUDED.enabled, kinds = EXCLUDED.kinds, updated_at = now()
+		RETURNING `+preferenceColumns,
+		pref.UserID, pref.Channel, pref.Address, pref.Locale, pref.Enabled, pq.Array(kinds))
+	return scanPreference(row)
+}
+
+func (r *NotificationRepositoryImpl) DeletePreference(ctx context.Context, userID, channel string) error {
+	res, err := r.DB.ExecContext(ctx, `DELETE FROM notification_preferences WHERE user_id = $1 AND channel = $2`, userID, channel)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *NotificationRepositoryImpl) EnqueueNotifications(ctx context.Context, notifications []model.Notification) (int, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	queued := 0
+	now := time.Now().UTC()
+	for _, n := range notifications {
+		res, err := tx.ExecContext(ctx, `INSERT INTO notifications (user_id, channel, kind, address, subject, body, dedup_key, next_attempt_at, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
+			ON CONFLICT (user_id, channel, dedup_key) DO NOTHING`,
+			n.UserID, n.Channel, n.Kind, n.Address, n.Subject, n.Body, n.DedupKey, now)
+		if err != nil {
+			return 0, err
+		}
+		if affected, _ := res.RowsAffected(); affected > 0 {
+			queued++
+		}
+	}
+	return queued, tx.Commit()
+}
+
+func (r *NotificationRepositoryImpl) ClaimDueNotifications(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.Notification, error) {
+	// SKIP LOCKED lets every replica dispatch without sending twice.
+	return r.queryNotifications(ctx, `UPDATE notifications SET attempts = attempts + 1, next_attempt_at = $2
+		WHERE id IN (SELECT id FROM notifications WHERE status = 'pending' AND next_attempt_at <= $1
+			ORDER BY next_attempt_at LIMIT $3 FOR UPDATE SKIP LOCKED)
+		RETURNING `+notificationColumns, now.UTC(), now.Add(lease).UTC(), limit)
+}
+
+func (r *NotificationRepositoryImpl) MarkNotificationSent(ctx context.Context, id string, at time.Time) error {
+	_, err := r.DB.ExecContext(ctx, `UPDATE notifications SET status = 'sent', sent_at = $2, next_attempt_at = NULL, last_error = NULL
+		WHERE id = $1`, id, at.UTC())
+	return err
+}
+
+func (r *NotificationRepositoryImpl) MarkNotificationFailed(ctx context.Context, id string, deliveryErr string, retryAt *time.Time) error {
+	var next sql.NullTime
+	if retryAt != nil {
+		next = sql.NullTime{Time: retryAt.UTC(), Valid: true}
+	}
+	_, err := r.DB.ExecContext(ctx, `UPDATE notifications SET last_error = $2, next_attempt_at = $3,
+		status = CASE WHEN $3::timestamp IS NULL THEN 'failed' ELSE 'pending' END
+		WHERE id = $1`, id, deliveryErr, next)
+	return err
+}
+
+func (r *NotificationRepositoryImpl) ListNotifications(ctx context.Context, userID string, limit int) ([]model.Notification, error) {
+	return r.queryNotifications(ctx, `SELECT `+notificationColumns+` FROM notifications WHERE user_id = $1
+		ORDER BY created_at DESC LIMIT $2`, userID, limit)
+}
+
+func (r *NotificationRepositoryImpl) ScheduleDividend(ctx context.Context, d model.ScheduledDividend) error {
+	_, err := r.DB.ExecContext(ctx, `INSERT INTO dividends (dividend_id, symbol, amount_per_share, currency, record_date, pay_date, entitled_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (dividend_id) DO NOTHING`,
+		d.DividendID, d.Symbol, d.AmountPerShare, d.Currency, d.RecordDate, d.PayDate, d.EntitledAt.UTC())
+	return err
+}
+
+func (r *NotificationRepositoryImpl) ListDueDividends(ctx context.Context, now time.Time, limit int) ([]model.ScheduledDividend, error) {
+	rows, err := r.DB.QueryContext(ctx, `SELECT dividend_id, symbol, amount_per_share, currency,
+		to_char(record_date, 'YYYY-MM-DD'), to_char(pay_date, 'YYYY-MM-DD'), entitled_at
+		FROM dividends WHERE notified_at IS NULL AND entitled_at <= $1 ORDER BY entitled_at LIMIT $2`, now.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var dividends []model.ScheduledDividend
+	for rows.Next() {
+		var d model.ScheduledDividend
+		if err := rows.Scan(&d.DividendID, &d.Symbol, &d.AmountPerShare, &d.Currency, &d.RecordDate, &d.PayDate, &d.EntitledAt); err != nil {
+			return nil, err
+		}
+		dividends = append(dividends, d)
+	}
+	return dividends, rows.Err()
+}
+
+func (r *NotificationRepositoryImpl) MarkDividendNotified(ctx context.Context, dividendID string, at time.Time) error {
+	_, err := r.DB.ExecContext(ctx, `UPDATE dividends SET notified_at = $2 WHERE dividend_id = $1 AND notified_at IS NULL`,
+		dividendID, at.UTC())
+	return err
+}
+
+func (r *NotificationRepositoryImpl) queryPreferences(ctx context.Context, query string, args ...interface{}) ([]model.NotificationPreference, error) {
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var prefs []model.NotificationPreference
+	for rows.Next() {
+		pref, err := scanPreference(rows)
+		if err != nil {
+			return nil, err
+		}
+		prefs = append(prefs, pref)
+	}
+	return prefs, rows.Err()
+}
+
+func scanPreference(row rowScanner) (model.NotificationPreference, error) {
+	var pref model.NotificationPreference
+	err := row.Scan(&pref.UserID, &pref.Channel, &pref.Address, &pref.Locale, &pref.Enabled, pq.Array(&pref.Kinds), &pref.UpdatedAt)
+	return pref, err
+}
+
+func (r *NotificationRepositoryImpl) queryNotifications(ctx context.Context, query string, args ...interface{}) ([]model.Notification, error) {
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var notifications []model.Notification
+	for rows.Next() {
+		var n model.Notification
+		var next, sent sql.NullTime
+		if err := rows.Scan(&n.ID, &n.UserID, &n.Channel, &n.Kind, &n.Address, &n.Subject, &n.Body, &n.DedupKey, &n.Status,
+			&n.Attempts, &next, &n.LastError, &n.CreatedAt, &sent); err != nil {
+			return nil, err
+		}
+		if next.Valid {
+			n.NextAttemptAt = &next.Time
+		}
+		if sent.Valid {
+			n.SentAt = &sent.Time
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
@@ -0,0 +1,148 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/events"
+	"github.com/sirupsen/logrus"
+)
+
+// OutboxEvent is an event waiting in the outbox to be published.
+type OutboxEvent struct {
+	ID       int64
+	Message  events.Message
+	Attempts int
+}
+
+// OutboxRepository holds events written alongside the change they describe
+// until they have been published.
+type OutboxRepository interface {
+	// ClaimDueEvents leases up to limit unpublished events due by now, oldest
+	// first, counting an attempt on each.
+	ClaimDueEvents(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]OutboxEvent, error)
+	MarkEventPublished(ctx context.Context, id int64, at time.Time) error
+	MarkEventFailed(ctx context.Context, id int64, publishErr string, retryAt time.Time) error
+	// DeletePublishedEvents deletes up to limit events published before
+	// before, returning how many it deleted.
+	DeletePublishedEvents(ctx context.Context, before time.Time, limit int) (int, error)
+}
+
+type OutboxRepositoryImpl struct {
+	DB *sql.DB
+}
+
+// outboxHold is how long an event written by a request waits before the
+// relay may claim it, leaving the writer time to publish it itself.
+const outboxHold = 30 * time.Second
+
+func (r *OutboxRepositoryImpl) ClaimDueEvents(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]OutboxEvent, error) {
+	// SKIP LOCKED lets every replica relay without publishing twice.
+	rows, err := r.DB.QueryContext(ctx, `UPDATE event_outbox SET attempts = attempts + 1, next_attempt_at = $2
+		WHERE id IN (SELECT id FROM event_outbox WHERE published_at IS NULL AND next_attempt_at <= $1
+			ORDER BY id LIMIT $3 FOR UPDATE SKIP LOCKED)
+		RETURNING id, topic, msg_key, type, headers, value, attempts`, now.UTC(), now.Add(lease).UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var due []OutboxEvent
+	for rows.Next() {
+		var e OutboxEvent
+		var headers []byte
+		if err := rows.Scan(&e.ID, &e.Message.Topic, &e.Message.Key, &e.Message.Type, &headers, &e.Message.Value, &e.Attempts); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(headers, &e.Message.Headers); err != nil {
+			return nil, err
+		}
+		due = append(due, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// Claimed rows come back in no particular order
+	sort.Slice(due, func(i, j int) bool { return due[i].ID < due[j].ID })
+	return due, nil
+}
+
+func (r *OutboxRepositoryImpl) MarkEventPublished(ctx context.Context, id int64, at time.Time) error {
+	_, err := r.DB.ExecContext(ctx, `UPDATE event_outbox SET published_at = $2, last_error = NULL WHERE id = $1`, id, at.UTC())
+	return err
+}
+
+func (r *OutboxRepositoryImpl) MarkEventFailed(ctx context.Context, id int64, publishErr string, retryAt time.Time) error {
+	_, err := r.DB.ExecContext(ctx, `UPDATE event_outbox SET last_error = $2, next_attempt_at = $3 WHERE id = $1`, id, publishErr, retryAt.UTC())
+	return err
+}
+
+func (r *OutboxRepositoryImpl) DeletePublishedEvents(ctx context.Context, before time.Time, limit int) (int, error) {
+	res, err := r.DB.ExecContext(ctx, `DELETE FROM event_outbox WHERE id IN (
+		SELECT id FROM event_outbox WHERE published_at < $1 ORDER BY id LIMIT $2)`, before.UTC(), limit)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// OutboxPublisher is an events.EventPublisher for events that are the whole
+// change, such as an announced corporate action: each is committed to the
+// outbox on its own, then published, leaving the relay to retry it.
+type OutboxPublisher struct {
+	DB     *sql.DB
+	Events events.EventPublisher
+}
+
+// Publish returns once msg is in the outbox, whether or not it could be
+// published yet.
+func (p *OutboxPublisher) Publish(ctx context.Context, msg events.Message) error {
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	id, err := enqueueEvent(ctx, tx, msg)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	publishEnqueued(ctx, p.DB, p.Events, id, msg)
+	return nil
+}
+
+func (p *OutboxPublisher) Close() error { return nil }
+
+// enqueueEvent writes msg to the outbox in tx and returns its id.
+func enqueueEvent(ctx context.Context, tx *sql.Tx, msg events.Message) (int64, error) {
+	headers, err := json.Marshal(msg.Headers)
+	if err != nil {
+		return 0, err
+	}
+	if msg.Headers == nil {
+		headers = []byte(`{}`)
+	}
+	var id int64
+	err = tx.QueryRowContext(ctx, `INSERT INTO event_outbox (topic, msg_key, type, headers, value, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		msg.Topic, msg.Key, msg.Type, headers, []byte(msg.Value), time.Now().Add(outboxHold).UTC()).Scan(&id)
+	return id, err
+}
+
+// publishEnqueued publishes an event its writer has committed to the outbox
+// and marks it published. On failure the relay publishes it later.
+func publishEnqueued(ctx context.Context, db *sql.DB, publisher events.EventPublisher, id int64, msg events.Message) {
+	if err := publisher.Publish(ctx, msg); err != nil {
+		logrus.WithError(err).WithField("type", msg.Type).Warn("Failed to publish event, leaving it to the outbox relay")
+		return
+	}
+	outbox := &OutboxRepositoryImpl{DB: db}
+	if err := outbox.MarkEventPublished(ctx, id, time.Now()); err != nil {
+		logrus.WithError(err).WithField("type", msg.Type).Warn("Failed to mark event published, the relay will publish it again")
+	}
+}
@@ -0,0 +1,105 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+)
+
+type CandleRepository interface {
+	// ListCandles returns up to limit of the latest candles starting in
+	// [from, to), oldest first. Zero times leave that end open.
+	ListCandles(ctx context.Context, symbol, interval string, from, to time.Time, limit int) ([]model.Candle, error)
+}
+
+// CandleStart returns the start of the interval containing t, in loc, so
+// hour and day candles line up with the exchange clock rather than UTC.
+func CandleStart(interval string, t time.Time, loc *time.Location) (time.Time, error) {
+	t = t.In(loc)
+	switch interval {
+	case model.CandleMinute:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc), nil
+	case model.CandleHour:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc), nil
+	case model.CandleDay:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc), nil
+	}
+	return time.Time{}, fmt.Errorf("unknown candle interval %q", interval)
+}
+
+func (r *PriceRepositoryImpl) location() *time.Location {
+	if r.Location != nil {
+		return r.Location
+	}
+	return time.UTC
+}
+
+// recordPrice appends an applied price to price_history and folds it into
+// its candles and the day's close. Prices already in history are skipped,
+// so redelivered prices never count twice. Callers only pass prices newer
+// than the stored one, so each becomes its candles' close.
+func (r *PriceRepositoryImpl) recordPrice(ctx context.Context, tx *sql.Tx, q model.Quote, volume int64) error {
+	res, err := tx.ExecContext(ctx, `INSERT INTO price_history (symbol, as_of, price, volume, source) VALUES ($1, $2, $3, NULLIF($4, 0), $5)
+		ON CONFLICT (symbol, as_of) DO NOTHING`, q.Symbol, q.AsOf.UTC(), q.Price.String(), volume, q.Source)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+	loc := r.location()
+	for _, interval := range model.CandleIntervals {
+		start, _ := CandleStart(interval, q.AsOf, loc)
+		_, err := tx.ExecContext(ctx, `INSERT INTO price_candles (symbol, interval, bucket_start, open, high, low, close, volume, last_at)
+			VALUES ($1, $2, $3, $4, $4, $4, $4, $5, $6)
+			ON CONFLICT (symbol, interval, bucket_start) DO UPDATE SET
+				high = GREATEST(price_candles.high, EXCLUDED.high),
+				low = LEAST(price_candles.low, EXCLUDED.low),
+				close = EXCLUDED.close,
+				volume = price_candles.volume + EXCLUDED.volume,
+				ticks = price_candles.ticks + 1,
+				last_at = EXCLUDED.last_at
+			WHERE price_candles.last_at < EXCLUDED.last_at`,
+			q.Symbol, interval, start.UTC(), q.Price.String(), volume, q.AsOf.UTC())
+		if err != nil {
+			return err
+		}
+	}
+	// The day candle's close is that day's closing price for valuation
+	_, err = tx.ExecContext(ctx, `INSERT INTO daily_closes (symbol, trade_date, close, as_of) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (symbol, trade_date) DO UPDATE SET close = EXCLUDED.close, as_of = EXCLUDED.as_of
+		WHERE daily_closes.as_of <= EXCLUDED.as_of`, q.Symbol, q.AsOf.In(loc).Format("2006-01-02"), q.Price.String(), q.AsOf.UTC())
+	return err
+}
+
+func (r *PriceRepositoryImpl) ListCandles(ctx context.Context, symbol, interval string, from, to time.Time, limit int) ([]model.Candle, error) {
+	rows, err := r.DB.QueryContext(ctx, `SELECT symbol, interval, bucket_start, open, high, low, close, volume, ticks, last_at FROM (
+			SELECT * FROM price_candles
+			WHERE symbol = $1 AND interval = $2 AND ($3::timestamp IS NULL OR bucket_start >= $3) AND ($4::timestamp IS NULL OR bucket_start < $4)
+			ORDER BY bucket_start DESC LIMIT $5
+		) latest ORDER BY bucket_start`, symbol, interval, nullTime(from), nullTime(to), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var candles []model.Candle
+	for rows.Next() {
+		var c model.Candle
+		if err := rows.Scan(&c.Symbol, &c.Interval, &c.Start, &c.Open, &c.High, &c.Low, &c.Close, &c.Volume, &c.Ticks, &c.LastAt); err != nil {
+			return nil, err
+		}
+		c.Start, c.LastAt = c.Start.UTC(), c.LastAt.UTC()
+		candles = append(candles, c)
+	}
+	return candles, rows.Err()
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	if t.IsZero() {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
@@ -0,0 +1,146 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/shopspring/decimal"
+)
+
+type PriceQuarantineRepository interface {
+	// QuarantinePrice holds qp for review. A symbol has at most one pending
+	// entry: while one exists, qp replaces it if it is no older and the
+	// entry's tick count goes up either way.
+	QuarantinePrice(ctx context.Context, qp model.QuarantinedPrice) (string, error)
+	ListQuarantinedPrices(ctx context.Context, status string, limit int) ([]model.QuarantinedPrice, error)
+	GetQuarantinedPrice(ctx context.Context, id string) (model.QuarantinedPrice, error)
+	// ResolveQuarantinedPrice moves a pending entry to status and returns
+	// ErrNotFound if no pending entry has that id.
+	ResolveQuarantinedPrice(ctx context.Context, id, status, reviewer string) error
+	// ApproveQuarantinedPrice approves a pending entry with a positive price
+	// and applies it as a tick in the same transaction, returning the entry
+	// and whether it became the current price. It returns ErrNotFound if no
+	// such entry is pending.
+	ApproveQuarantinedPrice(ctx context.Context, id, reviewer string) (model.QuarantinedPrice, bool, error)
+}
+
+type PriceQuarantineRepositoryImpl struct {
+	DB *sql.DB
+	// Prices applies approved entries
+	Prices *PriceRepositoryImpl
+}
+
+const quarantineColumns = `id, symbol, price, as_of, COALESCE(volume, 0), source, reason, previous_price, change_pct, band_pct,
+	ticks, status, COALESCE(reviewed_by, ''), created_at, reviewed_at`
+
+// quarantineRefresh replaces a pending entry's price with a repeat's when
+// the repeat is no older.
+var quarantineRefresh = func() string {
+	var set []string
+	for _, col := range []string{"price", "as_of", "volume", "source", "reason", "previous_price", "change_pct", "band_pct"} {
+		set = append(set, col+` = CASE WHEN EXCLUDED.as_of >= price_quarantine.as_of THEN EXCLUDED.`+col+` ELSE price_quarantine.`+col+` END`)
+	}
+	return strings.Join(set, ", ")
+}()
+
+func (r *PriceQuarantineRepositoryImpl) QuarantinePrice(ctx context.Context, qp model.QuarantinedPrice) (string, error) {
+	var id string
+	err := r.DB.QueryRowContext(ctx, `INSERT INTO price_quarantine (symbol, price, as_of, volume, source, reason, previous_price, change_pct, band_pct)
+		VALUES ($1, $2, $3, NULLIF($4, 0), $5, $6, $7, $8, $9)
+		ON CONFLICT (symbol) WHERE status = 'pending' DO UPDATE SET `+quarantineRefresh+`, ticks = price_quarantine.ticks + 1
+		RETURNING id`,
+		qp.Symbol, qp.Price.String(), qp.AsOf.UTC(), qp.Volume, qp.Source, qp.Reason,
+		nullDecimal(qp.PreviousPrice), nullDecimal(qp.ChangePct), qp.BandPct.String()).Scan(&id)
+	return id, err
+}
+
+func (r *PriceQuarantineRepositoryImpl) ListQuarantinedPrices(ctx context.Context, status string, limit int) ([]model.QuarantinedPrice, error) {
+	query := `SELECT ` + quarantineColumns + ` FROM price_quarantine WHERE ($1 = '' OR status = $1) ORDER BY created_at DESC LIMIT $2`
+	rows, err := r.DB.QueryContext(ctx, query, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var prices []model.QuarantinedPrice
+	for rows.Next() {
+		qp, err := scanQuarantinedPrice(rows)
+		if err != nil {
+			return nil, err
+		}
+		prices = append(prices, qp)
+	}
+	return prices, rows.Err()
+}
+
+func (r *PriceQuarantineRepositoryImpl) GetQuarantinedPrice(ctx context.Context, id string) (model.QuarantinedPrice, error) {
+	row := r.DB.QueryRowContext(ctx, `SELECT `+quarantineColumns+` FROM price_quarantine WHERE id = $1`, id)
+	qp, err := scanQuarantinedPrice(row)
+	if err == sql.ErrNoRows {
+		return model.QuarantinedPrice{}, ErrNotFound
+	}
+	return qp, err
+}
+
+func (r *PriceQuarantineRepositoryImpl) ResolveQuarantinedPrice(ctx context.Context, id, status, reviewer string) error {
+	res, err := r.DB.ExecContext(ctx, `UPDATE price_quarantine SET status = $2, reviewed_by = NULLIF($3, ''), reviewed_at = now()
+		WHERE id = $1 AND status = 'pending'`, id, status, reviewer)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PriceQuarantineRepositoryImpl) ApproveQuarantinedPrice(ctx context.Context, id, reviewer string) (model.QuarantinedPrice, bool, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return model.QuarantinedPrice{}, false, err
+	}
+	defer tx.Rollback()
+	qp, err := scanQuarantinedPrice(tx.QueryRowContext(ctx, `UPDATE price_quarantine SET status = 'approved', reviewed_by = NULLIF($2, ''), reviewed_at = now()
+		WHERE id = $1 AND status = 'pending' AND price > 0 RETURNING `+quarantineColumns, id, reviewer))
+	if err == sql.ErrNoRows {
+		return model.QuarantinedPrice{}, false, ErrNotFound
+	}
+	if err != nil {
+		return model.QuarantinedPrice{}, false, err
+	}
+	q := model.Quote{Symbol: qp.Symbol, Price: qp.Price, AsOf: qp.AsOf, Source: qp.Source}
+	applied, err := r.Prices.applyTick(ctx, tx, q, qp.Volume)
+	if err != nil {
+		return model.QuarantinedPrice{}, false, err
+	}
+	return qp, applied, tx.Commit()
+}
+
+func scanQuarantinedPrice(row rowScanner) (model.QuarantinedPrice, error) {
+	var qp model.QuarantinedPrice
+	var previous, change decimal.NullDecimal
+	var reviewed sql.NullTime
+	err := row.Scan(&qp.ID, &qp.Symbol, &qp.Price, &qp.AsOf, &qp.Volume, &qp.Source, &qp.Reason, &previous, &change, &qp.BandPct,
+		&qp.Ticks, &qp.Status, &qp.ReviewedBy, &qp.CreatedAt, &reviewed)
+	if err != nil {
+		return model.QuarantinedPrice{}, err
+	}
+	if previous.Valid {
+		qp.PreviousPrice = &previous.Decimal
+	}
+	if change.Valid {
+		qp.ChangePct = &change.Decimal
+	}
+	if reviewed.Valid {
+		qp.ReviewedAt = &reviewed.Time
+	}
+	return qp, nil
+}
+
+func nullDecimal(d *decimal.Decimal) sql.NullString {
+	if d == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: d.String(), Valid: true}
+}
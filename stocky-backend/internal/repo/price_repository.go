@@ -0,0 +1,163 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+)
+
+// PriceSource is the batch price lookup the repository values holdings with.
+// Each valuation makes one call for all the symbols it needs.
+type PriceSource interface {
+	GetPrices(ctx context.Context, symbols []string) (map[string]model.Quote, error)
+}
+
+type PriceRepository interface {
+	// UpsertPrices writes quotes into stock_prices, keeping the newer of the
+	// stored and incoming price for each symbol, and records the ones it
+	// applies like ticks. It returns the applied quotes in their stored
+	// currency.
+	UpsertPrices(ctx context.Context, quotes []model.Quote) ([]model.Quote, error)
+	ApplyTick(ctx context.Context, q model.Quote, volume int64) (bool, error)
+	HeldSymbols(ctx context.Context) ([]string, error)
+	RecordCloses(ctx context.Context, tradeDate time.Time, quotes []model.Quote) error
+	StartPriceRun(ctx context.Context, holder string, symbols int) (string, error)
+	FinishPriceRun(ctx context.Context, id, status string, updated int, runErr error) error
+	ListPriceRuns(ctx context.Context, limit int) ([]model.PriceUpdateRun, error)
+}
+
+// priceCurrency is the currency stored with a price: the quote's own ($3)
+// if it has one, else the instrument's, else INR.
+const priceCurrency = `COALESCE(NULLIF($3, ''), (SELECT currency FROM instruments WHERE symbol = $1 ORDER BY ` + exchangePreference + ` LIMIT 1), 'INR')`
+
+type PriceRepositoryImpl struct {
+	DB *sql.DB
+	// Location buckets candles and dates closes; nil means UTC
+	Location *time.Location
+}
+
+func (r *PriceRepositoryImpl) UpsertPrices(ctx context.Context, quotes []model.Quote) ([]model.Quote, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO stock_prices (symbol, price, currency, updated_at) VALUES ($1, $2, `+priceCurrency+`, $4)
+		ON CONFLICT (symbol) DO UPDATE SET price = EXCLUDED.price, currency = EXCLUDED.currency, updated_at = EXCLUDED.updated_at
+		WHERE stock_prices.updated_at <= EXCLUDED.updated_at
+		RETURNING currency`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	var applied []model.Quote
+	for _, q := range quotes {
+		err := stmt.QueryRowContext(ctx, q.Symbol, q.Price.String(), q.Currency, q.AsOf.UTC()).Scan(&q.Currency)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := r.recordPrice(ctx, tx, q, 0); err != nil {
+			return nil, err
+		}
+		applied = append(applied, q)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// HeldSymbols lists every symbol some user currently holds.
+func (r *PriceRepositoryImpl) HeldSymbols(ctx context.Context) ([]string, error) {
+	rows, err := r.DB.QueryContext(ctx, `SELECT DISTINCT stock_symbol FROM user_holdings WHERE shares <> 0 ORDER BY stock_symbol`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var symbols []string
+	for rows.Next() {
+		var symbol string
+		if err := rows.Scan(&symbol); err != nil {
+			return nil, err
+		}
+		symbols = append(symbols, symbol)
+	}
+	return symbols, rows.Err()
+}
+
+func (r *PriceRepositoryImpl) StartPriceRun(ctx context.Context, holder string, symbols int) (string, error) {
+	var id string
+	err := r.DB.QueryRowContext(ctx, `INSERT INTO price_update_runs (holder, symbols_total) VALUES ($1, $2) RETURNING id`, holder, symbols).Scan(&id)
+	return id, err
+}
+
+func (r *PriceRepositoryImpl) FinishPriceRun(ctx context.Context, id, status string, updated int, runErr error) error {
+	var msg sql.NullString
+	if runErr != nil {
+		msg = sql.NullString{String: runErr.Error(), Valid: true}
+	}
+	_, err := r.DB.ExecContext(ctx, `UPDATE price_update_runs SET status = $2, symbols_updated = $3, error = $4, finished_at = now() WHERE id = $1`,
+		id, status, updated, msg)
+	return err
+}
+
+func (r *PriceRepositoryImpl) ListPriceRuns(ctx context.Context, limit int) ([]model.PriceUpdateRun, error) {
+	rows, err := r.DB.QueryContext(ctx, `SELECT id, holder, status, symbols_total, symbols_updated, COALESCE(error, ''), started_at, finished_at
+		FROM price_update_runs ORDER BY started_at DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var runs []model.PriceUpdateRun
+	for rows.Next() {
+		var run model.PriceUpdateRun
+		var finished sql.NullTime
+		if err := rows.Scan(&run.ID, &run.Holder, &run.Status, &run.SymbolsTotal, &run.SymbolsUpdated, &run.Error, &run.StartedAt, &finished); err != nil {
+			return nil, err
+		}
+		if finished.Valid {
+			run.FinishedAt = &finished.Time
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// ApplyTick stores q as the symbol's current price if it is newer than the
+// stored one and records it in price_history, candles and the day's close,
+// in one transaction. It
+// reports false, writing nothing, for duplicate or out-of-order ticks.
+func (r *PriceRepositoryImpl) ApplyTick(ctx context.Context, q model.Quote, volume int64) (bool, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	applied, err := r.applyTick(ctx, tx, q, volume)
+	if err != nil || !applied {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// applyTick is ApplyTick within the caller's transaction.
+func (r *PriceRepositoryImpl) applyTick(ctx context.Context, tx *sql.Tx, q model.Quote, volume int64) (bool, error) {
+	res, err := tx.ExecContext(ctx, `INSERT INTO stock_prices (symbol, price, currency, updated_at) VALUES ($1, $2, `+priceCurrency+`, $4)
+		ON CONFLICT (symbol) DO UPDATE SET price = EXCLUDED.price, currency = EXCLUDED.currency, updated_at = EXCLUDED.updated_at
+		WHERE stock_prices.updated_at < EXCLUDED.updated_at`, q.Symbol, q.Price.String(), q.Currency, q.AsOf.UTC())
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if err := r.recordPrice(ctx, tx, q, volume); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -10,8 +10,12 @@ type RewardRepository interface {
 	CreateReward(ctx context.Context, reward model.Reward) (string, error)
 	ExistsByUniqueHashOrIdempotency(ctx context.Context, uniqueHash, idempotencyKey string) (bool, string)
 	CheckIdempotencyKey(ctx context.Context, key string) (bool, interface{})
+	// GetRewardByIdempotencyKey returns the reward stored under key, or
+	// ErrNotFound.
+	GetRewardByIdempotencyKey(ctx context.Context, key string) (model.Reward, error)
 	ListRewardsForDate(ctx context.Context, userID string, date interface{}) ([]model.Reward, error)
 	GetHistoricalINR(ctx context.Context, userID, from, to, page, size string) ([]model.HistoricalINR, error)
 	GetStats(ctx context.Context, userID string) (model.Stats, error)
 	GetPortfolio(ctx context.Context, userID string) (model.Portfolio, error)
+	ReverseReward(ctx context.Context, rewardID, reason string) (model.Reward, error)
 }
@@ -3,23 +3,38 @@ package repo
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"sort"
 	"time"
 
 	"github.com/shopspring/decimal"
 
+	"github.com/mhatrejeets/stocky-ms/internal/events"
+	"github.com/mhatrejeets/stocky-ms/internal/market"
 	"github.com/mhatrejeets/stocky-ms/internal/model"
 	"github.com/sirupsen/logrus"
 )
 
-type RewardRepositoryImpl struct {
-	DB            *sql.DB
-	Redis         RedisIdempotencyStore
-	KafkaProducer KafkaPublisher // interface for Kafka
-}
+var ErrAlreadyReversed = errors.New("reward already reversed")
 
-// KafkaPublisher interface
-type KafkaPublisher interface {
-	PublishRewardCreated(ctx context.Context, event model.RewardCreatedEvent) error
+type RewardRepositoryImpl struct {
+	DB     *sql.DB
+	Redis  RedisIdempotencyStore
+	Events events.EventPublisher
+	// Encoder envelopes the events published on Events
+	Encoder  *events.Encoder
+	Holdings HoldingsRepository
+	Prices   PriceSource
+	// FX converts non-INR prices; nil leaves them unconverted
+	FX FXSource
+	// Listings picks the exchange calendar each symbol's prices are judged
+	// by and the currency they are in; nil, or an unlisted symbol, means the
+	// default calendar and the quote's own currency, else INR
+	Listings ListingSource
+	// Staleness flags old prices; nil treats only missing prices as stale
+	Staleness *market.StalenessPolicy
+	// Lots records each reward's cost basis; nil leaves holdings without one
+	Lots TaxLotRepository
 }
 
 // RedisIdempotencyStore interface
@@ -28,50 +43,85 @@ type RedisIdempotencyStore interface {
 	Get(ctx context.Context, key string) (string, error)
 }
 
-// GetPortfolio returns the user's portfolio: total shares per stock and their current value
+// GetPortfolio returns the user's portfolio from the user_holdings projection
+// valued at current prices
 func (r *RewardRepositoryImpl) GetPortfolio(ctx context.Context, userID string) (model.Portfolio, error) {
-	query := `SELECT stock_symbol, SUM(shares) as total_shares FROM rewards WHERE user_id = $1 GROUP BY stock_symbol`
-	rows, err := r.DB.QueryContext(ctx, query, userID)
+	shareMap, err := r.Holdings.ListHoldings(ctx, userID)
 	if err != nil {
 		return model.Portfolio{}, err
 	}
-	defer rows.Close()
-	var holdings []model.Holding
-	var totalValue decimal.Decimal
-	for rows.Next() {
-		var symbol string
-		var sharesStr string
-		err := rows.Scan(&symbol, &sharesStr)
-		if err != nil {
-			return model.Portfolio{}, err
+	symbols := sortedSymbols(shareMap)
+	listings := r.listings(ctx, symbols)
+	quotes := r.quotes(ctx, symbols, listings)
+	rates := r.fxRates(ctx, quotes)
+	basis := r.costBasis(ctx, userID)
+	portfolio := model.Portfolio{TotalByCurrency: make(map[string]decimal.Decimal), FXRates: make(map[string]decimal.Decimal)}
+	for _, symbol := range symbols {
+		shares := shareMap[symbol]
+		h := model.Holding{Symbol: symbol, TotalShares: shares}
+		q, ok := quotes[symbol]
+		if ok {
+			asOf := q.AsOf
+			h.Currency = q.QuoteCurrency()
+			h.CurrentPrice = q.Price
+			h.TotalValue = shares.Mul(q.Price)
+			h.PriceSource = q.Source
+			h.PriceAsOf = &asOf
+			h.PriceDegraded = q.Degraded
+			if rate, found := rates[h.Currency]; found {
+				h.FXRate = rate.Rate
+				h.TotalValueINR = h.TotalValue.Mul(rate.Rate)
+				if h.Currency != model.CurrencyINR {
+					fxAsOf := rate.AsOf
+					h.FXAsOf = &fxAsOf
+					portfolio.FXRates[h.Currency] = rate.Rate
+				}
+			} else {
+				h.FXUnavailable = true
+			}
+			portfolio.TotalByCurrency[h.Currency] = portfolio.TotalByCurrency[h.Currency].Add(h.TotalValue)
+		} else {
+			h.PriceUnavailable = true
 		}
-		shares, _ := decimal.NewFromString(sharesStr)
-		// Fetch current price from stock_prices table
-		var priceStr string
-		err = r.DB.QueryRowContext(ctx, "SELECT price FROM stock_prices WHERE symbol = $1", symbol).Scan(&priceStr)
-		var price decimal.Decimal
-		if err == nil {
-			price, _ = decimal.NewFromString(priceStr)
+		h.IsStale = r.isStale(listings[symbol].Exchange, q, ok)
+		if b, found := basis[symbol]; found && costBasisCovers(b, h, ok) {
+			h.AverageCost = b.Invested.DivRound(b.Shares, 4)
+			h.InvestedValue = b.Invested
+			h.InvestedValueINR = b.InvestedINR
+			if ok {
+				h.UnrealizedPnL = h.TotalValue.Sub(b.Invested)
+			}
+			if ok && !h.FXUnavailable {
+				h.UnrealizedPnLINR = h.TotalValueINR.Sub(b.InvestedINR)
+				portfolio.InvestedTotalINR = portfolio.InvestedTotalINR.Add(b.InvestedINR)
+				portfolio.UnrealizedPnLINR = portfolio.UnrealizedPnLINR.Add(h.UnrealizedPnLINR)
+			}
 		} else {
-			price = decimal.Zero
+			h.CostBasisUnavailable = true
 		}
-		value := shares.Mul(price)
-		totalValue = totalValue.Add(value)
-		holdings = append(holdings, model.Holding{
-			Symbol:        symbol,
-			TotalShares:   shares,
-			CurrentPrice:  price,
-			TotalValueINR: value,
-		})
-	}
-	portfolio := model.Portfolio{
-		Holdings:          holdings,
-		PortfolioTotalINR: totalValue,
+		portfolio.Degraded = portfolio.Degraded || h.PriceDegraded || h.PriceUnavailable || h.FXUnavailable
+		portfolio.PortfolioTotalINR = portfolio.PortfolioTotalINR.Add(h.TotalValueINR)
+		portfolio.Holdings = append(portfolio.Holdings, h)
 	}
 	return portfolio, nil
 }
 
+// CreateReward stores the reward, its ledger entries, its tax lot and its
+// reward created event in one transaction, then publishes the event. An
+// event that fails to publish stays in the outbox for the relay.
 func (r *RewardRepositoryImpl) CreateReward(ctx context.Context, reward model.Reward) (string, error) {
+	value := r.ledgerValue(ctx, reward.StockSymbol, reward.Shares)
+	// Cost the lot at the fair value on the reward date
+	var lot model.TaxLot
+	if r.Lots != nil {
+		lot = r.lotFor(ctx, reward)
+	}
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
 	// Insert into rewards table
 	query := `INSERT INTO rewards (
 	       id, user_id, stock_symbol, shares, rewarded_at, created_at, unique_hash, idempotency_key, status
@@ -79,7 +129,7 @@ func (r *RewardRepositoryImpl) CreateReward(ctx context.Context, reward model.Re
 	       $1, $2, $3, $4, $5, $6, $7, $8, $9
        ) RETURNING id`
 	var id string
-	err := r.DB.QueryRowContext(ctx, query,
+	err = tx.QueryRowContext(ctx, query,
 		reward.ID,
 		reward.UserID,
 		reward.StockSymbol,
@@ -98,47 +148,67 @@ func (r *RewardRepositoryImpl) CreateReward(ctx context.Context, reward model.Re
 	// Insert ledger entries for reward
 	// Example: record stock units, INR outflow, and company fees
 	ledgerQuery := `INSERT INTO ledger_entries (
-	       event_type, user_id, stock_symbol, shares, inr_amount, fee_type, created_at
+	       event_type, user_id, stock_symbol, shares, currency, native_amount, fx_rate, inr_amount, fee_type, created_at
        ) VALUES (
-	       $1, $2, $3, $4, $5, $6, $7
+	       $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
        )`
-	// Mock price provider: get current price
-	price := decimal.NewFromFloat(3000.00) // Replace with real price lookup
-	inrAmount := price.Mul(reward.Shares)
 	// Record stock purchase
-	if _, err := r.DB.ExecContext(ctx, ledgerQuery,
-		"reward", reward.UserID, reward.StockSymbol, reward.Shares.String(), inrAmount.String(), "", reward.CreatedAt); err != nil {
+	if _, err := tx.ExecContext(ctx, ledgerQuery, "reward", reward.UserID, reward.StockSymbol, reward.Shares.String(),
+		value.currency, value.native.String(), nullDecimal(value.rate), nullDecimal(value.inr()), "", reward.CreatedAt); err != nil {
 		logrus.WithError(err).Error("Failed to insert ledger entry: reward purchase")
 		return "", err
 	}
 	// Record brokerage fee (example: 0.1%)
-	brokerage := inrAmount.Mul(decimal.NewFromFloat(0.001))
-	if _, err := r.DB.ExecContext(ctx, ledgerQuery,
-		"fee", reward.UserID, reward.StockSymbol, reward.Shares.String(), brokerage.String(), "brokerage", reward.CreatedAt); err != nil {
+	brokerage := value.scale(decimal.NewFromFloat(0.001))
+	if _, err := tx.ExecContext(ctx, ledgerQuery, "fee", reward.UserID, reward.StockSymbol, reward.Shares.String(),
+		brokerage.currency, brokerage.native.String(), nullDecimal(brokerage.rate), nullDecimal(brokerage.inr()), "brokerage", reward.CreatedAt); err != nil {
 		logrus.WithError(err).Error("Failed to insert ledger entry: brokerage fee")
 		return "", err
 	}
 	// Record STT fee (example: 0.025%)
-	stt := inrAmount.Mul(decimal.NewFromFloat(0.00025))
-	if _, err := r.DB.ExecContext(ctx, ledgerQuery,
-		"fee", reward.UserID, reward.StockSymbol, reward.Shares.String(), stt.String(), "STT", reward.CreatedAt); err != nil {
+	stt := value.scale(decimal.NewFromFloat(0.00025))
+	if _, err := tx.ExecContext(ctx, ledgerQuery, "fee", reward.UserID, reward.StockSymbol, reward.Shares.String(),
+		stt.currency, stt.native.String(), nullDecimal(stt.rate), nullDecimal(stt.inr()), "STT", reward.CreatedAt); err != nil {
 		logrus.WithError(err).Error("Failed to insert ledger entry: STT fee")
 		return "", err
 	}
 
-	// Set idempotency key in Redis (if needed)
+	if r.Lots != nil {
+		lot.RewardID = id
+		if err := recordLot(ctx, tx, lot); err != nil {
+			logrus.WithError(err).Error("Failed to record tax lot")
+			return "", err
+		}
+	}
 
-	// Publish to Kafka
-	if r.KafkaProducer != nil {
-		event := model.RewardCreatedEvent{
+	// Queue the reward event with the reward so it cannot be lost
+	var msg events.Message
+	var outboxID int64
+	if r.Events != nil {
+		msg, err = r.Encoder.RewardCreatedMessage(model.RewardCreatedEvent{
 			RewardID:      id,
 			UserID:        reward.UserID,
 			StockSymbol:   reward.StockSymbol,
 			Shares:        reward.Shares.String(),
 			RewardedAt:    reward.RewardedAt.Format(time.RFC3339),
 			CorrelationID: reward.IdempotencyKey,
+		})
+		if err == nil {
+			outboxID, err = enqueueEvent(ctx, tx, msg)
+		}
+		if err != nil {
+			logrus.WithError(err).Error("Failed to queue reward created event")
+			return "", err
 		}
-		_ = r.KafkaProducer.PublishRewardCreated(ctx, event)
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+
+	// Set idempotency key in Redis (if needed)
+
+	if r.Events != nil {
+		publishEnqueued(ctx, r.DB, r.Events, outboxID, msg)
 	}
 	return id, nil
 }
@@ -173,6 +243,17 @@ func (r *RewardRepositoryImpl) CheckIdempotencyKey(ctx context.Context, key stri
 	return true, id
 }
 
+func (r *RewardRepositoryImpl) GetRewardByIdempotencyKey(ctx context.Context, key string) (model.Reward, error) {
+	var rw model.Reward
+	err := r.DB.QueryRowContext(ctx, `SELECT id, user_id, stock_symbol, shares, rewarded_at, created_at, unique_hash, COALESCE(idempotency_key, ''), status
+		FROM rewards WHERE idempotency_key = $1 LIMIT 1`, key,
+	).Scan(&rw.ID, &rw.UserID, &rw.StockSymbol, &rw.Shares, &rw.RewardedAt, &rw.CreatedAt, &rw.UniqueHash, &rw.IdempotencyKey, &rw.Status)
+	if err == sql.ErrNoRows {
+		return model.Reward{}, ErrNotFound
+	}
+	return rw, err
+}
+
 func (r *RewardRepositoryImpl) ListRewardsForDate(ctx context.Context, userID string, date interface{}) ([]model.Reward, error) {
 	// Query rewards for the given user and date
 	t, ok := date.(time.Time)
@@ -203,20 +284,21 @@ func (r *RewardRepositoryImpl) ListRewardsForDate(ctx context.Context, userID st
 
 func (r *RewardRepositoryImpl) GetHistoricalINR(ctx context.Context, userID, from, to, page, size string) ([]model.HistoricalINR, error) {
 	// Query historical INR values for a user
-	// For each day, sum shares per symbol, then multiply by current price
-	query := `SELECT to_char(rewarded_at, 'YYYY-MM-DD') as date, stock_symbol, SUM(shares) as total_shares FROM rewards WHERE user_id = $1 AND rewarded_at >= $2 AND rewarded_at <= $3 GROUP BY date, stock_symbol ORDER BY date`
-	rows, err := r.DB.QueryContext(ctx, query, userID, from, to)
+	// For each day, sum shares per symbol, then multiply by that day's close.
+	// Reversed rewards were never the user's to value.
+	query := `SELECT to_char(rewarded_at, 'YYYY-MM-DD') as date, stock_symbol, SUM(shares) as total_shares FROM rewards WHERE user_id = $1 AND status = $4 AND rewarded_at >= $2 AND rewarded_at <= $3 GROUP BY date, stock_symbol ORDER BY date`
+	rows, err := r.DB.QueryContext(ctx, query, userID, from, to, model.RewardStatusActive)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	// Map date -> symbol -> shares
 	type daySymbol struct {
 		date   string
 		symbol string
+		shares decimal.Decimal
 	}
-	dayShares := make(map[daySymbol]decimal.Decimal)
-	dateSet := make(map[string]struct{})
+	var dayShares []daySymbol
+	symbolSet := make(map[string]decimal.Decimal)
 	for rows.Next() {
 		var date, symbol, sharesStr string
 		err := rows.Scan(&date, &symbol, &sharesStr)
@@ -224,68 +306,346 @@ func (r *RewardRepositoryImpl) GetHistoricalINR(ctx context.Context, userID, fro
 			return nil, err
 		}
 		shares, _ := decimal.NewFromString(sharesStr)
-		dayShares[daySymbol{date, symbol}] = shares
-		dateSet[date] = struct{}{}
+		dayShares = append(dayShares, daySymbol{date, symbol, shares})
+		symbolSet[symbol] = decimal.Zero
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// Past days are valued at their closing price; today, and any day with no
+	// recorded close, at the current price
+	symbols := sortedSymbols(symbolSet)
+	closes, err := loadCloses(ctx, r.DB, symbols, from, to)
+	if err != nil {
+		return nil, err
+	}
+	listings := r.listings(ctx, symbols)
+	quotes := r.quotes(ctx, symbols, listings)
+	// Closes carry no currency; a symbol is valued in the currency it is
+	// listed in, else the one it is quoted in now
+	currencies := make(map[string]model.Quote, len(symbols))
+	for _, symbol := range symbols {
+		currency := listings[symbol].Currency
+		if currency == "" {
+			currency = quotes[symbol].QuoteCurrency()
+		}
+		currencies[symbol] = model.Quote{Currency: currency}
 	}
-	// For each date, sum INR value using current price
+	rates := r.fxRates(ctx, currencies)
+	fxCloses, err := loadFXCloses(ctx, r.DB, foreignCurrencies(currencies), from, to)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	// Rows arrive ordered by date, so each day is a contiguous run
 	var result []model.HistoricalINR
-	for date := range dateSet {
-		var totalINR decimal.Decimal
-		for ds, shares := range dayShares {
-			if ds.date == date {
-				// Get current price for symbol
-				var priceStr string
-				err := r.DB.QueryRowContext(ctx, "SELECT price FROM stock_prices WHERE symbol = $1", ds.symbol).Scan(&priceStr)
-				var price decimal.Decimal
-				if err == nil {
-					price, _ = decimal.NewFromString(priceStr)
+	for _, ds := range dayShares {
+		if len(result) == 0 || result[len(result)-1].Date != ds.date {
+			result = append(result, model.HistoricalINR{Date: ds.date, INRValue: decimal.Zero})
+		}
+		last := &result[len(result)-1]
+		exchange := listings[ds.symbol].Exchange
+		q, ok := quotes[ds.symbol]
+		stale := r.isStale(exchange, q, ok)
+		currency := currencies[ds.symbol].Currency
+		rate, hasRate := rates[currency]
+		if ds.date < now.In(r.location(exchange)).Format("2006-01-02") {
+			if c, found := closes.at(ds.symbol, ds.date); found {
+				q, ok, stale = c.quote, true, !r.closeIsCurrent(exchange, c.date, ds.date)
+			} else {
+				stale = true
+			}
+			if currency != model.CurrencyINR {
+				if c, found := fxCloses.at(currency, ds.date); found {
+					rate, hasRate = model.FXRate{Currency: currency, Rate: c.quote.Price, AsOf: c.quote.AsOf}, true
 				} else {
-					price = decimal.Zero
+					stale = true
 				}
-				totalINR = totalINR.Add(shares.Mul(price))
 			}
 		}
-		result = append(result, model.HistoricalINR{
-			Date:     date,
-			INRValue: totalINR,
-			IsStale:  false,
-		})
+		if !hasRate {
+			stale, rate.Rate = true, decimal.Zero
+		}
+		last.IsStale = last.IsStale || stale
+		last.PriceAsOf = oldest(last.PriceAsOf, q, ok)
+		last.INRValue = last.INRValue.Add(ds.shares.Mul(q.Price).Mul(rate.Rate))
 	}
-	// Sort result by date ascending
-	// (optional, for consistent output)
-	// sort.Slice(result, func(i, j int) bool { return result[i].Date < result[j].Date })
 	return result, nil
 }
 
 func (r *RewardRepositoryImpl) GetStats(ctx context.Context, userID string) (model.Stats, error) {
-	query := `SELECT stock_symbol, SUM(shares) as total_shares FROM rewards WHERE user_id = $1 GROUP BY stock_symbol`
-	rows, err := r.DB.QueryContext(ctx, query, userID)
+	shareMap, err := r.Holdings.ListHoldings(ctx, userID)
 	if err != nil {
 		return model.Stats{}, err
 	}
-	defer rows.Close()
-	stats := model.Stats{TodayTotalBySymbol: make(map[string]decimal.Decimal)}
+	symbols := sortedSymbols(shareMap)
+	listings := r.listings(ctx, symbols)
+	quotes := r.quotes(ctx, symbols, listings)
+	rates := r.fxRates(ctx, quotes)
+	stats := model.Stats{
+		TodayTotalBySymbol: make(map[string]decimal.Decimal),
+		ValueByCurrency:    make(map[string]decimal.Decimal),
+		FXRates:            make(map[string]decimal.Decimal),
+	}
 	var total decimal.Decimal
-	for rows.Next() {
-		var symbol string
-		var sharesStr string
-		err := rows.Scan(&symbol, &sharesStr)
-		if err != nil {
-			return model.Stats{}, err
-		}
-		shares, _ := decimal.NewFromString(sharesStr)
+	for symbol, shares := range shareMap {
 		stats.TodayTotalBySymbol[symbol] = shares
-		// Fetch current price from stock_prices table
-		var priceStr string
-		err = r.DB.QueryRowContext(ctx, "SELECT price FROM stock_prices WHERE symbol = $1", symbol).Scan(&priceStr)
-		var price decimal.Decimal
-		if err == nil {
-			price, _ = decimal.NewFromString(priceStr)
-		} else {
-			price = decimal.Zero
+		q, ok := quotes[symbol]
+		stats.Degraded = stats.Degraded || !ok || q.Degraded
+		stats.IsStale = stats.IsStale || r.isStale(listings[symbol].Exchange, q, ok)
+		stats.PriceAsOf = oldest(stats.PriceAsOf, q, ok)
+		if !ok {
+			continue
+		}
+		currency := q.QuoteCurrency()
+		value := shares.Mul(q.Price)
+		stats.ValueByCurrency[currency] = stats.ValueByCurrency[currency].Add(value)
+		rate, found := rates[currency]
+		if !found {
+			stats.Degraded = true
+			continue
+		}
+		if currency != model.CurrencyINR {
+			stats.FXRates[currency] = rate.Rate
 		}
-		total = total.Add(shares.Mul(price))
+		total = total.Add(value.Mul(rate.Rate))
 	}
 	stats.PortfolioValueINR = total
 	return stats, nil
 }
+
+// ReverseReward marks an active reward reversed and records an offsetting
+// ledger entry, the tax lots it consumes and a reward reversed event in one
+// transaction, then publishes the event. The shares taken back are the reward's shares
+// restated for corporate actions since it was rewarded.
+func (r *RewardRepositoryImpl) ReverseReward(ctx context.Context, rewardID, reason string) (model.Reward, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Reward{}, err
+	}
+	defer tx.Rollback()
+
+	var rw model.Reward
+	reversedAt := time.Now()
+	err = tx.QueryRowContext(ctx, `UPDATE rewards SET status = $2, reversed_at = $4 WHERE id = $1 AND status = $3
+		RETURNING id, user_id, stock_symbol, shares, rewarded_at, created_at, unique_hash, COALESCE(idempotency_key, ''), status`,
+		rewardID, model.RewardStatusReversed, model.RewardStatusActive, reversedAt.UTC(),
+	).Scan(&rw.ID, &rw.UserID, &rw.StockSymbol, &rw.Shares, &rw.RewardedAt, &rw.CreatedAt, &rw.UniqueHash, &rw.IdempotencyKey, &rw.Status)
+	if err == sql.ErrNoRows {
+		var status string
+		if err := tx.QueryRowContext(ctx, `SELECT status FROM rewards WHERE id = $1`, rewardID).Scan(&status); err == sql.ErrNoRows {
+			return model.Reward{}, ErrNotFound
+		}
+		return model.Reward{}, ErrAlreadyReversed
+	}
+	if err != nil {
+		return model.Reward{}, err
+	}
+	// Hold off corporate actions on the symbol until the reversal commits
+	if err := lockSymbol(ctx, tx, rw.StockSymbol); err != nil {
+		return model.Reward{}, err
+	}
+	actions, err := loadAdjustments(ctx, tx, rw.StockSymbol)
+	if err != nil {
+		return model.Reward{}, err
+	}
+	shares := actions.restate(rw.Shares, rw.RewardedAt)
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO ledger_entries (event_type, user_id, stock_symbol, shares, fee_type, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`, "reversal", rw.UserID, rw.StockSymbol, shares.Neg().String(), "", reversedAt); err != nil {
+		logrus.WithError(err).Error("Failed to insert ledger entry: reversal")
+		return model.Reward{}, err
+	}
+
+	if r.Lots != nil {
+		if _, err := consumeLots(ctx, tx, rw.UserID, rw.StockSymbol, shares, model.DisposalReversal, rw.ID, reversedAt); err != nil {
+			logrus.WithError(err).Error("Failed to consume tax lots: reversal")
+			return model.Reward{}, err
+		}
+	}
+
+	var msg events.Message
+	var outboxID int64
+	if r.Events != nil {
+		msg, err = r.Encoder.RewardReversedMessage(model.RewardReversedEvent{
+			RewardID:    rw.ID,
+			UserID:      rw.UserID,
+			StockSymbol: rw.StockSymbol,
+			Shares:      shares.String(),
+			ReversedAt:  reversedAt.UTC().Format(time.RFC3339),
+			Reason:      reason,
+		})
+		if err == nil {
+			outboxID, err = enqueueEvent(ctx, tx, msg)
+		}
+		if err != nil {
+			logrus.WithError(err).Error("Failed to queue reward reversed event")
+			return model.Reward{}, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return model.Reward{}, err
+	}
+
+	if r.Events != nil {
+		publishEnqueued(ctx, r.DB, r.Events, outboxID, msg)
+	}
+	return rw, nil
+}
+
+// lotFor prices a reward's tax lot at the recorded price on its reward date,
+// falling back to the current price when none was recorded. The INR rate is
+// likewise the one on the reward date, else the current one.
+func (r *RewardRepositoryImpl) lotFor(ctx context.Context, reward model.Reward) model.TaxLot {
+	lot := model.TaxLot{
+		UserID:     reward.UserID,
+		Symbol:     reward.StockSymbol,
+		AcquiredAt: reward.RewardedAt,
+		Shares:     reward.Shares,
+		CostSource: model.CostSourceUnknown,
+	}
+	listings := r.listings(ctx, []string{reward.StockSymbol})
+	if r.DB != nil {
+		loc := r.location(listings[reward.StockSymbol].Exchange)
+		price, rate, source, currency, err := lotPriceAt(ctx, r.DB, reward.StockSymbol, reward.RewardedAt, loc)
+		if err != nil {
+			logrus.WithError(err).Warn("Recorded price lookup failed, costing lot at the current price")
+		} else if price != nil {
+			lot.CostPerShare, lot.FXRate, lot.CostSource, lot.Currency = price, rate, source, currency
+		}
+	}
+	var quotes map[string]model.Quote
+	if lot.CostPerShare == nil || lot.Currency == "" {
+		quotes = r.quotes(ctx, []string{reward.StockSymbol}, listings)
+	}
+	if q, ok := quotes[reward.StockSymbol]; ok {
+		if lot.CostPerShare == nil {
+			price := q.Price
+			lot.CostPerShare, lot.CostSource = &price, model.CostSourceCurrentPrice
+		}
+		if lot.Currency == "" {
+			lot.Currency = q.QuoteCurrency()
+		}
+	}
+	if lot.Currency == "" {
+		lot.Currency = model.CurrencyINR
+	}
+	if lot.Currency == model.CurrencyINR {
+		one := decimal.NewFromInt(1)
+		lot.FXRate = &one
+	} else if lot.FXRate == nil {
+		if rate, found := r.fxRates(ctx, map[string]model.Quote{reward.StockSymbol: {Currency: lot.Currency}})[lot.Currency]; found {
+			lot.FXRate = &rate.Rate
+		}
+	}
+	return lot
+}
+
+// costBasis returns the user's open lots per symbol. Like quotes, a failed
+// lookup is logged and leaves every holding without a cost basis.
+func (r *RewardRepositoryImpl) costBasis(ctx context.Context, userID string) map[string]model.CostBasis {
+	if r.Lots == nil {
+		return nil
+	}
+	basis, err := r.Lots.CostBasis(ctx, userID)
+	if err != nil {
+		logrus.WithError(err).Warn("Cost basis lookup failed, valuing without it")
+		return nil
+	}
+	return basis
+}
+
+// costBasisCovers reports whether the lots price every share of the holding
+// in the currency it is valued in.
+func costBasisCovers(b model.CostBasis, h model.Holding, priced bool) bool {
+	if b.Unknown || !b.Shares.Equal(h.TotalShares) || b.Shares.IsZero() {
+		return false
+	}
+	return !priced || b.Currency == h.Currency
+}
+
+// quotes prices symbols in one batch. A failed lookup is logged and treated
+// as no prices at all, so callers flag the valuation as degraded instead of
+// failing the request. Quotes from providers that report no currency are
+// stamped with their listing's, so a USD stock is never valued as INR.
+func (r *RewardRepositoryImpl) quotes(ctx context.Context, symbols []string, listings map[string]Listing) map[string]model.Quote {
+	if r.Prices == nil || len(symbols) == 0 {
+		return map[string]model.Quote{}
+	}
+	quotes, err := r.Prices.GetPrices(ctx, symbols)
+	if err != nil {
+		logrus.WithError(err).Warn("Price lookup failed, valuing without prices")
+		return map[string]model.Quote{}
+	}
+	for symbol, q := range quotes {
+		if l, ok := listings[symbol]; ok && q.Currency == "" {
+			q.Currency = l.Currency
+			quotes[symbol] = q
+		}
+	}
+	return quotes
+}
+
+// listings looks up the listing of each symbol. Like quotes, a failed
+// lookup is logged and leaves every symbol on the default calendar.
+func (r *RewardRepositoryImpl) listings(ctx context.Context, symbols []string) map[string]Listing {
+	if r.Listings == nil || len(symbols) == 0 {
+		return nil
+	}
+	listings, err := r.Listings.SymbolListings(ctx, symbols)
+	if err != nil {
+		logrus.WithError(err).Warn("Instrument listing lookup failed, valuing unlabelled prices as INR")
+		return nil
+	}
+	return listings
+}
+
+func (r *RewardRepositoryImpl) isStale(exchange string, q model.Quote, ok bool) bool {
+	if !ok {
+		return true
+	}
+	return r.Staleness != nil && r.Staleness.IsStaleOn(exchange, q.AsOf)
+}
+
+// location is the time zone of exchange when a calendar is configured.
+func (r *RewardRepositoryImpl) location(exchange string) *time.Location {
+	if r.Staleness != nil && r.Staleness.Calendar != nil {
+		return r.Staleness.CalendarFor(exchange).Hours.Location
+	}
+	return time.UTC
+}
+
+// closeIsCurrent reports whether a close recorded on closeDate is the one
+// that should value date on exchange: date itself on a trading day,
+// otherwise the trading day before it. Without a calendar any earlier close
+// is accepted.
+func (r *RewardRepositoryImpl) closeIsCurrent(exchange, closeDate, date string) bool {
+	if r.Staleness == nil || r.Staleness.Calendar == nil {
+		return true
+	}
+	cal := r.Staleness.CalendarFor(exchange)
+	day, err := time.ParseInLocation("2006-01-02", date, cal.Hours.Location)
+	if err != nil {
+		return false
+	}
+	endOfDay := day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	return cal.PreviousCloseDate(endOfDay).Format("2006-01-02") == closeDate
+}
+
+// oldest returns the earlier of cur and the quote's as-of time.
+func oldest(cur *time.Time, q model.Quote, ok bool) *time.Time {
+	if !ok || (cur != nil && !q.AsOf.Before(*cur)) {
+		return cur
+	}
+	asOf := q.AsOf
+	return &asOf
+}
+
+func sortedSymbols(holdings map[string]decimal.Decimal) []string {
+	symbols := make([]string, 0, len(holdings))
+	for symbol := range holdings {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
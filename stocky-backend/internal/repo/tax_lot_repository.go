@@ -0,0 +1,196 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/shopspring/decimal"
+)
+
+// TaxLotRepository reads the tax lots the reward repository records, and
+// consumes them oldest first when shares leave a holding. Reversals consume
+// lots in the reversal's own transaction.
+type TaxLotRepository interface {
+	// ConsumeLots takes shares out of the user's open lots of symbol, oldest
+	// first, in one transaction, and returns what it took from each. Shares
+	// beyond what the lots hold are left unconsumed.
+	ConsumeLots(ctx context.Context, userID, symbol string, shares decimal.Decimal, reason, reference string, at time.Time) ([]model.LotDisposal, error)
+	// CostBasis sums the user's open lots per symbol.
+	CostBasis(ctx context.Context, userID string) (map[string]model.CostBasis, error)
+	ListLots(ctx context.Context, userID string) ([]model.TaxLot, error)
+}
+
+type TaxLotRepositoryImpl struct {
+	DB *sql.DB
+}
+
+const taxLotColumns = `id, reward_id, user_id, symbol, acquired_at, shares, remaining_shares, currency, cost_per_share, fx_rate, cost_source`
+
+// recordLot stores lot unless its reward has one already. A lot acquired
+// before corporate actions that have already been applied is restated for
+// them, keeping its total cost, as applying them would have done. The symbol
+// lock holds off further actions until tx commits.
+func recordLot(ctx context.Context, tx *sql.Tx, lot model.TaxLot) error {
+	if err := lockSymbol(ctx, tx, lot.Symbol); err != nil {
+		return err
+	}
+	actions, err := loadAdjustments(ctx, tx, lot.Symbol)
+	if err != nil {
+		return err
+	}
+	ratio := actions.restate(decimal.NewFromInt(1), lot.AcquiredAt)
+	shares, cost := lot.Shares.Mul(ratio), lot.CostPerShare
+	if cost != nil {
+		restated := cost.Div(ratio)
+		cost = &restated
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO tax_lots (reward_id, user_id, symbol, acquired_at, shares, remaining_shares, currency, cost_per_share, fx_rate, cost_source)
+		VALUES ($1, $2, $3, $4, $5, $5, $6, $7, $8, $9) ON CONFLICT (reward_id) DO NOTHING`,
+		lot.RewardID, lot.UserID, lot.Symbol, lot.AcquiredAt.UTC(), shares.String(), lot.Currency,
+		nullDecimal(cost), nullDecimal(lot.FXRate), lot.CostSource)
+	return err
+}
+
+func (r *TaxLotRepositoryImpl) ConsumeLots(ctx context.Context, userID, symbol string, shares decimal.Decimal, reason, reference string, at time.Time) ([]model.LotDisposal, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	// Hold off corporate actions so the lots stay in the shares given
+	if err := lockSymbol(ctx, tx, symbol); err != nil {
+		return nil, err
+	}
+	disposals, err := consumeLots(ctx, tx, userID, symbol, shares, reason, reference, at)
+	if err != nil {
+		return nil, err
+	}
+	return disposals, tx.Commit()
+}
+
+// consumeLots is ConsumeLots within the caller's transaction, which must
+// hold the symbol lock.
+func consumeLots(ctx context.Context, tx *sql.Tx, userID, symbol string, shares decimal.Decimal, reason, reference string, at time.Time) ([]model.LotDisposal, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT id, remaining_shares, cost_per_share, fx_rate FROM tax_lots
+		WHERE user_id = $1 AND symbol = $2 AND remaining_shares > 0 ORDER BY acquired_at, created_at, id FOR UPDATE`, userID, symbol)
+	if err != nil {
+		return nil, err
+	}
+	var disposals []model.LotDisposal
+	remaining := shares
+	for remaining.IsPositive() && rows.Next() {
+		var d model.LotDisposal
+		var open decimal.Decimal
+		var cost, rate decimal.NullDecimal
+		if err := rows.Scan(&d.LotID, &open, &cost, &rate); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		d.Shares = decimal.Min(open, remaining)
+		if cost.Valid {
+			d.CostPerShare = &cost.Decimal
+		}
+		if rate.Valid {
+			d.FXRate = &rate.Decimal
+		}
+		d.Reason, d.Reference, d.DisposedAt = reason, reference, at.UTC()
+		disposals = append(disposals, d)
+		remaining = remaining.Sub(d.Shares)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, d := range disposals {
+		if _, err := tx.ExecContext(ctx, `UPDATE tax_lots SET remaining_shares = remaining_shares - $2 WHERE id = $1`, d.LotID, d.Shares.String()); err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO tax_lot_disposals (lot_id, shares, reason, reference, disposed_at) VALUES ($1, $2, $3, $4, $5)`,
+			d.LotID, d.Shares.String(), d.Reason, d.Reference, d.DisposedAt); err != nil {
+			return nil, err
+		}
+	}
+	return disposals, nil
+}
+
+func (r *TaxLotRepositoryImpl) CostBasis(ctx context.Context, userID string) (map[string]model.CostBasis, error) {
+	rows, err := r.DB.QueryContext(ctx, `SELECT symbol, MIN(currency), SUM(remaining_shares),
+			COALESCE(SUM(remaining_shares * cost_per_share), 0),
+			COALESCE(SUM(remaining_shares * cost_per_share * fx_rate), 0),
+			bool_or(cost_per_share IS NULL OR fx_rate IS NULL) OR COUNT(DISTINCT currency) > 1
+		FROM tax_lots WHERE user_id = $1 AND remaining_shares > 0 GROUP BY symbol`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	basis := make(map[string]model.CostBasis)
+	for rows.Next() {
+		var symbol string
+		var b model.CostBasis
+		if err := rows.Scan(&symbol, &b.Currency, &b.Shares, &b.Invested, &b.InvestedINR, &b.Unknown); err != nil {
+			return nil, err
+		}
+		basis[symbol] = b
+	}
+	return basis, rows.Err()
+}
+
+func (r *TaxLotRepositoryImpl) ListLots(ctx context.Context, userID string) ([]model.TaxLot, error) {
+	rows, err := r.DB.QueryContext(ctx, `SELECT `+taxLotColumns+` FROM tax_lots WHERE user_id = $1 ORDER BY symbol, acquired_at, created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var lots []model.TaxLot
+	for rows.Next() {
+		var lot model.TaxLot
+		var cost, rate decimal.NullDecimal
+		if err := rows.Scan(&lot.ID, &lot.RewardID, &lot.UserID, &lot.Symbol, &lot.AcquiredAt, &lot.Shares, &lot.RemainingShares,
+			&lot.Currency, &cost, &rate, &lot.CostSource); err != nil {
+			return nil, err
+		}
+		if cost.Valid {
+			lot.CostPerShare = &cost.Decimal
+		}
+		if rate.Valid {
+			lot.FXRate = &rate.Decimal
+		}
+		lots = append(lots, lot)
+	}
+	return lots, rows.Err()
+}
+
+// lotPriceAt is the recorded price of symbol at or before at: the last
+// applied price, else the last close, else the stored current price. The
+// currency is the symbol's stored quote currency, and rate the last INR rate
+// for it at or before at. Any of them is nil or empty when nothing is stored.
+func lotPriceAt(ctx context.Context, db *sql.DB, symbol string, at time.Time, loc *time.Location) (price, rate *decimal.Decimal, source, currency string, err error) {
+	var p, fx decimal.NullDecimal
+	var src, cur sql.NullString
+	err = db.QueryRowContext(ctx, `SELECT p.price, p.source, c.currency, fx.rate
+		FROM (SELECT (SELECT currency FROM stock_prices WHERE symbol = $1) AS currency) c
+		LEFT JOIN LATERAL (
+			SELECT price, source FROM (
+				(SELECT price, 'price_history' AS source, 1 AS rank FROM price_history WHERE symbol = $1 AND as_of <= $2 ORDER BY as_of DESC LIMIT 1)
+				UNION ALL
+				(SELECT close, 'daily_close', 2 FROM daily_closes WHERE symbol = $1 AND trade_date <= $3 ORDER BY trade_date DESC LIMIT 1)
+				UNION ALL
+				(SELECT price, 'current_price', 3 FROM stock_prices WHERE symbol = $1)
+			) candidates ORDER BY rank LIMIT 1
+		) p ON TRUE
+		LEFT JOIN LATERAL (
+			SELECT rate FROM fx_rates WHERE currency = c.currency AND as_of <= $2 ORDER BY as_of DESC LIMIT 1
+		) fx ON TRUE`, symbol, at.UTC(), at.In(loc).Format("2006-01-02")).Scan(&p, &src, &cur, &fx)
+	if err != nil {
+		return nil, nil, "", "", err
+	}
+	if p.Valid {
+		price, source = &p.Decimal, src.String
+	}
+	if fx.Valid {
+		rate = &fx.Decimal
+	}
+	return price, rate, source, cur.String, nil
+}
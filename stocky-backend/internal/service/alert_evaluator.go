@@ -0,0 +1,317 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+// AlertsFiredTotal counts fired alerts by rule kind.
+var AlertsFiredTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "stocky_alerts_fired_total",
+	Help: "Alerts fired, by rule kind.",
+}, []string{"kind"})
+
+// AlertDeliveriesTotal counts alert deliveries by result (delivered, failed).
+var AlertDeliveriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "stocky_alert_deliveries_total",
+	Help: "Alert deliveries, by result.",
+}, []string{"result"})
+
+// AlertNotifier delivers a fired alert to its user.
+type AlertNotifier interface {
+	NotifyAlert(ctx context.Context, alert model.AlertEvent) error
+}
+
+// PreviousCloseSource looks up each symbol's latest close before a date.
+type PreviousCloseSource interface {
+	PreviousCloses(ctx context.Context, symbols []string, date string) (map[string]decimal.Decimal, error)
+}
+
+// AlertEvaluator checks alert rules against price and holdings changes.
+// OnPrice and OnChange only queue the change; Run evaluates what has queued
+// up every Interval, so a burst of ticks costs one pass.
+//
+// Every replica may run an evaluator: FireAlert lets only one of them
+// record a given firing, and only the one that records it delivers it.
+type AlertEvaluator struct {
+	Rules      repo.AlertRepository
+	Portfolios PortfolioSource
+	Closes     PreviousCloseSource
+	Notifier   AlertNotifier
+	Location   *time.Location // trading day for daily moves; UTC when nil
+	Interval   time.Duration
+	Now        func() time.Time
+
+	mu      sync.Mutex
+	quotes  map[string]model.Quote
+	symbols map[string]bool
+	users   map[string]bool
+}
+
+func (e *AlertEvaluator) now() time.Time {
+	if e.Now != nil {
+		return e.Now()
+	}
+	return time.Now()
+}
+
+// OnPrice queues q's symbol for evaluation.
+func (e *AlertEvaluator) OnPrice(q model.Quote) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.init()
+	if prev, ok := e.quotes[q.Symbol]; !ok || !q.AsOf.Before(prev.AsOf) {
+		e.quotes[q.Symbol] = q
+	}
+	e.symbols[q.Symbol] = true
+}
+
+// OnChange queues the user, or the symbol's holders, for evaluation.
+func (e *AlertEvaluator) OnChange(change model.PortfolioChange) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.init()
+	if change.UserID != "" {
+		e.users[change.UserID] = true
+	} else if change.Symbol != "" {
+		e.symbols[change.Symbol] = true
+	}
+}
+
+func (e *AlertEvaluator) init() {
+	if e.quotes == nil {
+		e.quotes = make(map[string]model.Quote)
+		e.symbols = make(map[string]bool)
+		e.users = make(map[string]bool)
+	}
+}
+
+// Run evaluates queued changes every Interval until ctx is cancelled.
+func (e *AlertEvaluator) Run(ctx context.Context) error {
+	interval := e.Interval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := e.Evaluate(ctx); err != nil {
+				logrus.WithError(err).Warn("Alert evaluation failed, will retry")
+			}
+		}
+	}
+}
+
+// Evaluate checks the rules affected by everything queued since the last
+// call. If the rules cannot be loaded the changes stay queued.
+func (e *AlertEvaluator) Evaluate(ctx context.Context) error {
+	e.mu.Lock()
+	quotes, symbols, users := e.quotes, e.symbols, e.users
+	e.quotes, e.symbols, e.users = nil, nil, nil
+	e.mu.Unlock()
+	if len(symbols) == 0 && len(users) == 0 {
+		return nil
+	}
+	rules, err := e.Rules.ListActiveAlertRules(ctx, setKeys(symbols), setKeys(users))
+	if err != nil {
+		e.requeue(quotes, symbols, users)
+		return err
+	}
+
+	now := e.now()
+	loc := e.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	pass := &alertPass{evaluator: e, quotes: quotes, day: now.In(loc).Format("2006-01-02"), now: now, portfolios: make(map[string]*model.Portfolio)}
+	for _, rule := range rules {
+		switch rule.Kind {
+		case model.AlertPortfolioAbove, model.AlertPortfolioBelow:
+			pass.portfolioRule(ctx, rule)
+		case model.AlertDailyMove:
+			pass.dailyMoveRule(ctx, rule)
+		}
+	}
+	return nil
+}
+
+// requeue puts back a batch that could not be evaluated, keeping anything
+// newer that arrived meanwhile.
+func (e *AlertEvaluator) requeue(quotes map[string]model.Quote, symbols, users map[string]bool) {
+	for symbol := range symbols {
+		if q, ok := quotes[symbol]; ok {
+			e.OnPrice(q)
+		} else {
+			e.OnChange(model.PortfolioChange{Symbol: symbol})
+		}
+	}
+	for user := range users {
+		e.OnChange(model.PortfolioChange{UserID: user})
+	}
+}
+
+// alertPass is one evaluation, caching portfolios and closes across rules.
+type alertPass struct {
+	evaluator  *AlertEvaluator
+	quotes     map[string]model.Quote
+	day        string
+	now        time.Time
+	portfolios map[string]*model.Portfolio
+	closes     map[string]decimal.Decimal
+}
+
+// portfolio values userID once per pass; nil if that failed.
+func (p *alertPass) portfolio(ctx context.Context, userID string) *model.Portfolio {
+	if portfolio, ok := p.portfolios[userID]; ok {
+		return portfolio
+	}
+	var result *model.Portfolio
+	portfolio, err := p.evaluator.Portfolios.GetPortfolio(ctx, userID)
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Warn("Failed to value portfolio for alerts")
+	} else {
+		result = &portfolio
+	}
+	p.portfolios[userID] = result
+	return result
+}
+
+func (p *alertPass) previousClose(ctx context.Context, symbol string) (decimal.Decimal, bool) {
+	if p.closes == nil {
+		symbols := make([]string, 0, len(p.quotes))
+		for s := range p.quotes {
+			symbols = append(symbols, s)
+		}
+		closes, err := p.evaluator.Closes.PreviousCloses(ctx, symbols, p.day)
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to load previous closes for alerts")
+			closes = map[string]decimal.Decimal{}
+		}
+		p.closes = closes
+	}
+	c, ok := p.closes[symbol]
+	return c, ok && c.IsPositive()
+}
+
+// portfolioRule fires when the total crosses the threshold and re-arms the
+// rule once it is back on the other side. Degraded valuations are skipped
+// so a missing price cannot look like a crash.
+func (p *alertPass) portfolioRule(ctx context.Context, rule model.AlertRule) {
+	portfolio := p.portfolio(ctx, rule.UserID)
+	if portfolio == nil || portfolio.Degraded {
+		return
+	}
+	value := portfolio.PortfolioTotalINR
+	direction := "above"
+	crossed := value.GreaterThanOrEqual(rule.Threshold)
+	if rule.Kind == model.AlertPortfolioBelow {
+		direction = "below"
+		crossed = value.LessThanOrEqual(rule.Threshold)
+	}
+	if !crossed {
+		if !rule.Armed {
+			if err := p.evaluator.Rules.RearmAlertRule(ctx, rule.ID); err != nil {
+				logrus.WithError(err).WithField("rule_id", rule.ID).Warn("Failed to re-arm alert rule")
+			}
+		}
+		return
+	}
+	if !rule.Armed {
+		return
+	}
+	p.fire(ctx, rule, model.AlertEvent{
+		Value:   value,
+		Message: fmt.Sprintf("Your portfolio is worth %s INR, %s your alert at %s INR", value.StringFixed(2), direction, rule.Threshold.String()),
+	}, true)
+}
+
+// dailyMoveRule fires once per symbol per day when a price is at least the
+// threshold percent away from the previous close.
+func (p *alertPass) dailyMoveRule(ctx context.Context, rule model.AlertRule) {
+	symbols := []string{rule.Symbol}
+	if rule.Symbol == "" {
+		symbols = nil
+		if portfolio := p.portfolio(ctx, rule.UserID); portfolio != nil {
+			for _, h := range portfolio.Holdings {
+				symbols = append(symbols, h.Symbol)
+			}
+		}
+	}
+	for _, symbol := range symbols {
+		q, ok := p.quotes[symbol]
+		if !ok {
+			continue
+		}
+		prev, ok := p.previousClose(ctx, symbol)
+		if !ok {
+			continue
+		}
+		move := q.Price.Sub(prev).Div(prev).Mul(decimal.NewFromInt(100)).Round(2)
+		if move.Abs().LessThan(rule.Threshold) {
+			continue
+		}
+		sign := ""
+		if move.IsPositive() {
+			sign = "+"
+		}
+		p.fire(ctx, rule, model.AlertEvent{
+			Symbol:   symbol,
+			Value:    move,
+			DedupKey: symbol + ":" + p.day,
+			Message:  fmt.Sprintf("%s is %s%s%% today at %s, past your %s%% alert", symbol, sign, move.StringFixed(2), q.Price.String(), rule.Threshold.String()),
+		}, false)
+	}
+}
+
+// fire records the alert and, if this pass won the firing, delivers it.
+func (p *alertPass) fire(ctx context.Context, rule model.AlertRule, event model.AlertEvent, disarm bool) {
+	e := p.evaluator
+	event.RuleID = rule.ID
+	event.UserID = rule.UserID
+	event.Kind = rule.Kind
+	event.Threshold = rule.Threshold
+	event.FiredAt = p.now
+	event, fired, err := e.Rules.FireAlert(ctx, event, disarm)
+	if err != nil {
+		logrus.WithError(err).WithField("rule_id", rule.ID).Error("Failed to record alert")
+		return
+	}
+	if !fired {
+		return
+	}
+	AlertsFiredTotal.WithLabelValues(rule.Kind).Inc()
+	if e.Notifier == nil {
+		return
+	}
+	deliveryErr := ""
+	if err := e.Notifier.NotifyAlert(ctx, event); err != nil {
+		deliveryErr = err.Error()
+		AlertDeliveriesTotal.WithLabelValues("failed").Inc()
+		logrus.WithError(err).WithFields(logrus.Fields{"alert_id": event.ID, "user_id": event.UserID}).Warn("Failed to deliver alert")
+	} else {
+		AlertDeliveriesTotal.WithLabelValues("delivered").Inc()
+	}
+	if err := e.Rules.MarkAlertDelivered(ctx, event.ID, deliveryErr); err != nil {
+		logrus.WithError(err).WithField("alert_id", event.ID).Warn("Failed to record alert delivery")
+	}
+}
+
+func setKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+)
+
+// maxAlertCooldown caps a rule's cooldown at 30 days.
+const maxAlertCooldown = 30 * 24 * 3600
+
+// AlertService manages users' alert rules and their fired alerts.
+type AlertService struct {
+	Repo            repo.AlertRepository
+	Instruments     *InstrumentService // optional: reject unknown symbols
+	MaxRules        int                // per user; zero means unlimited
+	DefaultCooldown int                // seconds, for rules created without one
+}
+
+func (s *AlertService) List(ctx context.Context, userID string) ([]model.AlertRule, error) {
+	return s.Repo.ListAlertRules(ctx, userID)
+}
+
+func (s *AlertService) Get(ctx context.Context, userID, id string) (model.AlertRule, error) {
+	if !validRuleID(id) {
+		return model.AlertRule{}, repo.ErrNotFound
+	}
+	return s.Repo.GetAlertRule(ctx, userID, id)
+}
+
+func (s *AlertService) Create(ctx context.Context, userID string, req model.AlertRuleRequest) (model.AlertRule, error) {
+	if s.MaxRules > 0 {
+		n, err := s.Repo.CountAlertRules(ctx, userID)
+		if err != nil {
+			return model.AlertRule{}, err
+		}
+		if n >= s.MaxRules {
+			return model.AlertRule{}, &ValidationError{fmt.Errorf("at most %d alert rules per user", s.MaxRules)}
+		}
+	}
+	rule := model.AlertRule{UserID: userID, CooldownSeconds: s.DefaultCooldown, Active: true}
+	if req.Threshold == nil {
+		return model.AlertRule{}, &ValidationError{errors.New("threshold is required")}
+	}
+	if err := s.apply(ctx, &rule, req); err != nil {
+		return model.AlertRule{}, err
+	}
+	return s.Repo.CreateAlertRule(ctx, rule)
+}
+
+// Update changes the fields set in req and re-arms the rule.
+func (s *AlertService) Update(ctx context.Context, userID, id string, req model.AlertRuleRequest) (model.AlertRule, error) {
+	rule, err := s.Get(ctx, userID, id)
+	if err != nil {
+		return model.AlertRule{}, err
+	}
+	if err := s.apply(ctx, &rule, req); err != nil {
+		return model.AlertRule{}, err
+	}
+	return s.Repo.UpdateAlertRule(ctx, rule)
+}
+
+func (s *AlertService) Delete(ctx context.Context, userID, id string) error {
+	if !validRuleID(id) {
+		return repo.ErrNotFound
+	}
+	return s.Repo.DeleteAlertRule(ctx, userID, id)
+}
+
+// validRuleID screens out ids that could never match, which Postgres would
+// otherwise reject as malformed UUIDs.
+func validRuleID(id string) bool {
+	_, err := uuid.Parse(id)
+	return err == nil
+}
+
+// Events lists the user's fired alerts, newest first.
+func (s *AlertService) Events(ctx context.Context, userID string, limit int) ([]model.AlertEvent, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	return s.Repo.ListAlertEvents(ctx, userID, limit)
+}
+
+// apply copies the fields set in req onto rule and validates the result.
+func (s *AlertService) apply(ctx context.Context, rule *model.AlertRule, req model.AlertRuleRequest) error {
+	if req.Kind != "" {
+		rule.Kind = req.Kind
+	}
+	if req.Symbol != nil {
+		rule.Symbol = strings.ToUpper(strings.TrimSpace(*req.Symbol))
+	}
+	if req.Threshold != nil {
+		rule.Threshold = *req.Threshold
+	}
+	if req.CooldownSeconds != nil {
+		rule.CooldownSeconds = *req.CooldownSeconds
+	}
+	if req.Active != nil {
+		rule.Active = *req.Active
+	}
+
+	switch rule.Kind {
+	case model.AlertPortfolioAbove, model.AlertPortfolioBelow:
+		if rule.Symbol != "" {
+			return &ValidationError{fmt.Errorf("%s rules take no symbol", rule.Kind)}
+		}
+	case model.AlertDailyMove:
+		if rule.Symbol != "" && s.Instruments != nil {
+			instrument, err := s.Instruments.Resolve(ctx, rule.Symbol)
+			if errors.Is(err, ErrUnknownInstrument) {
+				return &ValidationError{err}
+			}
+			if err != nil && !errors.Is(err, ErrInactiveInstrument) {
+				return err
+			}
+			rule.Symbol = instrument.Symbol
+		}
+	default:
+		return &ValidationError{fmt.Errorf("kind must be one of %s, %s, %s", model.AlertPortfolioAbove, model.AlertPortfolioBelow, model.AlertDailyMove)}
+	}
+	if !rule.Threshold.IsPositive() {
+		return &ValidationError{errors.New("threshold must be positive")}
+	}
+	if rule.CooldownSeconds < 0 || rule.CooldownSeconds > maxAlertCooldown {
+		return &ValidationError{fmt.Errorf("cooldown_seconds must be between 0 and %d", maxAlertCooldown)}
+	}
+	return nil
+}
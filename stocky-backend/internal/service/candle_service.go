@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+)
+
+// CandleQuery selects candles for one symbol. Zero From or To leave that
+// end of the range open; Limit caps the result to the latest candles.
+type CandleQuery struct {
+	Symbol   string
+	Interval string
+	From     time.Time
+	To       time.Time
+	Limit    int
+}
+
+// CandleService serves the OHLCV candles the price repository builds as
+// prices are applied.
+type CandleService struct {
+	Repo        repo.CandleRepository
+	Instruments *InstrumentService // optional: resolve ISINs, 404 unknown symbols
+}
+
+func (s *CandleService) Candles(ctx context.Context, q CandleQuery) ([]model.Candle, error) {
+	q.Symbol = strings.ToUpper(strings.TrimSpace(q.Symbol))
+	if q.Interval == "" {
+		q.Interval = model.CandleDay
+	}
+	if _, err := repo.CandleStart(q.Interval, time.Time{}, time.UTC); err != nil {
+		return nil, &ValidationError{fmt.Errorf("interval must be one of %s", strings.Join(model.CandleIntervals, ", "))}
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return nil, &ValidationError{errors.New("from must be before to")}
+	}
+	if q.Limit <= 0 || q.Limit > 1000 {
+		q.Limit = 100
+	}
+	if s.Instruments != nil {
+		instrument, err := s.Instruments.Resolve(ctx, q.Symbol)
+		if err != nil && !errors.Is(err, ErrInactiveInstrument) {
+			return nil, err
+		}
+		q.Symbol = instrument.Symbol
+	}
+	return s.Repo.ListCandles(ctx, q.Symbol, q.Interval, q.From, q.To, q.Limit)
+}
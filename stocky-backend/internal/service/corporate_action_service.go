@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mhatrejeets/stocky-ms/internal/events"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/shopspring/decimal"
+)
+
+// CorporateActionService announces splits, bonus issues, consolidations and
+// cash dividends. Holdings are adjusted by the projector when the event is
+// consumed; dividends leave them alone. Events is normally a
+// repo.OutboxPublisher, so an announcement is kept until it is published.
+type CorporateActionService struct {
+	Events  events.EventPublisher
+	Encoder *events.Encoder
+}
+
+func (s *CorporateActionService) Announce(ctx context.Context, req model.CorporateActionRequest) (model.CorporateActionEvent, error) {
+	if err := validate.Struct(req); err != nil {
+		return model.CorporateActionEvent{}, &ValidationError{err}
+	}
+	ratio, _ := decimal.NewFromString(req.Ratio)
+	if !ratio.IsPositive() {
+		return model.CorporateActionEvent{}, &ValidationError{errors.New("ratio must be positive")}
+	}
+	effectiveAt, _ := time.Parse(time.RFC3339, req.EffectiveAt)
+	event := model.CorporateActionEvent{
+		ActionID:    uuid.NewString(),
+		Symbol:      strings.ToUpper(req.Symbol),
+		ActionType:  req.ActionType,
+		Ratio:       ratio.String(),
+		EffectiveAt: effectiveAt.UTC().Format(time.RFC3339),
+	}
+	msg, err := s.Encoder.CorporateActionMessage(event)
+	if err != nil {
+		return model.CorporateActionEvent{}, err
+	}
+	return event, s.Events.Publish(ctx, msg)
+}
+
+// DeclareDividend announces a cash dividend so holders can be notified.
+func (s *CorporateActionService) DeclareDividend(ctx context.Context, req model.DividendRequest) (model.DividendEvent, error) {
+	if err := validate.Struct(req); err != nil {
+		return model.DividendEvent{}, &ValidationError{err}
+	}
+	amount, _ := decimal.NewFromString(req.AmountPerShare)
+	if !amount.IsPositive() {
+		return model.DividendEvent{}, &ValidationError{errors.New("amount_per_share must be positive")}
+	}
+	if req.PayDate < req.RecordDate {
+		return model.DividendEvent{}, &ValidationError{errors.New("pay_date must not be before record_date")}
+	}
+	currency := strings.ToUpper(req.Currency)
+	if currency == "" {
+		currency = "INR"
+	}
+	event := model.DividendEvent{
+		DividendID:     uuid.NewString(),
+		Symbol:         strings.ToUpper(req.Symbol),
+		AmountPerShare: amount.String(),
+		Currency:       currency,
+		RecordDate:     req.RecordDate,
+		PayDate:        req.PayDate,
+	}
+	msg, err := s.Encoder.DividendDeclaredMessage(event)
+	if err != nil {
+		return model.DividendEvent{}, err
+	}
+	return event, s.Events.Publish(ctx, msg)
+}
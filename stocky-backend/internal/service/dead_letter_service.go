@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/mhatrejeets/stocky-ms/internal/events"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+)
+
+var ErrDeadLetterResolved = errors.New("dead letter already resolved")
+
+// DeadLetterService lets operators inspect, replay and discard messages that
+// exhausted their retries. Tiers are the retry tiers the consumer groups use.
+type DeadLetterService struct {
+	Repo   repo.DeadLetterRepository
+	Events events.EventPublisher
+	Tiers  []events.RetryTier
+}
+
+func (s *DeadLetterService) List(ctx context.Context, status string, limit int) ([]model.DeadLetter, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	return s.Repo.ListDeadLetters(ctx, status, limit)
+}
+
+func (s *DeadLetterService) Get(ctx context.Context, id string) (model.DeadLetter, error) {
+	if !validDeadLetterID(id) {
+		return model.DeadLetter{}, repo.ErrNotFound
+	}
+	return s.Repo.GetDeadLetter(ctx, id)
+}
+
+// Replay republishes the message to the first retry tier of the consumer
+// group that dead-lettered it, giving it a fresh set of attempts without
+// redelivering it to the other groups on its topic. The dead letter is
+// marked replayed first, so two replays cannot both publish it; if the
+// publish fails it goes back to pending.
+func (s *DeadLetterService) Replay(ctx context.Context, id string) error {
+	dl, err := s.resolve(ctx, id, model.DeadLetterReplayed)
+	if err != nil {
+		return err
+	}
+	msg := events.ReplayTo(events.Message{
+		Topic:   dl.OriginalTopic,
+		Key:     dl.Key,
+		Type:    dl.EventType,
+		Headers: dl.Headers,
+		Value:   dl.Payload,
+	}, dl.Group, s.Tiers)
+	if err := s.Events.Publish(ctx, msg); err != nil {
+		if reopenErr := s.Repo.UpdateDeadLetterStatus(ctx, id, model.DeadLetterPending); reopenErr != nil {
+			return errors.Join(err, reopenErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *DeadLetterService) Discard(ctx context.Context, id string) error {
+	_, err := s.resolve(ctx, id, model.DeadLetterDiscarded)
+	return err
+}
+
+// resolve moves a pending dead letter to status, telling a dead letter that
+// was already resolved apart from one that does not exist.
+func (s *DeadLetterService) resolve(ctx context.Context, id, status string) (model.DeadLetter, error) {
+	if !validDeadLetterID(id) {
+		return model.DeadLetter{}, repo.ErrNotFound
+	}
+	dl, err := s.Repo.ResolveDeadLetter(ctx, id, status)
+	if !errors.Is(err, repo.ErrNotFound) {
+		return dl, err
+	}
+	if _, getErr := s.Repo.GetDeadLetter(ctx, id); getErr != nil {
+		return model.DeadLetter{}, getErr
+	}
+	return model.DeadLetter{}, ErrDeadLetterResolved
+}
+
+// validDeadLetterID screens out ids that could never match, which Postgres
+// would otherwise reject as malformed UUIDs.
+func validDeadLetterID(id string) bool {
+	_, err := uuid.Parse(id)
+	return err == nil
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/sirupsen/logrus"
+)
+
+// FXUpdater copies INR rates for Currencies from Provider into the stored
+// rate history every Interval. Valuations read the stored rates, so they
+// keep working, on the last known rate, while the provider is down.
+type FXUpdater struct {
+	Provider   repo.FXSource
+	Store      repo.FXRepository
+	Currencies []string
+	Interval   time.Duration
+}
+
+// Run refreshes until ctx is cancelled.
+func (u *FXUpdater) Run(ctx context.Context) error {
+	for {
+		if _, err := u.Refresh(ctx); err != nil {
+			logrus.WithError(err).Error("FX rate refresh failed")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(u.Interval):
+		}
+	}
+}
+
+// Refresh fetches and records one set of rates, returning how many were new.
+func (u *FXUpdater) Refresh(ctx context.Context) (int, error) {
+	if len(u.Currencies) == 0 {
+		return 0, nil
+	}
+	found, err := u.Provider.GetRates(ctx, u.Currencies)
+	if err != nil {
+		return 0, err
+	}
+	rates := make([]model.FXRate, 0, len(found))
+	for _, c := range u.Currencies {
+		if rate, ok := found[c]; ok {
+			rates = append(rates, rate)
+		} else {
+			logrus.WithField("currency", c).Warn("No FX rate from provider")
+		}
+	}
+	return u.Store.RecordFXRates(ctx, rates)
+}
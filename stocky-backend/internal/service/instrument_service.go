@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrUnknownInstrument  = errors.New("unknown instrument")
+	ErrInactiveInstrument = errors.New("instrument is not active")
+)
+
+// InstrumentService is the instrument master: it resolves user supplied
+// symbols or ISINs to listed instruments and loads exchange listing files.
+type InstrumentService struct {
+	Repo repo.InstrumentRepository
+
+	loaded atomic.Bool // set once any listing has been seen
+	warned sync.Once
+}
+
+// Resolve normalizes code (a symbol or ISIN, any case) and returns its
+// listing, preferring NSE when a security trades on both exchanges.
+//
+// Until a listing file is imported the master is empty and validates
+// nothing: every code resolves to an active instrument of that symbol with
+// no currency.
+func (s *InstrumentService) Resolve(ctx context.Context, code string) (model.Instrument, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	found, err := s.Repo.FindInstruments(ctx, code)
+	if err != nil {
+		return model.Instrument{}, err
+	}
+	if len(found) == 0 {
+		loaded, err := s.isLoaded(ctx)
+		if err != nil {
+			return model.Instrument{}, err
+		}
+		if !loaded {
+			return model.Instrument{Symbol: code, Status: model.InstrumentActive}, nil
+		}
+		return model.Instrument{}, fmt.Errorf("%q: %w", code, ErrUnknownInstrument)
+	}
+	for _, in := range found {
+		if in.Status == model.InstrumentActive {
+			return in, nil
+		}
+	}
+	return found[0], fmt.Errorf("%s is %s: %w", found[0].Symbol, found[0].Status, ErrInactiveInstrument)
+}
+
+// isLoaded reports whether the master holds any listing. Listings are never
+// deleted, so once it has some it is not asked again.
+func (s *InstrumentService) isLoaded(ctx context.Context) (bool, error) {
+	if s.loaded.Load() {
+		return true, nil
+	}
+	imported, err := s.Repo.AnyInstruments(ctx)
+	if err != nil {
+		return false, err
+	}
+	if imported {
+		s.loaded.Store(true)
+		return true, nil
+	}
+	s.warned.Do(func() {
+		logrus.Warn("Instrument master is empty, accepting every symbol until listings are imported")
+	})
+	return false, nil
+}
+
+func (s *InstrumentService) Search(ctx context.Context, query string, limit int) ([]model.Instrument, error) {
+	if limit <= 0 || limit > 50 {
+		limit = 20
+	}
+	return s.Repo.SearchInstruments(ctx, strings.TrimSpace(query), limit)
+}
+
+// Symbols implements SymbolSource with every active listing.
+func (s *InstrumentService) Symbols(ctx context.Context) ([]string, error) {
+	return s.Repo.ActiveSymbols(ctx)
+}
+
+// Import loads a listing CSV and upserts it, returning the row count. The
+// whole file is rejected if any row is invalid.
+func (s *InstrumentService) Import(ctx context.Context, r io.Reader, exchange string) (int, error) {
+	instruments, err := ParseInstrumentsCSV(r, exchange)
+	if err != nil {
+		return 0, &ValidationError{err}
+	}
+	if err := s.Repo.UpsertInstruments(ctx, instruments); err != nil {
+		return 0, err
+	}
+	return len(instruments), nil
+}
+
+// instrumentColumns maps accepted header names, including those of the NSE
+// EQUITY_L and BSE scrip master files, to fields.
+var instrumentColumns = map[string]string{
+	"SYMBOL":          "symbol",
+	"SCRIP_ID":        "symbol",
+	"SECURITY ID":     "symbol",
+	"EXCHANGE":        "exchange",
+	"ISIN":            "isin",
+	"ISIN NUMBER":     "isin",
+	"ISIN_NO":         "isin",
+	"NAME":            "name",
+	"NAME OF COMPANY": "name",
+	"SECURITY NAME":   "name",
+	"SECTOR":          "sector",
+	"INDUSTRY":        "sector",
+	"LOT_SIZE":        "lot_size",
+	"MARKET LOT":      "lot_size",
+	"TICK_SIZE":       "tick_size",
+	"CURRENCY":        "currency",
+	"STATUS":          "status",
+	"SERIES":          "series",
+}
+
+// ParseInstrumentsCSV reads a bhavcopy-style listing file. Columns are
+// matched by header name; rows default to exchange when the file has no
+// EXCHANGE column, and to that exchange's currency when it has no CURRENCY
+// column. Non-equity series (anything but EQ/BE/BZ) are skipped.
+func ParseInstrumentsCSV(r io.Reader, exchange string) ([]model.Instrument, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	cols := make(map[string]int)
+	for i, h := range header {
+		if field, ok := instrumentColumns[strings.ToUpper(strings.TrimSpace(h))]; ok {
+			cols[field] = i
+		}
+	}
+	for _, required := range []string{"symbol", "isin", "name"} {
+		if _, ok := cols[required]; !ok {
+			return nil, fmt.Errorf("missing %s column", required)
+		}
+	}
+	get := func(rec []string, field string) string {
+		if i, ok := cols[field]; ok && i < len(rec) {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+
+	var out []model.Instrument
+	seen := make(map[string]int)
+	for line := 2; ; line++ {
+		rec, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		switch strings.ToUpper(get(rec, "series")) {
+		case "", "EQ", "BE", "BZ":
+		default:
+			continue
+		}
+		in := model.Instrument{
+			Symbol:   strings.ToUpper(get(rec, "symbol")),
+			Exchange: strings.ToUpper(get(rec, "exchange")),
+			ISIN:     strings.ToUpper(get(rec, "isin")),
+			Name:     get(rec, "name"),
+			Sector:   get(rec, "sector"),
+			LotSize:  1,
+			TickSize: decimal.RequireFromString("0.05"),
+			Currency: strings.ToUpper(get(rec, "currency")),
+			Status:   strings.ToLower(get(rec, "status")),
+		}
+		if in.Exchange == "" {
+			in.Exchange = strings.ToUpper(exchange)
+		}
+		if in.Currency == "" {
+			in.Currency = model.ExchangeCurrency(in.Exchange)
+		}
+		if in.Status == "" {
+			in.Status = model.InstrumentActive
+		}
+		if v := get(rec, "lot_size"); v != "" {
+			if in.LotSize, err = strconv.Atoi(v); err != nil || in.LotSize < 1 {
+				return nil, fmt.Errorf("line %d: invalid lot size %q", line, v)
+			}
+		}
+		if v := get(rec, "tick_size"); v != "" {
+			if in.TickSize, err = decimal.NewFromString(v); err != nil || !in.TickSize.IsPositive() {
+				return nil, fmt.Errorf("line %d: invalid tick size %q", line, v)
+			}
+		}
+		if err := validateInstrument(in); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		key := in.Exchange + ":" + in.Symbol
+		if prev, dup := seen[key]; dup {
+			return nil, fmt.Errorf("line %d: %s duplicates line %d", line, key, prev)
+		}
+		seen[key] = line
+		out = append(out, in)
+	}
+	return out, nil
+}
+
+var supportedExchanges = map[string]bool{
+	model.ExchangeNSE:    true,
+	model.ExchangeBSE:    true,
+	model.ExchangeNYSE:   true,
+	model.ExchangeNASDAQ: true,
+}
+
+// ValidCurrency checks for a three letter ISO 4217 style code.
+func ValidCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func validateInstrument(in model.Instrument) error {
+	switch {
+	case in.Symbol == "" || len(in.Symbol) > 16:
+		return fmt.Errorf("invalid symbol %q", in.Symbol)
+	case !supportedExchanges[in.Exchange]:
+		return fmt.Errorf("%s: unsupported exchange %q", in.Symbol, in.Exchange)
+	case !ValidCurrency(in.Currency):
+		return fmt.Errorf("%s: invalid currency %q", in.Symbol, in.Currency)
+	case !ValidISIN(in.ISIN):
+		return fmt.Errorf("%s: invalid ISIN %q", in.Symbol, in.ISIN)
+	case in.Name == "":
+		return fmt.Errorf("%s: missing name", in.Symbol)
+	}
+	switch in.Status {
+	case model.InstrumentActive, model.InstrumentSuspended, model.InstrumentDelisted:
+		return nil
+	}
+	return fmt.Errorf("%s: invalid status %q", in.Symbol, in.Status)
+}
+
+// ValidISIN checks the ISO 6166 format: a two letter country code, nine
+// alphanumerics and a Luhn check digit computed over the letter-expanded
+// digits.
+func ValidISIN(isin string) bool {
+	if len(isin) != 12 {
+		return false
+	}
+	var digits []int
+	for i, c := range isin {
+		switch {
+		case c >= '0' && c <= '9' && i >= 2:
+			digits = append(digits, int(c-'0'))
+		case c >= 'A' && c <= 'Z' && i < 11:
+			v := int(c-'A') + 10
+			digits = append(digits, v/10, v%10)
+		default:
+			return false
+		}
+	}
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := digits[i]
+		if (len(digits)-1-i)%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
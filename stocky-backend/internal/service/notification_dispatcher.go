@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// NotificationDeliveriesTotal counts delivery attempts by channel and result
+// (sent, retry, failed).
+var NotificationDeliveriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "stocky_notification_deliveries_total",
+	Help: "Notification delivery attempts, by channel and result.",
+}, []string{"channel", "result"})
+
+// NotificationSender delivers a notification on one channel. Errors
+// wrapping model.ErrUndeliverable are not retried.
+type NotificationSender interface {
+	Send(ctx context.Context, n model.Notification) error
+}
+
+// NotificationDispatcher sends queued notifications every Interval. Failed
+// sends are retried after Backoff, doubling up to MaxBackoff, until
+// MaxAttempts is spent. Every replica may run one: claims are leased, so a
+// notification is sent by one dispatcher at a time.
+type NotificationDispatcher struct {
+	Repo        repo.NotificationRepository
+	Senders     map[string]NotificationSender // by channel
+	Interval    time.Duration
+	BatchSize   int
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	SendTimeout time.Duration
+	Now         func() time.Time
+}
+
+func (d *NotificationDispatcher) now() time.Time {
+	if d.Now != nil {
+		return d.Now()
+	}
+	return time.Now()
+}
+
+// Run dispatches due notifications every Interval until ctx is cancelled.
+func (d *NotificationDispatcher) Run(ctx context.Context) error {
+	interval := d.Interval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if _, err := d.Dispatch(ctx); err != nil {
+				logrus.WithError(err).Warn("Notification dispatch failed, will retry")
+			}
+		}
+	}
+}
+
+// Dispatch sends one batch of due notifications, returning how many were
+// claimed.
+func (d *NotificationDispatcher) Dispatch(ctx context.Context) (int, error) {
+	batch := d.BatchSize
+	if batch <= 0 {
+		batch = 100
+	}
+	timeout := d.SendTimeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	// The lease covers sending the whole batch one after another.
+	due, err := d.Repo.ClaimDueNotifications(ctx, d.now(), time.Duration(batch)*timeout, batch)
+	if err != nil {
+		return 0, err
+	}
+	for _, n := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		d.send(ctx, n, timeout)
+	}
+	return len(due), nil
+}
+
+func (d *NotificationDispatcher) send(ctx context.Context, n model.Notification, timeout time.Duration) {
+	log := logrus.WithFields(logrus.Fields{"notification_id": n.ID, "user_id": n.UserID, "channel": n.Channel, "attempt": n.Attempts})
+	var err error
+	if sender, ok := d.Senders[n.Channel]; ok {
+		sendCtx, cancel := context.WithTimeout(ctx, timeout)
+		err = sender.Send(sendCtx, n)
+		cancel()
+	} else {
+		err = fmt.Errorf("%w: no sender for channel %s", model.ErrUndeliverable, n.Channel)
+	}
+	if err == nil {
+		NotificationDeliveriesTotal.WithLabelValues(n.Channel, "sent").Inc()
+		if err := d.Repo.MarkNotificationSent(ctx, n.ID, d.now()); err != nil {
+			log.WithError(err).Warn("Failed to record notification delivery")
+		}
+		return
+	}
+
+	var retryAt *time.Time
+	if !errors.Is(err, model.ErrUndeliverable) && n.Attempts < d.maxAttempts() {
+		at := d.now().Add(d.backoff(n.Attempts))
+		retryAt = &at
+		NotificationDeliveriesTotal.WithLabelValues(n.Channel, "retry").Inc()
+		log.WithError(err).Warn("Notification delivery failed, will retry")
+	} else {
+		NotificationDeliveriesTotal.WithLabelValues(n.Channel, "failed").Inc()
+		log.WithError(err).Error("Notification delivery failed, giving up")
+	}
+	if err := d.Repo.MarkNotificationFailed(ctx, n.ID, err.Error(), retryAt); err != nil {
+		log.WithError(err).Warn("Failed to record notification failure")
+	}
+}
+
+func (d *NotificationDispatcher) maxAttempts() int {
+	if d.MaxAttempts <= 0 {
+		return 5
+	}
+	return d.MaxAttempts
+}
+
+// backoff is the wait after the given attempt failed.
+func (d *NotificationDispatcher) backoff(attempt int) time.Duration {
+	wait, limit := d.Backoff, d.MaxBackoff
+	if wait <= 0 {
+		wait = 30 * time.Second
+	}
+	if limit <= 0 {
+		limit = time.Hour
+	}
+	for i := 1; i < attempt && wait < limit; i++ {
+		wait *= 2
+	}
+	if wait > limit {
+		wait = limit
+	}
+	return wait
+}
@@ -0,0 +1,341 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/events"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+var notificationChannels = []string{model.ChannelEmail, model.ChannelSMS, model.ChannelPush}
+
+var notificationKinds = []string{model.NotificationRewardCreated, model.NotificationRewardReversed, model.NotificationDividend}
+
+var phoneNumberPattern = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+// HolderSource lists everyone holding a symbol just before an instant and
+// how many shares.
+type HolderSource interface {
+	ListHoldersAt(ctx context.Context, symbol string, at time.Time) (map[string]decimal.Decimal, error)
+}
+
+// NotificationService keeps users' notification preferences and turns
+// reward, reversal and dividend events into notifications, rendered in each
+// user's locale and queued for the NotificationDispatcher.
+//
+// Dividend holders are those entitled at the end of the record date, so a
+// dividend declared ahead of it is held until then; Run notifies them once
+// it has passed.
+type NotificationService struct {
+	Repo             repo.NotificationRepository
+	Holders          HolderSource
+	Templates        *NotificationTemplates
+	Channels         []string       // channels that can be delivered; all when nil
+	Location         *time.Location // for dates in messages and record dates; UTC when nil
+	DividendInterval time.Duration  // how often Run looks for due dividends; a minute when zero
+	Now              func() time.Time
+}
+
+// notificationData is what templates can refer to.
+type notificationData struct {
+	Symbol         string
+	Shares         string
+	Date           string
+	Reason         string
+	AmountPerShare string
+	Amount         string
+	Currency       string
+	RecordDate     string
+	PayDate        string
+}
+
+// Register subscribes the service's handlers on registry.
+func (s *NotificationService) Register(registry *events.Registry) {
+	registry.Register(events.TypeRewardCreated, s.OnRewardCreated)
+	registry.Register(events.TypeRewardReversed, s.OnRewardReversed)
+	registry.Register(events.TypeDividendDeclared, s.OnDividendDeclared)
+}
+
+func (s *NotificationService) OnRewardCreated(ctx context.Context, msg events.Message) error {
+	var event model.RewardCreatedEvent
+	if err := decodeEvent(msg, &event); err != nil {
+		return err
+	}
+	data := notificationData{Symbol: event.StockSymbol, Shares: event.Shares, Date: s.date(event.RewardedAt)}
+	return s.notify(ctx, model.NotificationRewardCreated, repo.RewardCreatedKey(event.RewardID), map[string]notificationData{event.UserID: data})
+}
+
+func (s *NotificationService) OnRewardReversed(ctx context.Context, msg events.Message) error {
+	var event model.RewardReversedEvent
+	if err := decodeEvent(msg, &event); err != nil {
+		return err
+	}
+	data := notificationData{Symbol: event.StockSymbol, Shares: event.Shares, Date: s.date(event.ReversedAt), Reason: event.Reason}
+	return s.notify(ctx, model.NotificationRewardReversed, repo.RewardReversedKey(event.RewardID), map[string]notificationData{event.UserID: data})
+}
+
+// OnDividendDeclared schedules the dividend's notifications for the end of
+// its record date and sends them straight away if that has passed.
+func (s *NotificationService) OnDividendDeclared(ctx context.Context, msg events.Message) error {
+	var event model.DividendEvent
+	if err := decodeEvent(msg, &event); err != nil {
+		return err
+	}
+	if _, err := decimal.NewFromString(event.AmountPerShare); err != nil {
+		return err
+	}
+	recordDate, err := time.ParseInLocation("2006-01-02", event.RecordDate, s.location())
+	if err != nil {
+		return err
+	}
+	dividend := model.ScheduledDividend{DividendEvent: event, EntitledAt: recordDate.AddDate(0, 0, 1)}
+	if err := s.Repo.ScheduleDividend(ctx, dividend); err != nil {
+		return err
+	}
+	if dividend.EntitledAt.After(s.now()) {
+		return nil
+	}
+	return s.notifyDividend(ctx, dividend)
+}
+
+// Run notifies the holders of each dividend as its record date ends, every
+// DividendInterval until ctx is cancelled. Every replica may run it: the
+// notifications are deduplicated, so a dividend handled twice queues
+// nothing more.
+func (s *NotificationService) Run(ctx context.Context) error {
+	interval := s.DividendInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if _, err := s.NotifyDueDividends(ctx); err != nil {
+				logrus.WithError(err).Warn("Dividend notification failed, will retry")
+			}
+		}
+	}
+}
+
+// NotifyDueDividends notifies the holders of the dividends whose record
+// date has ended, returning how many were done. One failing does not hold
+// up the rest.
+func (s *NotificationService) NotifyDueDividends(ctx context.Context) (int, error) {
+	due, err := s.Repo.ListDueDividends(ctx, s.now(), 100)
+	if err != nil {
+		return 0, err
+	}
+	done := 0
+	var errs []error
+	for _, dividend := range due {
+		if err := s.notifyDividend(ctx, dividend); err != nil {
+			errs = append(errs, fmt.Errorf("dividend %s: %w", dividend.DividendID, err))
+			continue
+		}
+		done++
+	}
+	return done, errors.Join(errs...)
+}
+
+// notifyDividend tells everyone entitled to the dividend what their shares
+// will earn, then marks it notified.
+func (s *NotificationService) notifyDividend(ctx context.Context, dividend model.ScheduledDividend) error {
+	perShare, err := decimal.NewFromString(dividend.AmountPerShare)
+	if err != nil {
+		return err
+	}
+	holders, err := s.Holders.ListHoldersAt(ctx, dividend.Symbol, dividend.EntitledAt)
+	if err != nil {
+		return err
+	}
+	recipients := make(map[string]notificationData, len(holders))
+	for userID, shares := range holders {
+		recipients[userID] = notificationData{
+			Symbol:         dividend.Symbol,
+			Shares:         shares.String(),
+			AmountPerShare: perShare.String(),
+			Amount:         shares.Mul(perShare).StringFixed(2),
+			Currency:       dividend.Currency,
+			RecordDate:     dividend.RecordDate,
+			PayDate:        dividend.PayDate,
+		}
+	}
+	if err := s.notify(ctx, model.NotificationDividend, "dividend:"+dividend.DividendID, recipients); err != nil {
+		return err
+	}
+	return s.Repo.MarkDividendNotified(ctx, dividend.DividendID, s.now())
+}
+
+// notify queues kind for every enabled channel of each recipient. Returning
+// an error gets the event redelivered; dedupKey keeps that from queueing
+// anything twice.
+func (s *NotificationService) notify(ctx context.Context, kind, dedupKey string, recipients map[string]notificationData) error {
+	if len(recipients) == 0 {
+		return nil
+	}
+	users := make([]string, 0, len(recipients))
+	for userID := range recipients {
+		users = append(users, userID)
+	}
+	prefs, err := s.Repo.ListEnabledPreferences(ctx, users)
+	if err != nil {
+		return err
+	}
+	var notifications []model.Notification
+	for _, pref := range prefs {
+		data, ok := recipients[pref.UserID]
+		if !ok || !s.available(pref.Channel) || !(len(pref.Kinds) == 0 || contains(pref.Kinds, kind)) {
+			continue
+		}
+		subject, body, err := s.Templates.Render(kind, pref.Locale, pref.Channel, data)
+		if err != nil {
+			return err
+		}
+		notifications = append(notifications, model.Notification{
+			UserID:   pref.UserID,
+			Channel:  pref.Channel,
+			Kind:     kind,
+			Address:  pref.Address,
+			Subject:  subject,
+			Body:     body,
+			DedupKey: dedupKey,
+		})
+	}
+	if len(notifications) == 0 {
+		return nil
+	}
+	queued, err := s.Repo.EnqueueNotifications(ctx, notifications)
+	if err != nil {
+		return err
+	}
+	logrus.WithFields(logrus.Fields{"kind": kind, "key": dedupKey, "queued": queued}).Debug("Queued notifications")
+	return nil
+}
+
+func (s *NotificationService) date(timestamp string) string {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return timestamp
+	}
+	return t.In(s.location()).Format("02 Jan 2006")
+}
+
+func (s *NotificationService) location() *time.Location {
+	if s.Location != nil {
+		return s.Location
+	}
+	return time.UTC
+}
+
+func (s *NotificationService) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func (s *NotificationService) available(channel string) bool {
+	return s.Channels == nil || contains(s.Channels, channel)
+}
+
+func (s *NotificationService) Preferences(ctx context.Context, userID string) ([]model.NotificationPreference, error) {
+	return s.Repo.ListPreferences(ctx, userID)
+}
+
+// SetPreference creates or changes the user's preference for channel. A new
+// channel is enabled in the default locale unless req says otherwise.
+func (s *NotificationService) SetPreference(ctx context.Context, userID, channel string, req model.NotificationPreferenceRequest) (model.NotificationPreference, error) {
+	if !contains(notificationChannels, channel) {
+		return model.NotificationPreference{}, &ValidationError{fmt.Errorf("channel must be one of %s", strings.Join(notificationChannels, ", "))}
+	}
+	if !s.available(channel) {
+		return model.NotificationPreference{}, &ValidationError{fmt.Errorf("%s notifications are not available", channel)}
+	}
+	existing, err := s.Repo.ListPreferences(ctx, userID)
+	if err != nil {
+		return model.NotificationPreference{}, err
+	}
+	pref := model.NotificationPreference{UserID: userID, Channel: channel, Locale: s.Templates.DefaultLocale, Enabled: true}
+	for _, p := range existing {
+		if p.Channel == channel {
+			pref = p
+		}
+	}
+	if req.Address != nil {
+		pref.Address = strings.TrimSpace(*req.Address)
+	}
+	if req.Locale != nil {
+		pref.Locale = strings.TrimSpace(*req.Locale)
+	}
+	if req.Enabled != nil {
+		pref.Enabled = *req.Enabled
+	}
+	if req.Kinds != nil {
+		pref.Kinds = *req.Kinds
+	}
+	if err := s.validatePreference(pref); err != nil {
+		return model.NotificationPreference{}, err
+	}
+	return s.Repo.UpsertPreference(ctx, pref)
+}
+
+func (s *NotificationService) validatePreference(pref model.NotificationPreference) error {
+	switch pref.Channel {
+	case model.ChannelEmail:
+		if addr, err := mail.ParseAddress(pref.Address); err != nil || addr.Address != pref.Address {
+			return &ValidationError{errors.New("address must be a plain email address")}
+		}
+	case model.ChannelSMS:
+		if !phoneNumberPattern.MatchString(pref.Address) {
+			return &ValidationError{errors.New("address must be an E.164 phone number such as +919876543210")}
+		}
+	case model.ChannelPush:
+		if pref.Address == "" || len(pref.Address) > 4096 {
+			return &ValidationError{errors.New("address must be a push token of at most 4096 bytes")}
+		}
+	}
+	if !s.Templates.HasLocale(pref.Locale) {
+		return &ValidationError{fmt.Errorf("locale must be one of %s", strings.Join(s.Templates.Locales(), ", "))}
+	}
+	for _, kind := range pref.Kinds {
+		if !contains(notificationKinds, kind) {
+			return &ValidationError{fmt.Errorf("kinds must be among %s", strings.Join(notificationKinds, ", "))}
+		}
+	}
+	return nil
+}
+
+func (s *NotificationService) DeletePreference(ctx context.Context, userID, channel string) error {
+	return s.Repo.DeletePreference(ctx, userID, channel)
+}
+
+// History lists the user's notifications and their delivery status, newest
+// first.
+func (s *NotificationService) History(ctx context.Context, userID string, limit int) ([]model.Notification, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	return s.Repo.ListNotifications(ctx, userID, limit)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
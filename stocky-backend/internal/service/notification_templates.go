@@ -0,0 +1,129 @@
+package service
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+)
+
+//go:embed notification_templates/*.tmpl
+var embeddedNotificationTemplates embed.FS
+
+// NotificationTemplates renders notifications from <kind>.<locale>.tmpl
+// files. Each file defines "subject" and "body" for email and "text", the
+// short form sent by SMS and as a push body under the subject.
+type NotificationTemplates struct {
+	DefaultLocale string
+
+	templates map[string]*template.Template // by "<kind>.<locale>"
+}
+
+// LoadNotificationTemplates loads the templates bundled with the binary,
+// then any in dir (if non-empty), which may add locales or replace bundled
+// templates.
+func LoadNotificationTemplates(dir string) (*NotificationTemplates, error) {
+	t := &NotificationTemplates{DefaultLocale: "en", templates: make(map[string]*template.Template)}
+	sub, err := fs.Sub(embeddedNotificationTemplates, "notification_templates")
+	if err != nil {
+		return nil, err
+	}
+	if err := t.LoadFS(sub); err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		if err := t.LoadFS(os.DirFS(dir)); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// LoadFS registers every *.tmpl file in fsys.
+func (t *NotificationTemplates) LoadFS(fsys fs.FS) error {
+	paths, err := fs.Glob(fsys, "*.tmpl")
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		name := strings.TrimSuffix(path.Base(p), ".tmpl")
+		if strings.Count(name, ".") != 1 {
+			return fmt.Errorf("notification template %s: want <kind>.<locale>.tmpl", p)
+		}
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		tpl, err := template.New(name).Option("missingkey=error").Parse(string(data))
+		if err != nil {
+			return fmt.Errorf("notification template %s: %w", p, err)
+		}
+		for _, part := range []string{"subject", "body", "text"} {
+			if tpl.Lookup(part) == nil {
+				return fmt.Errorf("notification template %s: missing %q", p, part)
+			}
+		}
+		t.templates[name] = tpl
+	}
+	return nil
+}
+
+// Locales lists every locale with at least one template.
+func (t *NotificationTemplates) Locales() []string {
+	seen := make(map[string]bool)
+	for name := range t.templates {
+		seen[name[strings.Index(name, ".")+1:]] = true
+	}
+	locales := setKeys(seen)
+	sort.Strings(locales)
+	return locales
+}
+
+// HasLocale reports whether any template is written in locale.
+func (t *NotificationTemplates) HasLocale(locale string) bool {
+	for _, l := range t.Locales() {
+		if l == locale {
+			return true
+		}
+	}
+	return false
+}
+
+// Render fills kind's template for channel in locale, falling back to the
+// default locale when there is no translation.
+func (t *NotificationTemplates) Render(kind, locale, channel string, data interface{}) (subject, body string, err error) {
+	tpl, ok := t.templates[kind+"."+locale]
+	if !ok {
+		tpl, ok = t.templates[kind+"."+t.DefaultLocale]
+	}
+	if !ok {
+		return "", "", fmt.Errorf("no %s notification template for locale %q", kind, locale)
+	}
+	exec := func(part string) (string, error) {
+		var buf bytes.Buffer
+		err := tpl.ExecuteTemplate(&buf, part, data)
+		return buf.String(), err
+	}
+	switch channel {
+	case model.ChannelEmail:
+		if subject, err = exec("subject"); err != nil {
+			return "", "", err
+		}
+		body, err = exec("body")
+	case model.ChannelPush:
+		if subject, err = exec("subject"); err != nil {
+			return "", "", err
+		}
+		body, err = exec("text")
+	default:
+		body, err = exec("text")
+	}
+	return subject, body, err
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/events"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// OutboxPublishesTotal counts outbox relay publish attempts by result
+// (published, retry).
+var OutboxPublishesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "stocky_outbox_publishes_total",
+	Help: "Outbox relay publish attempts, by result.",
+}, []string{"result"})
+
+// OutboxRelay publishes events left in the outbox every Interval: those
+// whose writer failed to publish them, or crashed before it could. Failed
+// publishes are retried after Backoff, doubling up to MaxBackoff, for as long
+// as it takes. Events are delivered at least once, so consumers dedup them.
+type OutboxRelay struct {
+	Repo       repo.OutboxRepository
+	Events     events.EventPublisher
+	Interval   time.Duration
+	BatchSize  int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	Now        func() time.Time
+}
+
+func (o *OutboxRelay) now() time.Time {
+	if o.Now != nil {
+		return o.Now()
+	}
+	return time.Now()
+}
+
+// Run relays due events every Interval until ctx is cancelled.
+func (o *OutboxRelay) Run(ctx context.Context) error {
+	interval := o.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if _, err := o.Relay(ctx); err != nil {
+				logrus.WithError(err).Warn("Outbox relay failed, will retry")
+			}
+		}
+	}
+}
+
+// Relay publishes one batch of due events in the order they were written,
+// returning how many were claimed.
+func (o *OutboxRelay) Relay(ctx context.Context) (int, error) {
+	batch := o.BatchSize
+	if batch <= 0 {
+		batch = 100
+	}
+	due, err := o.Repo.ClaimDueEvents(ctx, o.now(), time.Minute, batch)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		log := logrus.WithFields(logrus.Fields{"outbox_id": e.ID, "type": e.Message.Type, "attempt": e.Attempts})
+		if err := o.Events.Publish(ctx, e.Message); err != nil {
+			OutboxPublishesTotal.WithLabelValues("retry").Inc()
+			log.WithError(err).Warn("Outbox publish failed, will retry")
+			if err := o.Repo.MarkEventFailed(ctx, e.ID, err.Error(), o.now().Add(o.backoff(e.Attempts))); err != nil {
+				log.WithError(err).Warn("Failed to record outbox publish failure")
+			}
+			continue
+		}
+		OutboxPublishesTotal.WithLabelValues("published").Inc()
+		if err := o.Repo.MarkEventPublished(ctx, e.ID, o.now()); err != nil {
+			log.WithError(err).Warn("Failed to mark outbox event published")
+		}
+	}
+	return len(due), nil
+}
+
+// backoff is the wait after the given attempt failed.
+func (o *OutboxRelay) backoff(attempt int) time.Duration {
+	wait, limit := o.Backoff, o.MaxBackoff
+	if wait <= 0 {
+		wait = 5 * time.Second
+	}
+	if limit <= 0 {
+		limit = 5 * time.Minute
+	}
+	for i := 1; i < attempt && wait < limit; i++ {
+		wait *= 2
+	}
+	if wait > limit {
+		wait = limit
+	}
+	return wait
+}
+
+// OutboxPruner deletes events published more than Retention ago, every
+// Interval, so the outbox only holds what is pending or recent.
+type OutboxPruner struct {
+	Repo      repo.OutboxRepository
+	Retention time.Duration
+	Interval  time.Duration
+	BatchSize int
+	Now       func() time.Time
+}
+
+// Run prunes every Interval until ctx is cancelled.
+func (p *OutboxPruner) Run(ctx context.Context) error {
+	interval := p.Interval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if _, err := p.Prune(ctx); err != nil {
+				logrus.WithError(err).Warn("Outbox prune failed, will retry")
+			}
+		}
+	}
+}
+
+// Prune deletes every event published before the retention window, in
+// batches, and returns how many it deleted.
+func (p *OutboxPruner) Prune(ctx context.Context) (int, error) {
+	retention, batch := p.Retention, p.BatchSize
+	if retention <= 0 {
+		retention = 7 * 24 * time.Hour
+	}
+	if batch <= 0 {
+		batch = 1000
+	}
+	now := time.Now
+	if p.Now != nil {
+		now = p.Now
+	}
+	before := now().Add(-retention)
+	total := 0
+	for ctx.Err() == nil {
+		n, err := p.Repo.DeletePublishedEvents(ctx, before, batch)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < batch {
+			break
+		}
+	}
+	if total > 0 {
+		logrus.WithField("deleted", total).Info("Pruned published outbox events")
+	}
+	return total, nil
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/events"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+// PortfolioProjector folds reward, reversal and corporate action events into
+// the user_holdings read model that serves the portfolio and stats endpoints.
+// Applied changes are announced on Notifier when set.
+type PortfolioProjector struct {
+	Holdings repo.HoldingsRepository
+	Notifier PortfolioNotifier
+}
+
+// PortfolioNotifier announces holdings changes to open portfolio streams.
+type PortfolioNotifier interface {
+	NotifyPortfolio(ctx context.Context, change model.PortfolioChange) error
+}
+
+// Register subscribes the projector's handlers on registry.
+func (p *PortfolioProjector) Register(registry *events.Registry) {
+	registry.Register(events.TypeRewardCreated, p.OnRewardCreated)
+	registry.Register(events.TypeRewardReversed, p.OnRewardReversed)
+	registry.Register(events.TypeCorporateAction, p.OnCorporateAction)
+}
+
+func (p *PortfolioProjector) OnRewardCreated(ctx context.Context, msg events.Message) error {
+	var event model.RewardCreatedEvent
+	if err := decodeEvent(msg, &event); err != nil {
+		return err
+	}
+	shares, err := decimal.NewFromString(event.Shares)
+	if err != nil {
+		return err
+	}
+	rewardedAt, err := time.Parse(time.RFC3339, event.RewardedAt)
+	if err != nil {
+		return err
+	}
+	reward := model.Reward{ID: event.RewardID, UserID: event.UserID, StockSymbol: event.StockSymbol, Shares: shares, RewardedAt: rewardedAt}
+	applied, err := p.Holdings.ApplyRewardCreated(ctx, reward)
+	if applied {
+		p.notify(ctx, model.PortfolioChange{UserID: event.UserID, Symbol: event.StockSymbol})
+	}
+	return err
+}
+
+// OnRewardReversed takes the reward out by what it holds now rather than the
+// event's share count, which a corporate action may since have restated.
+func (p *PortfolioProjector) OnRewardReversed(ctx context.Context, msg events.Message) error {
+	var event model.RewardReversedEvent
+	if err := decodeEvent(msg, &event); err != nil {
+		return err
+	}
+	applied, err := p.Holdings.ApplyRewardReversed(ctx, event.RewardID)
+	if applied {
+		p.notify(ctx, model.PortfolioChange{UserID: event.UserID, Symbol: event.StockSymbol})
+	}
+	return err
+}
+
+func (p *PortfolioProjector) OnCorporateAction(ctx context.Context, msg events.Message) error {
+	var event model.CorporateActionEvent
+	if err := decodeEvent(msg, &event); err != nil {
+		return err
+	}
+	ratio, err := decimal.NewFromString(event.Ratio)
+	if err != nil {
+		return err
+	}
+	// Announce rejects these; one from elsewhere would wipe out holdings
+	if !ratio.IsPositive() {
+		return fmt.Errorf("corporate action %s: ratio %s is not positive", event.ActionID, event.Ratio)
+	}
+	effectiveAt, err := time.Parse(time.RFC3339, event.EffectiveAt)
+	if err != nil {
+		return err
+	}
+	action := model.CorporateAction{ID: event.ActionID, Symbol: event.Symbol, ActionType: event.ActionType, Ratio: ratio, EffectiveAt: effectiveAt}
+	applied, err := p.Holdings.ApplyCorporateAction(ctx, repo.CorporateActionKey(event.ActionID), action)
+	if applied {
+		logrus.WithFields(logrus.Fields{"symbol": action.Symbol, "type": action.ActionType, "ratio": action.Ratio}).Info("Applied corporate action to holdings")
+		p.notify(ctx, model.PortfolioChange{Symbol: action.Symbol})
+	}
+	return err
+}
+
+// notify is best effort: the projection is already committed, and streams
+// also refresh on the next price for the symbol.
+func (p *PortfolioProjector) notify(ctx context.Context, change model.PortfolioChange) {
+	if p.Notifier == nil {
+		return
+	}
+	if err := p.Notifier.NotifyPortfolio(ctx, change); err != nil {
+		logrus.WithError(err).WithField("user_id", change.UserID).Warn("Failed to announce portfolio change")
+	}
+}
+
+// Rebuild recomputes the projection from the rewards ledger.
+func (p *PortfolioProjector) Rebuild(ctx context.Context) error {
+	start := time.Now()
+	if err := p.Holdings.Rebuild(ctx); err != nil {
+		return err
+	}
+	logrus.WithField("took", time.Since(start).String()).Info("Rebuilt user holdings projection")
+	return nil
+}
+
+func decodeEvent(msg events.Message, v interface{}) error {
+	env, err := events.Decode(msg)
+	if err != nil {
+		return err
+	}
+	return env.DecodeData(v)
+}
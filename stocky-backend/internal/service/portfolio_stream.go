@@ -0,0 +1,268 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrTooManyStreams is returned when a user already has MaxPerUser streams open.
+var ErrTooManyStreams = errors.New("too many open portfolio streams")
+
+// ErrStreamsClosed is returned for streams opened or ended by Shutdown.
+var ErrStreamsClosed = errors.New("portfolio streams are shutting down")
+
+// PortfolioStreamsOpen is the number of portfolio streams open on this replica.
+var PortfolioStreamsOpen = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "stocky_portfolio_streams_open",
+	Help: "Portfolio streams currently open on this replica.",
+})
+
+// PortfolioSource values a user's portfolio.
+type PortfolioSource interface {
+	GetPortfolio(ctx context.Context, userID string) (model.Portfolio, error)
+}
+
+// PortfolioStreams keeps track of open portfolio streams and wakes the ones
+// affected by a price or holdings change. Changes arrive through OnPrice and
+// OnChange, which main feeds from Redis pub/sub so every replica hears about
+// every change.
+//
+// Each stream has a one-slot wake-up signal, so a burst of changes collapses
+// into a single refresh and a slow client never has more than one snapshot
+// pending. Pushes to one stream are at least MinInterval apart.
+//
+// MaxPerUser is enforced per replica: a user whose streams land on several
+// replicas may hold up to MaxPerUser on each.
+type PortfolioStreams struct {
+	Portfolios  PortfolioSource
+	MinInterval time.Duration
+	Heartbeat   time.Duration
+	MaxPerUser  int
+
+	mu       sync.Mutex
+	byUser   map[string]map[*PortfolioStream]struct{}
+	bySymbol map[string]map[*PortfolioStream]struct{}
+	shutdown bool
+}
+
+// PortfolioStream is one client's subscription to its portfolio.
+type PortfolioStream struct {
+	hub     *PortfolioStreams
+	userID  string
+	symbols []string
+	wake    chan struct{}
+	done    chan struct{} // closed by Shutdown
+	closed  bool
+}
+
+// HeartbeatInterval is how often an idle stream is pinged.
+func (h *PortfolioStreams) HeartbeatInterval() time.Duration {
+	if h.Heartbeat > 0 {
+		return h.Heartbeat
+	}
+	return 15 * time.Second
+}
+
+// Open registers a stream for userID. Close it when the client goes away.
+func (h *PortfolioStreams) Open(userID string) (*PortfolioStream, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.byUser == nil {
+		h.byUser = make(map[string]map[*PortfolioStream]struct{})
+		h.bySymbol = make(map[string]map[*PortfolioStream]struct{})
+	}
+	if h.shutdown {
+		return nil, ErrStreamsClosed
+	}
+	if h.MaxPerUser > 0 && len(h.byUser[userID]) >= h.MaxPerUser {
+		return nil, ErrTooManyStreams
+	}
+	s := &PortfolioStream{hub: h, userID: userID, wake: make(chan struct{}, 1), done: make(chan struct{})}
+	addStream(h.byUser, userID, s)
+	PortfolioStreamsOpen.Inc()
+	return s, nil
+}
+
+// Shutdown ends every open stream and refuses new ones. http.Server.Shutdown
+// neither cancels long-lived requests nor tracks hijacked WebSocket
+// connections, so main registers it with RegisterOnShutdown.
+func (h *PortfolioStreams) Shutdown() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.shutdown {
+		return
+	}
+	h.shutdown = true
+	for _, streams := range h.byUser {
+		for s := range streams {
+			close(s.done)
+		}
+	}
+}
+
+// OnPrice wakes the streams holding q's symbol.
+func (h *PortfolioStreams) OnPrice(q model.Quote) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.bySymbol[q.Symbol] {
+		s.signal()
+	}
+}
+
+// OnChange wakes change.UserID's streams, or with no user, the streams
+// holding change.Symbol.
+func (h *PortfolioStreams) OnChange(change model.PortfolioChange) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	streams := h.byUser[change.UserID]
+	if change.UserID == "" {
+		streams = h.bySymbol[change.Symbol]
+	}
+	for s := range streams {
+		s.signal()
+	}
+}
+
+// Close unregisters the stream. It is safe to call more than once.
+func (s *PortfolioStream) Close() {
+	h := s.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	removeStream(h.byUser, s.userID, s)
+	for _, symbol := range s.symbols {
+		removeStream(h.bySymbol, symbol, s)
+	}
+	PortfolioStreamsOpen.Dec()
+}
+
+// Serve sends the portfolio at once and again whenever the stream is woken,
+// calling ping when it has been idle for HeartbeatInterval. It returns nil
+// when ctx is done, ErrStreamsClosed after Shutdown, and the error if send or
+// ping fails. Failing to value the portfolio is returned for the first
+// snapshot and logged after that.
+func (s *PortfolioStream) Serve(ctx context.Context, send func(model.Portfolio) error, ping func() error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-s.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	err := s.serve(ctx, send, ping)
+	select {
+	case <-s.done:
+		return ErrStreamsClosed
+	default:
+		return err
+	}
+}
+
+func (s *PortfolioStream) serve(ctx context.Context, send func(model.Portfolio) error, ping func() error) error {
+	portfolio, err := s.snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	if err := send(portfolio); err != nil {
+		return err
+	}
+	last := time.Now()
+	heartbeat := time.NewTicker(s.hub.HeartbeatInterval())
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-heartbeat.C:
+			if err := ping(); err != nil {
+				return err
+			}
+		case <-s.wake:
+			if wait := s.hub.MinInterval - time.Since(last); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return nil
+				}
+			}
+			last = time.Now()
+			portfolio, err := s.snapshot(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				logrus.WithError(err).WithField("user_id", s.userID).Warn("Failed to value portfolio for stream")
+				continue
+			}
+			if err := send(portfolio); err != nil {
+				return err
+			}
+			heartbeat.Reset(s.hub.HeartbeatInterval())
+		}
+	}
+}
+
+// snapshot values the portfolio and re-indexes the stream by the symbols it
+// now holds, so price changes for them wake it.
+func (s *PortfolioStream) snapshot(ctx context.Context) (model.Portfolio, error) {
+	portfolio, err := s.hub.Portfolios.GetPortfolio(ctx, s.userID)
+	if err != nil {
+		return model.Portfolio{}, err
+	}
+	symbols := make([]string, len(portfolio.Holdings))
+	for i, holding := range portfolio.Holdings {
+		symbols[i] = holding.Symbol
+	}
+	h := s.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !s.closed {
+		for _, symbol := range s.symbols {
+			removeStream(h.bySymbol, symbol, s)
+		}
+		for _, symbol := range symbols {
+			addStream(h.bySymbol, symbol, s)
+		}
+		s.symbols = symbols
+	}
+	return portfolio, nil
+}
+
+// signal marks the stream for a refresh without blocking; one pending
+// refresh covers any number of changes.
+func (s *PortfolioStream) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func addStream(index map[string]map[*PortfolioStream]struct{}, key string, s *PortfolioStream) {
+	set, ok := index[key]
+	if !ok {
+		set = make(map[*PortfolioStream]struct{})
+		index[key] = set
+	}
+	set[s] = struct{}{}
+}
+
+func removeStream(index map[string]map[*PortfolioStream]struct{}, key string, s *PortfolioStream) {
+	set := index[key]
+	delete(set, s)
+	if len(set) == 0 {
+		delete(index, key)
+	}
+}
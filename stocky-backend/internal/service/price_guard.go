@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+var ErrQuarantineResolved = errors.New("quarantined price already reviewed")
+
+// PricesQuarantinedTotal counts prices held for review, by reason.
+var PricesQuarantinedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "stocky_prices_quarantined_total",
+	Help: "Prices the bad-tick guard held for review instead of applying, by reason",
+}, []string{"reason"})
+
+// PriceBands are the largest moves, in percent of the last good price,
+// that are applied without review. Symbols overrides Default per symbol;
+// a band of zero disables the check.
+type PriceBands struct {
+	Default decimal.Decimal
+	Symbols map[string]decimal.Decimal
+}
+
+// For returns the band for symbol.
+func (b PriceBands) For(symbol string) decimal.Decimal {
+	if band, ok := b.Symbols[symbol]; ok {
+		return band
+	}
+	return b.Default
+}
+
+// ParsePriceBands reads a default band and SYMBOL=percent overrides, e.g.
+// the exchange's 5/10/20% circuit limits.
+func ParsePriceBands(def string, overrides []string) (PriceBands, error) {
+	band, err := parseBand(def)
+	if err != nil {
+		return PriceBands{}, fmt.Errorf("default price band %q: %w", def, err)
+	}
+	bands := PriceBands{Default: band, Symbols: make(map[string]decimal.Decimal, len(overrides))}
+	for _, entry := range overrides {
+		symbol, pct, ok := strings.Cut(entry, "=")
+		if !ok {
+			return PriceBands{}, fmt.Errorf("price band %q: want SYMBOL=percent", entry)
+		}
+		if band, err = parseBand(pct); err != nil {
+			return PriceBands{}, fmt.Errorf("price band %q: %w", entry, err)
+		}
+		bands.Symbols[strings.ToUpper(strings.TrimSpace(symbol))] = band
+	}
+	return bands, nil
+}
+
+func parseBand(pct string) (decimal.Decimal, error) {
+	band, err := decimal.NewFromString(strings.TrimSpace(pct))
+	if err != nil || band.IsNegative() {
+		return decimal.Zero, errors.New("invalid percent")
+	}
+	return band, nil
+}
+
+// PriceGuard screens prices before they are written. A non-positive price,
+// or one that moved more than its band from the last good price, is stored
+// in the quarantine table instead, where an admin can approve or reject it.
+// Each symbol has at most one pending entry, holding its newest bad price.
+type PriceGuard struct {
+	Reference  repo.PriceSource // last good prices, i.e. stock_prices
+	Quarantine repo.PriceQuarantineRepository
+	Bands      PriceBands
+
+	// Approved prices are applied by Quarantine and, when set, mirrored to
+	// Cache and announced through Notifier like any applied tick.
+	Cache    QuoteCache
+	Notifier PriceNotifier
+}
+
+// Check screens a single tick and reports whether it may be applied.
+func (g *PriceGuard) Check(ctx context.Context, q model.Quote, volume int64) (bool, error) {
+	refs, err := g.Reference.GetPrices(ctx, []string{q.Symbol})
+	if err != nil {
+		return false, fmt.Errorf("load last good price: %w", err)
+	}
+	ref, hasRef := refs[q.Symbol]
+	return g.screen(ctx, q, volume, ref, hasRef)
+}
+
+// Screen returns the quotes that may be applied, quarantining the rest.
+func (g *PriceGuard) Screen(ctx context.Context, quotes []model.Quote) ([]model.Quote, error) {
+	symbols := make([]string, len(quotes))
+	for i, q := range quotes {
+		symbols[i] = q.Symbol
+	}
+	refs, err := g.Reference.GetPrices(ctx, symbols)
+	if err != nil {
+		return nil, fmt.Errorf("load last good prices: %w", err)
+	}
+	accepted := make([]model.Quote, 0, len(quotes))
+	for _, q := range quotes {
+		ref, hasRef := refs[q.Symbol]
+		ok, err := g.screen(ctx, q, 0, ref, hasRef)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			accepted = append(accepted, q)
+		}
+	}
+	return accepted, nil
+}
+
+func (g *PriceGuard) screen(ctx context.Context, q model.Quote, volume int64, ref model.Quote, hasRef bool) (bool, error) {
+	qp, ok := g.inspect(q, ref, hasRef)
+	if ok {
+		return true, nil
+	}
+	qp.Volume = volume
+	if _, err := g.Quarantine.QuarantinePrice(ctx, qp); err != nil {
+		return false, fmt.Errorf("quarantine %s: %w", q.Symbol, err)
+	}
+	PricesQuarantinedTotal.WithLabelValues(qp.Reason).Inc()
+	logrus.WithFields(logrus.Fields{
+		"symbol": q.Symbol, "price": q.Price, "previous_price": ref.Price, "reason": qp.Reason, "source": q.Source,
+	}).Warn("Quarantined price")
+	return false, nil
+}
+
+// inspect compares q with the last good price. Without one, and for quotes
+// no newer than it (which the store drops anyway), only the sign is checked.
+func (g *PriceGuard) inspect(q model.Quote, ref model.Quote, hasRef bool) (model.QuarantinedPrice, bool) {
+	band := g.Bands.For(q.Symbol)
+	qp := model.QuarantinedPrice{Symbol: q.Symbol, Price: q.Price, AsOf: q.AsOf, Source: q.Source, BandPct: band}
+	if hasRef {
+		qp.PreviousPrice = &ref.Price
+	}
+	if !q.Price.IsPositive() {
+		qp.Reason = model.QuarantineNonPositive
+		return qp, false
+	}
+	if !hasRef || !ref.Price.IsPositive() || !q.AsOf.After(ref.AsOf) || !band.IsPositive() {
+		return qp, true
+	}
+	change := q.Price.Sub(ref.Price).Div(ref.Price).Mul(decimal.NewFromInt(100)).Round(4)
+	if change.Abs().GreaterThan(band) {
+		qp.Reason = model.QuarantineOutsideBand
+		qp.ChangePct = &change
+		return qp, false
+	}
+	return qp, true
+}
+
+func (g *PriceGuard) List(ctx context.Context, status string, limit int) ([]model.QuarantinedPrice, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	return g.Quarantine.ListQuarantinedPrices(ctx, status, limit)
+}
+
+func (g *PriceGuard) Get(ctx context.Context, id string) (model.QuarantinedPrice, error) {
+	return g.Quarantine.GetQuarantinedPrice(ctx, id)
+}
+
+// Approve applies a quarantined price as if it had passed the guard. It
+// becomes the current price unless a newer one has arrived since, and is
+// the reference for later ticks. The approval and the price write commit
+// together. It reports whether the price was applied.
+func (g *PriceGuard) Approve(ctx context.Context, id, reviewer string) (bool, error) {
+	qp, applied, err := g.Quarantine.ApproveQuarantinedPrice(ctx, id, reviewer)
+	if errors.Is(err, repo.ErrNotFound) {
+		// Either resolved already, missing, or pending with a bad price
+		if _, err := g.pending(ctx, id); err != nil {
+			return false, err
+		}
+		return false, &ValidationError{errors.New("a non-positive price cannot be approved")}
+	}
+	if err != nil {
+		return false, err
+	}
+	if applied {
+		publishQuote(ctx, g.Cache, g.Notifier, model.Quote{Symbol: qp.Symbol, Price: qp.Price, AsOf: qp.AsOf, Source: qp.Source})
+	}
+	return applied, nil
+}
+
+// Reject discards a quarantined price for good.
+func (g *PriceGuard) Reject(ctx context.Context, id, reviewer string) error {
+	if _, err := g.pending(ctx, id); err != nil {
+		return err
+	}
+	return g.resolve(ctx, id, model.QuarantineRejected, reviewer)
+}
+
+func (g *PriceGuard) pending(ctx context.Context, id string) (model.QuarantinedPrice, error) {
+	qp, err := g.Quarantine.GetQuarantinedPrice(ctx, id)
+	if err != nil {
+		return model.QuarantinedPrice{}, err
+	}
+	if qp.Status != model.QuarantinePending {
+		return model.QuarantinedPrice{}, ErrQuarantineResolved
+	}
+	return qp, nil
+}
+
+// resolve maps losing a race with another reviewer to ErrQuarantineResolved.
+func (g *PriceGuard) resolve(ctx context.Context, id, status, reviewer string) error {
+	err := g.Quarantine.ResolveQuarantinedPrice(ctx, id, status, reviewer)
+	if errors.Is(err, repo.ErrNotFound) {
+		return ErrQuarantineResolved
+	}
+	return err
+}
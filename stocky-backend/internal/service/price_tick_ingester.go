@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/events"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+// SourceTick marks prices that arrived on the price-updates topic.
+const SourceTick = "tick"
+
+// PriceTicksTotal counts ingested ticks by outcome: applied, stale
+// (out of order or duplicate), quarantined or invalid.
+var PriceTicksTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "stocky_price_ticks_total",
+	Help: "Price ticks consumed from the price-updates topic, by outcome",
+}, []string{"result"})
+
+// QuoteCache mirrors current prices into a shared cache.
+type QuoteCache interface {
+	SetQuote(ctx context.Context, q model.Quote) error
+}
+
+// PriceNotifier tells other replicas that a symbol's price changed.
+type PriceNotifier interface {
+	NotifyPrice(ctx context.Context, q model.Quote) error
+}
+
+// PriceTickIngester applies exchange ticks: it validates them, drops any
+// older than the stored price, and updates stock_prices, price_history, the
+// Redis price cache and subscribed replicas.
+type PriceTickIngester struct {
+	Store       repo.PriceRepository
+	Cache       QuoteCache
+	Notifier    PriceNotifier
+	Instruments *InstrumentService // optional: drop ticks for unknown symbols
+	Guard       *PriceGuard        // optional: quarantine outliers instead of applying them
+	MaxSkew     time.Duration      // how far in the future a tick may be stamped
+	Now         func() time.Time
+}
+
+// Register subscribes the ingester on registry.
+func (i *PriceTickIngester) Register(registry *events.Registry) {
+	registry.Register(events.TypePriceTick, i.Handle)
+}
+
+// Handle applies one tick. Invalid and out-of-order ticks are dropped rather
+// than retried, since a newer tick supersedes them anyway; only storage
+// failures are returned.
+func (i *PriceTickIngester) Handle(ctx context.Context, msg events.Message) error {
+	var tick model.PriceTick
+	if err := decodeEvent(msg, &tick); err != nil {
+		return i.drop(tick, err)
+	}
+	q, err := i.validate(tick)
+	if err != nil {
+		return i.drop(tick, err)
+	}
+	if i.Instruments != nil {
+		instrument, err := i.Instruments.Resolve(ctx, q.Symbol)
+		switch {
+		case errors.Is(err, ErrUnknownInstrument):
+			return i.drop(tick, err)
+		case err != nil && !errors.Is(err, ErrInactiveInstrument):
+			return err
+		}
+		q.Symbol = instrument.Symbol
+		if instrument.Currency != "" {
+			q.Currency = instrument.Currency
+		}
+	}
+	if i.Guard != nil {
+		ok, err := i.Guard.Check(ctx, q, tick.Volume)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			PriceTicksTotal.WithLabelValues("quarantined").Inc()
+			return nil
+		}
+	}
+	applied, err := i.Store.ApplyTick(ctx, q, tick.Volume)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		PriceTicksTotal.WithLabelValues("stale").Inc()
+		logrus.WithFields(logrus.Fields{"symbol": q.Symbol, "as_of": q.AsOf}).Debug("Dropped out-of-order price tick")
+		return nil
+	}
+	PriceTicksTotal.WithLabelValues("applied").Inc()
+	publishQuote(ctx, i.Cache, i.Notifier, q)
+	return nil
+}
+
+// publishQuote mirrors an applied price to the cache and other replicas.
+// The database is the source of truth, so both are best effort.
+func publishQuote(ctx context.Context, cache QuoteCache, notifier PriceNotifier, q model.Quote) {
+	if cache != nil {
+		if err := cache.SetQuote(ctx, q); err != nil {
+			logrus.WithError(err).WithField("symbol", q.Symbol).Warn("Failed to cache price")
+		}
+	}
+	if notifier != nil {
+		if err := notifier.NotifyPrice(ctx, q); err != nil {
+			logrus.WithError(err).WithField("symbol", q.Symbol).Warn("Failed to announce price")
+		}
+	}
+}
+
+func (i *PriceTickIngester) drop(tick model.PriceTick, err error) error {
+	PriceTicksTotal.WithLabelValues("invalid").Inc()
+	logrus.WithError(err).WithField("symbol", tick.Symbol).Warn("Dropped invalid price tick")
+	return nil
+}
+
+func (i *PriceTickIngester) validate(tick model.PriceTick) (model.Quote, error) {
+	q := model.Quote{Symbol: strings.ToUpper(strings.TrimSpace(tick.Symbol)), Source: SourceTick}
+	if q.Symbol == "" || len(q.Symbol) > 16 {
+		return q, fmt.Errorf("invalid symbol %q", tick.Symbol)
+	}
+	// With a guard, non-positive prices are quarantined rather than dropped
+	price, err := decimal.NewFromString(tick.Price)
+	if err != nil || (i.Guard == nil && !price.IsPositive()) {
+		return q, fmt.Errorf("invalid price %q", tick.Price)
+	}
+	q.Price = price
+	if q.AsOf, err = time.Parse(time.RFC3339Nano, tick.Timestamp); err != nil {
+		return q, fmt.Errorf("invalid timestamp %q", tick.Timestamp)
+	}
+	now := time.Now()
+	if i.Now != nil {
+		now = i.Now()
+	}
+	if q.AsOf.After(now.Add(i.MaxSkew)) {
+		return q, fmt.Errorf("timestamp %s is in the future", tick.Timestamp)
+	}
+	if tick.Volume < 0 {
+		return q, errors.New("negative volume")
+	}
+	return q, nil
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/market"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/sirupsen/logrus"
+)
+
+// LeaderLock elects a single replica to run a singleton job. TryAcquire takes
+// the lock or renews it if already held and reports whether this replica is
+// the leader; it never waits for another holder.
+type LeaderLock interface {
+	TryAcquire(ctx context.Context) (bool, error)
+	Release(ctx context.Context) error
+}
+
+// SymbolSource contributes symbols to the set the price updater refreshes.
+type SymbolSource interface {
+	Symbols(ctx context.Context) ([]string, error)
+}
+
+// SymbolSourceFunc adapts a function to SymbolSource.
+type SymbolSourceFunc func(ctx context.Context) ([]string, error)
+
+func (f SymbolSourceFunc) Symbols(ctx context.Context) ([]string, error) { return f(ctx) }
+
+// PriceUpdater periodically refreshes stock_prices for every symbol any
+// SymbolSource reports. Runs happen every Interval plus up to Jitter, and
+// only on the replica holding Lock. With a Calendar, scheduled runs are
+// limited to market hours plus one run after each close, which also records
+// the day's closing prices. Applied prices are mirrored to Cache and
+// announced through Notifier like applied ticks.
+type PriceUpdater struct {
+	Sources  []SymbolSource
+	Prices   repo.PriceSource
+	Store    repo.PriceRepository
+	Cache    QuoteCache
+	Notifier PriceNotifier
+	Guard    *PriceGuard // optional: quarantine outliers instead of writing them
+	Lock     LeaderLock
+	Holder   string
+	Interval time.Duration
+	Jitter   time.Duration
+	// Heartbeat renews Lock between runs, so a lease can be far shorter
+	// than Interval; 0 renews only when a run starts
+	Heartbeat time.Duration
+	Calendar  *market.Calendar
+	Now       func() time.Time
+
+	mu      sync.Mutex // serializes scheduled and manually triggered runs
+	lastRun time.Time
+}
+
+func (u *PriceUpdater) now() time.Time {
+	if u.Now != nil {
+		return u.Now()
+	}
+	return time.Now()
+}
+
+// Due reports whether a scheduled run should happen at now: always while
+// the market is open, and outside it only until the last close is captured.
+func (u *PriceUpdater) Due(now time.Time) bool {
+	if u.Calendar == nil || u.Calendar.IsOpen(now) {
+		return true
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.lastRun.Before(u.Calendar.LastClose(now))
+}
+
+// Run ticks until ctx is cancelled, releasing leadership on the way out.
+func (u *PriceUpdater) Run(ctx context.Context) error {
+	defer func() {
+		// ctx is already done; give the release its own deadline
+		releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := u.Lock.Release(releaseCtx); err != nil {
+			logrus.WithError(err).Warn("Failed to release price updater lock")
+		}
+	}()
+	if u.Heartbeat > 0 {
+		go u.heartbeat(ctx)
+	}
+	for {
+		if u.Due(u.now()) {
+			if _, err := u.Tick(ctx); err != nil {
+				logrus.WithError(err).Error("Price update failed")
+			}
+		}
+		wait := u.Interval
+		if u.Jitter > 0 {
+			wait += time.Duration(rand.Int63n(int64(u.Jitter)))
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+	}
+}
+
+// heartbeat renews leadership every Heartbeat until ctx is cancelled, also
+// during a run. A replica that is not the leader takes over a lease its
+// holder stopped renewing.
+func (u *PriceUpdater) heartbeat(ctx context.Context) {
+	ticker := time.NewTicker(u.Heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := u.Lock.TryAcquire(ctx); err != nil && ctx.Err() == nil {
+				logrus.WithError(err).Warn("Failed to renew price updater lock")
+			}
+		}
+	}
+}
+
+// Tick runs one update if this replica is the leader and returns the
+// recorded run, or nil when another replica holds the lock.
+func (u *PriceUpdater) Tick(ctx context.Context) (*model.PriceUpdateRun, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	leader, err := u.Lock.TryAcquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("acquire leadership: %w", err)
+	}
+	if !leader {
+		logrus.Debug("Another replica is updating prices")
+		return nil, nil
+	}
+	symbols, err := u.symbols(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("resolve symbols: %w", err)
+	}
+	now := u.now()
+	run := &model.PriceUpdateRun{Holder: u.Holder, SymbolsTotal: len(symbols), StartedAt: now}
+	if run.ID, err = u.Store.StartPriceRun(ctx, u.Holder, len(symbols)); err != nil {
+		return nil, fmt.Errorf("record run: %w", err)
+	}
+	fresh, updated, runErr := u.update(ctx, symbols)
+	if runErr == nil && u.Calendar != nil && !u.Calendar.IsOpen(now) {
+		runErr = u.recordCloses(ctx, now, fresh)
+	}
+	if runErr == nil {
+		u.lastRun = now
+	}
+	run.SymbolsUpdated = updated
+	switch {
+	case runErr != nil:
+		run.Status = model.PriceRunFailed
+		run.Error = runErr.Error()
+	case updated < len(symbols):
+		run.Status = model.PriceRunPartial
+	default:
+		run.Status = model.PriceRunSucceeded
+	}
+	if err := u.Store.FinishPriceRun(ctx, run.ID, run.Status, updated, runErr); err != nil {
+		return run, fmt.Errorf("record run: %w", err)
+	}
+	logrus.WithFields(logrus.Fields{"run_id": run.ID, "status": run.Status, "symbols": len(symbols), "updated": updated}).Info("Price update finished")
+	return run, runErr
+}
+
+func (u *PriceUpdater) update(ctx context.Context, symbols []string) ([]model.Quote, int, error) {
+	if len(symbols) == 0 {
+		return nil, 0, nil
+	}
+	quotes, err := u.Prices.GetPrices(ctx, symbols)
+	if err != nil {
+		return nil, 0, err
+	}
+	// Fallback quotes are our own stock_prices rows; writing them back would
+	// only make old prices look fresh
+	fresh := make([]model.Quote, 0, len(quotes))
+	for _, symbol := range symbols {
+		if q, ok := quotes[symbol]; ok && !q.Degraded {
+			q.Symbol = symbol
+			fresh = append(fresh, q)
+		}
+	}
+	if u.Guard != nil {
+		if fresh, err = u.Guard.Screen(ctx, fresh); err != nil {
+			return nil, 0, err
+		}
+	}
+	applied, err := u.Store.UpsertPrices(ctx, fresh)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, q := range applied {
+		publishQuote(ctx, u.Cache, u.Notifier, q)
+	}
+	return fresh, len(applied), nil
+}
+
+// recordCloses saves quotes taken after the last session as that session's
+// closing prices. Quotes older than the session's open belong to an earlier
+// day and are skipped.
+func (u *PriceUpdater) recordCloses(ctx context.Context, now time.Time, quotes []model.Quote) error {
+	tradeDate := u.Calendar.PreviousCloseDate(now)
+	if tradeDate.IsZero() {
+		return nil
+	}
+	opened := tradeDate.Add(u.Calendar.Hours.Open)
+	closes := make([]model.Quote, 0, len(quotes))
+	for _, q := range quotes {
+		if !q.AsOf.Before(opened) {
+			closes = append(closes, q)
+		}
+	}
+	if err := u.Store.RecordCloses(ctx, tradeDate, closes); err != nil {
+		return fmt.Errorf("record closes: %w", err)
+	}
+	return nil
+}
+
+// symbols merges every source into one sorted, de-duplicated list.
+func (u *PriceUpdater) symbols(ctx context.Context) ([]string, error) {
+	seen := make(map[string]bool)
+	for _, src := range u.Sources {
+		symbols, err := src.Symbols(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range symbols {
+			seen[s] = true
+		}
+	}
+	out := make([]string, 0, len(seen))
+	for s := range seen {
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// Runs lists the most recent recorded runs, newest first.
+func (u *PriceUpdater) Runs(ctx context.Context, limit int) ([]model.PriceUpdateRun, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	return u.Store.ListPriceRuns(ctx, limit)
+}
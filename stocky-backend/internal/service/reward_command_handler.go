@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/events"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/sirupsen/logrus"
+)
+
+// PartnerLimiter caps how fast each partner's commands are processed.
+// Reserve admits a command and returns zero, or returns how long the partner
+// must wait without admitting it.
+type PartnerLimiter interface {
+	Reserve(ctx context.Context, partnerID string) (time.Duration, error)
+}
+
+// RewardCommandHandler turns reward-commands messages into rewards through
+// the same RewardService path as POST /reward, so dedup by unique hash and
+// idempotency key applies unchanged, and publishes an accepted or rejected
+// result for every command. Idempotency keys are stored prefixed with the
+// partner id, so partners cannot collide on them.
+type RewardCommandHandler struct {
+	Rewards *RewardService
+	Events  events.EventPublisher
+	Encoder *events.Encoder
+	Limiter PartnerLimiter
+}
+
+// Handle is an events.Handler. Transient failures are returned so the
+// consumer's retry path kicks in; anything the partner must fix, including a
+// command that cannot be decoded, is rejected. A partner over its rate is
+// deferred rather than waited for, so its backlog does not hold up commands
+// sharing the consumer lane.
+func (h *RewardCommandHandler) Handle(ctx context.Context, msg events.Message) error {
+	env, err := events.Decode(msg)
+	if err != nil {
+		return h.reject(ctx, model.RewardCommandResult{UserID: msg.Key}, "malformed command: "+err.Error())
+	}
+	var cmd model.RewardCommand
+	if err := env.DecodeData(&cmd); err != nil {
+		return h.reject(ctx, model.RewardCommandResult{CommandID: env.ID, UserID: msg.Key}, "malformed command: "+err.Error())
+	}
+	if cmd.CommandID == "" {
+		cmd.CommandID = env.ID
+	}
+	// Without an explicit key the command id makes redelivery idempotent.
+	if cmd.IdempotencyKey == "" {
+		cmd.IdempotencyKey = cmd.CommandID
+	}
+	result := model.RewardCommandResult{
+		CommandID:      cmd.CommandID,
+		PartnerID:      cmd.PartnerID,
+		UserID:         cmd.UserID,
+		IdempotencyKey: cmd.IdempotencyKey,
+	}
+	if cmd.PartnerID == "" || cmd.UserID == "" {
+		return h.reject(ctx, result, "missing partner_id or user_id")
+	}
+	if h.Limiter != nil {
+		wait, err := h.Limiter.Reserve(ctx, cmd.PartnerID)
+		if err != nil {
+			// An unavailable limiter must not stop ingestion
+			logrus.WithError(err).WithField("partner_id", cmd.PartnerID).Warn("Partner rate limiter unavailable, admitting command")
+		} else if wait > 0 {
+			return &events.DeferredError{Until: time.Now().Add(wait), Reason: "partner over its command rate"}
+		}
+	}
+
+	// Keys are chosen by each partner, so they are only unique per partner
+	key := cmd.PartnerID + ":" + cmd.IdempotencyKey
+	// A replay of a command we already applied is accepted again, just like
+	// the Idempotency middleware answers a repeated POST /reward, unless the
+	// key was used for some other user's or stock's reward.
+	existing, err := h.Rewards.Repo.GetRewardByIdempotencyKey(ctx, key)
+	switch {
+	case err == nil:
+		if !h.sameReward(ctx, existing, cmd) {
+			return h.reject(ctx, result, "idempotency key already used for a different reward")
+		}
+		result.RewardID = existing.ID
+		result.Status = model.CommandAccepted
+		return h.publish(ctx, result)
+	case !errors.Is(err, repo.ErrNotFound):
+		return err
+	}
+
+	req := model.CreateRewardRequest{
+		StockSymbol: cmd.StockSymbol,
+		Shares:      cmd.Shares,
+		RewardedAt:  cmd.RewardedAt,
+	}
+	created := h.Rewards.CreateReward(ctx, cmd.UserID, req, key)
+	var invalid *ValidationError
+	switch {
+	case created.Conflict:
+		result.RewardID = created.RewardID
+		return h.reject(ctx, result, "duplicate reward")
+	case errors.As(created.Err, &invalid):
+		return h.reject(ctx, result, invalid.Error())
+	case created.Err != nil:
+		return created.Err
+	}
+	result.RewardID = created.RewardID
+	result.Status = model.CommandAccepted
+	return h.publish(ctx, result)
+}
+
+// sameReward reports whether a replayed command asks for the reward it
+// already created, comparing symbols as the reward service resolves them.
+func (h *RewardCommandHandler) sameReward(ctx context.Context, existing model.Reward, cmd model.RewardCommand) bool {
+	if existing.UserID != cmd.UserID {
+		return false
+	}
+	symbol := cmd.StockSymbol
+	if h.Rewards.Instruments != nil {
+		if instrument, err := h.Rewards.Instruments.Resolve(ctx, symbol); err == nil {
+			symbol = instrument.Symbol
+		}
+	}
+	return strings.EqualFold(existing.StockSymbol, symbol)
+}
+
+func (h *RewardCommandHandler) reject(ctx context.Context, result model.RewardCommandResult, reason string) error {
+	result.Status = model.CommandRejected
+	result.Reason = reason
+	logrus.WithFields(logrus.Fields{"command_id": result.CommandID, "partner_id": result.PartnerID, "reason": reason}).Info("Reward command rejected")
+	return h.publish(ctx, result)
+}
+
+func (h *RewardCommandHandler) publish(ctx context.Context, result model.RewardCommandResult) error {
+	msg, err := h.Encoder.RewardCommandResultMessage(result)
+	if err != nil {
+		return err
+	}
+	return h.Events.Publish(ctx, msg)
+}
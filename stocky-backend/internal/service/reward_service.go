@@ -23,8 +23,20 @@ var validate = validator.New()
 
 type RewardService struct {
 	Repo repo.RewardRepository
+	// Instruments, when set, rejects unknown or inactive symbols and
+	// normalizes the symbol (or ISIN) to its listed form
+	Instruments *InstrumentService
+	// Lots, when set, lists the tax lots behind each holding
+	Lots repo.TaxLotRepository
 }
 
+// ValidationError marks a reward request that can never succeed as sent, as
+// opposed to a transient failure that is worth retrying.
+type ValidationError struct{ Err error }
+
+func (e *ValidationError) Error() string { return e.Err.Error() }
+func (e *ValidationError) Unwrap() error { return e.Err }
+
 type CreateRewardResult struct {
 	RewardID string
 	Conflict bool
@@ -33,15 +45,25 @@ type CreateRewardResult struct {
 
 func (s *RewardService) CreateReward(ctx context.Context, userID string, req model.CreateRewardRequest, idempotencyKey string) CreateRewardResult {
 	if err := validate.Struct(req); err != nil {
-		return CreateRewardResult{"", false, err}
+		return CreateRewardResult{"", false, &ValidationError{err}}
+	}
+	if s.Instruments != nil {
+		instrument, err := s.Instruments.Resolve(ctx, req.StockSymbol)
+		if errors.Is(err, ErrUnknownInstrument) || errors.Is(err, ErrInactiveInstrument) {
+			return CreateRewardResult{"", false, &ValidationError{err}}
+		}
+		if err != nil {
+			return CreateRewardResult{"", false, err}
+		}
+		req.StockSymbol = instrument.Symbol
 	}
 	shares, err := decimal.NewFromString(req.Shares)
 	if err != nil {
-		return CreateRewardResult{"", false, errors.New("invalid shares format")}
+		return CreateRewardResult{"", false, &ValidationError{errors.New("invalid shares format")}}
 	}
 	rewardedAt, err := time.Parse(time.RFC3339, req.RewardedAt)
 	if err != nil {
-		return CreateRewardResult{"", false, errors.New("invalid rewarded_at format")}
+		return CreateRewardResult{"", false, &ValidationError{errors.New("invalid rewarded_at format")}}
 	}
 	// Compute unique hash
 	uniqueStr := userID + req.StockSymbol + req.Shares + req.RewardedAt
@@ -86,3 +108,23 @@ func (s *RewardService) GetStats(ctx context.Context, userID string) (model.Stat
 func (s *RewardService) GetPortfolio(ctx context.Context, userID string) (model.Portfolio, error) {
 	return s.Repo.GetPortfolio(ctx, userID)
 }
+
+// ListLots returns the user's tax lots, open and consumed, oldest first per
+// symbol. Without a lot store there are none.
+func (s *RewardService) ListLots(ctx context.Context, userID string) ([]model.TaxLot, error) {
+	if s.Lots == nil {
+		return []model.TaxLot{}, nil
+	}
+	lots, err := s.Lots.ListLots(ctx, userID)
+	if lots == nil && err == nil {
+		lots = []model.TaxLot{}
+	}
+	return lots, err
+}
+
+func (s *RewardService) ReverseReward(ctx context.Context, rewardID string, req model.ReverseRewardRequest) (model.Reward, error) {
+	if err := validate.Struct(req); err != nil {
+		return model.Reward{}, &ValidationError{err}
+	}
+	return s.Repo.ReverseReward(ctx, rewardID, req.Reason)
+}
@@ -1,3 +1,10 @@
 #!/bin/sh
 set -e
-migrate -path migrations -database "$DATABASE_URL" up
+# Migrations are embedded in the binary; any arguments are passed to the
+# migrate subcommand (default: up), e.g. `./scripts/run_migrations.sh status`.
+[ $# -eq 0 ] && set -- up
+if [ -x ./stocky-backend ]; then
+	./stocky-backend migrate "$@"
+else
+	go run ./cmd/api migrate "$@"
+fi
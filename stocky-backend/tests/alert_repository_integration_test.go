@@ -0,0 +1,137 @@
+//go:build integration
+
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertRepository_FiresOncePerCooldownAndCrossing(t *testing.T) {
+	alerts := &repo.AlertRepositoryImpl{DB: newTestDB(t)}
+	ctx := context.Background()
+	rule, err := alerts.CreateAlertRule(ctx, model.AlertRule{
+		UserID: "u1", Kind: model.AlertPortfolioAbove, Threshold: decimal.NewFromInt(10000), CooldownSeconds: 3600, Active: true,
+	})
+	require.NoError(t, err)
+	assert.True(t, rule.Armed)
+	at := time.Date(2025, 1, 6, 5, 0, 0, 0, time.UTC)
+	fire := func(firedAt time.Time, disarm bool) bool {
+		t.Helper()
+		_, fired, err := alerts.FireAlert(ctx, model.AlertEvent{
+			RuleID: rule.ID, UserID: "u1", Kind: rule.Kind, Value: decimal.NewFromInt(10500), Threshold: rule.Threshold, Message: "above", FiredAt: firedAt,
+		}, disarm)
+		require.NoError(t, err)
+		return fired
+	}
+
+	assert.True(t, fire(at, true))
+	assert.False(t, fire(at.Add(2*time.Hour), true), "disarmed until re-armed")
+	require.NoError(t, alerts.RearmAlertRule(ctx, rule.ID))
+	assert.False(t, fire(at.Add(30*time.Minute), true), "cooling down")
+	assert.True(t, fire(at.Add(time.Hour), false), "the cooldown is inclusive")
+
+	got, err := alerts.GetAlertRule(ctx, "u1", rule.ID)
+	require.NoError(t, err)
+	assert.True(t, got.Armed)
+	assert.True(t, at.Add(time.Hour).Equal(*got.LastFiredAt))
+
+	// Inactive rules never fire; updating one re-arms it
+	got.Active = false
+	got, err = alerts.UpdateAlertRule(ctx, got)
+	require.NoError(t, err)
+	assert.False(t, fire(at.Add(3*time.Hour), false))
+	events, err := alerts.ListAlertEvents(ctx, "u1", 10)
+	require.NoError(t, err)
+	assert.Len(t, events, 2)
+}
+
+func TestAlertRepository_DedupKeyAndDelivery(t *testing.T) {
+	alerts := &repo.AlertRepositoryImpl{DB: newTestDB(t)}
+	ctx := context.Background()
+	rule, err := alerts.CreateAlertRule(ctx, model.AlertRule{
+		UserID: "u1", Kind: model.AlertDailyMove, Symbol: "TCS", Threshold: decimal.NewFromInt(5), Active: true,
+	})
+	require.NoError(t, err)
+	at := time.Date(2025, 1, 6, 5, 0, 0, 0, time.UTC)
+	fire := func(firedAt time.Time, key string) (model.AlertEvent, bool) {
+		t.Helper()
+		event, fired, err := alerts.FireAlert(ctx, model.AlertEvent{
+			RuleID: rule.ID, UserID: "u1", Kind: rule.Kind, Symbol: "TCS", Value: decimal.NewFromInt(-6), Threshold: rule.Threshold,
+			Message: "TCS is -6.00% today", DedupKey: key, FiredAt: firedAt,
+		}, false)
+		require.NoError(t, err)
+		return event, fired
+	}
+
+	first, fired := fire(at, "TCS:2025-01-06")
+	require.True(t, fired)
+	_, fired = fire(at.Add(time.Minute), "TCS:2025-01-06")
+	assert.False(t, fired, "same day")
+	got, err := alerts.GetAlertRule(ctx, "u1", rule.ID)
+	require.NoError(t, err)
+	assert.True(t, at.Equal(*got.LastFiredAt), "a deduplicated firing leaves the rule alone")
+	second, fired := fire(at.Add(24*time.Hour), "TCS:2025-01-07")
+	require.True(t, fired)
+
+	require.NoError(t, alerts.MarkAlertDelivered(ctx, first.ID, ""))
+	require.NoError(t, alerts.MarkAlertDelivered(ctx, second.ID, "smtp down"))
+	events, err := alerts.ListAlertEvents(ctx, "u1", 10)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	// Newest first
+	assert.Equal(t, second.ID, events[0].ID)
+	assert.Nil(t, events[0].DeliveredAt)
+	assert.Equal(t, "smtp down", events[0].DeliveryError)
+	assert.NotNil(t, events[1].DeliveredAt)
+	assert.Empty(t, events[1].DeliveryError)
+	assert.Equal(t, "TCS", events[1].Symbol)
+	assert.Equal(t, rule.ID, events[1].RuleID)
+}
+
+func TestAlertRepository_ListsRulesAChangeAffects(t *testing.T) {
+	db := newTestDB(t)
+	alerts := &repo.AlertRepositoryImpl{DB: db}
+	ctx := context.Background()
+	create := func(userID, kind, symbol string, active bool) string {
+		rule, err := alerts.CreateAlertRule(ctx, model.AlertRule{UserID: userID, Kind: kind, Symbol: symbol, Threshold: decimal.NewFromInt(5), Active: active})
+		require.NoError(t, err)
+		return rule.ID
+	}
+	onTCS := create("u1", model.AlertDailyMove, "TCS", true)
+	holderAny := create("u2", model.AlertDailyMove, "", true)
+	holderTotal := create("u2", model.AlertPortfolioBelow, "", true)
+	create("u3", model.AlertDailyMove, "", true) // holds nothing
+	create("u1", model.AlertDailyMove, "TCS", false)
+	otherUser := create("u4", model.AlertPortfolioAbove, "", true)
+	_, err := db.ExecContext(ctx, `INSERT INTO user_holdings (user_id, stock_symbol, shares) VALUES ('u2', 'TCS', 2), ('u3', 'TCS', 0)`)
+	require.NoError(t, err)
+
+	ids := func(symbols, users []string) []string {
+		rules, err := alerts.ListActiveAlertRules(ctx, symbols, users)
+		require.NoError(t, err)
+		var out []string
+		for _, r := range rules {
+			out = append(out, r.ID)
+		}
+		return out
+	}
+	assert.ElementsMatch(t, []string{onTCS, holderAny, holderTotal}, ids([]string{"TCS"}, nil))
+	assert.ElementsMatch(t, []string{otherUser}, ids(nil, []string{"u4"}))
+	assert.Empty(t, ids(nil, nil))
+
+	n, err := alerts.CountAlertRules(ctx, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	_, err = alerts.GetAlertRule(ctx, "u2", onTCS)
+	assert.ErrorIs(t, err, repo.ErrNotFound, "rules are scoped to their owner")
+	assert.ErrorIs(t, alerts.DeleteAlertRule(ctx, "u2", onTCS), repo.ErrNotFound)
+	assert.NoError(t, alerts.DeleteAlertRule(ctx, "u1", onTCS))
+}
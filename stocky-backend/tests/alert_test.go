@@ -0,0 +1,367 @@
+package tests
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/mhatrejeets/stocky-ms/internal/api"
+	"github.com/mhatrejeets/stocky-ms/internal/infra"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/mhatrejeets/stocky-ms/internal/service"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockAlerts struct {
+	mock.Mock
+}
+
+var _ repo.AlertRepository = (*MockAlerts)(nil)
+
+func (m *MockAlerts) CreateAlertRule(ctx context.Context, rule model.AlertRule) (model.AlertRule, error) {
+	args := m.Called(ctx, rule)
+	return args.Get(0).(model.AlertRule), args.Error(1)
+}
+
+func (m *MockAlerts) ListAlertRules(ctx context.Context, userID string) ([]model.AlertRule, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]model.AlertRule), args.Error(1)
+}
+
+func (m *MockAlerts) CountAlertRules(ctx context.Context, userID string) (int, error) {
+	args := m.Called(ctx, userID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockAlerts) GetAlertRule(ctx context.Context, userID, id string) (model.AlertRule, error) {
+	args := m.Called(ctx, userID, id)
+	return args.Get(0).(model.AlertRule), args.Error(1)
+}
+
+func (m *MockAlerts) UpdateAlertRule(ctx context.Context, rule model.AlertRule) (model.AlertRule, error) {
+	args := m.Called(ctx, rule)
+	return args.Get(0).(model.AlertRule), args.Error(1)
+}
+
+func (m *MockAlerts) DeleteAlertRule(ctx context.Context, userID, id string) error {
+	return m.Called(ctx, userID, id).Error(0)
+}
+
+func (m *MockAlerts) ListActiveAlertRules(ctx context.Context, symbols, users []string) ([]model.AlertRule, error) {
+	args := m.Called(ctx, symbols, users)
+	return args.Get(0).([]model.AlertRule), args.Error(1)
+}
+
+func (m *MockAlerts) FireAlert(ctx context.Context, event model.AlertEvent, disarm bool) (model.AlertEvent, bool, error) {
+	args := m.Called(ctx, event, disarm)
+	return args.Get(0).(model.AlertEvent), args.Bool(1), args.Error(2)
+}
+
+func (m *MockAlerts) RearmAlertRule(ctx context.Context, id string) error {
+	return m.Called(ctx, id).Error(0)
+}
+
+func (m *MockAlerts) MarkAlertDelivered(ctx context.Context, id string, deliveryErr string) error {
+	return m.Called(ctx, id, deliveryErr).Error(0)
+}
+
+func (m *MockAlerts) ListAlertEvents(ctx context.Context, userID string, limit int) ([]model.AlertEvent, error) {
+	args := m.Called(ctx, userID, limit)
+	return args.Get(0).([]model.AlertEvent), args.Error(1)
+}
+
+// fired returns the events passed to FireAlert, in order.
+func (m *MockAlerts) fired() []model.AlertEvent {
+	var events []model.AlertEvent
+	for _, call := range m.Calls {
+		if call.Method == "FireAlert" {
+			events = append(events, call.Arguments.Get(1).(model.AlertEvent))
+		}
+	}
+	return events
+}
+
+// settablePortfolios serves a fixed portfolio per user.
+type settablePortfolios struct {
+	portfolios map[string]model.Portfolio
+}
+
+func (p *settablePortfolios) GetPortfolio(ctx context.Context, userID string) (model.Portfolio, error) {
+	return p.portfolios[userID], nil
+}
+
+type staticCloses map[string]decimal.Decimal
+
+func (c staticCloses) PreviousCloses(ctx context.Context, symbols []string, date string) (map[string]decimal.Decimal, error) {
+	return c, nil
+}
+
+// recordingAlerts collects delivered alerts, failing with err when set.
+type recordingAlerts struct {
+	alerts []model.AlertEvent
+	err    error
+}
+
+func (r *recordingAlerts) NotifyAlert(ctx context.Context, alert model.AlertEvent) error {
+	r.alerts = append(r.alerts, alert)
+	return r.err
+}
+
+func valued(total int64, symbols ...string) model.Portfolio {
+	p := model.Portfolio{PortfolioTotalINR: decimal.NewFromInt(total)}
+	for _, s := range symbols {
+		p.Holdings = append(p.Holdings, model.Holding{Symbol: s})
+	}
+	return p
+}
+
+func newAlertEvaluator(alerts *MockAlerts, portfolios *settablePortfolios, closes staticCloses) (*service.AlertEvaluator, *recordingAlerts, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2025, 1, 6, 5, 0, 0, 0, time.UTC)}
+	notifier := &recordingAlerts{}
+	return &service.AlertEvaluator{Rules: alerts, Portfolios: portfolios, Closes: closes, Notifier: notifier, Now: clock.Now}, notifier, clock
+}
+
+func threshold(v int64) *decimal.Decimal {
+	d := decimal.NewFromInt(v)
+	return &d
+}
+
+func TestAlertService_ValidatesRules(t *testing.T) {
+	alerts := new(MockAlerts)
+	svc := &service.AlertService{Repo: alerts, MaxRules: 2, DefaultCooldown: 3600}
+	ctx := context.Background()
+	tcs := "tcs"
+	var invalid *service.ValidationError
+	alerts.On("CountAlertRules", ctx, "u1").Return(0, nil).Times(5)
+
+	_, err := svc.Create(ctx, "u1", model.AlertRuleRequest{Kind: "price_spike", Threshold: threshold(5)})
+	assert.ErrorAs(t, err, &invalid)
+	_, err = svc.Create(ctx, "u1", model.AlertRuleRequest{Kind: model.AlertPortfolioAbove, Threshold: threshold(0)})
+	assert.ErrorAs(t, err, &invalid)
+	_, err = svc.Create(ctx, "u1", model.AlertRuleRequest{Kind: model.AlertPortfolioAbove, Symbol: &tcs, Threshold: threshold(100)})
+	assert.ErrorAs(t, err, &invalid)
+	_, err = svc.Create(ctx, "u1", model.AlertRuleRequest{Kind: model.AlertDailyMove})
+	assert.ErrorAs(t, err, &invalid)
+
+	want := model.AlertRule{UserID: "u1", Kind: model.AlertDailyMove, Symbol: "TCS", Threshold: decimal.NewFromInt(5), CooldownSeconds: 3600, Active: true}
+	created := want
+	created.ID = uuid.NewString()
+	alerts.On("CreateAlertRule", ctx, want).Return(created, nil).Once()
+	rule, err := svc.Create(ctx, "u1", model.AlertRuleRequest{Kind: model.AlertDailyMove, Symbol: &tcs, Threshold: threshold(5)})
+	require.NoError(t, err)
+	assert.Equal(t, created, rule)
+
+	alerts.On("CountAlertRules", ctx, "u1").Return(2, nil).Once()
+	_, err = svc.Create(ctx, "u1", model.AlertRuleRequest{Kind: model.AlertPortfolioAbove, Threshold: threshold(100)})
+	assert.ErrorAs(t, err, &invalid, "rule limit")
+
+	// Unset fields are kept
+	inactive := false
+	alerts.On("GetAlertRule", ctx, "u1", rule.ID).Return(rule, nil).Once()
+	deactivated := rule
+	deactivated.Active = false
+	alerts.On("UpdateAlertRule", ctx, deactivated).Return(deactivated, nil).Once()
+	updated, err := svc.Update(ctx, "u1", rule.ID, model.AlertRuleRequest{Active: &inactive})
+	require.NoError(t, err)
+	assert.False(t, updated.Active)
+
+	alerts.On("GetAlertRule", ctx, "u2", rule.ID).Return(model.AlertRule{}, repo.ErrNotFound).Once()
+	_, err = svc.Get(ctx, "u2", rule.ID)
+	assert.ErrorIs(t, err, repo.ErrNotFound)
+	// Malformed ids never reach Postgres
+	_, err = svc.Get(ctx, "u1", "not-a-uuid")
+	assert.ErrorIs(t, err, repo.ErrNotFound)
+	assert.ErrorIs(t, svc.Delete(ctx, "u1", "not-a-uuid"), repo.ErrNotFound)
+	alerts.On("DeleteAlertRule", ctx, "u1", rule.ID).Return(nil).Once()
+	assert.NoError(t, svc.Delete(ctx, "u1", rule.ID))
+	alerts.AssertExpectations(t)
+}
+
+func TestAlertEvaluator_PortfolioRuleFiresOnCrossingAndRearms(t *testing.T) {
+	alerts := new(MockAlerts)
+	rule := model.AlertRule{ID: "r1", UserID: "u1", Kind: model.AlertPortfolioAbove, Threshold: decimal.NewFromInt(10000), Active: true, Armed: true}
+	portfolios := &settablePortfolios{portfolios: map[string]model.Portfolio{}}
+	evaluator, notifier, clock := newAlertEvaluator(alerts, portfolios, nil)
+	ctx := context.Background()
+	evaluate := func(total int64, armed bool) {
+		t.Helper()
+		rule.Armed = armed
+		portfolios.portfolios["u1"] = valued(total, "TCS")
+		clock.Advance(time.Minute)
+		alerts.On("ListActiveAlertRules", ctx, []string{"TCS"}, []string{}).Return([]model.AlertRule{rule}, nil).Once()
+		evaluator.OnPrice(model.Quote{Symbol: "TCS", AsOf: clock.Now()})
+		require.NoError(t, evaluator.Evaluate(ctx))
+	}
+
+	evaluate(9000, true) // below and armed: nothing to do
+	alerts.On("FireAlert", ctx, mock.Anything, true).Return(model.AlertEvent{ID: "a1", UserID: "u1"}, true, nil).Once()
+	alerts.On("MarkAlertDelivered", ctx, "a1", "").Return(nil).Once()
+	evaluate(10500, true)
+	require.Len(t, alerts.fired(), 1)
+	event := alerts.fired()[0]
+	assert.Equal(t, model.AlertEvent{
+		RuleID: "r1", UserID: "u1", Kind: model.AlertPortfolioAbove, Value: decimal.NewFromInt(10500), Threshold: rule.Threshold,
+		Message: "Your portfolio is worth 10500.00 INR, above your alert at 10000 INR", FiredAt: clock.Now(),
+	}, event)
+	assert.Len(t, notifier.alerts, 1)
+
+	evaluate(11000, false) // still above but disarmed: no repeat
+	alerts.On("RearmAlertRule", ctx, "r1").Return(nil).Once()
+	evaluate(9500, false) // back below: re-arms
+
+	// Another replica, or the cooldown, won the firing: nothing is delivered
+	alerts.On("FireAlert", ctx, mock.Anything, true).Return(model.AlertEvent{}, false, nil).Once()
+	evaluate(10200, true)
+	assert.Len(t, notifier.alerts, 1)
+
+	// Degraded values are skipped so a missing price cannot look like a crash
+	portfolios.portfolios["u1"] = model.Portfolio{PortfolioTotalINR: decimal.NewFromInt(20000), Degraded: true}
+	alerts.On("ListActiveAlertRules", ctx, []string{}, []string{"u1"}).Return([]model.AlertRule{rule}, nil).Once()
+	evaluator.OnChange(model.PortfolioChange{UserID: "u1"})
+	require.NoError(t, evaluator.Evaluate(ctx))
+	assert.Len(t, alerts.fired(), 2)
+	alerts.AssertExpectations(t)
+}
+
+func TestAlertEvaluator_RequeuesWhenRulesCannotLoad(t *testing.T) {
+	alerts := new(MockAlerts)
+	evaluator, _, _ := newAlertEvaluator(alerts, &settablePortfolios{}, nil)
+	ctx := context.Background()
+	alerts.On("ListActiveAlertRules", ctx, []string{"TCS"}, []string{}).Return([]model.AlertRule(nil), errors.New("db down")).Once()
+	alerts.On("ListActiveAlertRules", ctx, []string{"TCS"}, []string{}).Return([]model.AlertRule(nil), nil).Once()
+
+	evaluator.OnPrice(model.Quote{Symbol: "TCS"})
+	assert.Error(t, evaluator.Evaluate(ctx))
+	assert.NoError(t, evaluator.Evaluate(ctx))
+	assert.NoError(t, evaluator.Evaluate(ctx), "nothing left queued")
+	alerts.AssertExpectations(t)
+}
+
+func TestAlertEvaluator_DailyMoveFiresPerSymbolAndDay(t *testing.T) {
+	alerts := new(MockAlerts)
+	rules := []model.AlertRule{
+		{ID: "r1", UserID: "u1", Kind: model.AlertDailyMove, Symbol: "TCS", Threshold: decimal.NewFromInt(5), Active: true, Armed: true},
+		{ID: "r2", UserID: "u2", Kind: model.AlertDailyMove, Threshold: decimal.NewFromInt(3), Active: true, Armed: true},
+	}
+	alerts.On("ListActiveAlertRules", mock.Anything, mock.Anything, mock.Anything).Return(rules, nil)
+	alerts.On("FireAlert", mock.Anything, mock.Anything, false).Return(model.AlertEvent{ID: "a"}, true, nil)
+	alerts.On("MarkAlertDelivered", mock.Anything, "a", "").Return(nil)
+	portfolios := &settablePortfolios{portfolios: map[string]model.Portfolio{"u2": valued(0, "INFY")}}
+	closes := staticCloses{"TCS": decimal.NewFromInt(100), "INFY": decimal.NewFromInt(200)}
+	evaluator, _, clock := newAlertEvaluator(alerts, portfolios, closes)
+	price := func(symbol string, p int64) {
+		clock.Advance(time.Minute)
+		evaluator.OnPrice(model.Quote{Symbol: symbol, Price: decimal.NewFromInt(p), AsOf: clock.Now()})
+		require.NoError(t, evaluator.Evaluate(context.Background()))
+	}
+
+	price("TCS", 104) // +4%: below 5%
+	assert.Empty(t, alerts.fired())
+	price("TCS", 94) // -6%
+	require.Len(t, alerts.fired(), 1)
+	tcs := alerts.fired()[0]
+	assert.Equal(t, "r1", tcs.RuleID)
+	assert.Equal(t, "TCS", tcs.Symbol)
+	assert.True(t, decimal.NewFromInt(-6).Equal(tcs.Value))
+	assert.Equal(t, "TCS:2025-01-06", tcs.DedupKey, "FireAlert drops repeats the same day")
+	assert.Contains(t, tcs.Message, "-6.00%")
+
+	price("INFY", 207) // +3.5%: only u2's any-holding rule watches INFY
+	require.Len(t, alerts.fired(), 2)
+	infy := alerts.fired()[1]
+	assert.Equal(t, "u2", infy.UserID)
+	assert.Equal(t, "INFY:2025-01-06", infy.DedupKey)
+
+	clock.Advance(24 * time.Hour)
+	price("TCS", 90)
+	require.Len(t, alerts.fired(), 3)
+	assert.Equal(t, "TCS:2025-01-07", alerts.fired()[2].DedupKey)
+}
+
+func TestAlertEvaluator_RecordsFailedDelivery(t *testing.T) {
+	alerts := new(MockAlerts)
+	rule := model.AlertRule{ID: "r1", UserID: "u1", Kind: model.AlertPortfolioAbove, Threshold: decimal.NewFromInt(1), Active: true, Armed: true}
+	alerts.On("ListActiveAlertRules", mock.Anything, mock.Anything, mock.Anything).Return([]model.AlertRule{rule}, nil)
+	alerts.On("FireAlert", mock.Anything, mock.Anything, true).Return(model.AlertEvent{ID: "a1"}, true, nil)
+	alerts.On("MarkAlertDelivered", mock.Anything, "a1", "smtp down").Return(nil).Once()
+	portfolios := &settablePortfolios{portfolios: map[string]model.Portfolio{"u1": valued(10, "TCS")}}
+	evaluator, notifier, _ := newAlertEvaluator(alerts, portfolios, nil)
+	notifier.err = errors.New("smtp down")
+
+	evaluator.OnPrice(model.Quote{Symbol: "TCS"})
+	require.NoError(t, evaluator.Evaluate(context.Background()))
+	alerts.AssertExpectations(t)
+}
+
+func TestWebhookAlertNotifier_SignsBody(t *testing.T) {
+	var body []byte
+	var signature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/hook" {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get("X-Stocky-Signature")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+	notifier := &infra.WebhookAlertNotifier{URL: srv.URL + "/hook", Secret: "s3cret", Client: srv.Client()}
+
+	require.NoError(t, notifier.NotifyAlert(context.Background(), model.AlertEvent{ID: "a1", UserID: "u1", Message: "hi"}))
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), signature)
+	assert.Contains(t, string(body), `"message":"hi"`)
+
+	notifier.URL = srv.URL + "/missing"
+	assert.Error(t, notifier.NotifyAlert(context.Background(), model.AlertEvent{ID: "a2"}))
+}
+
+func TestAlertHandler_ScopesRulesToCaller(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	rg := r.Group("/api/v1", func(c *gin.Context) {
+		c.Set("user_id", c.GetHeader("X-Test-User"))
+		c.Next()
+	})
+	alerts := new(MockAlerts)
+	(&api.AlertHandler{Service: &service.AlertService{Repo: alerts}}).RegisterRoutes(rg)
+	do := func(method, path, user, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Test-User", user)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	rule := model.AlertRule{ID: uuid.NewString(), UserID: "u1", Kind: model.AlertPortfolioAbove, Threshold: decimal.NewFromInt(50000), Active: true, Armed: true}
+	alerts.On("CreateAlertRule", mock.Anything, mock.MatchedBy(func(r model.AlertRule) bool { return r.UserID == "u1" })).Return(rule, nil).Once()
+	alerts.On("ListAlertRules", mock.Anything, "u1").Return([]model.AlertRule{rule}, nil).Once()
+	alerts.On("GetAlertRule", mock.Anything, "u1", mock.Anything).Return(model.AlertRule{}, repo.ErrNotFound).Once()
+	alerts.On("ListAlertEvents", mock.Anything, "u1", 50).Return([]model.AlertEvent{}, nil).Once()
+
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/api/v1/alerts/u1/rules", "u2", "").Code)
+	assert.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/v1/alerts/u1/rules", "u1", `{"kind":"portfolio_above","threshold":"50000"}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, do(http.MethodPost, "/api/v1/alerts/u1/rules", "u1", `{"kind":"portfolio_above","threshold":"-1"}`).Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/v1/alerts/u1/rules/"+uuid.NewString(), "u1", "").Code)
+	list := do(http.MethodGet, "/api/v1/alerts/u1/rules", "u1", "")
+	assert.Equal(t, http.StatusOK, list.Code)
+	assert.Contains(t, list.Body.String(), `"threshold":"50000"`)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/v1/alerts/u1/events", "u1", "").Code)
+	alerts.AssertExpectations(t)
+}
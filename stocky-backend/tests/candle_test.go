@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/market"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/mhatrejeets/stocky-ms/internal/service"
+	"github.com/stretchr/testify/assert"
+)
+
+// candleQueries records the queries CandleService passes on.
+type candleQueries struct {
+	got []service.CandleQuery
+}
+
+func (c *candleQueries) ListCandles(ctx context.Context, symbol, interval string, from, to time.Time, limit int) ([]model.Candle, error) {
+	c.got = append(c.got, service.CandleQuery{Symbol: symbol, Interval: interval, From: from, To: to, Limit: limit})
+	return nil, nil
+}
+
+func TestCandleStart_AlignsToExchangeClock(t *testing.T) {
+	loc := market.NSE().Location
+	at := ist(t, "2025-09-25 10:47").Add(31 * time.Second).UTC()
+	for interval, want := range map[string]time.Time{
+		model.CandleMinute: ist(t, "2025-09-25 10:47"),
+		model.CandleHour:   ist(t, "2025-09-25 10:00"),
+		model.CandleDay:    ist(t, "2025-09-25 00:00"),
+	} {
+		got, err := repo.CandleStart(interval, at, loc)
+		assert.NoError(t, err)
+		assert.True(t, want.Equal(got), "%s: got %s", interval, got)
+	}
+	// An IST hour starts on the half hour in UTC
+	hour, _ := repo.CandleStart(model.CandleHour, at, loc)
+	assert.Equal(t, 30, hour.UTC().Minute())
+
+	_, err := repo.CandleStart("5m", at, loc)
+	assert.Error(t, err)
+}
+
+func TestCandleService_ValidatesQuery(t *testing.T) {
+	queries := &candleQueries{}
+	svc := &service.CandleService{Repo: queries}
+	ctx := context.Background()
+	var invalid *service.ValidationError
+
+	_, err := svc.Candles(ctx, service.CandleQuery{Symbol: " tcs ", Limit: 5000})
+	assert.NoError(t, err)
+	assert.Equal(t, service.CandleQuery{Symbol: "TCS", Interval: model.CandleDay, Limit: 100}, queries.got[0])
+
+	_, err = svc.Candles(ctx, service.CandleQuery{Symbol: "TCS", Interval: "5m"})
+	assert.True(t, errors.As(err, &invalid))
+
+	now := time.Now()
+	_, err = svc.Candles(ctx, service.CandleQuery{Symbol: "TCS", Interval: model.CandleMinute, From: now, To: now.Add(-time.Hour)})
+	assert.True(t, errors.As(err, &invalid))
+	assert.Len(t, queries.got, 1)
+}
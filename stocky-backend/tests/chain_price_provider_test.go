@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/infra"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubPrices serves fixed quotes, or err when set, and counts calls.
+type stubPrices struct {
+	quotes map[string]decimal.Decimal
+	err    error
+	calls  int
+}
+
+func (s *stubPrices) GetPrice(ctx context.Context, symbol string) (decimal.Decimal, time.Time, error) {
+	return decimal.Zero, time.Time{}, errors.New("not used")
+}
+
+func (s *stubPrices) GetPrices(ctx context.Context, symbols []string) (map[string]model.Quote, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	out := make(map[string]model.Quote)
+	for _, symbol := range symbols {
+		if p, ok := s.quotes[symbol]; ok {
+			out[symbol] = model.Quote{Symbol: symbol, Price: p, AsOf: time.Now()}
+		}
+	}
+	return out, nil
+}
+
+func TestChainPriceProvider_FallsThroughSources(t *testing.T) {
+	primary := &stubPrices{quotes: map[string]decimal.Decimal{"TCS": decimal.NewFromInt(10)}}
+	secondary := &stubPrices{quotes: map[string]decimal.Decimal{"INFY": decimal.NewFromInt(20)}}
+	lastKnown := &stubPrices{quotes: map[string]decimal.Decimal{"WIPRO": decimal.NewFromInt(30), "TCS": decimal.NewFromInt(1)}}
+	chain := &infra.ChainPriceProvider{
+		Sources: []infra.NamedPriceSource{
+			{Name: "http", Provider: primary},
+			{Name: "mock", Provider: secondary},
+		},
+		Fallback: lastKnown,
+	}
+
+	quotes, err := chain.GetPrices(context.Background(), []string{"TCS", "INFY", "WIPRO", "NOPE"})
+	assert.NoError(t, err)
+	assert.Equal(t, "http", quotes["TCS"].Source)
+	assert.True(t, decimal.NewFromInt(10).Equal(quotes["TCS"].Price))
+	assert.Equal(t, "mock", quotes["INFY"].Source)
+	assert.False(t, quotes["INFY"].Degraded)
+	assert.Equal(t, infra.SourceLastKnownGood, quotes["WIPRO"].Source)
+	assert.True(t, quotes["WIPRO"].Degraded)
+	assert.NotContains(t, quotes, "NOPE")
+}
+
+func TestChainPriceProvider_BreakerSkipsFailingSource(t *testing.T) {
+	now := time.Now()
+	breaker := infra.NewCircuitBreaker(2, time.Minute)
+	breaker.Now = func() time.Time { return now }
+	failing := &stubPrices{err: errors.New("connection refused")}
+	lastKnown := &stubPrices{quotes: map[string]decimal.Decimal{"TCS": decimal.NewFromInt(5)}}
+	chain := &infra.ChainPriceProvider{
+		Sources:  []infra.NamedPriceSource{{Name: "http", Provider: failing, Breaker: breaker}},
+		Fallback: lastKnown,
+	}
+
+	for i := 0; i < 3; i++ {
+		quotes, err := chain.GetPrices(context.Background(), []string{"TCS"})
+		assert.NoError(t, err)
+		assert.True(t, quotes["TCS"].Degraded)
+	}
+	assert.Equal(t, 2, failing.calls)
+	assert.Equal(t, infra.BreakerOpen, breaker.State())
+
+	// After the cooldown one trial call goes through and closes the breaker
+	now = now.Add(time.Minute)
+	failing.err = nil
+	failing.quotes = map[string]decimal.Decimal{"TCS": decimal.NewFromInt(6)}
+	quotes, err := chain.GetPrices(context.Background(), []string{"TCS"})
+	assert.NoError(t, err)
+	assert.Equal(t, "http", quotes["TCS"].Source)
+	assert.Equal(t, infra.BreakerClosed, breaker.State())
+}
+
+func TestChainPriceProvider_AllSourcesDown(t *testing.T) {
+	chain := &infra.ChainPriceProvider{
+		Sources:  []infra.NamedPriceSource{{Name: "http", Provider: &stubPrices{err: errors.New("down")}}},
+		Fallback: &stubPrices{err: errors.New("db down")},
+	}
+	_, err := chain.GetPrices(context.Background(), []string{"TCS"})
+	assert.Error(t, err)
+
+	_, _, err = chain.GetPrice(context.Background(), "TCS")
+	assert.Error(t, err)
+}
+
+func TestCircuitBreaker_HalfOpenFailureReopens(t *testing.T) {
+	now := time.Now()
+	b := infra.NewCircuitBreaker(1, time.Second)
+	b.Now = func() time.Time { return now }
+	assert.True(t, b.Allow())
+	b.Failure()
+	assert.False(t, b.Allow())
+
+	now = now.Add(time.Second)
+	assert.True(t, b.Allow())
+	assert.False(t, b.Allow(), "only one trial call while half-open")
+	b.Failure()
+	assert.Equal(t, infra.BreakerOpen, b.State())
+}
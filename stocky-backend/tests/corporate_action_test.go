@@ -0,0 +1,43 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mhatrejeets/stocky-ms/internal/events"
+	"github.com/mhatrejeets/stocky-ms/internal/infra"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCorporateActionService_RejectsNonPositiveRatio(t *testing.T) {
+	bus := infra.NewMemoryBus()
+	actions := &service.CorporateActionService{Events: bus, Encoder: testEncoder(t)}
+	ctx := context.Background()
+
+	for _, ratio := range []string{"0", "-2", "0.000"} {
+		_, err := actions.Announce(ctx, model.CorporateActionRequest{Symbol: "TCS", ActionType: "split", Ratio: ratio, EffectiveAt: "2025-10-01T03:45:00Z"})
+		var invalid *service.ValidationError
+		assert.ErrorAs(t, err, &invalid, ratio)
+	}
+	assert.Empty(t, bus.Messages(events.TopicCorporateActions))
+
+	announced, err := actions.Announce(ctx, model.CorporateActionRequest{Symbol: "tcs", ActionType: "consolidation", Ratio: "0.50", EffectiveAt: "2025-10-01T09:15:00+05:30"})
+	require.NoError(t, err)
+	assert.Equal(t, "0.5", announced.Ratio)
+	assert.Equal(t, "TCS", announced.Symbol)
+	assert.Equal(t, "2025-10-01T03:45:00Z", announced.EffectiveAt)
+}
+
+func TestPortfolioProjector_RejectsNonPositiveRatio(t *testing.T) {
+	holdings := new(MockHoldings)
+	projector := &service.PortfolioProjector{Holdings: holdings}
+	msg, err := testEncoder(t).CorporateActionMessage(model.CorporateActionEvent{ActionID: "a1", Symbol: "TCS", ActionType: "split", Ratio: "0", EffectiveAt: "2025-10-01T03:45:00Z"})
+	require.NoError(t, err)
+
+	assert.Error(t, projector.OnCorporateAction(context.Background(), msg))
+	holdings.AssertNotCalled(t, "ApplyCorporateAction", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/mhatrejeets/stocky-ms/internal/api"
+	"github.com/mhatrejeets/stocky-ms/internal/events"
+	"github.com/mhatrejeets/stocky-ms/internal/infra"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/mhatrejeets/stocky-ms/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockDeadLetters struct {
+	mock.Mock
+}
+
+var _ repo.DeadLetterRepository = (*MockDeadLetters)(nil)
+
+func (m *MockDeadLetters) RecordDeadLetter(ctx context.Context, msg events.Message) error {
+	return m.Called(ctx, msg).Error(0)
+}
+
+func (m *MockDeadLetters) ListDeadLetters(ctx context.Context, status string, limit int) ([]model.DeadLetter, error) {
+	args := m.Called(ctx, status, limit)
+	letters, _ := args.Get(0).([]model.DeadLetter)
+	return letters, args.Error(1)
+}
+
+func (m *MockDeadLetters) GetDeadLetter(ctx context.Context, id string) (model.DeadLetter, error) {
+	args := m.Called(ctx, id)
+	dl, _ := args.Get(0).(model.DeadLetter)
+	return dl, args.Error(1)
+}
+
+func (m *MockDeadLetters) UpdateDeadLetterStatus(ctx context.Context, id, status string) error {
+	return m.Called(ctx, id, status).Error(0)
+}
+
+func (m *MockDeadLetters) ResolveDeadLetter(ctx context.Context, id, status string) (model.DeadLetter, error) {
+	args := m.Called(ctx, id, status)
+	dl, _ := args.Get(0).(model.DeadLetter)
+	return dl, args.Error(1)
+}
+
+func TestDeadLetterService_ReplayClaimsOnceAndReopensOnFailure(t *testing.T) {
+	ctx := context.Background()
+	letters := new(MockDeadLetters)
+	publisher := new(MockPublisher)
+	svc := &service.DeadLetterService{Repo: letters, Events: publisher, Tiers: events.DefaultRetryTiers}
+	dl := model.DeadLetter{
+		ID: uuid.NewString(), OriginalTopic: events.TopicRewardEvents, Group: "notify", EventType: events.TypeRewardCreated,
+		Key: "u1", Headers: map[string]string{"correlation_id": "c1", events.HeaderAttempt: "3"}, Status: model.DeadLetterReplayed,
+	}
+
+	letters.On("ResolveDeadLetter", ctx, dl.ID, model.DeadLetterReplayed).Return(dl, nil).Once()
+	publisher.On("Publish", ctx, mock.MatchedBy(func(msg events.Message) bool {
+		return msg.Topic == "reward-events.notify.retry.1m" && events.Attempt(msg) == 0 && msg.Headers["correlation_id"] == "c1"
+	})).Return(nil).Once()
+	require.NoError(t, svc.Replay(ctx, dl.ID))
+
+	// Already replayed by someone else
+	letters.On("ResolveDeadLetter", ctx, dl.ID, model.DeadLetterReplayed).Return(nil, repo.ErrNotFound).Once()
+	letters.On("GetDeadLetter", ctx, dl.ID).Return(dl, nil).Once()
+	assert.ErrorIs(t, svc.Replay(ctx, dl.ID), service.ErrDeadLetterResolved)
+
+	// A failed publish leaves it pending to be replayed again
+	letters.On("ResolveDeadLetter", ctx, dl.ID, model.DeadLetterReplayed).Return(dl, nil).Once()
+	publisher.On("Publish", ctx, mock.Anything).Return(errors.New("broker down")).Once()
+	letters.On("UpdateDeadLetterStatus", ctx, dl.ID, model.DeadLetterPending).Return(nil).Once()
+	assert.Error(t, svc.Replay(ctx, dl.ID))
+
+	letters.AssertExpectations(t)
+	publisher.AssertExpectations(t)
+}
+
+func TestDeadLetterHandler_MalformedIDIsNotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	letters := new(MockDeadLetters)
+	(&api.DeadLetterHandler{Service: &service.DeadLetterService{Repo: letters}}).RegisterRoutes(r.Group("/admin"))
+	missing := uuid.NewString()
+	letters.On("ResolveDeadLetter", mock.Anything, missing, model.DeadLetterDiscarded).Return(nil, repo.ErrNotFound).Once()
+	letters.On("GetDeadLetter", mock.Anything, missing).Return(nil, repo.ErrNotFound).Once()
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/admin/dlq/not-a-uuid", nil),
+		httptest.NewRequest(http.MethodPost, "/admin/dlq/not-a-uuid/replay", nil),
+		httptest.NewRequest(http.MethodDelete, "/admin/dlq/not-a-uuid", nil),
+		httptest.NewRequest(http.MethodDelete, "/admin/dlq/"+missing, nil),
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code, req.Method+" "+req.URL.Path)
+	}
+	letters.AssertExpectations(t)
+}
+
+func TestRetryRouter_UnrecordedDeadLetterIsNotCommitted(t *testing.T) {
+	bus := infra.NewMemoryBus()
+	letters := new(MockDeadLetters)
+	router := &events.RetryRouter{Publisher: bus, Group: "notify", Sink: letters}
+	letters.On("RecordDeadLetter", mock.Anything, mock.Anything).Return(errors.New("db down")).Once()
+
+	assert.Error(t, router.Route(context.Background(), rewardCreatedMessage(t), errors.New("handler failed")))
+	letters.AssertExpectations(t)
+}
@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/mhatrejeets/stocky-ms/internal/events"
+	"github.com/mhatrejeets/stocky-ms/internal/infra"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func rewardCreatedMessage(t *testing.T) events.Message {
+	msg, err := testEncoder(t).RewardCreatedMessage(model.RewardCreatedEvent{
+		RewardID:    "reward-1",
+		UserID:      "user-1",
+		StockSymbol: "RELIANCE",
+		Shares:      "1.5",
+	})
+	assert.NoError(t, err)
+	return msg
+}
+
+func TestMemoryBus_PublishAndSubscribe(t *testing.T) {
+	bus := infra.NewMemoryBus()
+	sub := bus.Subscribe(events.TopicRewardEvents, 1)
+
+	msg := rewardCreatedMessage(t)
+	assert.NoError(t, bus.Publish(context.Background(), msg))
+
+	got := <-sub
+	assert.Equal(t, events.TypeRewardCreated, got.Type)
+	assert.Len(t, bus.Messages(events.TopicRewardEvents), 1)
+	assert.NoError(t, bus.Close())
+}
+
+func TestMemoryBus_CloseDuringPublish(t *testing.T) {
+	bus := infra.NewMemoryBus()
+	sub := bus.Subscribe(events.TopicRewardEvents, 64)
+	go func() {
+		for range sub {
+		}
+	}()
+	msg := rewardCreatedMessage(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if err := bus.Publish(context.Background(), msg); err != nil {
+					assert.ErrorIs(t, err, infra.ErrBusClosed)
+					return
+				}
+			}
+		}()
+	}
+	assert.NoError(t, bus.Close())
+	wg.Wait()
+
+	assert.ErrorIs(t, bus.Publish(context.Background(), msg), infra.ErrBusClosed)
+	_, open := <-bus.Subscribe(events.TopicRewardEvents, 1)
+	assert.False(t, open)
+	assert.NoError(t, bus.Close())
+}
+
+func TestFilePublisher_WritesJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	p, err := infra.NewFilePublisher(path)
+	assert.NoError(t, err)
+	assert.NoError(t, p.Publish(context.Background(), rewardCreatedMessage(t)))
+	assert.NoError(t, p.Publish(context.Background(), rewardCreatedMessage(t)))
+	assert.NoError(t, p.Close())
+
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+	var lines int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var msg events.Message
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &msg))
+		assert.Equal(t, events.TopicRewardEvents, msg.Topic)
+		lines++
+	}
+	assert.Equal(t, 2, lines)
+}
+
+func TestNewEventPublisher_ConfiguredBackendMustOpen(t *testing.T) {
+	publisher, err := infra.NewEventPublisher(nil)
+	assert.NoError(t, err)
+	assert.IsType(t, &infra.NoopPublisher{}, publisher)
+
+	// Brokers nobody listens on
+	_, err = infra.NewEventPublisher([]string{"127.0.0.1:1"})
+	assert.Error(t, err)
+
+	t.Setenv("EVENT_PUBLISHER", "file")
+	t.Setenv("EVENT_FILE_PATH", filepath.Join(t.TempDir(), "missing", "events.jsonl"))
+	_, err = infra.NewEventPublisher(nil)
+	assert.Error(t, err)
+
+	t.Setenv("EVENT_PUBLISHER", "carrier-pigeon")
+	_, err = infra.NewEventPublisher(nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,27 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mhatrejeets/stocky-ms/internal/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_DispatchByType(t *testing.T) {
+	registry := events.NewRegistry()
+	var got []string
+	registry.Register("a", func(ctx context.Context, msg events.Message) error {
+		got = append(got, "a:"+msg.Key)
+		return nil
+	})
+	registry.Register("b", func(ctx context.Context, msg events.Message) error {
+		return errors.New("boom")
+	})
+
+	assert.NoError(t, registry.Dispatch(context.Background(), events.Message{Type: "a", Key: "user-1"}))
+	assert.EqualError(t, registry.Dispatch(context.Background(), events.Message{Type: "b"}), "boom")
+	assert.NoError(t, registry.Dispatch(context.Background(), events.Message{Type: "unknown"}))
+	assert.Equal(t, []string{"a:user-1"}, got)
+}
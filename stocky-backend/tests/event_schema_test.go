@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/mhatrejeets/stocky-ms/internal/events"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var rewardSchemaV1 = events.Schema{
+	Type:    "com.example.reward",
+	Version: 1,
+	Fields: []events.SchemaField{
+		{Name: "reward_id", Type: events.FieldString, Required: true},
+		{Name: "note", Type: events.FieldString},
+	},
+}
+
+// testEncoder envelopes events against the embedded schemas.
+func testEncoder(t *testing.T) *events.Encoder {
+	t.Helper()
+	enc, err := events.NewDefaultEncoder()
+	require.NoError(t, err)
+	return enc
+}
+
+func TestSchemaRegistry_Compatibility(t *testing.T) {
+	registry := events.NewSchemaRegistry()
+	assert.NoError(t, registry.Register(rewardSchemaV1))
+
+	requiredAdded := events.Schema{Type: rewardSchemaV1.Type, Version: 2, Fields: append(rewardSchemaV1.Fields, events.SchemaField{Name: "amount", Type: events.FieldNumber, Required: true})}
+	assert.Error(t, registry.Register(requiredAdded))
+
+	typeChanged := events.Schema{Type: rewardSchemaV1.Type, Version: 2, Fields: []events.SchemaField{{Name: "reward_id", Type: events.FieldNumber, Required: true}}}
+	assert.Error(t, registry.Register(typeChanged))
+
+	optionalAdded := events.Schema{Type: rewardSchemaV1.Type, Version: 2, Fields: append(rewardSchemaV1.Fields, events.SchemaField{Name: "amount", Type: events.FieldNumber})}
+	assert.NoError(t, registry.Register(optionalAdded))
+	latest, ok := registry.Latest(rewardSchemaV1.Type)
+	assert.True(t, ok)
+	assert.Equal(t, 2, latest.Version)
+}
+
+func TestSchema_Validate(t *testing.T) {
+	assert.NoError(t, rewardSchemaV1.Validate([]byte(`{"reward_id":"r1"}`)))
+	assert.Error(t, rewardSchemaV1.Validate([]byte(`{"note":"x"}`)))
+	assert.Error(t, rewardSchemaV1.Validate([]byte(`{"reward_id":1}`)))
+	assert.Error(t, rewardSchemaV1.Validate([]byte(`{"reward_id":"r1","extra":true}`)))
+}
+
+func TestEncoder_RoundTripJSONAndProtobuf(t *testing.T) {
+	event := model.RewardCreatedEvent{
+		RewardID:    "reward-1",
+		UserID:      "user-1",
+		StockSymbol: "TCS",
+		Shares:      "2",
+		RewardedAt:  "2025-09-25T11:30:00Z",
+	}
+	for _, encoding := range []string{events.EncodingJSON, events.EncodingProtobuf} {
+		enc := &events.Encoder{Source: "/test", Encoding: encoding, Schemas: testEncoder(t).Schemas}
+		msg, err := enc.Encode(events.TopicRewardEvents, event.UserID, events.TypeRewardCreated, 1, event.RewardID, event)
+		assert.NoError(t, err)
+		assert.Equal(t, "user-1", msg.Key)
+
+		env, err := events.Decode(msg)
+		assert.NoError(t, err)
+		assert.Equal(t, events.SpecVersion, env.SpecVersion)
+		assert.Equal(t, events.TypeRewardCreated, env.Type)
+		assert.NotEmpty(t, env.ID)
+		assert.Equal(t, "urn:stocky:schema:com.stocky.reward.created:v1", env.DataSchema)
+
+		var decoded model.RewardCreatedEvent
+		assert.NoError(t, env.DecodeData(&decoded))
+		assert.Equal(t, event, decoded)
+	}
+}
+
+func TestEncoder_NilFailsInsteadOfPanicking(t *testing.T) {
+	var enc *events.Encoder
+	_, err := enc.RewardCreatedMessage(model.RewardCreatedEvent{RewardID: "r1"})
+	assert.ErrorIs(t, err, events.ErrNoEncoder)
+}
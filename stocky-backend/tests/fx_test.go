@@ -0,0 +1,217 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/infra"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/mhatrejeets/stocky-ms/internal/service"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockFX struct {
+	mock.Mock
+}
+
+var _ repo.FXRepository = (*MockFX)(nil)
+
+func (m *MockFX) GetRates(ctx context.Context, currencies []string) (map[string]model.FXRate, error) {
+	args := m.Called(ctx, currencies)
+	return args.Get(0).(map[string]model.FXRate), args.Error(1)
+}
+
+func (m *MockFX) RecordFXRates(ctx context.Context, rates []model.FXRate) (int, error) {
+	args := m.Called(ctx, rates)
+	return args.Int(0), args.Error(1)
+}
+
+// fxRates serves rates as the latest for their currencies.
+func fxRates(rates ...model.FXRate) *MockFX {
+	latest := make(map[string]model.FXRate, len(rates))
+	for _, rate := range rates {
+		latest[rate.Currency] = rate
+	}
+	fx := new(MockFX)
+	fx.On("GetRates", mock.Anything, mock.Anything).Return(latest, nil)
+	return fx
+}
+
+// staticHoldings serves one user's holdings.
+type staticHoldings map[string]decimal.Decimal
+
+func (h staticHoldings) ApplyRewardCreated(ctx context.Context, reward model.Reward) (bool, error) {
+	return false, nil
+}
+
+func (h staticHoldings) ApplyRewardReversed(ctx context.Context, rewardID string) (bool, error) {
+	return false, nil
+}
+
+func (h staticHoldings) ApplyCorporateAction(ctx context.Context, eventKey string, action model.CorporateAction) (bool, error) {
+	return false, nil
+}
+
+func (h staticHoldings) ListHoldings(ctx context.Context, userID string) (map[string]decimal.Decimal, error) {
+	return h, nil
+}
+
+func (h staticHoldings) Rebuild(ctx context.Context) error { return nil }
+
+func TestParseFXRates(t *testing.T) {
+	rates, err := infra.ParseFXRates("usd=83.25, EUR=90.1")
+	require.NoError(t, err)
+	assert.Equal(t, "83.25", rates["USD"].String())
+	assert.Equal(t, "90.1", rates["EUR"].String())
+
+	for _, spec := range []string{"USD", "USD=0", "USD=abc"} {
+		_, err := infra.ParseFXRates(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestHTTPFXProvider_ValidatesRates(t *testing.T) {
+	asOf := time.Now().UTC().Add(-time.Minute).Format(time.RFC3339)
+	future := time.Now().UTC().Add(time.Hour).Format(time.RFC3339)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "USD,EUR,GBP", r.URL.Query().Get("currencies"))
+		fmt.Fprintf(w, `{"rates":[
+			{"currency":"usd","rate":"83.2150","as_of":%q},
+			{"currency":"EUR","rate":"0","as_of":%q},
+			{"currency":"GBP","rate":"105","as_of":%q},
+			{"currency":"JPY","rate":"0.56","as_of":%q}]}`, asOf, asOf, future, asOf)
+	}))
+	defer srv.Close()
+
+	p := &infra.HTTPFXProvider{BaseURL: srv.URL, Client: &http.Client{Timeout: time.Second}}
+	rates, err := p.GetRates(context.Background(), []string{"USD", "EUR", "GBP"})
+	require.NoError(t, err)
+	assert.Len(t, rates, 1)
+	assert.Equal(t, "83.215", rates["USD"].Rate.String())
+	assert.Equal(t, infra.SourceFXHTTP, rates["USD"].Source)
+}
+
+func TestFXUpdater_RecordsProviderRates(t *testing.T) {
+	store := new(MockFX)
+	at := time.Date(2025, 9, 25, 6, 0, 0, 0, time.UTC)
+	store.On("RecordFXRates", mock.Anything, []model.FXRate{{Currency: "USD", Rate: decimal.RequireFromString("83.2"), AsOf: at, Source: infra.SourceFXStatic}}).Return(1, nil)
+	updater := &service.FXUpdater{
+		Provider:   &infra.StaticFXProvider{Rates: map[string]decimal.Decimal{"USD": decimal.RequireFromString("83.2")}, Now: func() time.Time { return at }},
+		Store:      store,
+		Currencies: []string{"USD", "EUR"},
+	}
+	n, err := updater.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	store.AssertExpectations(t)
+}
+
+type MockListings struct {
+	mock.Mock
+}
+
+var _ repo.ListingSource = (*MockListings)(nil)
+
+func (m *MockListings) SymbolListings(ctx context.Context, symbols []string) (map[string]repo.Listing, error) {
+	args := m.Called(ctx, symbols)
+	listings, _ := args.Get(0).(map[string]repo.Listing)
+	return listings, args.Error(1)
+}
+
+// newFXRewardRepo values TCS in INR and AAPL and VOO in USD. Only VOO's
+// quote says so; AAPL's currency comes from the instrument master, as for
+// providers that report none.
+func newFXRewardRepo(fx repo.FXSource) *repo.RewardRepositoryImpl {
+	asOf := time.Now().Add(-time.Minute)
+	listings := new(MockListings)
+	listings.On("SymbolListings", mock.Anything, []string{"AAPL", "TCS", "VOO"}).Return(map[string]repo.Listing{
+		"AAPL": {Exchange: model.ExchangeNASDAQ, Currency: model.CurrencyUSD},
+	}, nil)
+	return &repo.RewardRepositoryImpl{
+		Holdings: staticHoldings{"TCS": decimal.NewFromInt(2), "AAPL": decimal.RequireFromString("0.5"), "VOO": decimal.NewFromInt(1)},
+		Prices: quoteSourceFunc(func(symbols []string) (map[string]model.Quote, error) {
+			return map[string]model.Quote{
+				"TCS":  {Symbol: "TCS", Price: decimal.NewFromInt(4000), AsOf: asOf},
+				"AAPL": {Symbol: "AAPL", Price: decimal.NewFromInt(200), AsOf: asOf},
+				"VOO":  {Symbol: "VOO", Price: decimal.NewFromInt(500), Currency: model.CurrencyUSD, AsOf: asOf},
+			}, nil
+		}),
+		FX:       fx,
+		Listings: listings,
+	}
+}
+
+func TestRewardRepository_ConvertsForeignHoldingsToINR(t *testing.T) {
+	fxAt := time.Now().Add(-time.Hour)
+	r := newFXRewardRepo(fxRates(model.FXRate{Currency: model.CurrencyUSD, Rate: decimal.RequireFromString("83.5"), AsOf: fxAt}))
+	ctx := context.Background()
+
+	portfolio, err := r.GetPortfolio(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, portfolio.Holdings, 3)
+	aapl := portfolio.Holdings[0]
+	assert.Equal(t, "AAPL", aapl.Symbol)
+	assert.Equal(t, model.CurrencyUSD, aapl.Currency)
+	assert.Equal(t, "100", aapl.TotalValue.String())
+	assert.Equal(t, "83.5", aapl.FXRate.String())
+	assert.Equal(t, "8350", aapl.TotalValueINR.String())
+	assert.True(t, fxAt.Equal(*aapl.FXAsOf))
+	tcs := portfolio.Holdings[1]
+	assert.Equal(t, model.CurrencyINR, tcs.Currency)
+	assert.Equal(t, "1", tcs.FXRate.String())
+	assert.Nil(t, tcs.FXAsOf)
+	assert.Equal(t, "8000", tcs.TotalValueINR.String())
+	// 8000 + (100 + 500) * 83.5
+	assert.Equal(t, "58100", portfolio.PortfolioTotalINR.String())
+	assert.Equal(t, "600", portfolio.TotalByCurrency[model.CurrencyUSD].String())
+	assert.Equal(t, "83.5", portfolio.FXRates[model.CurrencyUSD].String())
+	assert.False(t, portfolio.Degraded)
+
+	stats, err := r.GetStats(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, "58100", stats.PortfolioValueINR.String())
+	assert.Equal(t, "8000", stats.ValueByCurrency[model.CurrencyINR].String())
+	assert.Equal(t, "600", stats.ValueByCurrency[model.CurrencyUSD].String())
+	assert.False(t, stats.Degraded)
+}
+
+func TestRewardRepository_MissingFXRateDegrades(t *testing.T) {
+	fx := new(MockFX)
+	fx.On("GetRates", mock.Anything, mock.Anything).Return(map[string]model.FXRate(nil), errors.New("fx down"))
+	r := newFXRewardRepo(fx)
+	ctx := context.Background()
+
+	portfolio, err := r.GetPortfolio(ctx, "user-1")
+	require.NoError(t, err)
+	assert.True(t, portfolio.Degraded)
+	assert.True(t, portfolio.Holdings[0].FXUnavailable)
+	assert.True(t, portfolio.Holdings[0].TotalValueINR.IsZero())
+	assert.Equal(t, "100", portfolio.Holdings[0].TotalValue.String())
+	assert.Equal(t, "8000", portfolio.PortfolioTotalINR.String())
+
+	stats, err := r.GetStats(ctx, "user-1")
+	require.NoError(t, err)
+	assert.True(t, stats.Degraded)
+	assert.Equal(t, "8000", stats.PortfolioValueINR.String())
+	assert.Empty(t, stats.FXRates)
+}
+
+func TestRewardRepository_UnlabelledQuoteWithoutListingSourceIsINR(t *testing.T) {
+	r := newFXRewardRepo(fxRates(model.FXRate{Currency: model.CurrencyUSD, Rate: decimal.RequireFromString("83.5"), AsOf: time.Now()}))
+	r.Listings = nil
+
+	stats, err := r.GetStats(context.Background(), "user-1")
+	require.NoError(t, err)
+	// AAPL's 100 now counts as INR: 8000 + 100 + 500 * 83.5
+	assert.Equal(t, "8100", stats.ValueByCurrency[model.CurrencyINR].String())
+	assert.Equal(t, "49850", stats.PortfolioValueINR.String())
+}
@@ -0,0 +1,43 @@
+//go:build integration
+
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/health"
+	"github.com/mhatrejeets/stocky-ms/internal/market"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStalePricesChecker_RunRefreshesGaugeWithoutProbes(t *testing.T) {
+	db := newTestDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	now := ist(t, "2025-01-15 10:50")
+	_, err := db.ExecContext(ctx, `INSERT INTO user_holdings (user_id, stock_symbol, shares) VALUES ('u1', 'TCS', 1), ('u1', 'INFY', 2)`)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `INSERT INTO stock_prices (symbol, price, updated_at) VALUES ('TCS', 4000, $1)`, now.Add(-time.Minute).UTC())
+	require.NoError(t, err)
+	health.StalePriceRatio.Set(0)
+
+	checker := &health.StalePricesChecker{
+		DB:       db,
+		Policy:   &market.StalenessPolicy{Calendar: nseCalendar(t), MaxAge: 15 * time.Minute, Now: func() time.Time { return now }},
+		MaxRatio: 0.2,
+		Interval: 10 * time.Millisecond,
+	}
+	go checker.Run(ctx)
+
+	// INFY has no price at all
+	assert.Eventually(t, func() bool { return testutil.ToFloat64(health.StalePriceRatio) == 0.5 }, 5*time.Second, 10*time.Millisecond)
+
+	_, err = db.ExecContext(ctx, `INSERT INTO stock_prices (symbol, price, updated_at) VALUES ('INFY', 1500, $1)`, now.Add(-time.Minute).UTC())
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return testutil.ToFloat64(health.StalePriceRatio) == 0 }, 5*time.Second, 10*time.Millisecond)
+	assert.NoError(t, checker.Check(ctx))
+}
@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/health"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthRegistry_CriticalFailure(t *testing.T) {
+	reg := health.NewRegistry(100*time.Millisecond, 0)
+	reg.Register(health.CheckerFunc{CheckName: "db", Fn: func(ctx context.Context) error { return errors.New("down") }}, true)
+	reg.Register(health.CheckerFunc{CheckName: "cache", Fn: func(ctx context.Context) error { return nil }}, true)
+
+	report := reg.Run(context.Background())
+	assert.Equal(t, health.StatusFail, report.Status)
+	assert.Equal(t, "down", report.Checks[0].Error)
+	assert.Equal(t, health.StatusOK, report.Checks[1].Status)
+}
+
+func TestHealthRegistry_NonCriticalDegrades(t *testing.T) {
+	reg := health.NewRegistry(100*time.Millisecond, 0)
+	reg.Register(health.CheckerFunc{CheckName: "prices", Fn: func(ctx context.Context) error { return errors.New("stale") }}, false)
+
+	report := reg.Run(context.Background())
+	assert.Equal(t, health.StatusDegraded, report.Status)
+}
+
+func TestHealthRegistry_Timeout(t *testing.T) {
+	reg := health.NewRegistry(20*time.Millisecond, 0)
+	reg.Register(health.CheckerFunc{CheckName: "slow", Fn: func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}}, true)
+
+	start := time.Now()
+	report := reg.Run(context.Background())
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, health.StatusFail, report.Status)
+}
+
+func TestHealthRegistry_CachesResults(t *testing.T) {
+	var calls int32
+	reg := health.NewRegistry(100*time.Millisecond, time.Minute)
+	reg.Register(health.CheckerFunc{CheckName: "db", Fn: func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}}, true)
+
+	reg.Run(context.Background())
+	reg.Run(context.Background())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,252 @@
+//go:build integration
+
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mhatrejeets/stocky-ms/internal/events"
+	"github.com/mhatrejeets/stocky-ms/internal/infra"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/mhatrejeets/stocky-ms/internal/service"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// holdingsFixture wires the reward repository to the projection in-process,
+// the way main does without Kafka.
+type holdingsFixture struct {
+	db       *sql.DB
+	bus      *infra.MemoryBus
+	holdings *repo.HoldingsRepositoryImpl
+	rewards  *repo.RewardRepositoryImpl
+	events   events.EventPublisher
+}
+
+func newHoldingsFixture(t *testing.T) *holdingsFixture {
+	db := newTestDB(t)
+	holdings := &repo.HoldingsRepositoryImpl{DB: db}
+	registry := events.NewRegistry()
+	(&service.PortfolioProjector{Holdings: holdings}).Register(registry)
+	bus := infra.NewMemoryBus()
+	publisher := &events.DispatchingPublisher{Publisher: bus, Registry: registry}
+	return &holdingsFixture{
+		db:       db,
+		bus:      bus,
+		holdings: holdings,
+		events:   publisher,
+		rewards: &repo.RewardRepositoryImpl{
+			DB: db, Events: publisher, Encoder: testEncoder(t), Holdings: holdings, Lots: &repo.TaxLotRepositoryImpl{DB: db},
+		},
+	}
+}
+
+func (f *holdingsFixture) reward(t *testing.T, userID string, shares int64, at time.Time) string {
+	id, err := f.rewards.CreateReward(context.Background(), model.Reward{
+		ID: uuid.NewString(), UserID: userID, StockSymbol: "TCS", Shares: decimal.NewFromInt(shares), RewardedAt: at,
+		CreatedAt: time.Now().UTC(), UniqueHash: uuid.NewString(), IdempotencyKey: uuid.NewString(), Status: model.RewardStatusActive,
+	})
+	require.NoError(t, err)
+	return id
+}
+
+func (f *holdingsFixture) split(t *testing.T, ratio string, effective time.Time) {
+	msg, err := testEncoder(t).CorporateActionMessage(model.CorporateActionEvent{
+		ActionID: uuid.NewString(), Symbol: "TCS", ActionType: "split", Ratio: ratio, EffectiveAt: effective.Format(time.RFC3339),
+	})
+	require.NoError(t, err)
+	require.NoError(t, f.events.Publish(context.Background(), msg))
+}
+
+func (f *holdingsFixture) held(t *testing.T, userID string) string {
+	holdings, err := f.holdings.ListHoldings(context.Background(), userID)
+	require.NoError(t, err)
+	return holdings["TCS"].String()
+}
+
+func TestHoldings_ReversalAfterSplitTakesRestatedShares(t *testing.T) {
+	f := newHoldingsFixture(t)
+	ctx := context.Background()
+	rewarded := time.Date(2025, 9, 1, 5, 0, 0, 0, time.UTC)
+	id := f.reward(t, "u1", 10, rewarded)
+	f.split(t, "2", rewarded.AddDate(0, 0, 7))
+	require.Equal(t, "20", f.held(t, "u1"))
+
+	_, err := f.rewards.ReverseReward(ctx, id, "fraud")
+	require.NoError(t, err)
+
+	// The payload the projector consumed says what the reward held
+	published := f.bus.Messages(events.TopicRewardEvents)
+	last := published[len(published)-1]
+	require.Equal(t, events.TypeRewardReversed, last.Type)
+	var event model.RewardReversedEvent
+	require.NoError(t, decodeMessage(last, &event))
+	assert.Equal(t, "20", event.Shares)
+	assert.Equal(t, "0", f.held(t, "u1"))
+
+	var open string
+	require.NoError(t, f.db.QueryRowContext(ctx, `SELECT remaining_shares FROM tax_lots WHERE reward_id = $1`, id).Scan(&open))
+	assert.True(t, decimal.RequireFromString(open).IsZero(), open)
+
+	require.NoError(t, f.holdings.Rebuild(ctx))
+	assert.Equal(t, "0", f.held(t, "u1"))
+}
+
+func TestHoldings_LateActionRestatesOnlyEarlierRewards(t *testing.T) {
+	f := newHoldingsFixture(t)
+	ctx := context.Background()
+	t0 := time.Date(2025, 9, 1, 5, 0, 0, 0, time.UTC)
+	older := f.reward(t, "u1", 10, t0)
+	newer := f.reward(t, "u1", 5, t0.AddDate(0, 0, 14))
+	// Recorded after both rewards, effective between them
+	f.split(t, "2", t0.AddDate(0, 0, 7))
+	assert.Equal(t, "25", f.held(t, "u1"))
+
+	lots := map[string]string{}
+	rows, err := f.db.QueryContext(ctx, `SELECT reward_id, shares FROM tax_lots`)
+	require.NoError(t, err)
+	for rows.Next() {
+		var id, shares string
+		require.NoError(t, rows.Scan(&id, &shares))
+		lots[id] = decimal.RequireFromString(shares).String()
+	}
+	require.NoError(t, rows.Err())
+	rows.Close()
+	assert.Equal(t, map[string]string{older: "20", newer: "5"}, lots)
+
+	// A reward backdated before an applied action is restated on arrival
+	f.reward(t, "u1", 1, t0)
+	assert.Equal(t, "27", f.held(t, "u1"))
+
+	require.NoError(t, f.holdings.Rebuild(ctx))
+	assert.Equal(t, "27", f.held(t, "u1"))
+}
+
+func TestHoldings_ReversalBeforeCreationAddsNothing(t *testing.T) {
+	f := newHoldingsFixture(t)
+	ctx := context.Background()
+	// Create without publishing, as if the creation event were still in flight
+	f.rewards.Events = nil
+	id := f.reward(t, "u1", 10, time.Date(2025, 9, 1, 5, 0, 0, 0, time.UTC))
+
+	applied, err := f.holdings.ApplyRewardReversed(ctx, id)
+	require.NoError(t, err)
+	assert.True(t, applied)
+	applied, err = f.holdings.ApplyRewardCreated(ctx, model.Reward{ID: id, UserID: "u1", StockSymbol: "TCS", Shares: decimal.NewFromInt(10)})
+	require.NoError(t, err)
+	assert.False(t, applied)
+	assert.Equal(t, "0", f.held(t, "u1"))
+}
+
+func TestHoldings_ListHoldersAtReplaysTheLedger(t *testing.T) {
+	f := newHoldingsFixture(t)
+	ctx := context.Background()
+	t0 := time.Date(2025, 9, 1, 5, 0, 0, 0, time.UTC)
+	f.reward(t, "u1", 10, t0)
+	reversed := f.reward(t, "u2", 4, t0)
+	f.split(t, "2", t0.AddDate(0, 0, 7))
+	f.reward(t, "u3", 3, t0.AddDate(0, 0, 10))
+	_, err := f.rewards.ReverseReward(ctx, reversed, "fraud")
+	require.NoError(t, err)
+	holdersAt := func(at time.Time) map[string]string {
+		t.Helper()
+		holders, err := f.holdings.ListHoldersAt(ctx, "TCS", at)
+		require.NoError(t, err)
+		shares := map[string]string{}
+		for userID, held := range holders {
+			shares[userID] = held.String()
+		}
+		return shares
+	}
+
+	assert.Equal(t, map[string]string{"u1": "10", "u2": "4"}, holdersAt(t0.AddDate(0, 0, 5)), "before the split, and the reversal came later")
+	assert.Equal(t, map[string]string{"u1": "20", "u2": "8"}, holdersAt(t0.AddDate(0, 0, 8)), "restated by the split")
+	assert.Equal(t, map[string]string{"u1": "20", "u3": "3"}, holdersAt(time.Now().Add(time.Hour)))
+	assert.Empty(t, holdersAt(t0), "a reward counts from just after it was made")
+}
+
+func TestRewardRepository_OutboxKeepsUnpublishedEvents(t *testing.T) {
+	f := newHoldingsFixture(t)
+	ctx := context.Background()
+	down := new(MockPublisher)
+	down.On("Publish", mock.Anything, mock.Anything).Return(errors.New("broker down"))
+	f.rewards.Events = down
+
+	id := f.reward(t, "u1", 10, time.Date(2025, 9, 1, 5, 0, 0, 0, time.UTC))
+	assert.Equal(t, "0", f.held(t, "u1"))
+
+	relay := &service.OutboxRelay{Repo: &repo.OutboxRepositoryImpl{DB: f.db}, Events: f.events}
+	// Nothing is due while the writer may still publish it itself
+	claimed, err := relay.Relay(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, claimed)
+
+	relay.Now = func() time.Time { return time.Now().Add(time.Minute) }
+	claimed, err = relay.Relay(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, claimed)
+	assert.Equal(t, "10", f.held(t, "u1"))
+
+	var published sql.NullTime
+	require.NoError(t, f.db.QueryRowContext(ctx, `SELECT published_at FROM event_outbox WHERE msg_key = $1`, "u1").Scan(&published))
+	assert.True(t, published.Valid, id)
+	claimed, err = relay.Relay(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, claimed)
+}
+
+func TestCorporateActions_AnnouncementsGoThroughTheOutbox(t *testing.T) {
+	f := newHoldingsFixture(t)
+	ctx := context.Background()
+	down := new(MockPublisher)
+	down.On("Publish", mock.Anything, mock.Anything).Return(errors.New("broker down"))
+	svc := &service.CorporateActionService{Events: &repo.OutboxPublisher{DB: f.db, Events: down}, Encoder: testEncoder(t)}
+	f.reward(t, "u1", 10, time.Date(2025, 9, 1, 5, 0, 0, 0, time.UTC))
+
+	// Accepted while the broker is down, and applied once the relay runs
+	_, err := svc.Announce(ctx, model.CorporateActionRequest{Symbol: "TCS", ActionType: "split", Ratio: "2", EffectiveAt: "2025-09-08T00:00:00Z"})
+	require.NoError(t, err)
+	_, err = svc.DeclareDividend(ctx, model.DividendRequest{Symbol: "TCS", AmountPerShare: "5", RecordDate: "2025-09-10", PayDate: "2025-09-20"})
+	require.NoError(t, err)
+	assert.Equal(t, "10", f.held(t, "u1"))
+
+	outbox := &repo.OutboxRepositoryImpl{DB: f.db}
+	relay := &service.OutboxRelay{Repo: outbox, Events: f.events, Now: func() time.Time { return time.Now().Add(time.Minute) }}
+	claimed, err := relay.Relay(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, claimed)
+	assert.Equal(t, "20", f.held(t, "u1"))
+
+	// Published events stay until they are past retention, pending ones
+	// until they are published
+	_, err = svc.Announce(ctx, model.CorporateActionRequest{Symbol: "INFY", ActionType: "bonus", Ratio: "1.5", EffectiveAt: "2025-09-09T00:00:00Z"})
+	require.NoError(t, err)
+	pruner := &service.OutboxPruner{Repo: outbox, Retention: time.Hour}
+	deleted, err := pruner.Prune(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+	pruner.Now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	deleted, err = pruner.Prune(ctx)
+	require.NoError(t, err)
+	// The reward's event and the two announcements relayed above
+	assert.Equal(t, 3, deleted)
+	var pending int
+	require.NoError(t, f.db.QueryRowContext(ctx, `SELECT count(*) FROM event_outbox WHERE published_at IS NULL`).Scan(&pending))
+	assert.Equal(t, 1, pending)
+}
+
+func decodeMessage(msg events.Message, v interface{}) error {
+	env, err := events.Decode(msg)
+	if err != nil {
+		return err
+	}
+	return env.DecodeData(v)
+}
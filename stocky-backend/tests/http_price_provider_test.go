@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/infra"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func newQuoteProvider(url string) *infra.HTTPPriceProvider {
+	return &infra.HTTPPriceProvider{BaseURL: url, Client: &http.Client{Timeout: time.Second}, BatchSize: 2, Retries: 2, Backoff: time.Millisecond}
+}
+
+func TestHTTPPriceProvider_BatchesAndValidates(t *testing.T) {
+	var requests int32
+	asOf := time.Now().UTC().Add(-time.Minute).Format(time.RFC3339)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		var quotes []string
+		for _, s := range strings.Split(r.URL.Query().Get("symbols"), ",") {
+			price := "100.5"
+			if s == "BAD" {
+				price = "0"
+			}
+			quotes = append(quotes, fmt.Sprintf(`{"symbol":%q,"price":%q,"as_of":%q}`, s, price, asOf))
+		}
+		// An unrequested symbol must be ignored
+		quotes = append(quotes, fmt.Sprintf(`{"symbol":"EXTRA","price":"1","as_of":%q}`, asOf))
+		fmt.Fprintf(w, `{"quotes":[%s]}`, strings.Join(quotes, ","))
+	}))
+	defer srv.Close()
+
+	quotes, err := newQuoteProvider(srv.URL).GetPrices(context.Background(), []string{"TCS", "INFY", "BAD"})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	assert.Len(t, quotes, 2)
+	assert.True(t, decimal.RequireFromString("100.5").Equal(quotes["TCS"].Price))
+	assert.NotContains(t, quotes, "BAD")
+	assert.NotContains(t, quotes, "EXTRA")
+}
+
+func TestHTTPPriceProvider_RetriesServerErrors(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprintf(w, `{"quotes":[{"symbol":"TCS","price":"10","as_of":%q}]}`, time.Now().UTC().Format(time.RFC3339))
+	}))
+	defer srv.Close()
+
+	price, _, err := newQuoteProvider(srv.URL).GetPrice(context.Background(), "TCS")
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(10).Equal(price))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+}
+
+func TestHTTPPriceProvider_DoesNotRetryClientErrors(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	_, err := newQuoteProvider(srv.URL).GetPrices(context.Background(), []string{"TCS"})
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/mhatrejeets/stocky-ms/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockInstruments struct {
+	mock.Mock
+}
+
+var _ repo.InstrumentRepository = (*MockInstruments)(nil)
+
+func (m *MockInstruments) UpsertInstruments(ctx context.Context, instruments []model.Instrument) error {
+	return m.Called(ctx, instruments).Error(0)
+}
+
+func (m *MockInstruments) FindInstruments(ctx context.Context, code string) ([]model.Instrument, error) {
+	args := m.Called(ctx, code)
+	return args.Get(0).([]model.Instrument), args.Error(1)
+}
+
+func (m *MockInstruments) SearchInstruments(ctx context.Context, query string, limit int) ([]model.Instrument, error) {
+	args := m.Called(ctx, query, limit)
+	return args.Get(0).([]model.Instrument), args.Error(1)
+}
+
+func (m *MockInstruments) ActiveSymbols(ctx context.Context) ([]string, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockInstruments) AnyInstruments(ctx context.Context) (bool, error) {
+	args := m.Called(ctx)
+	return args.Bool(0), args.Error(1)
+}
+
+func TestValidISIN(t *testing.T) {
+	for _, isin := range []string{"INE002A01018", "INE467B01029", "US0378331005"} {
+		assert.True(t, service.ValidISIN(isin), isin)
+	}
+	for _, isin := range []string{"INE002A01019", "INE002A0101", "1NE002A01018", "ine002a01018"} {
+		assert.False(t, service.ValidISIN(isin), isin)
+	}
+}
+
+func TestParseInstrumentsCSV_EquityListFormat(t *testing.T) {
+	csv := "SYMBOL,NAME OF COMPANY, SERIES,ISIN NUMBER, MARKET LOT\n" +
+		"reliance,Reliance Industries Limited,EQ,INE002A01018,1\n" +
+		"RELIANCEPP,Reliance Partly Paid,E1,INE002A01018,1\n" +
+		"TCS,Tata Consultancy Services Limited,EQ,INE467B01029,1\n"
+	instruments, err := service.ParseInstrumentsCSV(strings.NewReader(csv), "nse")
+	require.NoError(t, err)
+	require.Len(t, instruments, 2)
+	assert.Equal(t, "RELIANCE", instruments[0].Symbol)
+	assert.Equal(t, model.ExchangeNSE, instruments[0].Exchange)
+	assert.Equal(t, model.InstrumentActive, instruments[0].Status)
+}
+
+func TestParseInstrumentsCSV_RejectsBadRows(t *testing.T) {
+	for name, body := range map[string]string{
+		"bad isin":  "SYMBOL,NAME,ISIN\nTCS,TCS,INE467B01020\n",
+		"duplicate": "SYMBOL,NAME,ISIN\nTCS,TCS,INE467B01029\nTCS,TCS,INE467B01029\n",
+		"no isin":   "SYMBOL,NAME\nTCS,TCS\n",
+	} {
+		_, err := service.ParseInstrumentsCSV(strings.NewReader(body), "NSE")
+		assert.Error(t, err, name)
+	}
+}
+
+func TestCreateReward_NormalizesAndValidatesSymbol(t *testing.T) {
+	instruments := new(MockInstruments)
+	instruments.On("FindInstruments", mock.Anything, "INE002A01018").Return([]model.Instrument{
+		{Symbol: "RELIANCE", Exchange: "NSE", ISIN: "INE002A01018", Status: model.InstrumentActive},
+	}, nil)
+	instruments.On("FindInstruments", mock.Anything, "RELIANC").Return([]model.Instrument(nil), nil)
+	instruments.On("FindInstruments", mock.Anything, "JPASSOC").Return([]model.Instrument{
+		{Symbol: "JPASSOC", Exchange: "NSE", ISIN: "INE455F01025", Status: model.InstrumentSuspended},
+	}, nil)
+	instruments.On("AnyInstruments", mock.Anything).Return(true, nil)
+	rewards := new(MockRewardRepo)
+	svc := &service.RewardService{Repo: rewards, Instruments: &service.InstrumentService{Repo: instruments}}
+	req := model.CreateRewardRequest{StockSymbol: "INE002A01018", Shares: "1.5", RewardedAt: "2025-09-25T11:30:00Z"}
+
+	rewards.On("ExistsByUniqueHashOrIdempotency", mock.Anything, mock.Anything, "k1").Return(false, "")
+	rewards.On("CreateReward", mock.Anything, mock.MatchedBy(func(r model.Reward) bool { return r.StockSymbol == "RELIANCE" })).Return("reward-1", nil)
+	result := svc.CreateReward(context.Background(), "user-1", req, "k1")
+	assert.NoError(t, result.Err)
+	rewards.AssertExpectations(t)
+
+	for _, symbol := range []string{"RELIANC", "jpassoc"} {
+		req.StockSymbol = symbol
+		result = svc.CreateReward(context.Background(), "user-1", req, "k2")
+		var invalid *service.ValidationError
+		assert.True(t, errors.As(result.Err, &invalid), symbol)
+	}
+}
+
+func TestParseInstrumentsCSV_Currencies(t *testing.T) {
+	csv := "SYMBOL,NAME,ISIN,EXCHANGE,CURRENCY\n" +
+		"TCS,Tata Consultancy Services Limited,INE467B01029,NSE,\n" +
+		"AAPL,Apple Inc,US0378331005,NASDAQ,\n" +
+		"VOO,Vanguard S&P 500 ETF,US9229083632,NYSE,usd\n"
+	instruments, err := service.ParseInstrumentsCSV(strings.NewReader(csv), "")
+	require.NoError(t, err)
+	require.Len(t, instruments, 3)
+	assert.Equal(t, model.CurrencyINR, instruments[0].Currency)
+	assert.Equal(t, model.CurrencyUSD, instruments[1].Currency)
+	assert.Equal(t, model.CurrencyUSD, instruments[2].Currency)
+
+	_, err = service.ParseInstrumentsCSV(strings.NewReader("SYMBOL,NAME,ISIN,EXCHANGE,CURRENCY\nAAPL,Apple Inc,US0378331005,NASDAQ,US\n"), "")
+	assert.ErrorContains(t, err, "invalid currency")
+	_, err = service.ParseInstrumentsCSV(strings.NewReader("SYMBOL,NAME,ISIN,EXCHANGE\nAAPL,Apple Inc,US0378331005,LSE\n"), "")
+	assert.ErrorContains(t, err, "unsupported exchange")
+}
+
+func TestInstrumentService_EmptyMasterAcceptsEverySymbol(t *testing.T) {
+	instruments := new(MockInstruments)
+	instruments.On("FindInstruments", mock.Anything, mock.Anything).Return([]model.Instrument(nil), nil)
+	instruments.On("AnyInstruments", mock.Anything).Return(false, nil).Once()
+	svc := &service.InstrumentService{Repo: instruments}
+	ctx := context.Background()
+
+	in, err := svc.Resolve(ctx, " tcs ")
+	require.NoError(t, err)
+	assert.Equal(t, "TCS", in.Symbol)
+	assert.Equal(t, model.InstrumentActive, in.Status)
+	instruments.AssertCalled(t, "FindInstruments", mock.Anything, "TCS")
+
+	// The first import switches validation on, and it stays on
+	instruments.On("AnyInstruments", mock.Anything).Return(true, nil).Once()
+	_, err = svc.Resolve(ctx, "TCS")
+	assert.ErrorIs(t, err, service.ErrUnknownInstrument)
+	_, err = svc.Resolve(ctx, "TCS")
+	assert.ErrorIs(t, err, service.ErrUnknownInstrument)
+	instruments.AssertNumberOfCalls(t, "AnyInstruments", 2)
+}
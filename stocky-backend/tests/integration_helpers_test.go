@@ -0,0 +1,141 @@
+//go:build integration
+
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"github.com/mhatrejeets/stocky-ms/internal/migrate"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// Integration tests share one Postgres and one Redis container per run,
+// started on first use. TEST_DATABASE_URL (a server URL whose user may create
+// databases) and TEST_REDIS_ADDR point them at existing servers instead.
+var (
+	postgresOnce sync.Once
+	postgresURL  string
+	postgresErr  error
+
+	redisOnce sync.Once
+	redisAddr string
+	redisErr  error
+)
+
+func startPostgres() (string, error) {
+	if url := os.Getenv("TEST_DATABASE_URL"); url != "" {
+		return url, nil
+	}
+	ctx := context.Background()
+	c, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "postgres:15",
+			ExposedPorts: []string{"5432/tcp"},
+			Env: map[string]string{
+				"POSTGRES_DB":       "stocky",
+				"POSTGRES_USER":     "stocky",
+				"POSTGRES_PASSWORD": "password",
+			},
+			WaitingFor: wait.ForLog("database system is ready to accept connections").WithOccurrence(2).WithStartupTimeout(time.Minute),
+		},
+		Started: true,
+	})
+	if err != nil {
+		return "", err
+	}
+	host, err := c.Host(ctx)
+	if err != nil {
+		return "", err
+	}
+	port, err := c.MappedPort(ctx, "5432/tcp")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("postgres://stocky:password@%s:%s/stocky?sslmode=disable", host, port.Port()), nil
+}
+
+// newTestDB returns a fresh, fully migrated database of its own, dropped when
+// the test ends.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	postgresOnce.Do(func() { postgresURL, postgresErr = startPostgres() })
+	require.NoError(t, postgresErr)
+
+	admin, err := sql.Open("postgres", postgresURL)
+	require.NoError(t, err)
+	t.Cleanup(func() { admin.Close() })
+	name := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	_, err = admin.Exec(`CREATE DATABASE ` + name)
+	require.NoError(t, err)
+
+	url := postgresURL
+	if i := strings.LastIndex(url, "/"); i >= 0 {
+		rest := url[i+1:]
+		query := ""
+		if j := strings.Index(rest, "?"); j >= 0 {
+			query = rest[j:]
+		}
+		url = url[:i+1] + name + query
+	}
+	db, err := sql.Open("postgres", url)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		db.Close()
+		admin.Exec(`DROP DATABASE IF EXISTS ` + name + ` WITH (FORCE)`)
+	})
+
+	m, err := migrate.New(db)
+	require.NoError(t, err)
+	require.NoError(t, m.Up(context.Background()))
+	return db
+}
+
+func startRedis() (string, error) {
+	if addr := os.Getenv("TEST_REDIS_ADDR"); addr != "" {
+		return addr, nil
+	}
+	ctx := context.Background()
+	c, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "redis:7",
+			ExposedPorts: []string{"6379/tcp"},
+			WaitingFor:   wait.ForListeningPort("6379/tcp"),
+		},
+		Started: true,
+	})
+	if err != nil {
+		return "", err
+	}
+	host, err := c.Host(ctx)
+	if err != nil {
+		return "", err
+	}
+	port, err := c.MappedPort(ctx, "6379/tcp")
+	if err != nil {
+		return "", err
+	}
+	return host + ":" + port.Port(), nil
+}
+
+// newTestRedis returns a client on the shared Redis; tests keep apart by
+// using their own key prefixes.
+func newTestRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	redisOnce.Do(func() { redisAddr, redisErr = startRedis() })
+	require.NoError(t, redisErr)
+	client := redis.NewClient(&redis.Options{Addr: redisAddr})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
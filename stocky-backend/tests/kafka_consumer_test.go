@@ -0,0 +1,191 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/mhatrejeets/stocky-ms/internal/events"
+	"github.com/mhatrejeets/stocky-ms/internal/infra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSession records the offsets a consumer group handler marks.
+type fakeSession struct {
+	ctx    context.Context
+	mu     sync.Mutex
+	marked []int64
+}
+
+func (s *fakeSession) Claims() map[string][]int32               { return nil }
+func (s *fakeSession) MemberID() string                         { return "member" }
+func (s *fakeSession) GenerationID() int32                      { return 1 }
+func (s *fakeSession) MarkOffset(string, int32, int64, string)  {}
+func (s *fakeSession) Commit()                                  {}
+func (s *fakeSession) ResetOffset(string, int32, int64, string) {}
+func (s *fakeSession) Context() context.Context                 { return s.ctx }
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, _ string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.marked = append(s.marked, msg.Offset)
+}
+
+func (s *fakeSession) lastMarked() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.marked) == 0 {
+		return -1
+	}
+	return s.marked[len(s.marked)-1]
+}
+
+type fakeClaim struct{ messages chan *sarama.ConsumerMessage }
+
+func (c *fakeClaim) Topic() string                            { return events.TopicRewardEvents }
+func (c *fakeClaim) Partition() int32                         { return 0 }
+func (c *fakeClaim) InitialOffset() int64                     { return 0 }
+func (c *fakeClaim) HighWaterMarkOffset() int64               { return 0 }
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+// consume runs the worker's handler over one claim until the claim is
+// closed or ctx is cancelled, reporting on the returned channel.
+func consume(ctx context.Context, w *infra.ConsumerGroupWorker) (*fakeSession, *fakeClaim, <-chan error) {
+	sess := &fakeSession{ctx: ctx}
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 64)}
+	returned := make(chan error, 1)
+	go func() { returned <- w.Handler().ConsumeClaim(sess, claim) }()
+	return sess, claim, returned
+}
+
+func record(offset int64, key string) *sarama.ConsumerMessage {
+	return &sarama.ConsumerMessage{
+		Topic: events.TopicRewardEvents, Offset: offset, Key: []byte(key),
+		Headers: []*sarama.RecordHeader{{Key: []byte("event_type"), Value: []byte(events.TypeRewardCreated)}},
+	}
+}
+
+// keysOnDifferentLanes returns two keys LaneFor splits across lanes.
+func keysOnDifferentLanes(t *testing.T, lanes int) (string, string) {
+	for i := 1; i < 100; i++ {
+		other := fmt.Sprintf("user-%d", i)
+		if infra.LaneFor([]byte("user-0"), lanes) != infra.LaneFor([]byte(other), lanes) {
+			return "user-0", other
+		}
+	}
+	t.Fatal("no two keys on different lanes")
+	return "", ""
+}
+
+func TestLaneFor(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		key := []byte(fmt.Sprintf("user-%d", i))
+		lane := infra.LaneFor(key, 8)
+		assert.True(t, lane >= 0 && lane < 8)
+		assert.Equal(t, lane, infra.LaneFor(key, 8))
+		assert.Equal(t, 0, infra.LaneFor(key, 1))
+	}
+}
+
+func TestConsumerGroupWorker_SameKeyRunsInOrder(t *testing.T) {
+	var mu sync.Mutex
+	seen := map[string][]int64{}
+	registry := events.NewRegistry()
+	registry.Register(events.TypeRewardCreated, func(ctx context.Context, msg events.Message) error {
+		// Early messages are slowest, so any lane running a key's messages
+		// in parallel would finish them out of order
+		offset, _ := strconv.ParseInt(msg.Headers["offset"], 10, 64)
+		time.Sleep(time.Duration(5-offset%5) * time.Millisecond)
+		mu.Lock()
+		seen[msg.Key] = append(seen[msg.Key], offset)
+		mu.Unlock()
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sess, claim, returned := consume(ctx, &infra.ConsumerGroupWorker{Registry: registry, Concurrency: 4})
+
+	want := map[string][]int64{}
+	for offset := int64(0); offset < 30; offset++ {
+		key := fmt.Sprintf("user-%d", offset%3)
+		msg := record(offset, key)
+		msg.Headers = append(msg.Headers, &sarama.RecordHeader{Key: []byte("offset"), Value: []byte(strconv.FormatInt(offset, 10))})
+		want[key] = append(want[key], offset)
+		claim.messages <- msg
+	}
+	close(claim.messages)
+	require.NoError(t, <-returned)
+	assert.Equal(t, want, seen)
+	assert.Equal(t, int64(29), sess.lastMarked())
+}
+
+func TestConsumerGroupWorker_MarksOffsetOnlyAfterEarlierOnesFinish(t *testing.T) {
+	slow, fast := keysOnDifferentLanes(t, 2)
+	release := make(chan struct{})
+	handled := make(chan string, 4)
+	registry := events.NewRegistry()
+	registry.Register(events.TypeRewardCreated, func(ctx context.Context, msg events.Message) error {
+		if msg.Key == slow {
+			<-release
+		}
+		handled <- msg.Key
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sess, claim, returned := consume(ctx, &infra.ConsumerGroupWorker{Registry: registry, Concurrency: 2})
+
+	claim.messages <- record(0, slow)
+	claim.messages <- record(1, fast)
+	claim.messages <- record(2, fast)
+	assert.Equal(t, fast, <-handled)
+	assert.Equal(t, fast, <-handled)
+	// Offsets 1 and 2 are done, but 0 is not
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int64(-1), sess.lastMarked())
+
+	close(release)
+	assert.Equal(t, slow, <-handled)
+	assert.Eventually(t, func() bool { return sess.lastMarked() == 2 }, time.Second, 5*time.Millisecond)
+	// Marking the newest finished offset covers the ones before it
+	assert.Equal(t, []int64{2}, sess.marked)
+	close(claim.messages)
+	require.NoError(t, <-returned)
+}
+
+func TestConsumerGroupWorker_RebalanceWaitsForInFlightMessages(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	registry := events.NewRegistry()
+	registry.Register(events.TypeRewardCreated, func(ctx context.Context, msg events.Message) error {
+		close(started)
+		<-release
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	sess, claim, returned := consume(ctx, &infra.ConsumerGroupWorker{Registry: registry, Concurrency: 2})
+
+	claim.messages <- record(0, "user-1")
+	<-started
+	// The partition is revoked while the message is being handled
+	cancel()
+	select {
+	case <-returned:
+		t.Fatal("claim released with a message in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Equal(t, int64(-1), sess.lastMarked())
+
+	close(release)
+	select {
+	case err := <-returned:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("claim not released after the message finished")
+	}
+	// Finished before the claim was given up, so it is committed
+	assert.Equal(t, int64(0), sess.lastMarked())
+}
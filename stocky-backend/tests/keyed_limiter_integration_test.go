@@ -0,0 +1,48 @@
+//go:build integration
+
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mhatrejeets/stocky-ms/internal/infra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisKeyedLimiter_SharedBetweenReplicas(t *testing.T) {
+	client := newTestRedis(t)
+	prefix := "ratelimit:" + uuid.NewString() + ":"
+	// Two replicas, one bucket of 3 refilling at 10/s
+	a := &infra.RedisKeyedLimiter{Client: client, Prefix: prefix, Rate: 10, Burst: 3}
+	b := &infra.RedisKeyedLimiter{Client: client, Prefix: prefix, Rate: 10, Burst: 3}
+	ctx := context.Background()
+
+	admitted := 0
+	for i := 0; i < 6; i++ {
+		l := a
+		if i%2 == 1 {
+			l = b
+		}
+		wait, err := l.Reserve(ctx, "partner-1")
+		require.NoError(t, err)
+		if wait == 0 {
+			admitted++
+		} else {
+			assert.LessOrEqual(t, wait, 100*time.Millisecond)
+		}
+	}
+	assert.Equal(t, 3, admitted)
+
+	wait, err := a.Reserve(ctx, "partner-2")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+
+	time.Sleep(120 * time.Millisecond)
+	wait, err = b.Reserve(ctx, "partner-1")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+}
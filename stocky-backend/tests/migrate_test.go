@@ -0,0 +1,39 @@
+package tests
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/mhatrejeets/stocky-ms/internal/migrate"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrateLoad_OrdersAndPairs(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/0002_prices.up.sql":   {Data: []byte("CREATE TABLE b ();")},
+		"m/0002_prices.down.sql": {Data: []byte("DROP TABLE b;")},
+		"m/0001_init.up.sql":     {Data: []byte("CREATE TABLE a ();")},
+		"m/README.md":            {Data: []byte("ignored")},
+	}
+	migrations, err := migrate.Load(fsys, "m")
+	assert.NoError(t, err)
+	assert.Len(t, migrations, 2)
+	assert.Equal(t, int64(1), migrations[0].Version)
+	assert.Equal(t, "init", migrations[0].Name)
+	assert.Empty(t, migrations[0].Down)
+	assert.Equal(t, "DROP TABLE b;", migrations[1].Down)
+}
+
+func TestMigrateLoad_MissingUp(t *testing.T) {
+	fsys := fstest.MapFS{"m/0003_x.down.sql": {Data: []byte("DROP TABLE x;")}}
+	_, err := migrate.Load(fsys, "m")
+	assert.Error(t, err)
+}
+
+func TestMigrateNew_EmbeddedMigrationsHaveDownFiles(t *testing.T) {
+	m, err := migrate.New(nil)
+	assert.NoError(t, err)
+	for _, mig := range m.Migrations {
+		assert.NotEmpty(t, mig.Down, "migration %d", mig.Version)
+	}
+}
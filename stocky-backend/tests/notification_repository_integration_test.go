@@ -0,0 +1,126 @@
+//go:build integration
+
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotificationRepository_PreferencesUpsertAndFilter(t *testing.T) {
+	notifications := &repo.NotificationRepositoryImpl{DB: newTestDB(t)}
+	ctx := context.Background()
+
+	_, err := notifications.UpsertPreference(ctx, preference("u1", model.ChannelEmail, "u1@example.com", "en"))
+	require.NoError(t, err)
+	pref := preference("u1", model.ChannelSMS, "+919876543210", "hi", model.NotificationDividend)
+	_, err = notifications.UpsertPreference(ctx, pref)
+	require.NoError(t, err)
+	pref.Enabled = false
+	_, err = notifications.UpsertPreference(ctx, pref)
+	require.NoError(t, err)
+	_, err = notifications.UpsertPreference(ctx, preference("u2", model.ChannelPush, "token", "en"))
+	require.NoError(t, err)
+
+	all, err := notifications.ListPreferences(ctx, "u1")
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, []string{model.NotificationDividend}, all[1].Kinds)
+	assert.False(t, all[1].Enabled, "upserting replaces the channel's settings")
+
+	enabled, err := notifications.ListEnabledPreferences(ctx, []string{"u1", "u3"})
+	require.NoError(t, err)
+	require.Len(t, enabled, 1)
+	assert.Equal(t, model.ChannelEmail, enabled[0].Channel)
+	assert.Empty(t, enabled[0].Kinds)
+
+	require.NoError(t, notifications.DeletePreference(ctx, "u1", model.ChannelEmail))
+	assert.ErrorIs(t, notifications.DeletePreference(ctx, "u1", model.ChannelEmail), repo.ErrNotFound)
+}
+
+func TestNotificationRepository_DedupsClaimsAndSettles(t *testing.T) {
+	notifications := &repo.NotificationRepositoryImpl{DB: newTestDB(t)}
+	ctx := context.Background()
+	queue := func(userID, dedupKey string) int {
+		t.Helper()
+		n, err := notifications.EnqueueNotifications(ctx, []model.Notification{{
+			UserID: userID, Channel: model.ChannelEmail, Kind: model.NotificationRewardCreated, Address: userID + "@example.com", Body: "hi", DedupKey: dedupKey,
+		}})
+		require.NoError(t, err)
+		return n
+	}
+	assert.Equal(t, 1, queue("u1", "k1"))
+	assert.Equal(t, 0, queue("u1", "k1"), "the same event queues once per user and channel")
+	assert.Equal(t, 1, queue("u2", "k1"))
+
+	now := time.Now().Add(time.Second)
+	claimed, err := notifications.ClaimDueNotifications(ctx, now, time.Minute, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 2)
+	assert.Equal(t, 1, claimed[0].Attempts)
+	again, err := notifications.ClaimDueNotifications(ctx, now.Add(30*time.Second), time.Minute, 10)
+	require.NoError(t, err)
+	assert.Empty(t, again, "leased until the lease ends")
+
+	byUser := map[string]model.Notification{}
+	for _, n := range claimed {
+		byUser[n.UserID] = n
+	}
+	require.NoError(t, notifications.MarkNotificationSent(ctx, byUser["u1"].ID, now))
+	retryAt := now.Add(5 * time.Minute)
+	require.NoError(t, notifications.MarkNotificationFailed(ctx, byUser["u2"].ID, "connection refused", &retryAt))
+
+	again, err = notifications.ClaimDueNotifications(ctx, now.Add(2*time.Minute), time.Minute, 10)
+	require.NoError(t, err)
+	assert.Empty(t, again, "a sent notification is done and a failed one waits for its retry")
+	again, err = notifications.ClaimDueNotifications(ctx, retryAt, time.Minute, 10)
+	require.NoError(t, err)
+	require.Len(t, again, 1)
+	assert.Equal(t, 2, again[0].Attempts)
+	assert.Equal(t, "connection refused", again[0].LastError)
+	require.NoError(t, notifications.MarkNotificationFailed(ctx, again[0].ID, "mailbox unavailable", nil))
+
+	history, err := notifications.ListNotifications(ctx, "u2", 10)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, model.NotificationFailed, history[0].Status)
+	assert.Nil(t, history[0].NextAttemptAt)
+	history, err = notifications.ListNotifications(ctx, "u1", 10)
+	require.NoError(t, err)
+	assert.Equal(t, model.NotificationSent, history[0].Status)
+	assert.NotNil(t, history[0].SentAt)
+}
+
+func TestNotificationRepository_DividendsComeDueOnce(t *testing.T) {
+	notifications := &repo.NotificationRepositoryImpl{DB: newTestDB(t)}
+	ctx := context.Background()
+	entitled := time.Date(2025, 1, 10, 18, 30, 0, 0, time.UTC)
+	dividend := model.ScheduledDividend{
+		DividendEvent: model.DividendEvent{DividendID: "d1", Symbol: "INFY", AmountPerShare: "12.5", Currency: "INR", RecordDate: "2025-01-10", PayDate: "2025-01-20"},
+		EntitledAt:    entitled,
+	}
+	require.NoError(t, notifications.ScheduleDividend(ctx, dividend))
+	redeclared := dividend
+	redeclared.AmountPerShare = "99"
+	require.NoError(t, notifications.ScheduleDividend(ctx, redeclared))
+
+	due, err := notifications.ListDueDividends(ctx, entitled.Add(-time.Second), 10)
+	require.NoError(t, err)
+	assert.Empty(t, due, "not due before the record date ends")
+	due, err = notifications.ListDueDividends(ctx, entitled, 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, dividend.DividendEvent, due[0].DividendEvent, "the first declaration is kept")
+	assert.True(t, entitled.Equal(due[0].EntitledAt))
+
+	require.NoError(t, notifications.MarkDividendNotified(ctx, "d1", entitled.Add(time.Minute)))
+	due, err = notifications.ListDueDividends(ctx, entitled.Add(time.Hour), 10)
+	require.NoError(t, err)
+	assert.Empty(t, due)
+}
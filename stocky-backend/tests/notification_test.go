@@ -0,0 +1,587 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mhatrejeets/stocky-ms/internal/api"
+	"github.com/mhatrejeets/stocky-ms/internal/events"
+	"github.com/mhatrejeets/stocky-ms/internal/infra"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/mhatrejeets/stocky-ms/internal/service"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockNotifications struct {
+	mock.Mock
+}
+
+var _ repo.NotificationRepository = (*MockNotifications)(nil)
+
+func (m *MockNotifications) ListPreferences(ctx context.Context, userID string) ([]model.NotificationPreference, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]model.NotificationPreference), args.Error(1)
+}
+
+func (m *MockNotifications) ListEnabledPreferences(ctx context.Context, userIDs []string) ([]model.NotificationPreference, error) {
+	sorted := append([]string(nil), userIDs...)
+	sort.Strings(sorted)
+	args := m.Called(ctx, sorted) // recipients come from a map
+	return args.Get(0).([]model.NotificationPreference), args.Error(1)
+}
+
+func (m *MockNotifications) UpsertPreference(ctx context.Context, pref model.NotificationPreference) (model.NotificationPreference, error) {
+	args := m.Called(ctx, pref)
+	return args.Get(0).(model.NotificationPreference), args.Error(1)
+}
+
+func (m *MockNotifications) DeletePreference(ctx context.Context, userID, channel string) error {
+	return m.Called(ctx, userID, channel).Error(0)
+}
+
+func (m *MockNotifications) EnqueueNotifications(ctx context.Context, notifications []model.Notification) (int, error) {
+	args := m.Called(ctx, notifications)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockNotifications) ClaimDueNotifications(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.Notification, error) {
+	args := m.Called(ctx, now, lease, limit)
+	return args.Get(0).([]model.Notification), args.Error(1)
+}
+
+func (m *MockNotifications) MarkNotificationSent(ctx context.Context, id string, at time.Time) error {
+	return m.Called(ctx, id, at).Error(0)
+}
+
+func (m *MockNotifications) MarkNotificationFailed(ctx context.Context, id string, deliveryErr string, retryAt *time.Time) error {
+	return m.Called(ctx, id, deliveryErr, retryAt).Error(0)
+}
+
+func (m *MockNotifications) ListNotifications(ctx context.Context, userID string, limit int) ([]model.Notification, error) {
+	args := m.Called(ctx, userID, limit)
+	return args.Get(0).([]model.Notification), args.Error(1)
+}
+
+func (m *MockNotifications) ScheduleDividend(ctx context.Context, dividend model.ScheduledDividend) error {
+	return m.Called(ctx, dividend).Error(0)
+}
+
+func (m *MockNotifications) ListDueDividends(ctx context.Context, now time.Time, limit int) ([]model.ScheduledDividend, error) {
+	args := m.Called(ctx, now, limit)
+	return args.Get(0).([]model.ScheduledDividend), args.Error(1)
+}
+
+func (m *MockNotifications) MarkDividendNotified(ctx context.Context, dividendID string, at time.Time) error {
+	return m.Called(ctx, dividendID, at).Error(0)
+}
+
+// enqueued returns everything passed to EnqueueNotifications, ordered by
+// user and channel.
+func (m *MockNotifications) enqueued() []model.Notification {
+	var queued []model.Notification
+	for _, call := range m.Calls {
+		if call.Method == "EnqueueNotifications" {
+			queued = append(queued, call.Arguments.Get(1).([]model.Notification)...)
+		}
+	}
+	sort.Slice(queued, func(i, j int) bool {
+		return queued[i].UserID+queued[i].Channel < queued[j].UserID+queued[j].Channel
+	})
+	return queued
+}
+
+// claims sets up the next claim to return notifications.
+func (m *MockNotifications) claims(notifications ...model.Notification) {
+	m.On("ClaimDueNotifications", mock.Anything, mock.Anything, mock.Anything, 100).Return(notifications, nil).Once()
+}
+
+// scheduled returns the dividends passed to ScheduleDividend, in order.
+func (m *MockNotifications) scheduled() []model.ScheduledDividend {
+	var dividends []model.ScheduledDividend
+	for _, call := range m.Calls {
+		if call.Method == "ScheduleDividend" {
+			dividends = append(dividends, call.Arguments.Get(1).(model.ScheduledDividend))
+		}
+	}
+	return dividends
+}
+
+type MockHolders struct {
+	mock.Mock
+}
+
+func (m *MockHolders) ListHoldersAt(ctx context.Context, symbol string, at time.Time) (map[string]decimal.Decimal, error) {
+	args := m.Called(ctx, symbol, at)
+	return args.Get(0).(map[string]decimal.Decimal), args.Error(1)
+}
+
+// recordingSender records what it sends and fails with err while set.
+type recordingSender struct {
+	mu   sync.Mutex
+	sent []model.Notification
+	err  error
+}
+
+func (s *recordingSender) Send(ctx context.Context, n model.Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, n)
+	return nil
+}
+
+func newNotificationService(t *testing.T, notifications *MockNotifications, holders service.HolderSource) *service.NotificationService {
+	templates, err := service.LoadNotificationTemplates("")
+	require.NoError(t, err)
+	loc, err := time.LoadLocation("Asia/Kolkata")
+	require.NoError(t, err)
+	return &service.NotificationService{Repo: notifications, Holders: holders, Templates: templates, Location: loc}
+}
+
+func preference(userID, channel, address, locale string, kinds ...string) model.NotificationPreference {
+	return model.NotificationPreference{UserID: userID, Channel: channel, Address: address, Locale: locale, Enabled: true, Kinds: kinds}
+}
+
+func TestNotificationTemplates_RenderLocalizedWithFallback(t *testing.T) {
+	templates, err := service.LoadNotificationTemplates("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"en", "hi"}, templates.Locales())
+	data := struct{ Symbol, Shares, Date, Reason string }{"TCS", "10", "06 Jan 2025", ""}
+
+	subject, body, err := templates.Render(model.NotificationRewardCreated, "en", model.ChannelEmail, data)
+	require.NoError(t, err)
+	assert.Equal(t, "You've been rewarded 10 TCS shares", subject)
+	assert.Contains(t, body, "on 06 Jan 2025")
+
+	subject, body, err = templates.Render(model.NotificationRewardCreated, "hi", model.ChannelPush, data)
+	require.NoError(t, err)
+	assert.Equal(t, "आपको TCS के 10 शेयर इनाम में मिले हैं", subject)
+	assert.True(t, strings.HasPrefix(body, "Stocky: इनाम"), body)
+
+	subject, body, err = templates.Render(model.NotificationRewardCreated, "fr", model.ChannelSMS, data)
+	require.NoError(t, err)
+	assert.Empty(t, subject)
+	assert.Equal(t, "Stocky: 10 TCS shares were added to your portfolio as a reward.", body)
+
+	_, _, err = templates.Render("unknown", "en", model.ChannelSMS, data)
+	assert.Error(t, err)
+}
+
+func TestNotificationService_QueuesRewardNotificationsOnWantedChannels(t *testing.T) {
+	notifications := &MockNotifications{}
+	svc := newNotificationService(t, notifications, nil)
+	notifications.On("ListEnabledPreferences", mock.Anything, []string{"u1"}).Return([]model.NotificationPreference{
+		preference("u1", model.ChannelEmail, "u1@example.com", "en"),
+		preference("u1", model.ChannelSMS, "+919876543210", "hi", model.NotificationDividend),
+	}, nil)
+	notifications.On("EnqueueNotifications", mock.Anything, mock.Anything).Return(1, nil)
+	registry := events.NewRegistry()
+	svc.Register(registry)
+
+	msg, err := testEncoder(t).RewardCreatedMessage(model.RewardCreatedEvent{
+		RewardID: "r1", UserID: "u1", StockSymbol: "TCS", Shares: "10", RewardedAt: "2025-01-06T20:00:00Z",
+	})
+	require.NoError(t, err)
+	require.NoError(t, registry.Dispatch(context.Background(), msg))
+
+	queued := notifications.enqueued()
+	require.Len(t, queued, 1, "the SMS channel only wants dividends")
+	assert.Equal(t, "u1", queued[0].UserID)
+	assert.Equal(t, model.ChannelEmail, queued[0].Channel)
+	assert.Equal(t, "u1@example.com", queued[0].Address)
+	assert.Equal(t, "You've been rewarded 10 TCS shares", queued[0].Subject)
+	assert.Contains(t, queued[0].Body, "on 07 Jan 2025", "dates are shown in the market's time zone")
+	assert.Equal(t, repo.RewardCreatedKey("r1"), queued[0].DedupKey, "redelivery queues under the same key")
+}
+
+func TestNotificationService_DividendNotifiesHoldersOfRecord(t *testing.T) {
+	notifications := &MockNotifications{}
+	holders := &MockHolders{}
+	svc := newNotificationService(t, notifications, holders)
+	clock := &fakeClock{now: time.Date(2025, 1, 15, 9, 0, 0, 0, svc.Location)}
+	svc.Now = clock.Now
+	endOfRecordDate := time.Date(2025, 1, 11, 0, 0, 0, 0, svc.Location)
+
+	notifications.On("ScheduleDividend", mock.Anything, mock.Anything).Return(nil)
+	holders.On("ListHoldersAt", mock.Anything, "INFY", endOfRecordDate).
+		Return(map[string]decimal.Decimal{"u1": decimal.NewFromInt(10), "u2": decimal.RequireFromString("2.5")}, nil)
+	notifications.On("ListEnabledPreferences", mock.Anything, []string{"u1", "u2"}).Return([]model.NotificationPreference{
+		preference("u1", model.ChannelSMS, "+919876543210", "en"),
+		preference("u2", model.ChannelPush, "device-token", "hi"),
+	}, nil)
+	notifications.On("EnqueueNotifications", mock.Anything, mock.Anything).Return(2, nil)
+	notifications.On("MarkDividendNotified", mock.Anything, mock.Anything, clock.Now()).Return(nil)
+	registry := events.NewRegistry()
+	svc.Register(registry)
+	actions := &service.CorporateActionService{Events: &events.DispatchingPublisher{Publisher: &infra.NoopPublisher{}, Registry: registry}, Encoder: testEncoder(t)}
+
+	declared, err := actions.DeclareDividend(context.Background(), model.DividendRequest{
+		Symbol: "infy", AmountPerShare: "12.5", RecordDate: "2025-01-10", PayDate: "2025-01-20",
+	})
+	require.NoError(t, err)
+
+	require.Len(t, notifications.scheduled(), 1)
+	assert.Equal(t, declared, notifications.scheduled()[0].DividendEvent)
+	assert.True(t, endOfRecordDate.Equal(notifications.scheduled()[0].EntitledAt))
+	notifications.AssertCalled(t, "MarkDividendNotified", mock.Anything, declared.DividendID, clock.Now())
+	queued := notifications.enqueued()
+	require.Len(t, queued, 2)
+	assert.Equal(t, "Stocky: INFY dividend of INR 125.00 on your 10 shares, payable 2025-01-20.", queued[0].Body)
+	assert.Equal(t, model.ChannelPush, queued[1].Channel)
+	assert.Equal(t, "INFY ने प्रति शेयर INR 12.5 का लाभांश घोषित किया", queued[1].Subject)
+	assert.Contains(t, queued[1].Body, "INR 31.25")
+	assert.Equal(t, "dividend:"+declared.DividendID, queued[0].DedupKey)
+
+	_, err = actions.DeclareDividend(context.Background(), model.DividendRequest{
+		Symbol: "INFY", AmountPerShare: "0", RecordDate: "2025-01-10", PayDate: "2025-01-20",
+	})
+	var invalid *service.ValidationError
+	assert.ErrorAs(t, err, &invalid)
+	_, err = actions.DeclareDividend(context.Background(), model.DividendRequest{
+		Symbol: "INFY", AmountPerShare: "1", RecordDate: "2025-01-10", PayDate: "2025-01-09",
+	})
+	assert.ErrorAs(t, err, &invalid)
+}
+
+func TestNotificationService_DividendWaitsForRecordDateToEnd(t *testing.T) {
+	notifications := &MockNotifications{}
+	holders := &MockHolders{}
+	svc := newNotificationService(t, notifications, holders)
+	clock := &fakeClock{now: time.Date(2025, 1, 10, 15, 0, 0, 0, svc.Location)}
+	svc.Now = clock.Now
+	registry := events.NewRegistry()
+	svc.Register(registry)
+	ctx := context.Background()
+
+	notifications.On("ScheduleDividend", mock.Anything, mock.Anything).Return(nil)
+	msg, err := testEncoder(t).DividendDeclaredMessage(model.DividendEvent{
+		DividendID: "d1", Symbol: "INFY", AmountPerShare: "12.5", Currency: "INR", RecordDate: "2025-01-10", PayDate: "2025-01-20",
+	})
+	require.NoError(t, err)
+	require.NoError(t, registry.Dispatch(ctx, msg))
+	holders.AssertNotCalled(t, "ListHoldersAt", mock.Anything, mock.Anything, mock.Anything)
+	require.Len(t, notifications.scheduled(), 1)
+	due := notifications.scheduled()[0]
+
+	// At midnight the record date is over; one dividend failing does not
+	// hold up the next
+	clock.Advance(9 * time.Hour)
+	other := model.ScheduledDividend{
+		DividendEvent: model.DividendEvent{DividendID: "d0", Symbol: "TCS", AmountPerShare: "5", Currency: "INR", RecordDate: "2025-01-09", PayDate: "2025-01-15"},
+		EntitledAt:    time.Date(2025, 1, 10, 0, 0, 0, 0, svc.Location),
+	}
+	notifications.On("ListDueDividends", mock.Anything, clock.Now(), 100).Return([]model.ScheduledDividend{other, due}, nil)
+	holders.On("ListHoldersAt", mock.Anything, "TCS", other.EntitledAt).Return(map[string]decimal.Decimal(nil), errors.New("connection reset"))
+	holders.On("ListHoldersAt", mock.Anything, "INFY", due.EntitledAt).Return(map[string]decimal.Decimal{"u1": decimal.NewFromInt(4)}, nil)
+	notifications.On("ListEnabledPreferences", mock.Anything, []string{"u1"}).Return([]model.NotificationPreference{
+		preference("u1", model.ChannelEmail, "u1@example.com", "en"),
+	}, nil)
+	notifications.On("EnqueueNotifications", mock.Anything, mock.Anything).Return(1, nil)
+	notifications.On("MarkDividendNotified", mock.Anything, "d1", clock.Now()).Return(nil)
+
+	done, err := svc.NotifyDueDividends(ctx)
+	assert.ErrorContains(t, err, "dividend d0")
+	assert.Equal(t, 1, done)
+	require.Len(t, notifications.enqueued(), 1)
+	assert.Contains(t, notifications.enqueued()[0].Body, "INR 50.00")
+	notifications.AssertNotCalled(t, "MarkDividendNotified", mock.Anything, "d0", mock.Anything)
+}
+
+func TestNotificationService_ValidatesPreferences(t *testing.T) {
+	notifications := &MockNotifications{}
+	svc := newNotificationService(t, notifications, nil)
+	svc.Channels = []string{model.ChannelEmail, model.ChannelSMS}
+	ctx := context.Background()
+	str := func(s string) *string { return &s }
+	var invalid *service.ValidationError
+	notifications.On("ListPreferences", mock.Anything, "u1").Return([]model.NotificationPreference(nil), nil)
+
+	for name, tc := range map[string]struct {
+		channel string
+		req     model.NotificationPreferenceRequest
+	}{
+		"bad email":         {model.ChannelEmail, model.NotificationPreferenceRequest{Address: str("Jo <jo@example.com>")}},
+		"bad phone":         {model.ChannelSMS, model.NotificationPreferenceRequest{Address: str("98765 43210")}},
+		"unknown locale":    {model.ChannelEmail, model.NotificationPreferenceRequest{Address: str("jo@example.com"), Locale: str("fr")}},
+		"unknown kind":      {model.ChannelEmail, model.NotificationPreferenceRequest{Address: str("jo@example.com"), Kinds: &[]string{"news"}}},
+		"unknown channel":   {"fax", model.NotificationPreferenceRequest{Address: str("jo@example.com")}},
+		"unavailable":       {model.ChannelPush, model.NotificationPreferenceRequest{Address: str("token")}},
+		"missing address":   {model.ChannelEmail, model.NotificationPreferenceRequest{}},
+		"missing sms phone": {model.ChannelSMS, model.NotificationPreferenceRequest{Locale: str("hi")}},
+	} {
+		_, err := svc.SetPreference(ctx, "u1", tc.channel, tc.req)
+		assert.ErrorAs(t, err, &invalid, name)
+	}
+	notifications.AssertNotCalled(t, "UpsertPreference", mock.Anything, mock.Anything)
+
+	// Defaults fill in a new channel and the address is trimmed
+	stored := preference("u1", model.ChannelEmail, "jo@example.com", "en")
+	notifications.On("UpsertPreference", mock.Anything, stored).Return(stored, nil).Once()
+	_, err := svc.SetPreference(ctx, "u1", model.ChannelEmail, model.NotificationPreferenceRequest{Address: str(" jo@example.com ")})
+	require.NoError(t, err)
+
+	// Unset fields are kept
+	notifications.ExpectedCalls = nil
+	notifications.On("ListPreferences", mock.Anything, "u1").Return([]model.NotificationPreference{stored}, nil)
+	disabled := stored
+	disabled.Enabled = false
+	notifications.On("UpsertPreference", mock.Anything, disabled).Return(disabled, nil)
+	off := false
+	_, err = svc.SetPreference(ctx, "u1", model.ChannelEmail, model.NotificationPreferenceRequest{Enabled: &off})
+	require.NoError(t, err)
+	notifications.AssertExpectations(t)
+}
+
+func newNotificationDispatcher(notifications *MockNotifications, sender service.NotificationSender) (*service.NotificationDispatcher, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2025, 1, 6, 10, 0, 0, 0, time.UTC)}
+	return &service.NotificationDispatcher{
+		Repo:        notifications,
+		Senders:     map[string]service.NotificationSender{model.ChannelEmail: sender},
+		MaxAttempts: 3,
+		Backoff:     time.Minute,
+		MaxBackoff:  90 * time.Second,
+		Now:         clock.Now,
+	}, clock
+}
+
+func emailNotification(id string, attempts int) model.Notification {
+	return model.Notification{ID: id, UserID: "u1", Channel: model.ChannelEmail, Kind: model.NotificationRewardCreated,
+		Address: "u1@example.com", Body: "hi", DedupKey: "k1", Status: model.NotificationPending, Attempts: attempts}
+}
+
+func TestNotificationDispatcher_RetriesWithBackoffThenGivesUp(t *testing.T) {
+	notifications := &MockNotifications{}
+	sender := &recordingSender{err: errors.New("connection refused")}
+	dispatcher, clock := newNotificationDispatcher(notifications, sender)
+	ctx := context.Background()
+
+	notifications.claims(emailNotification("n1", 1))
+	retryAt := clock.Now().Add(time.Minute)
+	notifications.On("MarkNotificationFailed", mock.Anything, "n1", "connection refused", &retryAt).Return(nil).Once()
+	n, err := dispatcher.Dispatch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	notifications.AssertCalled(t, "ClaimDueNotifications", mock.Anything, clock.Now(), 100*30*time.Second, 100)
+
+	clock.Advance(time.Minute)
+	notifications.claims(emailNotification("n1", 2))
+	capped := clock.Now().Add(90 * time.Second)
+	notifications.On("MarkNotificationFailed", mock.Anything, "n1", "connection refused", &capped).Return(nil).Once()
+	dispatcher.Dispatch(ctx)
+
+	clock.Advance(90 * time.Second)
+	notifications.claims(emailNotification("n1", 3))
+	notifications.On("MarkNotificationFailed", mock.Anything, "n1", "connection refused", (*time.Time)(nil)).Return(nil).Once()
+	dispatcher.Dispatch(ctx)
+	notifications.AssertExpectations(t)
+}
+
+func TestNotificationDispatcher_SendsAndSkipsUndeliverable(t *testing.T) {
+	notifications := &MockNotifications{}
+	sender := &recordingSender{}
+	dispatcher, clock := newNotificationDispatcher(notifications, sender)
+	sms := emailNotification("n2", 1)
+	sms.Channel = model.ChannelSMS
+	notifications.claims(emailNotification("n1", 1), sms)
+	notifications.On("MarkNotificationSent", mock.Anything, "n1", clock.Now()).Return(nil).Once()
+	// A channel without a sender is not retried
+	notifications.On("MarkNotificationFailed", mock.Anything, "n2", mock.Anything, (*time.Time)(nil)).Return(nil).Once()
+
+	n, err := dispatcher.Dispatch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	require.Len(t, sender.sent, 1)
+	assert.Equal(t, "n1", sender.sent[0].ID)
+
+	sender.err = model.ErrUndeliverable
+	notifications.claims(emailNotification("n3", 1))
+	notifications.On("MarkNotificationFailed", mock.Anything, "n3", model.ErrUndeliverable.Error(), (*time.Time)(nil)).Return(nil).Once()
+	dispatcher.Dispatch(context.Background())
+	notifications.AssertExpectations(t)
+}
+
+// smtpSink is a minimal SMTP server that keeps the mail it accepts and
+// rejects recipients starting with "nobody".
+type smtpSink struct {
+	ln   net.Listener
+	mail chan string
+}
+
+func newSMTPSink(t *testing.T) *smtpSink {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	sink := &smtpSink{ln: ln, mail: make(chan string, 10)}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go sink.serve(conn)
+		}
+	}()
+	return sink
+}
+
+func (s *smtpSink) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+	reply("220 sink ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 sink")
+		case strings.HasPrefix(cmd, "RCPT TO:<NOBODY"):
+			reply("550 no such user")
+		case strings.HasPrefix(cmd, "DATA"):
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			s.mail <- data.String()
+			reply("250 queued")
+		case strings.HasPrefix(cmd, "QUIT"):
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func TestSMTPNotificationSender_DeliversToSink(t *testing.T) {
+	sink := newSMTPSink(t)
+	sender := &infra.SMTPNotificationSender{Addr: sink.ln.Addr().String(), From: "Stocky <alerts@stocky.test>"}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	require.NoError(t, sender.Send(ctx, model.Notification{
+		ID: "n1", Address: "u1@example.com", Subject: "आपको TCS के 10 शेयर मिले", Body: "नमस्ते,\nline two",
+	}))
+	msg, err := mail.ReadMessage(strings.NewReader(<-sink.mail))
+	require.NoError(t, err)
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "आपको TCS के 10 शेयर मिले", subject)
+	assert.Equal(t, "u1@example.com", msg.Header.Get("To"))
+	assert.Equal(t, "Stocky <alerts@stocky.test>", msg.Header.Get("From"))
+	assert.Equal(t, "<n1@stocky>", msg.Header.Get("Message-ID"))
+	body, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	require.NoError(t, err)
+	assert.Equal(t, "नमस्ते,\r\nline two\r\n", string(body))
+
+	err = sender.Send(ctx, model.Notification{Address: "nobody@example.com", Body: "hi"})
+	assert.ErrorIs(t, err, model.ErrUndeliverable)
+}
+
+func TestHTTPNotificationSenders_ClassifyGatewayResponses(t *testing.T) {
+	var mu sync.Mutex
+	var got map[string]interface{}
+	var headers http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		headers = r.Header.Clone()
+		json.NewDecoder(r.Body).Decode(&got)
+		switch r.URL.Path {
+		case "/ok":
+			w.WriteHeader(http.StatusAccepted)
+		case "/busy":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+	n := model.Notification{ID: "n1", Kind: model.NotificationDividend, Address: "+919876543210", Subject: "Dividend", Body: "INR 125.00"}
+	ctx := context.Background()
+
+	sms := &infra.SMSGatewaySender{URL: srv.URL + "/ok", APIKey: "key", SenderID: "STOCKY", Client: srv.Client()}
+	require.NoError(t, sms.Send(ctx, n))
+	assert.Equal(t, map[string]interface{}{"to": "+919876543210", "from": "STOCKY", "message": "INR 125.00"}, got)
+	assert.Equal(t, "Bearer key", headers.Get("Authorization"))
+	assert.Equal(t, "n1", headers.Get("Idempotency-Key"))
+
+	sms.URL = srv.URL + "/busy"
+	err := sms.Send(ctx, n)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, model.ErrUndeliverable, "5xx is retried")
+	sms.URL = srv.URL + "/bad"
+	assert.ErrorIs(t, sms.Send(ctx, n), model.ErrUndeliverable)
+
+	push := &infra.PushGatewaySender{URL: srv.URL + "/ok", Client: srv.Client()}
+	require.NoError(t, push.Send(ctx, n))
+	assert.Equal(t, "Dividend", got["title"])
+	assert.Equal(t, map[string]interface{}{"kind": "dividend", "notification_id": "n1"}, got["data"])
+}
+
+func TestNotificationHandler_ScopesPreferencesToCaller(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	rg := r.Group("/api/v1", func(c *gin.Context) {
+		c.Set("user_id", c.GetHeader("X-Test-User"))
+		c.Next()
+	})
+	notifications := &MockNotifications{}
+	(&api.NotificationHandler{Service: newNotificationService(t, notifications, nil)}).RegisterRoutes(rg)
+	do := func(method, path, user, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Test-User", user)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	stored := preference("u1", model.ChannelEmail, "u1@example.com", "hi")
+	notifications.On("ListPreferences", mock.Anything, "u1").Return([]model.NotificationPreference{stored}, nil)
+	notifications.On("UpsertPreference", mock.Anything, stored).Return(stored, nil)
+	notifications.On("DeletePreference", mock.Anything, "u1", model.ChannelEmail).Return(nil).Once()
+	notifications.On("DeletePreference", mock.Anything, "u1", model.ChannelEmail).Return(repo.ErrNotFound).Once()
+	notifications.On("ListNotifications", mock.Anything, "u1", 50).Return([]model.Notification{}, nil)
+
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/api/v1/notifications/u1/preferences", "u2", "").Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodPut, "/api/v1/notifications/u1/preferences/email", "u1", `{"address":"u1@example.com","locale":"hi"}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, do(http.MethodPut, "/api/v1/notifications/u1/preferences/sms", "u1", `{"address":"12345"}`).Code)
+	list := do(http.MethodGet, "/api/v1/notifications/u1/preferences", "u1", "")
+	assert.Equal(t, http.StatusOK, list.Code)
+	assert.Contains(t, list.Body.String(), `"locale":"hi"`)
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/api/v1/notifications/u1/preferences/email", "u1", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/api/v1/notifications/u1/preferences/email", "u1", "").Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/v1/notifications/u1/history", "u1", "").Code)
+	notifications.AssertExpectations(t)
+}
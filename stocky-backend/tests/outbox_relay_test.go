@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/events"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/mhatrejeets/stocky-ms/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockPublisher struct {
+	mock.Mock
+}
+
+func (m *MockPublisher) Publish(ctx context.Context, msg events.Message) error {
+	return m.Called(ctx, msg).Error(0)
+}
+
+func (m *MockPublisher) Close() error { return nil }
+
+type MockOutbox struct {
+	mock.Mock
+}
+
+func (m *MockOutbox) ClaimDueEvents(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]repo.OutboxEvent, error) {
+	args := m.Called(ctx, now, lease, limit)
+	due, _ := args.Get(0).([]repo.OutboxEvent)
+	return due, args.Error(1)
+}
+
+func (m *MockOutbox) MarkEventPublished(ctx context.Context, id int64, at time.Time) error {
+	return m.Called(ctx, id, at).Error(0)
+}
+
+func (m *MockOutbox) MarkEventFailed(ctx context.Context, id int64, publishErr string, retryAt time.Time) error {
+	return m.Called(ctx, id, publishErr, retryAt).Error(0)
+}
+
+func (m *MockOutbox) DeletePublishedEvents(ctx context.Context, before time.Time, limit int) (int, error) {
+	args := m.Called(ctx, before, limit)
+	return args.Int(0), args.Error(1)
+}
+
+func TestOutboxRelay_PublishesAndBacksOff(t *testing.T) {
+	now := time.Date(2025, 9, 25, 6, 0, 0, 0, time.UTC)
+	created := events.Message{Topic: events.TopicRewardEvents, Key: "u1", Type: events.TypeRewardCreated, Value: []byte(`{}`)}
+	reversed := events.Message{Topic: events.TopicRewardEvents, Key: "u1", Type: events.TypeRewardReversed, Value: []byte(`{}`)}
+	outbox := new(MockOutbox)
+	outbox.On("ClaimDueEvents", mock.Anything, now, mock.Anything, 100).Return([]repo.OutboxEvent{
+		{ID: 1, Message: created, Attempts: 1},
+		{ID: 2, Message: reversed, Attempts: 3},
+	}, nil)
+	outbox.On("MarkEventPublished", mock.Anything, int64(1), now).Return(nil).Once()
+	// The third attempt failed: 5s doubled twice
+	outbox.On("MarkEventFailed", mock.Anything, int64(2), "broker down", now.Add(20*time.Second)).Return(nil).Once()
+	publisher := new(MockPublisher)
+	publisher.On("Publish", mock.Anything, created).Return(nil).Once()
+	publisher.On("Publish", mock.Anything, reversed).Return(errors.New("broker down")).Once()
+
+	relay := &service.OutboxRelay{Repo: outbox, Events: publisher, Now: func() time.Time { return now }}
+	claimed, err := relay.Relay(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, claimed)
+	outbox.AssertExpectations(t)
+	publisher.AssertExpectations(t)
+}
+
+func TestOutboxRelay_ClaimFailure(t *testing.T) {
+	outbox := new(MockOutbox)
+	outbox.On("ClaimDueEvents", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("db down"))
+	publisher := new(MockPublisher)
+
+	relay := &service.OutboxRelay{Repo: outbox, Events: publisher}
+	_, err := relay.Relay(context.Background())
+	assert.Error(t, err)
+	publisher.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything)
+}
+
+func TestOutboxPruner_DeletesInBatchesPastRetention(t *testing.T) {
+	now := time.Date(2025, 9, 25, 6, 0, 0, 0, time.UTC)
+	before := now.Add(-48 * time.Hour)
+	outbox := new(MockOutbox)
+	outbox.On("DeletePublishedEvents", mock.Anything, before, 10).Return(10, nil).Twice()
+	outbox.On("DeletePublishedEvents", mock.Anything, before, 10).Return(3, nil).Once()
+
+	pruner := &service.OutboxPruner{Repo: outbox, Retention: 48 * time.Hour, BatchSize: 10, Now: func() time.Time { return now }}
+	deleted, err := pruner.Prune(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 23, deleted)
+	outbox.AssertExpectations(t)
+
+	outbox = new(MockOutbox)
+	outbox.On("DeletePublishedEvents", mock.Anything, mock.Anything, 1000).Return(0, errors.New("db down")).Once()
+	_, err = (&service.OutboxPruner{Repo: outbox}).Prune(context.Background())
+	assert.Error(t, err)
+}
@@ -0,0 +1,89 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/events"
+	"github.com/mhatrejeets/stocky-ms/internal/infra"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/mhatrejeets/stocky-ms/internal/service"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockHoldings struct {
+	mock.Mock
+}
+
+func (m *MockHoldings) ApplyRewardCreated(ctx context.Context, reward model.Reward) (bool, error) {
+	args := m.Called(ctx, reward)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockHoldings) ApplyRewardReversed(ctx context.Context, rewardID string) (bool, error) {
+	args := m.Called(ctx, rewardID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockHoldings) ApplyCorporateAction(ctx context.Context, key string, action model.CorporateAction) (bool, error) {
+	args := m.Called(ctx, key, action)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockHoldings) ListHoldings(ctx context.Context, userID string) (map[string]decimal.Decimal, error) {
+	args := m.Called(ctx, userID)
+	holdings, _ := args.Get(0).(map[string]decimal.Decimal)
+	return holdings, args.Error(1)
+}
+
+func (m *MockHoldings) Rebuild(ctx context.Context) error {
+	return m.Called(ctx).Error(0)
+}
+
+var _ repo.HoldingsRepository = (*MockHoldings)(nil)
+
+func TestPortfolioProjector_AppliesEvents(t *testing.T) {
+	holdings := new(MockHoldings)
+	projector := &service.PortfolioProjector{Holdings: holdings}
+	registry := events.NewRegistry()
+	projector.Register(registry)
+	publisher := &events.DispatchingPublisher{Publisher: infra.NewMemoryBus(), Registry: registry}
+	ctx := context.Background()
+	effective := time.Date(2025, 10, 1, 3, 45, 0, 0, time.UTC)
+
+	holdings.On("ApplyRewardCreated", mock.Anything, model.Reward{
+		ID: "r1", UserID: "u1", StockSymbol: "TCS", Shares: decimal.NewFromInt(10), RewardedAt: time.Date(2025, 9, 25, 11, 30, 0, 0, time.UTC),
+	}).Return(true, nil).Once()
+	holdings.On("ApplyCorporateAction", mock.Anything, repo.CorporateActionKey("a1"), mock.MatchedBy(func(a model.CorporateAction) bool {
+		return a.ID == "a1" && a.Symbol == "TCS" && a.Ratio.Equal(decimal.NewFromInt(2)) && a.EffectiveAt.Equal(effective)
+	})).Return(true, nil).Once()
+	// The reversal is applied by reward id, whatever share count it carries
+	holdings.On("ApplyRewardReversed", mock.Anything, "r1").Return(true, nil).Once()
+
+	created, err := testEncoder(t).RewardCreatedMessage(model.RewardCreatedEvent{RewardID: "r1", UserID: "u1", StockSymbol: "TCS", Shares: "10", RewardedAt: "2025-09-25T11:30:00Z"})
+	require.NoError(t, err)
+	require.NoError(t, publisher.Publish(ctx, created))
+	split, err := testEncoder(t).CorporateActionMessage(model.CorporateActionEvent{ActionID: "a1", Symbol: "TCS", ActionType: "split", Ratio: "2", EffectiveAt: effective.Format(time.RFC3339)})
+	require.NoError(t, err)
+	require.NoError(t, publisher.Publish(ctx, split))
+	reversed, err := testEncoder(t).RewardReversedMessage(model.RewardReversedEvent{RewardID: "r1", UserID: "u1", StockSymbol: "TCS", Shares: "20", ReversedAt: time.Now().UTC().Format(time.RFC3339)})
+	require.NoError(t, err)
+	require.NoError(t, publisher.Publish(ctx, reversed))
+
+	holdings.AssertExpectations(t)
+}
+
+func TestPortfolioProjector_RejectsMalformedReward(t *testing.T) {
+	holdings := new(MockHoldings)
+	projector := &service.PortfolioProjector{Holdings: holdings}
+	msg, err := testEncoder(t).RewardCreatedMessage(model.RewardCreatedEvent{RewardID: "r1", UserID: "u1", StockSymbol: "TCS", Shares: "10", RewardedAt: "yesterday"})
+	require.NoError(t, err)
+
+	assert.Error(t, projector.OnRewardCreated(context.Background(), msg))
+	holdings.AssertNotCalled(t, "ApplyRewardCreated", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,282 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/mhatrejeets/stocky-ms/internal/api"
+	"github.com/mhatrejeets/stocky-ms/internal/events"
+	"github.com/mhatrejeets/stocky-ms/internal/infra"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/service"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// countingPortfolios values every user as holding symbols, with a total
+// that counts the lookups.
+type countingPortfolios struct {
+	symbols []string
+	calls   atomic.Int64
+}
+
+func (p *countingPortfolios) GetPortfolio(ctx context.Context, userID string) (model.Portfolio, error) {
+	n := p.calls.Add(1)
+	portfolio := model.Portfolio{PortfolioTotalINR: decimal.NewFromInt(n)}
+	for _, symbol := range p.symbols {
+		portfolio.Holdings = append(portfolio.Holdings, model.Holding{Symbol: symbol})
+	}
+	return portfolio, nil
+}
+
+// serveStream runs a stream in the background and returns its snapshots.
+func serveStream(t *testing.T, streams *service.PortfolioStreams, userID string) (<-chan model.Portfolio, context.CancelFunc) {
+	stream, err := streams.Open(userID)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	out := make(chan model.Portfolio, 16)
+	go func() {
+		defer stream.Close()
+		_ = stream.Serve(ctx, func(p model.Portfolio) error {
+			out <- p
+			return nil
+		}, func() error { return nil })
+	}()
+	t.Cleanup(cancel)
+	return out, cancel
+}
+
+func nextSnapshot(t *testing.T, ch <-chan model.Portfolio) model.Portfolio {
+	t.Helper()
+	select {
+	case p := <-ch:
+		return p
+	case <-time.After(time.Second):
+		t.Fatal("no snapshot")
+		return model.Portfolio{}
+	}
+}
+
+func assertNoSnapshot(t *testing.T, ch <-chan model.Portfolio) {
+	t.Helper()
+	select {
+	case p := <-ch:
+		t.Fatalf("unexpected snapshot %v", p.PortfolioTotalINR)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestPortfolioStreams_WakesOnRelevantChanges(t *testing.T) {
+	streams := &service.PortfolioStreams{Portfolios: &countingPortfolios{symbols: []string{"TCS"}}}
+	snapshots, _ := serveStream(t, streams, "u1")
+	nextSnapshot(t, snapshots)
+
+	streams.OnPrice(model.Quote{Symbol: "INFY"})
+	streams.OnChange(model.PortfolioChange{UserID: "u2", Symbol: "TCS"})
+	assertNoSnapshot(t, snapshots)
+
+	streams.OnPrice(model.Quote{Symbol: "TCS"})
+	nextSnapshot(t, snapshots)
+	streams.OnChange(model.PortfolioChange{UserID: "u1", Symbol: "WIPRO"})
+	nextSnapshot(t, snapshots)
+	streams.OnChange(model.PortfolioChange{Symbol: "TCS"})
+	nextSnapshot(t, snapshots)
+}
+
+func TestPortfolioStreams_CoalescesBursts(t *testing.T) {
+	portfolios := &countingPortfolios{symbols: []string{"TCS"}}
+	streams := &service.PortfolioStreams{Portfolios: portfolios, MinInterval: 100 * time.Millisecond}
+	snapshots, _ := serveStream(t, streams, "u1")
+	nextSnapshot(t, snapshots)
+
+	for i := 0; i < 50; i++ {
+		streams.OnPrice(model.Quote{Symbol: "TCS"})
+	}
+	latest := nextSnapshot(t, snapshots)
+	assertNoSnapshot(t, snapshots)
+	assert.EqualValues(t, 2, portfolios.calls.Load())
+	assert.True(t, decimal.NewFromInt(2).Equal(latest.PortfolioTotalINR))
+}
+
+func TestPortfolioStreams_LimitsStreamsPerUser(t *testing.T) {
+	streams := &service.PortfolioStreams{Portfolios: &countingPortfolios{}, MaxPerUser: 1}
+	first, err := streams.Open("u1")
+	require.NoError(t, err)
+	_, err = streams.Open("u1")
+	assert.ErrorIs(t, err, service.ErrTooManyStreams)
+	_, err = streams.Open("u2")
+	assert.NoError(t, err)
+
+	first.Close()
+	first.Close()
+	_, err = streams.Open("u1")
+	assert.NoError(t, err)
+}
+
+// recordingPortfolioNotifier collects announced changes.
+type recordingPortfolioNotifier struct {
+	mu      sync.Mutex
+	changes []model.PortfolioChange
+}
+
+func (r *recordingPortfolioNotifier) NotifyPortfolio(ctx context.Context, change model.PortfolioChange) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.changes = append(r.changes, change)
+	return nil
+}
+
+func TestPortfolioProjector_AnnouncesAppliedChanges(t *testing.T) {
+	notifier := &recordingPortfolioNotifier{}
+	holdings := new(MockHoldings)
+	holdings.On("ApplyRewardCreated", mock.Anything, mock.Anything).Return(true, nil).Once()
+	holdings.On("ApplyRewardCreated", mock.Anything, mock.Anything).Return(false, nil)
+	holdings.On("ApplyCorporateAction", mock.Anything, mock.Anything, mock.Anything).Return(true, nil)
+	projector := &service.PortfolioProjector{Holdings: holdings, Notifier: notifier}
+	registry := events.NewRegistry()
+	projector.Register(registry)
+	publisher := &events.DispatchingPublisher{Publisher: infra.NewMemoryBus(), Registry: registry}
+	ctx := context.Background()
+
+	created, err := testEncoder(t).RewardCreatedMessage(model.RewardCreatedEvent{RewardID: "r1", UserID: "u1", StockSymbol: "TCS", Shares: "10", RewardedAt: "2025-09-25T11:30:00Z"})
+	require.NoError(t, err)
+	require.NoError(t, publisher.Publish(ctx, created))
+	require.NoError(t, publisher.Publish(ctx, created)) // redelivery is not announced again
+	split, err := testEncoder(t).CorporateActionMessage(model.CorporateActionEvent{ActionID: "a1", Symbol: "TCS", ActionType: "split", Ratio: "2", EffectiveAt: time.Now().UTC().Format(time.RFC3339)})
+	require.NoError(t, err)
+	require.NoError(t, publisher.Publish(ctx, split))
+
+	assert.Equal(t, []model.PortfolioChange{{UserID: "u1", Symbol: "TCS"}, {Symbol: "TCS"}}, notifier.changes)
+}
+
+func newStreamRouter(streams *service.PortfolioStreams) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	rg := r.Group("/api/v1", func(c *gin.Context) {
+		c.Set("user_id", c.GetHeader("X-Test-User"))
+		c.Next()
+	})
+	(&api.PortfolioStreamHandler{Streams: streams}).RegisterRoutes(rg)
+	return r
+}
+
+func newStreamServer(t *testing.T, streams *service.PortfolioStreams) *httptest.Server {
+	srv := httptest.NewServer(newStreamRouter(streams))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestPortfolioStreamHandler_ServerSentEvents(t *testing.T) {
+	streams := &service.PortfolioStreams{Portfolios: &countingPortfolios{symbols: []string{"TCS"}}}
+	srv := newStreamServer(t, streams)
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/v1/portfolio/u1/stream", nil)
+	req.Header.Set("X-Test-User", "u2")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/v1/portfolio/u1/stream", nil)
+	req.Header.Set("X-Test-User", "u1")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(resp.Body)
+	readEvent := func() model.Portfolio {
+		var portfolio model.Portfolio
+		for {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				require.NoError(t, json.Unmarshal([]byte(data), &portfolio))
+				return portfolio
+			}
+		}
+	}
+	assert.Equal(t, "TCS", readEvent().Holdings[0].Symbol)
+	streams.OnPrice(model.Quote{Symbol: "TCS"})
+	assert.True(t, decimal.NewFromInt(2).Equal(readEvent().PortfolioTotalINR))
+}
+
+func TestPortfolioStreamHandler_WebSocket(t *testing.T) {
+	streams := &service.PortfolioStreams{Portfolios: &countingPortfolios{symbols: []string{"TCS"}}, MaxPerUser: 1}
+	srv := newStreamServer(t, streams)
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/v1/portfolio/u1/stream"
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"X-Test-User": {"u1"}})
+	require.NoError(t, err)
+	defer conn.Close()
+	var msg struct {
+		Type      string          `json:"type"`
+		Portfolio model.Portfolio `json:"portfolio"`
+	}
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, "portfolio", msg.Type)
+	assert.Equal(t, "TCS", msg.Portfolio.Holdings[0].Symbol)
+
+	// A second stream for the same user is refused before upgrading
+	_, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"X-Test-User": {"u1"}})
+	assert.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+
+	streams.OnChange(model.PortfolioChange{UserID: "u1"})
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.True(t, decimal.NewFromInt(2).Equal(msg.Portfolio.PortfolioTotalINR))
+}
+
+func TestPortfolioStreamHandler_ShutdownEndsStreams(t *testing.T) {
+	streams := &service.PortfolioStreams{Portfolios: &countingPortfolios{symbols: []string{"TCS"}}}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &http.Server{Handler: newStreamRouter(streams)}
+	srv.RegisterOnShutdown(streams.Shutdown)
+	go srv.Serve(listener)
+	base := "http://" + listener.Addr().String() + "/api/v1/portfolio/u1/stream"
+
+	req, _ := http.NewRequest(http.MethodGet, base, nil)
+	req.Header.Set("X-Test-User", "u1")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	_, err = reader.ReadString('\n')
+	require.NoError(t, err)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(base, "http"), http.Header{"X-Test-User": {"u1"}})
+	require.NoError(t, err)
+	defer conn.Close()
+	var msg map[string]any
+	require.NoError(t, conn.ReadJSON(&msg))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, srv.Shutdown(ctx), "streams must not hold up shutdown")
+
+	_, err = io.ReadAll(reader)
+	assert.NoError(t, err, "the event stream ends cleanly")
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "got %v", err)
+	_, err = streams.Open("u1")
+	assert.ErrorIs(t, err, service.ErrStreamsClosed)
+}
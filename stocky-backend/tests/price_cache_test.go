@@ -0,0 +1,250 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/infra"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowPrices serves price for every symbol, optionally holding each call
+// until gate is closed, and counts calls. Safe for concurrent use.
+type slowPrices struct {
+	gate     chan struct{}
+	degraded bool
+	calls    atomic.Int32
+
+	mu    sync.Mutex
+	price decimal.Decimal
+}
+
+func (s *slowPrices) setPrice(p int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.price = decimal.NewFromInt(p)
+}
+
+func (s *slowPrices) GetPrice(ctx context.Context, symbol string) (decimal.Decimal, time.Time, error) {
+	return decimal.Zero, time.Time{}, errors.New("not used")
+}
+
+func (s *slowPrices) GetPrices(ctx context.Context, symbols []string) (map[string]model.Quote, error) {
+	s.calls.Add(1)
+	if s.gate != nil {
+		<-s.gate
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]model.Quote)
+	for _, symbol := range symbols {
+		out[symbol] = model.Quote{Symbol: symbol, Price: s.price, AsOf: time.Now(), Degraded: s.degraded}
+	}
+	return out, nil
+}
+
+// memoryQuoteStore is an in-memory QuoteStore; bad symbols decode as malformed.
+type memoryQuoteStore struct {
+	mu      sync.Mutex
+	entries map[string]infra.CachedQuote
+	bad     map[string]bool
+}
+
+func (m *memoryQuoteStore) GetQuotes(ctx context.Context, symbols []string) (map[string]infra.CachedQuote, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]infra.CachedQuote)
+	var errs []error
+	for _, symbol := range symbols {
+		if m.bad[symbol] {
+			errs = append(errs, fmt.Errorf("%s: %w", symbol, infra.ErrMalformedCachedPrice))
+			continue
+		}
+		if e, ok := m.entries[symbol]; ok {
+			out[symbol] = e
+		}
+	}
+	return out, errors.Join(errs...)
+}
+
+func (m *memoryQuoteStore) SetQuotes(ctx context.Context, entries []infra.CachedQuote, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.entries == nil {
+		m.entries = make(map[string]infra.CachedQuote)
+	}
+	for _, e := range entries {
+		m.entries[e.Quote.Symbol] = e
+		delete(m.bad, e.Quote.Symbol)
+	}
+	return nil
+}
+
+func (m *memoryQuoteStore) get(symbol string) (infra.CachedQuote, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[symbol]
+	return e, ok
+}
+
+// fakeClock is a settable clock for cache freshness.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newPriceCache(provider infra.PriceProvider, shared infra.QuoteStore, size int) (*infra.CachedPriceProvider, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2025, 1, 6, 10, 0, 0, 0, time.UTC)}
+	c := infra.NewCachedPriceProvider(provider, shared, size, time.Minute, 10*time.Minute)
+	c.Now = clock.Now
+	return c, clock
+}
+
+func TestCachedPriceProvider_CoalescesConcurrentMisses(t *testing.T) {
+	provider := &slowPrices{gate: make(chan struct{}), price: decimal.NewFromInt(100)}
+	cache, _ := newPriceCache(provider, nil, 10)
+
+	var wg sync.WaitGroup
+	results := make([]decimal.Decimal, 20)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p, _, err := cache.GetPrice(context.Background(), "TCS")
+			assert.NoError(t, err)
+			results[i] = p
+		}(i)
+	}
+	require.Eventually(t, func() bool { return provider.calls.Load() == 1 }, time.Second, time.Millisecond)
+	close(provider.gate)
+	wg.Wait()
+
+	assert.EqualValues(t, 1, provider.calls.Load())
+	for _, p := range results {
+		assert.True(t, decimal.NewFromInt(100).Equal(p))
+	}
+}
+
+func TestCachedPriceProvider_ServesStaleWhileRefreshing(t *testing.T) {
+	provider := &slowPrices{price: decimal.NewFromInt(100)}
+	shared := &memoryQuoteStore{}
+	cache, clock := newPriceCache(provider, shared, 10)
+	ctx := context.Background()
+
+	_, err := cache.GetPrices(ctx, []string{"TCS"})
+	require.NoError(t, err)
+	_, ok := shared.get("TCS")
+	assert.True(t, ok, "provider results are written to the shared layer")
+
+	// Fresh: no provider call
+	_, err = cache.GetPrices(ctx, []string{"TCS"})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, provider.calls.Load())
+
+	// Stale: the old price comes back at once and a refresh runs behind it
+	provider.setPrice(110)
+	clock.Advance(2 * time.Minute)
+	quotes, err := cache.GetPrices(ctx, []string{"TCS"})
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(100).Equal(quotes["TCS"].Price))
+	require.Eventually(t, func() bool {
+		quotes, err := cache.GetPrices(ctx, []string{"TCS"})
+		return err == nil && decimal.NewFromInt(110).Equal(quotes["TCS"].Price)
+	}, time.Second, time.Millisecond)
+	assert.EqualValues(t, 2, provider.calls.Load())
+
+	// Past MaxStale the entry is reloaded before answering
+	provider.setPrice(120)
+	clock.Advance(time.Hour)
+	quotes, err = cache.GetPrices(ctx, []string{"TCS"})
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(120).Equal(quotes["TCS"].Price))
+}
+
+func TestCachedPriceProvider_ReadsSharedLayerBeforeProvider(t *testing.T) {
+	provider := &slowPrices{price: decimal.NewFromInt(100)}
+	shared := &memoryQuoteStore{bad: map[string]bool{"INFY": true}}
+	cache, clock := newPriceCache(provider, shared, 10)
+	require.NoError(t, shared.SetQuotes(context.Background(), []infra.CachedQuote{
+		{Quote: model.Quote{Symbol: "TCS", Price: decimal.NewFromInt(42)}, FetchedAt: clock.Now()},
+	}, time.Hour))
+
+	quotes, err := cache.GetPrices(context.Background(), []string{"TCS", "INFY"})
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(42).Equal(quotes["TCS"].Price))
+	// The malformed entry is reloaded from the provider and overwritten
+	assert.True(t, decimal.NewFromInt(100).Equal(quotes["INFY"].Price))
+	assert.EqualValues(t, 1, provider.calls.Load())
+	fixed, ok := shared.get("INFY")
+	require.True(t, ok)
+	assert.True(t, decimal.NewFromInt(100).Equal(fixed.Quote.Price))
+}
+
+func TestCachedPriceProvider_EvictsLeastRecentlyUsed(t *testing.T) {
+	provider := &slowPrices{price: decimal.NewFromInt(100)}
+	cache, _ := newPriceCache(provider, nil, 2)
+	ctx := context.Background()
+
+	for _, symbol := range []string{"TCS", "INFY", "TCS", "WIPRO"} {
+		_, err := cache.GetPrices(ctx, []string{symbol})
+		require.NoError(t, err)
+	}
+	assert.EqualValues(t, 3, provider.calls.Load())
+
+	// INFY was the least recently used and is fetched again
+	_, err := cache.GetPrices(ctx, []string{"TCS", "INFY"})
+	require.NoError(t, err)
+	assert.EqualValues(t, 4, provider.calls.Load())
+}
+
+func TestCachedPriceProvider_DoesNotCacheDegradedQuotes(t *testing.T) {
+	provider := &slowPrices{price: decimal.NewFromInt(100), degraded: true}
+	cache, _ := newPriceCache(provider, nil, 10)
+
+	for i := 0; i < 2; i++ {
+		quotes, err := cache.GetPrices(context.Background(), []string{"TCS"})
+		require.NoError(t, err)
+		assert.True(t, quotes["TCS"].Degraded)
+	}
+	assert.EqualValues(t, 2, provider.calls.Load())
+}
+
+func TestCachedPriceProvider_ObserveTakesNewerPrices(t *testing.T) {
+	provider := &slowPrices{price: decimal.NewFromInt(100)}
+	cache, _ := newPriceCache(provider, nil, 10)
+	ctx := context.Background()
+	quotes, err := cache.GetPrices(ctx, []string{"TCS"})
+	require.NoError(t, err)
+
+	cache.Observe(model.Quote{Symbol: "TCS", Price: decimal.NewFromInt(90), AsOf: quotes["TCS"].AsOf.Add(-time.Minute)})
+	cache.Observe(model.Quote{Symbol: "INFY", Price: decimal.NewFromInt(50), AsOf: time.Now()})
+	quotes, err = cache.GetPrices(ctx, []string{"TCS"})
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(100).Equal(quotes["TCS"].Price), "older announcements are ignored")
+
+	cache.Observe(model.Quote{Symbol: "TCS", Price: decimal.NewFromInt(105), AsOf: quotes["TCS"].AsOf.Add(time.Minute)})
+	quotes, err = cache.GetPrices(ctx, []string{"TCS"})
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(105).Equal(quotes["TCS"].Price))
+	assert.EqualValues(t, 1, provider.calls.Load())
+}
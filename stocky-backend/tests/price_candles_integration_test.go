@@ -0,0 +1,83 @@
+//go:build integration
+
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/market"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriceRepository_FoldsTicksIntoCandlesAndCloses(t *testing.T) {
+	db := newTestDB(t)
+	prices := &repo.PriceRepositoryImpl{DB: db, Location: market.NSE().Location}
+	ctx := context.Background()
+	base := ist(t, "2025-09-25 10:47")
+	tick := func(price, volume int64, at time.Time) bool {
+		applied, err := prices.ApplyTick(ctx, model.Quote{Symbol: "TCS", Price: decimal.NewFromInt(price), AsOf: at, Source: "tick"}, volume)
+		require.NoError(t, err)
+		return applied
+	}
+
+	assert.True(t, tick(4000, 10, base.Add(5*time.Second)))
+	assert.True(t, tick(4100, 5, base.Add(40*time.Second)))
+	assert.False(t, tick(3900, 7, base.Add(20*time.Second)), "older than the stored price")
+	assert.True(t, tick(3950, 3, base.Add(70*time.Second)))
+	assert.False(t, tick(3950, 3, base.Add(70*time.Second)), "redelivered")
+	// The updater's prices carry no volume but still count as ticks
+	_, err := prices.UpsertPrices(ctx, []model.Quote{{Symbol: "TCS", Price: decimal.NewFromInt(4020), AsOf: base.Add(90 * time.Second), Source: "http"}})
+	require.NoError(t, err)
+	// Past midnight IST, though still the 25th in UTC
+	assert.True(t, tick(4030, 1, ist(t, "2025-09-26 04:00")))
+
+	type ohlcv struct {
+		open, high, low, close string
+		volume                 int64
+		ticks                  int
+		lastAt                 time.Time
+	}
+	candles := func(interval string) map[time.Time]ohlcv {
+		list, err := prices.ListCandles(ctx, "TCS", interval, time.Time{}, time.Time{}, 10)
+		require.NoError(t, err)
+		out := make(map[time.Time]ohlcv, len(list))
+		for _, c := range list {
+			out[c.Start] = ohlcv{c.Open.String(), c.High.String(), c.Low.String(), c.Close.String(), c.Volume, c.Ticks, c.LastAt}
+		}
+		return out
+	}
+	utc := func(at time.Time) time.Time { return at.UTC() }
+
+	assert.Equal(t, map[time.Time]ohlcv{
+		utc(base):                       {"4000", "4100", "4000", "4100", 15, 2, utc(base.Add(40 * time.Second))},
+		utc(base.Add(time.Minute)):      {"3950", "4020", "3950", "4020", 3, 2, utc(base.Add(90 * time.Second))},
+		utc(ist(t, "2025-09-26 04:00")): {"4030", "4030", "4030", "4030", 1, 1, utc(ist(t, "2025-09-26 04:00"))},
+	}, candles(model.CandleMinute))
+	day := candles(model.CandleDay)
+	assert.Equal(t, ohlcv{"4000", "4100", "3950", "4020", 18, 4, utc(base.Add(90 * time.Second))}, day[utc(ist(t, "2025-09-25 00:00"))])
+	assert.Equal(t, ohlcv{"4030", "4030", "4030", "4030", 1, 1, utc(ist(t, "2025-09-26 04:00"))}, day[utc(ist(t, "2025-09-26 00:00"))])
+	assert.Equal(t, day[utc(ist(t, "2025-09-25 00:00"))], candles(model.CandleHour)[utc(ist(t, "2025-09-25 10:00"))])
+
+	var history int
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT count(*) FROM price_history WHERE symbol = 'TCS'`).Scan(&history))
+	assert.Equal(t, 5, history)
+
+	closes := map[string]string{}
+	rows, err := db.QueryContext(ctx, `SELECT to_char(trade_date, 'YYYY-MM-DD'), close FROM daily_closes WHERE symbol = 'TCS'`)
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var date string
+		var close decimal.Decimal
+		require.NoError(t, rows.Scan(&date, &close))
+		closes[date] = close.String()
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, map[string]string{"2025-09-25": "4020", "2025-09-26": "4030"}, closes)
+}
@@ -0,0 +1,228 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/mhatrejeets/stocky-ms/internal/service"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockQuarantine struct {
+	mock.Mock
+}
+
+func (m *MockQuarantine) QuarantinePrice(ctx context.Context, qp model.QuarantinedPrice) (string, error) {
+	args := m.Called(ctx, qp)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockQuarantine) ListQuarantinedPrices(ctx context.Context, status string, limit int) ([]model.QuarantinedPrice, error) {
+	args := m.Called(ctx, status, limit)
+	return args.Get(0).([]model.QuarantinedPrice), args.Error(1)
+}
+
+func (m *MockQuarantine) GetQuarantinedPrice(ctx context.Context, id string) (model.QuarantinedPrice, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(model.QuarantinedPrice), args.Error(1)
+}
+
+func (m *MockQuarantine) ResolveQuarantinedPrice(ctx context.Context, id, status, reviewer string) error {
+	return m.Called(ctx, id, status, reviewer).Error(0)
+}
+
+func (m *MockQuarantine) ApproveQuarantinedPrice(ctx context.Context, id, reviewer string) (model.QuarantinedPrice, bool, error) {
+	args := m.Called(ctx, id, reviewer)
+	return args.Get(0).(model.QuarantinedPrice), args.Bool(1), args.Error(2)
+}
+
+// quarantined returns the prices passed to QuarantinePrice, in order.
+func (m *MockQuarantine) quarantined() []model.QuarantinedPrice {
+	var prices []model.QuarantinedPrice
+	for _, call := range m.Calls {
+		if call.Method == "QuarantinePrice" {
+			prices = append(prices, call.Arguments.Get(1).(model.QuarantinedPrice))
+		}
+	}
+	return prices
+}
+
+// newPriceGuard checks against the last good prices in last.
+func newPriceGuard(t *testing.T, last map[string]model.Quote) (*service.PriceGuard, *MockQuarantine) {
+	bands, err := service.ParsePriceBands("20", []string{"tcs=10"})
+	assert.NoError(t, err)
+	quarantine := new(MockQuarantine)
+	quarantine.On("QuarantinePrice", mock.Anything, mock.Anything).Return("q-1", nil).Maybe()
+	return &service.PriceGuard{
+		Reference: quoteSourceFunc(func(symbols []string) (map[string]model.Quote, error) {
+			return last, nil
+		}),
+		Quarantine: quarantine,
+		Bands:      bands,
+	}, quarantine
+}
+
+func TestParsePriceBands(t *testing.T) {
+	bands, err := service.ParsePriceBands("20", []string{"TCS=10", " infy = 5 "})
+	assert.NoError(t, err)
+	assert.Equal(t, "10", bands.For("TCS").String())
+	assert.Equal(t, "5", bands.For("INFY").String())
+	assert.Equal(t, "20", bands.For("WIPRO").String())
+
+	for _, tc := range []struct {
+		def       string
+		overrides []string
+	}{{"x", nil}, {"-1", nil}, {"20", []string{"TCS"}}, {"20", []string{"TCS=ten"}}} {
+		_, err := service.ParsePriceBands(tc.def, tc.overrides)
+		assert.Error(t, err, "%v", tc)
+	}
+}
+
+func TestPriceGuard_QuarantinesOutliers(t *testing.T) {
+	at := time.Date(2025, 9, 25, 6, 0, 0, 0, time.UTC)
+	guard, quarantine := newPriceGuard(t, map[string]model.Quote{
+		"TCS":  {Symbol: "TCS", Price: decimal.NewFromInt(4000), AsOf: at},
+		"INFY": {Symbol: "INFY", Price: decimal.NewFromInt(1500), AsOf: at},
+	})
+	ctx := context.Background()
+	later := at.Add(time.Second)
+
+	check := func(symbol string, price int64, asOf time.Time) bool {
+		ok, err := guard.Check(ctx, model.Quote{Symbol: symbol, Price: decimal.NewFromInt(price), AsOf: asOf}, 0)
+		assert.NoError(t, err)
+		return ok
+	}
+	assert.True(t, check("TCS", 4400, later), "exactly on the 10% band")
+	assert.False(t, check("TCS", 4401, later))
+	assert.True(t, check("INFY", 1750, later), "within the 20% default")
+	assert.False(t, check("INFY", 150000, later), "100x the last good price")
+	assert.False(t, check("INFY", 0, later))
+	assert.True(t, check("WIPRO", 500, later), "no last good price to compare with")
+	assert.True(t, check("TCS", 1, at.Add(-time.Minute)), "older than the last good price; the store drops it")
+
+	held := quarantine.quarantined()
+	assert.Len(t, held, 3)
+	spike := held[1]
+	assert.Equal(t, model.QuarantineOutsideBand, spike.Reason)
+	assert.Equal(t, "1500", spike.PreviousPrice.String())
+	assert.Equal(t, "9900", spike.ChangePct.String())
+	assert.Equal(t, "20", spike.BandPct.String())
+	assert.Equal(t, model.QuarantineNonPositive, held[2].Reason)
+}
+
+func TestPriceTickIngester_QuarantinesSpikes(t *testing.T) {
+	now := time.Date(2025, 9, 25, 6, 0, 0, 0, time.UTC)
+	store := &MockPrices{}
+	store.On("ApplyTick", mock.Anything, mock.Anything, int64(100)).Return(true, nil)
+	ingester, cache, _ := newTickIngester(store, now)
+	ingester.Guard, _ = newPriceGuard(t, map[string]model.Quote{"TCS": {Symbol: "TCS", Price: decimal.NewFromInt(4000), AsOf: now.Add(-time.Minute)}})
+	ctx := context.Background()
+
+	assert.NoError(t, ingester.Handle(ctx, tickMessage(t, "TCS", "4000", now.Add(-3*time.Second))))
+	assert.NoError(t, ingester.Handle(ctx, tickMessage(t, "TCS", "400000", now.Add(-2*time.Second))))
+	assert.NoError(t, ingester.Handle(ctx, tickMessage(t, "TCS", "4010", now.Add(-time.Second))))
+
+	applied := store.quotes("ApplyTick")
+	if assert.Len(t, applied, 2) {
+		assert.Equal(t, "4010", applied[1].Price.String())
+	}
+	assert.Len(t, cache.quotes, 2)
+}
+
+func TestPriceUpdater_SkipsQuarantinedQuotes(t *testing.T) {
+	at := time.Now().Add(-time.Minute)
+	store := &MockPrices{}
+	store.recordsRun(2, model.PriceRunPartial)
+	store.On("UpsertPrices", mock.Anything, mock.Anything).Return(appliesAll, nil)
+	guard, quarantine := newPriceGuard(t, map[string]model.Quote{"TCS": {Symbol: "TCS", Price: decimal.NewFromInt(4000), AsOf: at}})
+	updater := &service.PriceUpdater{
+		Sources: []service.SymbolSource{staticSymbols("TCS", "INFY")},
+		Prices: quoteSourceFunc(func(symbols []string) (map[string]model.Quote, error) {
+			return map[string]model.Quote{
+				"TCS":  {Price: decimal.Zero, AsOf: time.Now()},
+				"INFY": {Price: decimal.NewFromInt(1500), AsOf: time.Now()},
+			}, nil
+		}),
+		Store: store,
+		Guard: guard,
+		Lock:  &fakeLock{leader: true},
+	}
+
+	run, err := updater.Tick(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, model.PriceRunPartial, run.Status)
+	upserted := store.quotes("UpsertPrices")
+	if assert.Len(t, upserted, 1) {
+		assert.Equal(t, "INFY", upserted[0].Symbol)
+	}
+	assert.Len(t, quarantine.quarantined(), 1)
+	store.AssertExpectations(t)
+}
+
+func TestPriceTickIngester_QuarantinesNonPositiveTicks(t *testing.T) {
+	now := time.Date(2025, 9, 25, 6, 0, 0, 0, time.UTC)
+	store := &MockPrices{}
+	ingester, _, _ := newTickIngester(store, now)
+	ctx := context.Background()
+
+	// Without a guard a zero price is invalid and dropped
+	assert.NoError(t, ingester.Handle(ctx, tickMessage(t, "TCS", "0", now.Add(-2*time.Second))))
+	var quarantine *MockQuarantine
+	ingester.Guard, quarantine = newPriceGuard(t, nil)
+	assert.NoError(t, ingester.Handle(ctx, tickMessage(t, "TCS", "-1", now.Add(-time.Second))))
+
+	store.AssertNotCalled(t, "ApplyTick", mock.Anything, mock.Anything, mock.Anything)
+	held := quarantine.quarantined()
+	if assert.Len(t, held, 1) {
+		assert.Equal(t, model.QuarantineNonPositive, held[0].Reason)
+		assert.Equal(t, "-1", held[0].Price.String())
+		assert.Equal(t, int64(100), held[0].Volume)
+	}
+}
+
+func TestPriceGuard_ApproveAndReject(t *testing.T) {
+	at := time.Date(2025, 9, 25, 6, 0, 0, 0, time.UTC)
+	guard, quarantine := newPriceGuard(t, nil)
+	notifier := &recordingQuotes{}
+	guard.Notifier = notifier
+	ctx := context.Background()
+	approved := model.QuarantinedPrice{ID: "q-1", Symbol: "TCS", Price: decimal.NewFromInt(2800), AsOf: at, Source: "http", Status: model.QuarantineApproved}
+
+	quarantine.On("ApproveQuarantinedPrice", ctx, "q-1", "admin-1").Return(approved, true, nil).Once()
+	applied, err := guard.Approve(ctx, "q-1", "admin-1")
+	assert.NoError(t, err)
+	assert.True(t, applied)
+	if assert.Len(t, notifier.quotes, 1) {
+		assert.Equal(t, "2800", notifier.quotes[0].Price.String())
+	}
+
+	// A newer price arrived meanwhile: approved but not applied or announced
+	quarantine.On("ApproveQuarantinedPrice", ctx, "q-2", "admin-1").Return(approved, false, nil)
+	applied, err = guard.Approve(ctx, "q-2", "admin-1")
+	assert.NoError(t, err)
+	assert.False(t, applied)
+	assert.Len(t, notifier.quotes, 1)
+
+	quarantine.On("ApproveQuarantinedPrice", ctx, mock.Anything, "admin-1").Return(model.QuarantinedPrice{}, false, repo.ErrNotFound)
+	quarantine.On("GetQuarantinedPrice", ctx, "q-1").Return(approved, nil)
+	_, err = guard.Approve(ctx, "q-1", "admin-1")
+	assert.ErrorIs(t, err, service.ErrQuarantineResolved)
+
+	quarantine.On("GetQuarantinedPrice", ctx, "q-3").Return(model.QuarantinedPrice{ID: "q-3", Symbol: "TCS", Status: model.QuarantinePending}, nil)
+	var invalid *service.ValidationError
+	_, err = guard.Approve(ctx, "q-3", "admin-1")
+	assert.True(t, errors.As(err, &invalid))
+	quarantine.On("ResolveQuarantinedPrice", ctx, "q-3", model.QuarantineRejected, "admin-1").Return(nil)
+	assert.NoError(t, guard.Reject(ctx, "q-3", "admin-1"))
+
+	quarantine.On("GetQuarantinedPrice", ctx, "missing").Return(model.QuarantinedPrice{}, repo.ErrNotFound)
+	_, err = guard.Approve(ctx, "missing", "admin-1")
+	assert.ErrorIs(t, err, repo.ErrNotFound)
+	quarantine.AssertExpectations(t)
+}
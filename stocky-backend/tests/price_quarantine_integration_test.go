@@ -0,0 +1,100 @@
+//go:build integration
+
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriceQuarantine_KeepsOnePendingEntryPerSymbol(t *testing.T) {
+	db := newTestDB(t)
+	quarantine := &repo.PriceQuarantineRepositoryImpl{DB: db, Prices: &repo.PriceRepositoryImpl{DB: db}}
+	ctx := context.Background()
+	at := time.Date(2025, 9, 25, 6, 0, 0, 0, time.UTC)
+	hold := func(symbol string, price int64, asOf time.Time, reason string) string {
+		id, err := quarantine.QuarantinePrice(ctx, model.QuarantinedPrice{
+			Symbol: symbol, Price: decimal.NewFromInt(price), AsOf: asOf, Source: "tick", Reason: reason, BandPct: decimal.NewFromInt(10),
+		})
+		require.NoError(t, err)
+		return id
+	}
+
+	first := hold("TCS", 400000, at, model.QuarantineOutsideBand)
+	assert.Equal(t, first, hold("TCS", 0, at.Add(2*time.Second), model.QuarantineNonPositive))
+	// Older than the entry: counted, but the newest price stays
+	assert.Equal(t, first, hold("TCS", 390000, at.Add(time.Second), model.QuarantineOutsideBand))
+	other := hold("INFY", 0, at, model.QuarantineNonPositive)
+	assert.NotEqual(t, first, other)
+
+	qp, err := quarantine.GetQuarantinedPrice(ctx, first)
+	require.NoError(t, err)
+	assert.Equal(t, 3, qp.Ticks)
+	assert.True(t, qp.Price.IsZero())
+	assert.Equal(t, model.QuarantineNonPositive, qp.Reason)
+	assert.True(t, at.Add(2*time.Second).Equal(qp.AsOf))
+
+	// A resolved entry no longer absorbs repeats
+	require.NoError(t, quarantine.ResolveQuarantinedPrice(ctx, first, model.QuarantineRejected, "admin-1"))
+	assert.NotEqual(t, first, hold("TCS", 410000, at.Add(3*time.Second), model.QuarantineOutsideBand))
+	pending, err := quarantine.ListQuarantinedPrices(ctx, model.QuarantinePending, 10)
+	require.NoError(t, err)
+	assert.Len(t, pending, 2)
+}
+
+func TestPriceQuarantine_ApproveAppliesThePriceInTheSameTransaction(t *testing.T) {
+	db := newTestDB(t)
+	prices := &repo.PriceRepositoryImpl{DB: db}
+	quarantine := &repo.PriceQuarantineRepositoryImpl{DB: db, Prices: prices}
+	ctx := context.Background()
+	at := time.Date(2025, 9, 25, 6, 0, 0, 0, time.UTC)
+	_, err := prices.ApplyTick(ctx, model.Quote{Symbol: "TCS", Price: decimal.NewFromInt(4000), AsOf: at}, 0)
+	require.NoError(t, err)
+	hold := func(price int64, asOf time.Time) string {
+		id, err := quarantine.QuarantinePrice(ctx, model.QuarantinedPrice{
+			Symbol: "TCS", Price: decimal.NewFromInt(price), AsOf: asOf, Volume: 25, Source: "tick", Reason: model.QuarantineOutsideBand, BandPct: decimal.NewFromInt(10),
+		})
+		require.NoError(t, err)
+		return id
+	}
+	current := func() (price decimal.Decimal) {
+		require.NoError(t, db.QueryRowContext(ctx, `SELECT price FROM stock_prices WHERE symbol = 'TCS'`).Scan(&price))
+		return price
+	}
+
+	id := hold(2800, at.Add(time.Minute))
+	qp, applied, err := quarantine.ApproveQuarantinedPrice(ctx, id, "admin-1")
+	require.NoError(t, err)
+	assert.True(t, applied)
+	assert.Equal(t, model.QuarantineApproved, qp.Status)
+	assert.Equal(t, "admin-1", qp.ReviewedBy)
+	assert.Equal(t, "2800", current().String())
+	var history int
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT count(*) FROM price_history WHERE symbol = 'TCS'`).Scan(&history))
+	assert.Equal(t, 2, history)
+
+	_, _, err = quarantine.ApproveQuarantinedPrice(ctx, id, "admin-2")
+	assert.ErrorIs(t, err, repo.ErrNotFound)
+
+	// Older than the current price: approved, current price unchanged
+	id = hold(2700, at.Add(30*time.Second))
+	_, applied, err = quarantine.ApproveQuarantinedPrice(ctx, id, "admin-1")
+	require.NoError(t, err)
+	assert.False(t, applied)
+	assert.Equal(t, "2800", current().String())
+
+	// Non-positive prices stay pending
+	id = hold(0, at.Add(2*time.Minute))
+	_, _, err = quarantine.ApproveQuarantinedPrice(ctx, id, "admin-1")
+	assert.ErrorIs(t, err, repo.ErrNotFound)
+	qp, err = quarantine.GetQuarantinedPrice(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, model.QuarantinePending, qp.Status)
+}
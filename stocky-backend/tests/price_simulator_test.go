@@ -0,0 +1,124 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/infra"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var simEpoch = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func newSimulator(clock infra.Clock) *infra.PriceSimulator {
+	return &infra.PriceSimulator{
+		Seed:       7,
+		Epoch:      simEpoch,
+		Step:       time.Minute,
+		Clock:      clock,
+		Symbols:    map[string]infra.SimSymbol{"TCS": {Start: 3500}},
+		Volatility: 0.3,
+	}
+}
+
+func TestPriceSimulator_IsDeterministic(t *testing.T) {
+	at := simEpoch.Add(36 * time.Hour)
+	a := newSimulator(nil)
+	b := newSimulator(nil)
+	// Query order and intermediate lookups must not change the path
+	b.QuoteAt("TCS", simEpoch.Add(time.Hour))
+	assert.True(t, a.QuoteAt("TCS", at).Price.Equal(b.QuoteAt("TCS", at).Price))
+	assert.True(t, a.QuoteAt("NEWCO", at).Price.Equal(b.QuoteAt("NEWCO", at).Price))
+
+	c := newSimulator(nil)
+	c.Seed = 8
+	assert.False(t, a.QuoteAt("TCS", at).Price.Equal(c.QuoteAt("TCS", at).Price))
+
+	start := a.QuoteAt("TCS", simEpoch)
+	assert.True(t, decimal.NewFromInt(3500).Equal(start.Price))
+	assert.Equal(t, infra.SourceSimulator, start.Source)
+}
+
+func TestPriceSimulator_FollowsVirtualClock(t *testing.T) {
+	clock := infra.NewVirtualClock(simEpoch)
+	sim := newSimulator(clock)
+	first, err := sim.GetPrices(context.Background(), []string{"TCS"})
+	require.NoError(t, err)
+
+	clock.Advance(30 * 24 * time.Hour)
+	later, err := sim.GetPrices(context.Background(), []string{"TCS"})
+	require.NoError(t, err)
+	assert.Equal(t, simEpoch.Add(30*24*time.Hour), later["TCS"].AsOf)
+	assert.False(t, first["TCS"].Price.Equal(later["TCS"].Price))
+	// A month at 30% annual volatility stays within a sane band
+	ratio, _ := later["TCS"].Price.Div(first["TCS"].Price).Float64()
+	assert.InDelta(t, 1, ratio, 0.5)
+}
+
+func TestPriceSimulator_GapsAndSpikes(t *testing.T) {
+	sim := newSimulator(nil)
+	gapFrom := simEpoch.Add(time.Hour)
+	sim.Gaps = []infra.SimGap{{Symbol: "TCS", From: gapFrom, To: gapFrom.Add(time.Hour)}}
+	spikeAt := simEpoch.Add(3 * time.Hour)
+	sim.Spikes = []infra.SimSpike{{Symbol: "TCS", At: spikeAt, Duration: time.Minute, Factor: 10}}
+
+	frozen := sim.QuoteAt("TCS", gapFrom.Add(30*time.Minute))
+	assert.Equal(t, gapFrom, frozen.AsOf)
+	assert.True(t, sim.QuoteAt("TCS", gapFrom).Price.Equal(frozen.Price))
+
+	clean := newSimulator(nil)
+	spiked := sim.QuoteAt("TCS", spikeAt)
+	assert.True(t, clean.QuoteAt("TCS", spikeAt).Price.Mul(decimal.NewFromInt(10)).Sub(spiked.Price).Abs().LessThan(decimal.NewFromFloat(0.1)))
+	assert.True(t, clean.QuoteAt("TCS", spikeAt.Add(time.Minute)).Price.Equal(sim.QuoteAt("TCS", spikeAt.Add(time.Minute)).Price))
+}
+
+func TestParseSimPrices(t *testing.T) {
+	prices, err := infra.ParseSimPrices("tcs=3500, INFY=1500:0.2")
+	require.NoError(t, err)
+	assert.Equal(t, infra.SimSymbol{Start: 3500}, prices["TCS"])
+	assert.Equal(t, infra.SimSymbol{Start: 1500, Volatility: 0.2}, prices["INFY"])
+
+	_, err = infra.ParseSimPrices("TCS=-1")
+	assert.Error(t, err)
+}
+
+func TestPriceSimulator_NonPositiveStepAndSpeedDoNotPanic(t *testing.T) {
+	sim := newSimulator(&infra.ScaledClock{Origin: simEpoch})
+	sim.Step = 0
+	var quotes map[string]model.Quote
+	require.NotPanics(t, func() {
+		var err error
+		quotes, err = sim.GetPrices(context.Background(), []string{"TCS"})
+		require.NoError(t, err)
+	})
+	assert.True(t, quotes["TCS"].Price.IsPositive())
+}
+
+func TestNewPriceSimulatorFromEnv_RejectsNonPositiveStepAndSpeed(t *testing.T) {
+	for key, value := range map[string]string{"SIM_STEP": "0s", "SIM_SPEED": "-2", "SIM_VOLATILITY": "-0.1"} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, value)
+			_, err := infra.NewPriceSimulatorFromEnv()
+			assert.ErrorContains(t, err, key)
+		})
+	}
+}
+
+func TestPriceSimulator_EvictsLeastRecentlyUsedWalks(t *testing.T) {
+	sim := newSimulator(nil)
+	sim.MaxWalks = 2
+	at := simEpoch.Add(time.Hour)
+	tcs := sim.QuoteAt("TCS", at)
+	sim.QuoteAt("INFY", at)
+	sim.QuoteAt("TCS", at)
+	sim.QuoteAt("WIPRO", at)
+	assert.Equal(t, 2, sim.Walks())
+	// INFY was evicted; recomputing it gives the same price
+	assert.True(t, newSimulator(nil).QuoteAt("INFY", at).Price.Equal(sim.QuoteAt("INFY", at).Price))
+	assert.True(t, tcs.Price.Equal(sim.QuoteAt("TCS", at).Price))
+	assert.Equal(t, 2, sim.Walks())
+}
@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/events"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// recordingQuotes captures quotes handed to the cache or notifier.
+type recordingQuotes struct {
+	quotes []model.Quote
+}
+
+func (r *recordingQuotes) SetQuote(ctx context.Context, q model.Quote) error {
+	r.quotes = append(r.quotes, q)
+	return nil
+}
+
+func (r *recordingQuotes) NotifyPrice(ctx context.Context, q model.Quote) error {
+	r.quotes = append(r.quotes, q)
+	return nil
+}
+
+func tickMessage(t *testing.T, symbol, price string, at time.Time) events.Message {
+	msg, err := testEncoder(t).PriceTickMessage(model.PriceTick{
+		Symbol:    symbol,
+		Exchange:  model.ExchangeNSE,
+		Price:     price,
+		Volume:    100,
+		Timestamp: at.Format(time.RFC3339Nano),
+	})
+	assert.NoError(t, err)
+	return msg
+}
+
+func newTickIngester(store *MockPrices, now time.Time) (*service.PriceTickIngester, *recordingQuotes, *recordingQuotes) {
+	cache, notifier := &recordingQuotes{}, &recordingQuotes{}
+	return &service.PriceTickIngester{
+		Store:    store,
+		Cache:    cache,
+		Notifier: notifier,
+		MaxSkew:  5 * time.Second,
+		Now:      func() time.Time { return now },
+	}, cache, notifier
+}
+
+func TestPriceTickIngester_AnnouncesAppliedTicksOnly(t *testing.T) {
+	now := time.Date(2025, 9, 25, 6, 0, 0, 0, time.UTC)
+	store := &MockPrices{}
+	store.On("ApplyTick", mock.Anything, mock.Anything, int64(100)).Return(true, nil).Once()
+	// The store keeps the newest tick and drops late and redelivered ones
+	store.On("ApplyTick", mock.Anything, mock.Anything, int64(100)).Return(false, nil)
+	ingester, cache, notifier := newTickIngester(store, now)
+	ctx := context.Background()
+
+	assert.NoError(t, ingester.Handle(ctx, tickMessage(t, "tcs", "3950.25", now.Add(-time.Second))))
+	assert.NoError(t, ingester.Handle(ctx, tickMessage(t, "TCS", "3940", now.Add(-2*time.Second))))
+	assert.NoError(t, ingester.Handle(ctx, tickMessage(t, "TCS", "3950.25", now.Add(-time.Second))))
+
+	applied := store.quotes("ApplyTick")
+	assert.Len(t, applied, 3)
+	latest := applied[0]
+	assert.Equal(t, "TCS", latest.Symbol)
+	assert.Equal(t, "3950.25", latest.Price.String())
+	assert.Equal(t, service.SourceTick, latest.Source)
+	assert.Len(t, cache.quotes, 1)
+	assert.Len(t, notifier.quotes, 1)
+	assert.Equal(t, "TCS", notifier.quotes[0].Symbol)
+}
+
+func TestPriceTickIngester_DropsInvalidTicks(t *testing.T) {
+	now := time.Date(2025, 9, 25, 6, 0, 0, 0, time.UTC)
+	store := &MockPrices{}
+	ingester, cache, _ := newTickIngester(store, now)
+	ctx := context.Background()
+
+	for _, msg := range []events.Message{
+		tickMessage(t, "TCS", "0", now),
+		tickMessage(t, "TCS", "-5", now),
+		tickMessage(t, "TCS", "abc", now),
+		tickMessage(t, "", "100", now),
+		tickMessage(t, "TCS", "100", now.Add(time.Minute)),
+		{Type: events.TypePriceTick, Value: []byte(`not json`)},
+	} {
+		assert.NoError(t, ingester.Handle(ctx, msg))
+	}
+	store.AssertNotCalled(t, "ApplyTick", mock.Anything, mock.Anything, mock.Anything)
+	assert.Empty(t, cache.quotes)
+}
+
+func TestPriceTickIngester_ReturnsStorageErrors(t *testing.T) {
+	now := time.Date(2025, 9, 25, 6, 0, 0, 0, time.UTC)
+	store := &MockPrices{}
+	store.On("ApplyTick", mock.Anything, mock.Anything, int64(100)).Return(false, errors.New("db down"))
+	ingester, cache, _ := newTickIngester(store, now)
+
+	assert.EqualError(t, ingester.Handle(context.Background(), tickMessage(t, "TCS", "100", now)), "db down")
+	assert.Empty(t, cache.quotes)
+}
@@ -0,0 +1,299 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/mhatrejeets/stocky-ms/internal/service"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeLock struct {
+	leader   bool
+	err      error
+	acquires atomic.Int32
+}
+
+func (l *fakeLock) TryAcquire(ctx context.Context) (bool, error) {
+	l.acquires.Add(1)
+	return l.leader, l.err
+}
+
+func (l *fakeLock) Release(ctx context.Context) error { return nil }
+
+type MockPrices struct {
+	mock.Mock
+}
+
+var _ repo.PriceRepository = (*MockPrices)(nil)
+
+// UpsertPrices returns the quotes it is given when set up with appliesAll.
+func (m *MockPrices) UpsertPrices(ctx context.Context, quotes []model.Quote) ([]model.Quote, error) {
+	args := m.Called(ctx, quotes)
+	if apply, ok := args.Get(0).(func([]model.Quote) []model.Quote); ok {
+		return apply(quotes), args.Error(1)
+	}
+	applied, _ := args.Get(0).([]model.Quote)
+	return applied, args.Error(1)
+}
+
+// appliesAll stands in for a store where every quote is newer.
+func appliesAll(quotes []model.Quote) []model.Quote { return quotes }
+
+func (m *MockPrices) ApplyTick(ctx context.Context, q model.Quote, volume int64) (bool, error) {
+	args := m.Called(ctx, q, volume)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPrices) HeldSymbols(ctx context.Context) ([]string, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockPrices) RecordCloses(ctx context.Context, tradeDate time.Time, quotes []model.Quote) error {
+	return m.Called(ctx, tradeDate, quotes).Error(0)
+}
+
+func (m *MockPrices) StartPriceRun(ctx context.Context, holder string, symbols int) (string, error) {
+	args := m.Called(ctx, holder, symbols)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockPrices) FinishPriceRun(ctx context.Context, id, status string, updated int, runErr error) error {
+	return m.Called(ctx, id, status, updated, runErr).Error(0)
+}
+
+func (m *MockPrices) ListPriceRuns(ctx context.Context, limit int) ([]model.PriceUpdateRun, error) {
+	args := m.Called(ctx, limit)
+	return args.Get(0).([]model.PriceUpdateRun), args.Error(1)
+}
+
+// quotes returns the quotes passed to method, in order: UpsertPrices and
+// RecordCloses take a batch, ApplyTick one.
+func (m *MockPrices) quotes(method string) []model.Quote {
+	var quotes []model.Quote
+	for _, call := range m.Calls {
+		switch {
+		case call.Method != method:
+		case method == "ApplyTick":
+			quotes = append(quotes, call.Arguments.Get(1).(model.Quote))
+		case method == "RecordCloses":
+			quotes = append(quotes, call.Arguments.Get(2).([]model.Quote)...)
+		default:
+			quotes = append(quotes, call.Arguments.Get(1).([]model.Quote)...)
+		}
+	}
+	return quotes
+}
+
+// recordsRun expects a run of symbols started as run-1 and finished with
+// status.
+func (m *MockPrices) recordsRun(symbols int, status string) {
+	m.On("StartPriceRun", mock.Anything, mock.Anything, symbols).Return("run-1", nil).Once()
+	m.On("FinishPriceRun", mock.Anything, "run-1", status, mock.Anything, mock.Anything).Return(nil).Once()
+}
+
+type quoteSourceFunc func(symbols []string) (map[string]model.Quote, error)
+
+func (f quoteSourceFunc) GetPrices(ctx context.Context, symbols []string) (map[string]model.Quote, error) {
+	return f(symbols)
+}
+
+func staticSymbols(symbols ...string) service.SymbolSource {
+	return service.SymbolSourceFunc(func(ctx context.Context) ([]string, error) { return symbols, nil })
+}
+
+func TestPriceUpdater_UpdatesUnionOfSourcesAndRecordsRun(t *testing.T) {
+	store := &MockPrices{}
+	store.On("StartPriceRun", mock.Anything, "replica-1", 3).Return("run-1", nil)
+	store.On("UpsertPrices", mock.Anything, mock.Anything).Return(appliesAll, nil)
+	store.On("FinishPriceRun", mock.Anything, "run-1", model.PriceRunPartial, 2, nil).Return(nil)
+	var requested []string
+	updater := &service.PriceUpdater{
+		Sources: []service.SymbolSource{staticSymbols("TCS", "INFY"), staticSymbols("INFY", "WIPRO")},
+		Prices: quoteSourceFunc(func(symbols []string) (map[string]model.Quote, error) {
+			requested = symbols
+			return map[string]model.Quote{
+				"TCS":   {Price: decimal.NewFromInt(10), AsOf: time.Now(), Source: "http"},
+				"INFY":  {Price: decimal.NewFromInt(20), AsOf: time.Now(), Source: "http"},
+				"WIPRO": {Price: decimal.NewFromInt(30), AsOf: time.Now(), Degraded: true},
+			}, nil
+		}),
+		Store:  store,
+		Lock:   &fakeLock{leader: true},
+		Holder: "replica-1",
+	}
+
+	run, err := updater.Tick(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"INFY", "TCS", "WIPRO"}, requested)
+	// The degraded (fallback) quote is not written back as fresh
+	assert.Len(t, store.quotes("UpsertPrices"), 2)
+	assert.Equal(t, model.PriceRunPartial, run.Status)
+	store.AssertExpectations(t)
+}
+
+func TestPriceUpdater_SkipsWhenNotLeader(t *testing.T) {
+	store := &MockPrices{}
+	updater := &service.PriceUpdater{
+		Sources: []service.SymbolSource{staticSymbols("TCS")},
+		Prices: quoteSourceFunc(func(symbols []string) (map[string]model.Quote, error) {
+			t.Fatal("follower must not fetch prices")
+			return nil, nil
+		}),
+		Store: store,
+		Lock:  &fakeLock{leader: false},
+	}
+	run, err := updater.Tick(context.Background())
+	assert.NoError(t, err)
+	assert.Nil(t, run)
+	store.AssertNotCalled(t, "StartPriceRun", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPriceUpdater_RecordsFailedRun(t *testing.T) {
+	store := &MockPrices{}
+	store.recordsRun(1, model.PriceRunFailed)
+	updater := &service.PriceUpdater{
+		Sources: []service.SymbolSource{staticSymbols("TCS")},
+		Prices: quoteSourceFunc(func(symbols []string) (map[string]model.Quote, error) {
+			return nil, errors.New("quote api down")
+		}),
+		Store: store,
+		Lock:  &fakeLock{leader: true},
+	}
+	run, err := updater.Tick(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, model.PriceRunFailed, run.Status)
+	store.AssertExpectations(t)
+}
+
+func TestPriceUpdater_FollowsTradingCalendar(t *testing.T) {
+	cal := nseCalendar(t)
+	store := &MockPrices{}
+	store.recordsRun(2, model.PriceRunSucceeded)
+	store.On("UpsertPrices", mock.Anything, mock.Anything).Return(appliesAll, nil)
+	store.On("RecordCloses", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	now := ist(t, "2025-01-15 15:45")
+	updater := &service.PriceUpdater{
+		Sources: []service.SymbolSource{staticSymbols("TCS", "INFY")},
+		Prices: quoteSourceFunc(func(symbols []string) (map[string]model.Quote, error) {
+			return map[string]model.Quote{
+				"TCS":  {Price: decimal.NewFromInt(10), AsOf: ist(t, "2025-01-15 15:30")},
+				"INFY": {Price: decimal.NewFromInt(20), AsOf: ist(t, "2025-01-14 15:30")},
+			}, nil
+		}),
+		Store:    store,
+		Lock:     &fakeLock{leader: true},
+		Calendar: cal,
+		Now:      func() time.Time { return now },
+	}
+
+	assert.True(t, updater.Due(ist(t, "2025-01-15 11:00")))
+	// After the close one run captures the closing prices, then it idles
+	assert.True(t, updater.Due(now))
+	_, err := updater.Tick(context.Background())
+	assert.NoError(t, err)
+	closes := store.quotes("RecordCloses")
+	require.Len(t, closes, 1, "yesterday's INFY quote is not today's close")
+	assert.Equal(t, "TCS", closes[0].Symbol)
+	assert.False(t, updater.Due(ist(t, "2025-01-15 20:00")))
+	assert.True(t, updater.Due(ist(t, "2025-01-16 09:15")))
+
+	// Once Thursday's close is in, nothing runs over the holiday and weekend
+	now = ist(t, "2025-01-16 16:00")
+	store.recordsRun(2, model.PriceRunSucceeded)
+	_, err = updater.Tick(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, updater.Due(ist(t, "2025-01-17 12:00")), "holiday")
+	assert.False(t, updater.Due(ist(t, "2025-01-19 12:00")), "weekend")
+}
+
+// maybePrices accepts any run and write.
+func maybePrices() *MockPrices {
+	store := &MockPrices{}
+	store.On("StartPriceRun", mock.Anything, mock.Anything, mock.Anything).Return("run-1", nil).Maybe()
+	store.On("UpsertPrices", mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+	store.On("FinishPriceRun", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	return store
+}
+
+func TestPriceUpdater_HeartbeatRenewsLeaseBetweenRuns(t *testing.T) {
+	lock := &fakeLock{leader: true}
+	updater := &service.PriceUpdater{
+		Prices:    quoteSourceFunc(func(symbols []string) (map[string]model.Quote, error) { return nil, nil }),
+		Store:     maybePrices(),
+		Lock:      lock,
+		Interval:  time.Hour,
+		Heartbeat: 5 * time.Millisecond,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		updater.Run(ctx)
+		close(done)
+	}()
+
+	// One acquire for the first run, the rest from the heartbeat
+	assert.Eventually(t, func() bool { return lock.acquires.Load() >= 4 }, time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+}
+
+// notifyPrice delivers announced prices in-process, as the Redis
+// subscription in main does.
+type notifyPrice func(q model.Quote)
+
+func (f notifyPrice) NotifyPrice(ctx context.Context, q model.Quote) error {
+	f(q)
+	return nil
+}
+
+func TestPriceUpdater_AppliedQuotesReachAlertEvaluator(t *testing.T) {
+	alerts := new(MockAlerts)
+	rule := model.AlertRule{ID: "r1", UserID: "u1", Kind: model.AlertDailyMove, Symbol: "TCS", Threshold: decimal.NewFromInt(5), Active: true, Armed: true}
+	alerts.On("ListActiveAlertRules", mock.Anything, []string{"TCS"}, []string{}).Return([]model.AlertRule{rule}, nil).Once()
+	alerts.On("FireAlert", mock.Anything, mock.Anything, false).Return(model.AlertEvent{ID: "a1"}, true, nil).Once()
+	alerts.On("MarkAlertDelivered", mock.Anything, "a1", "").Return(nil).Once()
+	evaluator, _, clock := newAlertEvaluator(alerts, &settablePortfolios{}, staticCloses{"TCS": decimal.NewFromInt(100)})
+
+	store := &MockPrices{}
+	store.recordsRun(2, model.PriceRunPartial)
+	// INFY is older than the stored price, so only TCS is applied
+	store.On("UpsertPrices", mock.Anything, mock.Anything).Return(func(quotes []model.Quote) []model.Quote {
+		return quotes[1:]
+	}, nil).Once()
+	cache := &recordingQuotes{}
+	updater := &service.PriceUpdater{
+		Sources: []service.SymbolSource{staticSymbols("TCS", "INFY")},
+		Prices: quoteSourceFunc(func(symbols []string) (map[string]model.Quote, error) {
+			return map[string]model.Quote{
+				"INFY": {Price: decimal.NewFromInt(1500), AsOf: clock.Now().Add(-time.Hour), Source: "http"},
+				"TCS":  {Price: decimal.NewFromInt(94), AsOf: clock.Now(), Source: "http"},
+			}, nil
+		}),
+		Store:    store,
+		Cache:    cache,
+		Notifier: notifyPrice(evaluator.OnPrice),
+		Lock:     &fakeLock{leader: true},
+	}
+
+	_, err := updater.Tick(context.Background())
+	require.NoError(t, err)
+	require.Len(t, cache.quotes, 1)
+	assert.Equal(t, "TCS", cache.quotes[0].Symbol)
+
+	require.NoError(t, evaluator.Evaluate(context.Background()))
+	require.Len(t, alerts.fired(), 1)
+	assert.Equal(t, "TCS", alerts.fired()[0].Symbol)
+	assert.True(t, decimal.NewFromInt(-6).Equal(alerts.fired()[0].Value))
+	alerts.AssertExpectations(t)
+	store.AssertExpectations(t)
+}
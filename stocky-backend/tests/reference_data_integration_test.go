@@ -0,0 +1,78 @@
+//go:build integration
+
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrumentRepository_FindsBySymbolOrISINNSEFirst(t *testing.T) {
+	instruments := &repo.InstrumentRepositoryImpl{DB: newTestDB(t)}
+	ctx := context.Background()
+	imported, err := instruments.AnyInstruments(ctx)
+	require.NoError(t, err)
+	assert.False(t, imported)
+
+	listing := func(symbol, exchange, isin, status string) model.Instrument {
+		return model.Instrument{Symbol: symbol, Exchange: exchange, ISIN: isin, Name: symbol, LotSize: 1,
+			TickSize: decimal.RequireFromString("0.05"), Currency: model.CurrencyINR, Status: status}
+	}
+	require.NoError(t, instruments.UpsertInstruments(ctx, []model.Instrument{
+		listing("RELIANCE", model.ExchangeBSE, "INE002A01018", model.InstrumentActive),
+		listing("RELIANCE", model.ExchangeNSE, "INE002A01018", model.InstrumentActive),
+		listing("JPASSOC", model.ExchangeNSE, "INE455F01025", model.InstrumentActive),
+	}))
+	// Re-importing updates the listing in place
+	require.NoError(t, instruments.UpsertInstruments(ctx, []model.Instrument{listing("JPASSOC", model.ExchangeNSE, "INE455F01025", model.InstrumentSuspended)}))
+
+	found, err := instruments.FindInstruments(ctx, "INE002A01018")
+	require.NoError(t, err)
+	require.Len(t, found, 2)
+	assert.Equal(t, model.ExchangeNSE, found[0].Exchange)
+	assert.Equal(t, model.ExchangeBSE, found[1].Exchange)
+	found, err = instruments.FindInstruments(ctx, "JPASSOC")
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, model.InstrumentSuspended, found[0].Status)
+	assert.Equal(t, "0.05", found[0].TickSize.String())
+	found, err = instruments.FindInstruments(ctx, "RELIANC")
+	require.NoError(t, err)
+	assert.Empty(t, found)
+
+	active, err := instruments.ActiveSymbols(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"RELIANCE"}, active)
+	imported, err = instruments.AnyInstruments(ctx)
+	require.NoError(t, err)
+	assert.True(t, imported)
+}
+
+func TestFXRepository_ServesLatestRecordedRate(t *testing.T) {
+	fx := &repo.FXRepositoryImpl{DB: newTestDB(t)}
+	ctx := context.Background()
+	at := time.Date(2025, 9, 25, 6, 0, 0, 0, time.UTC)
+	rate := func(currency, value string, asOf time.Time) model.FXRate {
+		return model.FXRate{Currency: currency, Rate: decimal.RequireFromString(value), AsOf: asOf, Source: "static"}
+	}
+
+	n, err := fx.RecordFXRates(ctx, []model.FXRate{rate("USD", "83.2", at), rate("USD", "83.5", at.Add(time.Hour)), rate("EUR", "90.1", at)})
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	n, err = fx.RecordFXRates(ctx, []model.FXRate{rate("USD", "99", at)})
+	require.NoError(t, err)
+	assert.Zero(t, n, "a rate already recorded for the time is kept")
+
+	rates, err := fx.GetRates(ctx, []string{"USD", "GBP"})
+	require.NoError(t, err)
+	require.Len(t, rates, 1, "currencies without a rate are left out")
+	assert.Equal(t, "83.5", rates["USD"].Rate.String())
+	assert.True(t, at.Add(time.Hour).Equal(rates["USD"].AsOf))
+}
@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/events"
+	"github.com/mhatrejeets/stocky-ms/internal/infra"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingSink struct{ letters []events.Message }
+
+func (s *recordingSink) RecordDeadLetter(ctx context.Context, msg events.Message) error {
+	s.letters = append(s.letters, msg)
+	return nil
+}
+
+func TestRetryRouter_WalksTiersThenDLQ(t *testing.T) {
+	bus := infra.NewMemoryBus()
+	sink := &recordingSink{}
+	router := &events.RetryRouter{Publisher: bus, Group: "notify", Tiers: events.DefaultRetryTiers, Sink: sink}
+	ctx := context.Background()
+	cause := errors.New("handler failed")
+
+	msg := rewardCreatedMessage(t)
+	assert.NoError(t, router.Route(ctx, msg, cause))
+	assert.Empty(t, bus.Messages("reward-events.retry.1m"))
+	first := bus.Messages("reward-events.notify.retry.1m")
+	assert.Len(t, first, 1)
+	assert.Equal(t, "notify", first[0].Headers[events.HeaderGroup])
+	assert.Equal(t, "1", first[0].Headers[events.HeaderAttempt])
+	assert.Equal(t, "reward-events", first[0].Headers[events.HeaderOriginalTopic])
+	assert.Equal(t, msg.Headers["correlation_id"], first[0].Headers["correlation_id"])
+	retryAt, err := time.Parse(time.RFC3339, first[0].Headers[events.HeaderRetryAt])
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), retryAt, 5*time.Second)
+
+	assert.NoError(t, router.Route(ctx, first[0], cause))
+	second := bus.Messages("reward-events.notify.retry.10m")
+	assert.Len(t, second, 1)
+
+	assert.NoError(t, router.Route(ctx, second[0], cause))
+	dlq := bus.Messages("reward-events.notify.dlq")
+	assert.Len(t, dlq, 1)
+	assert.Equal(t, "3", dlq[0].Headers[events.HeaderAttempt])
+	assert.Equal(t, "handler failed", dlq[0].Headers[events.HeaderError])
+	assert.Empty(t, dlq[0].Headers[events.HeaderRetryAt])
+	assert.Len(t, sink.letters, 1)
+	assert.Equal(t, "notify", sink.letters[0].Headers[events.HeaderGroup])
+
+	replay := events.StripRetryHeaders(dlq[0])
+	assert.Equal(t, "reward-events", replay.Topic)
+	assert.Equal(t, msg.Headers, replay.Headers)
+}
+
+func TestReplayTo_GoesBackThroughTheGroupsRetryPath(t *testing.T) {
+	bus := infra.NewMemoryBus()
+	router := &events.RetryRouter{Publisher: bus, Group: "notify", Tiers: events.DefaultRetryTiers[:1]}
+	ctx := context.Background()
+	msg := rewardCreatedMessage(t)
+	cause := errors.New("handler failed")
+	assert.NoError(t, router.Route(ctx, msg, cause))
+	assert.NoError(t, router.Route(ctx, bus.Messages("reward-events.notify.retry.1m")[0], cause))
+	dead := bus.Messages("reward-events.notify.dlq")[0]
+
+	replay := events.ReplayTo(dead, "notify", events.DefaultRetryTiers)
+	assert.Equal(t, "reward-events.notify.retry.1m", replay.Topic)
+	assert.Equal(t, "reward-events", events.OriginalTopic(replay))
+	assert.Equal(t, 0, events.Attempt(replay))
+	assert.Empty(t, replay.Headers[events.HeaderError])
+	assert.Equal(t, "notify", replay.Headers[events.HeaderGroup])
+	retryAt, err := time.Parse(time.RFC3339, replay.Headers[events.HeaderRetryAt])
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), retryAt, 5*time.Second)
+	assert.Equal(t, msg.Headers["correlation_id"], replay.Headers["correlation_id"])
+
+	// A dead letter recorded before groups were tracked goes back to its topic
+	legacy := events.ReplayTo(dead, "", events.DefaultRetryTiers)
+	assert.Equal(t, "reward-events", legacy.Topic)
+	assert.Equal(t, msg.Headers, legacy.Headers)
+}
+
+func TestRetryRouter_DeferKeepsAttempt(t *testing.T) {
+	bus := infra.NewMemoryBus()
+	router := &events.RetryRouter{Publisher: bus, Group: "commands", Tiers: events.DefaultRetryTiers}
+	until := time.Now().Add(3 * time.Second).UTC().Truncate(time.Second)
+
+	msg := rewardCreatedMessage(t)
+	msg.Headers[events.HeaderAttempt] = "1"
+	assert.NoError(t, router.Defer(context.Background(), msg, until))
+	parked := bus.Messages("reward-events.commands.retry.1m")
+	assert.Len(t, parked, 1)
+	assert.Equal(t, "1", parked[0].Headers[events.HeaderAttempt])
+	assert.Equal(t, "reward-events", parked[0].Headers[events.HeaderOriginalTopic])
+	assert.Equal(t, until.Format(time.RFC3339), parked[0].Headers[events.HeaderRetryAt])
+
+	assert.Error(t, (&events.RetryRouter{Publisher: bus}).Defer(context.Background(), msg, until))
+}
+
+func TestRetryRouter_Topics(t *testing.T) {
+	router := &events.RetryRouter{Group: "stocky-notifications", Tiers: events.DefaultRetryTiers}
+	assert.Equal(t, []string{"reward-events.stocky-notifications.retry.1m", "reward-events.stocky-notifications.retry.10m"}, router.Topics("reward-events"))
+	// Two groups on one topic never share a retry topic
+	other := &events.RetryRouter{Group: "stocky-portfolio-projection", Tiers: events.DefaultRetryTiers}
+	assert.NotContains(t, other.Topics("reward-events"), router.Topics("reward-events")[0])
+}
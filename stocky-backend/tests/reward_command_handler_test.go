@@ -0,0 +1,213 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/events"
+	"github.com/mhatrejeets/stocky-ms/internal/infra"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/mhatrejeets/stocky-ms/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func commandMessage(t *testing.T, cmd model.RewardCommand) events.Message {
+	msg, err := testEncoder(t).RewardCommandMessage(cmd)
+	assert.NoError(t, err)
+	return msg
+}
+
+func lastResult(t *testing.T, bus *infra.MemoryBus) (string, model.RewardCommandResult) {
+	msgs := bus.Messages(events.TopicRewardCommandResults)
+	assert.NotEmpty(t, msgs)
+	env, err := events.Decode(msgs[len(msgs)-1])
+	assert.NoError(t, err)
+	var result model.RewardCommandResult
+	assert.NoError(t, env.DecodeData(&result))
+	return env.Type, result
+}
+
+var validCommand = model.RewardCommand{
+	CommandID:   "cmd-1",
+	PartnerID:   "partner-1",
+	UserID:      "user-1",
+	StockSymbol: "RELIANCE",
+	Shares:      "1.000000",
+	RewardedAt:  "2025-09-25T11:30:00Z",
+}
+
+func TestRewardCommandHandler_Accepts(t *testing.T) {
+	rewards := new(MockRewardRepo)
+	bus := infra.NewMemoryBus()
+	h := &service.RewardCommandHandler{Rewards: &service.RewardService{Repo: rewards}, Events: bus, Encoder: testEncoder(t), Limiter: infra.NewKeyedLimiter(100, 10)}
+	rewards.On("GetRewardByIdempotencyKey", mock.Anything, "partner-1:cmd-1").Return(nil, repo.ErrNotFound)
+	rewards.On("ExistsByUniqueHashOrIdempotency", mock.Anything, mock.Anything, "partner-1:cmd-1").Return(false, "")
+	rewards.On("CreateReward", mock.Anything, mock.Anything).Return("reward-uuid", nil)
+
+	assert.NoError(t, h.Handle(context.Background(), commandMessage(t, validCommand)))
+	eventType, result := lastResult(t, bus)
+	assert.Equal(t, events.TypeRewardCommandAccepted, eventType)
+	assert.Equal(t, "reward-uuid", result.RewardID)
+	assert.Equal(t, "partner-1", result.PartnerID)
+}
+
+func TestRewardCommandHandler_RejectsInvalidAndDuplicate(t *testing.T) {
+	rewards := new(MockRewardRepo)
+	bus := infra.NewMemoryBus()
+	h := &service.RewardCommandHandler{Rewards: &service.RewardService{Repo: rewards}, Events: bus, Encoder: testEncoder(t)}
+	rewards.On("GetRewardByIdempotencyKey", mock.Anything, mock.Anything).Return(nil, repo.ErrNotFound)
+
+	invalid := validCommand
+	invalid.Shares = "lots"
+	assert.NoError(t, h.Handle(context.Background(), commandMessage(t, invalid)))
+	eventType, result := lastResult(t, bus)
+	assert.Equal(t, events.TypeRewardCommandRejected, eventType)
+	assert.NotEmpty(t, result.Reason)
+
+	rewards.On("ExistsByUniqueHashOrIdempotency", mock.Anything, mock.Anything, mock.Anything).Return(true, "reward-uuid")
+	assert.NoError(t, h.Handle(context.Background(), commandMessage(t, validCommand)))
+	_, result = lastResult(t, bus)
+	assert.Equal(t, "duplicate reward", result.Reason)
+	assert.Equal(t, "reward-uuid", result.RewardID)
+}
+
+func TestRewardCommandHandler_ReplayIsAccepted(t *testing.T) {
+	rewards := new(MockRewardRepo)
+	bus := infra.NewMemoryBus()
+	h := &service.RewardCommandHandler{Rewards: &service.RewardService{Repo: rewards}, Events: bus, Encoder: testEncoder(t)}
+	rewards.On("GetRewardByIdempotencyKey", mock.Anything, "partner-1:cmd-1").Return(model.Reward{ID: "reward-uuid", UserID: "user-1", StockSymbol: "RELIANCE"}, nil)
+
+	assert.NoError(t, h.Handle(context.Background(), commandMessage(t, validCommand)))
+	eventType, result := lastResult(t, bus)
+	assert.Equal(t, events.TypeRewardCommandAccepted, eventType)
+	assert.Equal(t, "reward-uuid", result.RewardID)
+	rewards.AssertNotCalled(t, "CreateReward", mock.Anything, mock.Anything)
+}
+
+func TestRewardCommandHandler_KeysAreScopedToThePartner(t *testing.T) {
+	rewards := new(MockRewardRepo)
+	bus := infra.NewMemoryBus()
+	h := &service.RewardCommandHandler{Rewards: &service.RewardService{Repo: rewards}, Events: bus, Encoder: testEncoder(t)}
+	// partner-1 already used the key for user-1's RELIANCE reward
+	rewards.On("GetRewardByIdempotencyKey", mock.Anything, "partner-1:key-1").Return(model.Reward{ID: "reward-uuid", UserID: "user-1", StockSymbol: "RELIANCE"}, nil)
+	rewards.On("GetRewardByIdempotencyKey", mock.Anything, "partner-2:key-1").Return(nil, repo.ErrNotFound)
+	rewards.On("ExistsByUniqueHashOrIdempotency", mock.Anything, mock.Anything, "partner-2:key-1").Return(false, "")
+	rewards.On("CreateReward", mock.Anything, mock.MatchedBy(func(rw model.Reward) bool { return rw.IdempotencyKey == "partner-2:key-1" })).Return("other-uuid", nil)
+
+	// The same key from another partner is a new reward
+	other := validCommand
+	other.IdempotencyKey, other.PartnerID, other.UserID = "key-1", "partner-2", "user-2"
+	assert.NoError(t, h.Handle(context.Background(), commandMessage(t, other)))
+	eventType, result := lastResult(t, bus)
+	assert.Equal(t, events.TypeRewardCommandAccepted, eventType)
+	assert.Equal(t, "other-uuid", result.RewardID)
+	assert.Equal(t, "key-1", result.IdempotencyKey)
+
+	// Reusing it for another user or stock is rejected without the reward
+	for _, reuse := range []func(*model.RewardCommand){
+		func(cmd *model.RewardCommand) { cmd.UserID = "user-2" },
+		func(cmd *model.RewardCommand) { cmd.StockSymbol = "TCS" },
+	} {
+		cmd := validCommand
+		cmd.IdempotencyKey = "key-1"
+		reuse(&cmd)
+		assert.NoError(t, h.Handle(context.Background(), commandMessage(t, cmd)))
+		eventType, result = lastResult(t, bus)
+		assert.Equal(t, events.TypeRewardCommandRejected, eventType)
+		assert.Equal(t, "idempotency key already used for a different reward", result.Reason)
+		assert.Empty(t, result.RewardID)
+	}
+
+	// A true replay is accepted, whatever the symbol's case
+	replay := validCommand
+	replay.IdempotencyKey, replay.StockSymbol = "key-1", "reliance"
+	assert.NoError(t, h.Handle(context.Background(), commandMessage(t, replay)))
+	eventType, result = lastResult(t, bus)
+	assert.Equal(t, events.TypeRewardCommandAccepted, eventType)
+	assert.Equal(t, "reward-uuid", result.RewardID)
+	rewards.AssertNumberOfCalls(t, "CreateReward", 1)
+}
+
+func TestRewardCommandHandler_KeyLookupFailureIsRetried(t *testing.T) {
+	rewards := new(MockRewardRepo)
+	bus := infra.NewMemoryBus()
+	h := &service.RewardCommandHandler{Rewards: &service.RewardService{Repo: rewards}, Events: bus, Encoder: testEncoder(t)}
+	rewards.On("GetRewardByIdempotencyKey", mock.Anything, mock.Anything).Return(nil, errors.New("db down"))
+
+	assert.Error(t, h.Handle(context.Background(), commandMessage(t, validCommand)))
+	assert.Empty(t, bus.Messages(events.TopicRewardCommandResults))
+	rewards.AssertNotCalled(t, "CreateReward", mock.Anything, mock.Anything)
+}
+
+func TestRewardCommandHandler_TransientErrorIsReturned(t *testing.T) {
+	rewards := new(MockRewardRepo)
+	bus := infra.NewMemoryBus()
+	h := &service.RewardCommandHandler{Rewards: &service.RewardService{Repo: rewards}, Events: bus, Encoder: testEncoder(t)}
+	rewards.On("GetRewardByIdempotencyKey", mock.Anything, mock.Anything).Return(nil, repo.ErrNotFound)
+	rewards.On("ExistsByUniqueHashOrIdempotency", mock.Anything, mock.Anything, mock.Anything).Return(false, "")
+	rewards.On("CreateReward", mock.Anything, mock.Anything).Return("", errors.New("db down"))
+
+	assert.Error(t, h.Handle(context.Background(), commandMessage(t, validCommand)))
+	assert.Empty(t, bus.Messages(events.TopicRewardCommandResults))
+}
+
+func TestRewardCommandHandler_RejectsMalformedCommand(t *testing.T) {
+	rewards := new(MockRewardRepo)
+	bus := infra.NewMemoryBus()
+	h := &service.RewardCommandHandler{Rewards: &service.RewardService{Repo: rewards}, Events: bus, Encoder: testEncoder(t)}
+
+	msg := commandMessage(t, validCommand)
+	msg.Value = []byte(`{"specversion":"1.0","id":"env-1","datacontenttype":"application/json","data":"not an object"}`)
+	assert.NoError(t, h.Handle(context.Background(), msg))
+	eventType, result := lastResult(t, bus)
+	assert.Equal(t, events.TypeRewardCommandRejected, eventType)
+	assert.Equal(t, "env-1", result.CommandID)
+	assert.Equal(t, "user-1", result.UserID)
+	assert.Contains(t, result.Reason, "malformed command")
+
+	msg.Value = []byte("garbage")
+	assert.NoError(t, h.Handle(context.Background(), msg))
+	assert.Len(t, bus.Messages(events.TopicRewardCommandResults), 2)
+	rewards.AssertNotCalled(t, "CreateReward", mock.Anything, mock.Anything)
+}
+
+func TestRewardCommandHandler_DefersPartnerOverRate(t *testing.T) {
+	rewards := new(MockRewardRepo)
+	bus := infra.NewMemoryBus()
+	h := &service.RewardCommandHandler{Rewards: &service.RewardService{Repo: rewards}, Events: bus, Encoder: testEncoder(t), Limiter: infra.NewKeyedLimiter(1, 1)}
+	rewards.On("GetRewardByIdempotencyKey", mock.Anything, mock.Anything).Return(model.Reward{ID: "reward-uuid", UserID: "user-1", StockSymbol: "RELIANCE"}, nil)
+
+	assert.NoError(t, h.Handle(context.Background(), commandMessage(t, validCommand)))
+	err := h.Handle(context.Background(), commandMessage(t, validCommand))
+	var deferred *events.DeferredError
+	assert.ErrorAs(t, err, &deferred)
+	assert.WithinDuration(t, time.Now().Add(time.Second), deferred.Until, 100*time.Millisecond)
+	assert.Len(t, bus.Messages(events.TopicRewardCommandResults), 1)
+
+	// Another partner has its own bucket
+	other := validCommand
+	other.PartnerID = "partner-2"
+	assert.NoError(t, h.Handle(context.Background(), commandMessage(t, other)))
+}
+
+func TestKeyedLimiter_ReserveDoesNotTakeWhenEmpty(t *testing.T) {
+	l := infra.NewKeyedLimiter(10, 2)
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		wait, err := l.Reserve(ctx, "p")
+		assert.NoError(t, err)
+		assert.Zero(t, wait)
+	}
+	wait, _ := l.Reserve(ctx, "p")
+	assert.InDelta(t, 100*time.Millisecond, wait, float64(10*time.Millisecond))
+	time.Sleep(wait)
+	wait, _ = l.Reserve(ctx, "p")
+	assert.Zero(t, wait)
+
+	wait, _ = infra.NewKeyedLimiter(0, 1).Reserve(ctx, "p")
+	assert.Zero(t, wait)
+}
@@ -0,0 +1,113 @@
+//go:build integration
+
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewardRepository_HistoricalINROnePriceLookup(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	day := time.Date(2025, 9, 22, 5, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		for _, symbol := range []string{"TCS", "INFY", "WIPRO"} {
+			_, err := db.ExecContext(ctx, `INSERT INTO rewards (id, user_id, stock_symbol, shares, rewarded_at, unique_hash, status)
+				VALUES ($1, 'user-1', $2, 1, $3, $4, $5)`, uuid.NewString(), symbol, day.AddDate(0, 0, i), uuid.NewString(), model.RewardStatusActive)
+			require.NoError(t, err)
+		}
+	}
+	prices := &stubPrices{quotes: map[string]decimal.Decimal{"TCS": decimal.NewFromInt(4000), "INFY": decimal.NewFromInt(1500)}}
+	r := &repo.RewardRepositoryImpl{DB: db, Prices: prices}
+
+	days, err := r.GetHistoricalINR(ctx, "user-1", "2025-09-22", "2025-09-27", "", "")
+	require.NoError(t, err)
+	assert.Len(t, days, 5)
+	// Five days of three symbols cost one batch lookup
+	assert.Equal(t, 1, prices.calls)
+}
+
+func TestRewardRepository_HistoricalINRPricesUnlabelledQuotesInListingCurrency(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	day := time.Date(2025, 9, 22, 15, 0, 0, 0, time.UTC)
+	_, err := db.ExecContext(ctx, `INSERT INTO instruments (symbol, exchange, isin, name, currency) VALUES ('AAPL', 'NASDAQ', 'US0378331005', 'Apple', 'USD')`)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `INSERT INTO rewards (id, user_id, stock_symbol, shares, rewarded_at, unique_hash, status)
+		VALUES ($1, 'user-1', 'AAPL', 2, $2, $3, $4)`, uuid.NewString(), day, uuid.NewString(), model.RewardStatusActive)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `INSERT INTO daily_closes (symbol, trade_date, close, as_of) VALUES ('AAPL', '2025-09-22', 200, $1)`, day)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `INSERT INTO fx_rates (currency, as_of, rate, source) VALUES ('USD', $1, 83, 'test')`, day)
+	require.NoError(t, err)
+	// stubPrices reports no currency, like the HTTP provider and the simulator
+	prices := &stubPrices{quotes: map[string]decimal.Decimal{"AAPL": decimal.NewFromInt(210)}}
+	r := &repo.RewardRepositoryImpl{DB: db, Prices: prices, FX: &repo.FXRepositoryImpl{DB: db}, Listings: &repo.InstrumentRepositoryImpl{DB: db}}
+
+	days, err := r.GetHistoricalINR(ctx, "user-1", "2025-09-22", "2025-09-22", "", "")
+	require.NoError(t, err)
+	require.Len(t, days, 1)
+	// 2 shares at the $200 close and 83 INR per USD
+	assert.Equal(t, "33200", days[0].INRValue.String())
+}
+
+func TestRewardRepository_HistoricalINRUsesListingCurrencyWithoutAQuote(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	day := time.Date(2025, 9, 22, 15, 0, 0, 0, time.UTC)
+	_, err := db.ExecContext(ctx, `INSERT INTO instruments (symbol, exchange, isin, name, currency) VALUES ('AAPL', 'NASDAQ', 'US0378331005', 'Apple', 'USD')`)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `INSERT INTO rewards (id, user_id, stock_symbol, shares, rewarded_at, unique_hash, status)
+		VALUES ($1, 'user-1', 'AAPL', 2, $2, $3, $4)`, uuid.NewString(), day, uuid.NewString(), model.RewardStatusActive)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `INSERT INTO daily_closes (symbol, trade_date, close, as_of) VALUES ('AAPL', '2025-09-22', 200, $1)`, day)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `INSERT INTO fx_rates (currency, as_of, rate, source) VALUES ('USD', $1, 83, 'test')`, day)
+	require.NoError(t, err)
+	// No current price, so nothing but the instrument says AAPL is in USD
+	r := &repo.RewardRepositoryImpl{DB: db, Prices: &stubPrices{}, FX: &repo.FXRepositoryImpl{DB: db}, Listings: &repo.InstrumentRepositoryImpl{DB: db}}
+
+	days, err := r.GetHistoricalINR(ctx, "user-1", "2025-09-22", "2025-09-22", "", "")
+	require.NoError(t, err)
+	require.Len(t, days, 1)
+	assert.Equal(t, "33200", days[0].INRValue.String())
+}
+
+func TestRewardRepository_HistoricalINRLeavesOutReversedRewards(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	day := time.Date(2025, 9, 22, 5, 0, 0, 0, time.UTC)
+	for _, rw := range []struct {
+		symbol string
+		shares int
+		at     time.Time
+		status string
+	}{
+		{"TCS", 2, day, model.RewardStatusActive},
+		{"TCS", 5, day, model.RewardStatusReversed},
+		{"INFY", 1, day.AddDate(0, 0, 1), model.RewardStatusReversed},
+	} {
+		_, err := db.ExecContext(ctx, `INSERT INTO rewards (id, user_id, stock_symbol, shares, rewarded_at, unique_hash, status)
+			VALUES ($1, 'user-1', $2, $3, $4, $5, $6)`, uuid.NewString(), rw.symbol, rw.shares, rw.at, uuid.NewString(), rw.status)
+		require.NoError(t, err)
+	}
+	_, err := db.ExecContext(ctx, `INSERT INTO daily_closes (symbol, trade_date, close, as_of) VALUES ('TCS', '2025-09-22', 4000, $1)`, day)
+	require.NoError(t, err)
+	r := &repo.RewardRepositoryImpl{DB: db, Prices: &stubPrices{quotes: map[string]decimal.Decimal{"TCS": decimal.NewFromInt(4100), "INFY": decimal.NewFromInt(1500)}}}
+
+	days, err := r.GetHistoricalINR(ctx, "user-1", "2025-09-22", "2025-09-24", "", "")
+	require.NoError(t, err)
+	// Only the 2 active shares; the day with nothing but a reversal is gone
+	require.Len(t, days, 1)
+	assert.Equal(t, "2025-09-22", days[0].Date)
+	assert.Equal(t, "8000", days[0].INRValue.String())
+}
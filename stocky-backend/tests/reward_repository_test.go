@@ -0,0 +1,35 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewardRepository_OnePriceLookupPerValuation(t *testing.T) {
+	prices := &stubPrices{quotes: map[string]decimal.Decimal{"TCS": decimal.NewFromInt(4000), "INFY": decimal.NewFromInt(1500)}}
+	r := &repo.RewardRepositoryImpl{
+		Holdings: staticHoldings{"TCS": decimal.NewFromInt(2), "INFY": decimal.NewFromInt(3), "WIPRO": decimal.NewFromInt(1)},
+		Prices:   prices,
+	}
+	ctx := context.Background()
+
+	portfolio, err := r.GetPortfolio(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, prices.calls)
+	assert.Equal(t, "12500", portfolio.PortfolioTotalINR.String())
+
+	_, err = r.GetStats(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, prices.calls)
+
+	// Nothing held, nothing to look up
+	r.Holdings = staticHoldings{}
+	_, err = r.GetPortfolio(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, prices.calls)
+}
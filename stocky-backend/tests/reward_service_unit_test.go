@@ -26,6 +26,11 @@ func (m *MockRewardRepo) CheckIdempotencyKey(ctx context.Context, key string) (b
 	args := m.Called(ctx, key)
 	return args.Bool(0), args.Get(1)
 }
+func (m *MockRewardRepo) GetRewardByIdempotencyKey(ctx context.Context, key string) (model.Reward, error) {
+	args := m.Called(ctx, key)
+	rw, _ := args.Get(0).(model.Reward)
+	return rw, args.Error(1)
+}
 
 func TestCreateReward_Success(t *testing.T) {
 	repo := new(MockRewardRepo)
@@ -59,3 +64,8 @@ func TestCreateReward_Duplicate(t *testing.T) {
 	assert.Error(t, result.Err)
 	assert.Equal(t, "reward-uuid", result.RewardID)
 }
+
+func (m *MockRewardRepo) ReverseReward(ctx context.Context, rewardID, reason string) (model.Reward, error) {
+	args := m.Called(ctx, rewardID, reason)
+	return args.Get(0).(model.Reward), args.Error(1)
+}
@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mhatrejeets/stocky-ms/internal/market"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ist(t *testing.T, value string) time.Time {
+	loc, err := time.LoadLocation("Asia/Kolkata")
+	require.NoError(t, err)
+	ts, err := time.ParseInLocation("2006-01-02 15:04", value, loc)
+	require.NoError(t, err)
+	return ts
+}
+
+func nseCalendar(t *testing.T) *market.Calendar {
+	holidays, err := market.LoadHolidays(strings.NewReader("# test\nexchange,date,description\nNSE,2025-01-17,Test holiday\nBSE,2025-01-16,Other exchange\n"))
+	require.NoError(t, err)
+	return market.NewCalendar("nse", market.NSE(), holidays)
+}
+
+func TestCalendar_TradingDaysAndCloses(t *testing.T) {
+	cal := nseCalendar(t)
+	// Wednesday 2025-01-15 during the session
+	assert.True(t, cal.IsOpen(ist(t, "2025-01-15 10:00")))
+	assert.False(t, cal.IsOpen(ist(t, "2025-01-15 15:30")))
+	assert.True(t, cal.LastClose(ist(t, "2025-01-15 10:00")).Equal(ist(t, "2025-01-14 15:30")))
+	// BSE's holiday doesn't close NSE
+	assert.True(t, cal.IsTradingDay(ist(t, "2025-01-16 12:00")))
+	// Friday is a holiday, so the weekend looks back to Thursday
+	assert.False(t, cal.IsTradingDay(ist(t, "2025-01-17 12:00")))
+	assert.True(t, cal.LastClose(ist(t, "2025-01-19 11:00")).Equal(ist(t, "2025-01-16 15:30")))
+	assert.True(t, cal.PreviousCloseDate(ist(t, "2025-01-19 11:00")).Equal(ist(t, "2025-01-16 00:00")))
+	assert.True(t, cal.NextOpen(ist(t, "2025-01-16 16:00")).Equal(ist(t, "2025-01-20 09:15")))
+}
+
+func TestLoadHolidayFile_Bundled(t *testing.T) {
+	holidays, err := market.LoadHolidayFile("")
+	require.NoError(t, err)
+	cal := market.NewCalendar("NSE", market.NSE(), holidays)
+	desc, ok := cal.Holiday(ist(t, "2025-12-25 12:00"))
+	assert.True(t, ok)
+	assert.Equal(t, "Christmas", desc)
+}
+
+func TestStalenessPolicy(t *testing.T) {
+	now := ist(t, "2025-01-15 11:00")
+	policy := &market.StalenessPolicy{Calendar: nseCalendar(t), MaxAge: 15 * time.Minute, Now: func() time.Time { return now }}
+
+	// Trading hours: age against MaxAge
+	assert.False(t, policy.IsStale(ist(t, "2025-01-15 10:50")))
+	assert.True(t, policy.IsStale(ist(t, "2025-01-15 10:40")))
+	assert.True(t, policy.IsStale(time.Time{}))
+
+	// Long weekend: Thursday's closing price is still current, Wednesday's is not
+	now = ist(t, "2025-01-18 12:00")
+	assert.False(t, policy.IsStale(ist(t, "2025-01-16 15:29")))
+	assert.True(t, policy.IsStale(ist(t, "2025-01-15 15:30")))
+}
+
+func TestStalenessPolicy_JudgesEachExchangeOnItsOwnCalendar(t *testing.T) {
+	holidays, err := market.LoadHolidays(strings.NewReader("NSE,2025-01-17,Test holiday\nNYSE,2025-01-20,Martin Luther King Jr. Day\n"))
+	require.NoError(t, err)
+	// Friday evening in India: NSE is shut for its holiday, NYSE is trading
+	now := ist(t, "2025-01-17 21:00")
+	policy := &market.StalenessPolicy{
+		Calendar:  market.NewCalendar("NSE", market.NSE(), holidays),
+		Exchanges: market.ExchangeCalendars(holidays),
+		MaxAge:    15 * time.Minute,
+		Now:       func() time.Time { return now },
+	}
+	morning := ist(t, "2025-01-17 20:30")
+	assert.True(t, policy.IsStaleOn("NYSE", morning))
+	assert.True(t, policy.IsStaleOn("nasdaq", morning))
+	assert.False(t, policy.IsStaleOn("NSE", ist(t, "2025-01-16 15:30")))
+	// Unknown exchanges fall back to the default calendar
+	assert.False(t, policy.IsStaleOn("LSE", ist(t, "2025-01-16 15:30")))
+
+	// Monday is a US holiday, so Friday's US close is still current there
+	now = ist(t, "2025-01-20 22:00")
+	fridayClose := ist(t, "2025-01-18 02:30")
+	assert.False(t, policy.IsStaleOn("NYSE", fridayClose))
+	assert.True(t, policy.IsStaleOn("NSE", fridayClose))
+	assert.True(t, policy.CalendarFor("NYSE").IsTradingDay(ist(t, "2025-01-17 21:00")))
+}
+
+func TestParseHours_RejectsInvertedSession(t *testing.T) {
+	_, err := market.ParseHours("Asia/Kolkata", "15:30", "09:15")
+	assert.Error(t, err)
+	_, err = market.ParseHours("Mars/Olympus", "09:15", "15:30")
+	assert.Error(t, err)
+}
@@ -0,0 +1,250 @@
+//go:build integration
+
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mhatrejeets/stocky-ms/internal/migrate"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lots returns the user's tax lots by reward.
+func (f *holdingsFixture) lots(t *testing.T, userID string) map[string]model.TaxLot {
+	lots, err := (&repo.TaxLotRepositoryImpl{DB: f.db}).ListLots(context.Background(), userID)
+	require.NoError(t, err)
+	byReward := make(map[string]model.TaxLot, len(lots))
+	for _, lot := range lots {
+		byReward[lot.RewardID] = lot
+	}
+	return byReward
+}
+
+func (f *holdingsFixture) exec(t *testing.T, query string, args ...interface{}) {
+	_, err := f.db.ExecContext(context.Background(), query, args...)
+	require.NoError(t, err)
+}
+
+func TestTaxLots_CostIsThePriceOnTheRewardDate(t *testing.T) {
+	f := newHoldingsFixture(t)
+	day := time.Date(2025, 9, 2, 0, 0, 0, 0, time.UTC)
+	f.exec(t, `INSERT INTO stock_prices (symbol, price, currency, updated_at) VALUES ('TCS', 4000, 'INR', $1)`, day.AddDate(0, 0, 20))
+	f.exec(t, `INSERT INTO daily_closes (symbol, trade_date, close, as_of) VALUES ('TCS', '2025-09-01', 3600, $1)`, day)
+	f.exec(t, `INSERT INTO price_history (symbol, as_of, price, source) VALUES ('TCS', $1, 3700, 'test'), ('TCS', $2, 3900, 'test')`,
+		day.Add(5*time.Hour), day.AddDate(0, 0, 8))
+
+	recorded := f.reward(t, "u1", 1, day.AddDate(0, 0, 3))
+	// Before the first recorded price, on a day with a close
+	closed := f.reward(t, "u1", 1, day.Add(-12*time.Hour))
+	// Before anything but the current price
+	current := f.reward(t, "u1", 1, day.AddDate(0, 0, -5))
+
+	lots := f.lots(t, "u1")
+	require.Len(t, lots, 3)
+	for id, want := range map[string]struct{ cost, source string }{
+		recorded: {"3700", model.CostSourcePriceHistory},
+		closed:   {"3600", model.CostSourceDailyClose},
+		current:  {"4000", model.CostSourceCurrentPrice},
+	} {
+		lot := lots[id]
+		require.NotNil(t, lot.CostPerShare, want.source)
+		assert.Equal(t, want.cost, lot.CostPerShare.String(), want.source)
+		assert.Equal(t, want.source, lot.CostSource)
+		assert.Equal(t, model.CurrencyINR, lot.Currency)
+		assert.Equal(t, "1", lot.FXRate.String())
+	}
+}
+
+func TestTaxLots_ForeignLotTakesTheRateOnTheRewardDate(t *testing.T) {
+	f := newHoldingsFixture(t)
+	ctx := context.Background()
+	at := time.Date(2025, 9, 2, 15, 0, 0, 0, time.UTC)
+	f.exec(t, `INSERT INTO stock_prices (symbol, price, currency, updated_at) VALUES ('AAPL', 200, 'USD', $1)`, at.AddDate(0, 0, 1))
+	f.exec(t, `INSERT INTO price_history (symbol, as_of, price, source) VALUES ('AAPL', $1, 180, 'test')`, at.Add(-time.Hour))
+	f.exec(t, `INSERT INTO fx_rates (currency, as_of, rate, source) VALUES ('USD', $1, 80, 'test'), ('USD', $2, 85, 'test')`,
+		at.Add(-2*time.Hour), at.AddDate(0, 0, 1))
+
+	id, err := f.rewards.CreateReward(ctx, model.Reward{
+		ID: uuid.NewString(), UserID: "u1", StockSymbol: "AAPL", Shares: decimal.RequireFromString("0.5"), RewardedAt: at,
+		CreatedAt: time.Now().UTC(), UniqueHash: uuid.NewString(), IdempotencyKey: uuid.NewString(), Status: model.RewardStatusActive,
+	})
+	require.NoError(t, err)
+
+	lot := f.lots(t, "u1")[id]
+	assert.Equal(t, model.CurrencyUSD, lot.Currency)
+	assert.Equal(t, "180", lot.CostPerShare.String())
+	assert.Equal(t, "80", lot.FXRate.String())
+
+	basis, err := (&repo.TaxLotRepositoryImpl{DB: f.db}).CostBasis(ctx, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, "90", basis["AAPL"].Invested.String())
+	assert.Equal(t, "7200", basis["AAPL"].InvestedINR.String())
+}
+
+// disposals lists every disposal as lot, shares and reference, in order.
+func (f *holdingsFixture) disposals(t *testing.T) [][3]string {
+	rows, err := f.db.QueryContext(context.Background(), `SELECT lot_id, shares, reference FROM tax_lot_disposals ORDER BY created_at, disposed_at, shares DESC`)
+	require.NoError(t, err)
+	defer rows.Close()
+	var out [][3]string
+	for rows.Next() {
+		var lot, shares, reference string
+		require.NoError(t, rows.Scan(&lot, &shares, &reference))
+		out = append(out, [3]string{lot, decimal.RequireFromString(shares).String(), reference})
+	}
+	require.NoError(t, rows.Err())
+	return out
+}
+
+func TestTaxLots_ReversalsAndSalesConsumeOldestLotsFirst(t *testing.T) {
+	f := newHoldingsFixture(t)
+	ctx := context.Background()
+	t0 := time.Date(2025, 9, 1, 5, 0, 0, 0, time.UTC)
+	f.exec(t, `INSERT INTO price_history (symbol, as_of, price, source) VALUES ('TCS', $1, 3000, 'test'), ('TCS', $2, 3200, 'test'), ('TCS', $3, 3300, 'test')`,
+		t0.Add(-time.Hour), t0.AddDate(0, 0, 13), t0.AddDate(0, 0, 19))
+	a := f.reward(t, "u1", 10, t0)
+	b := f.reward(t, "u1", 5, t0.AddDate(0, 0, 14))
+	c := f.reward(t, "u1", 8, t0.AddDate(0, 0, 20))
+
+	// Reversing b takes its shares from the oldest lot, a
+	_, err := f.rewards.ReverseReward(ctx, b, "fraud")
+	require.NoError(t, err)
+	lots := f.lots(t, "u1")
+	assert.Equal(t, "5", lots[a].RemainingShares.String())
+	assert.Equal(t, "5", lots[b].RemainingShares.String())
+	assert.Equal(t, "8", lots[c].RemainingShares.String())
+
+	// Reversing c finishes a and moves on to b
+	_, err = f.rewards.ReverseReward(ctx, c, "fraud")
+	require.NoError(t, err)
+	lots = f.lots(t, "u1")
+	assert.True(t, lots[a].RemainingShares.IsZero())
+	assert.Equal(t, "2", lots[b].RemainingShares.String())
+	assert.Equal(t, "8", lots[c].RemainingShares.String())
+	assert.Equal(t, [][3]string{
+		{lots[a].ID, "5", b},
+		{lots[a].ID, "5", c},
+		{lots[b].ID, "3", c},
+	}, f.disposals(t))
+
+	basis, err := (&repo.TaxLotRepositoryImpl{DB: f.db}).CostBasis(ctx, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, f.held(t, "u1"), basis["TCS"].Shares.String())
+	assert.Equal(t, "32800", basis["TCS"].Invested.String())
+
+	// A sale takes the rest of b, then part of c, each at its own cost
+	lotRepo := &repo.TaxLotRepositoryImpl{DB: f.db}
+	sold, err := lotRepo.ConsumeLots(ctx, "u1", "TCS", decimal.NewFromInt(4), model.DisposalSale, "order-1", t0.AddDate(0, 1, 0))
+	require.NoError(t, err)
+	require.Len(t, sold, 2)
+	assert.Equal(t, lots[b].ID, sold[0].LotID)
+	assert.Equal(t, "2", sold[0].Shares.String())
+	assert.Equal(t, "3200", sold[0].CostPerShare.String())
+	assert.Equal(t, lots[c].ID, sold[1].LotID)
+	assert.Equal(t, "2", sold[1].Shares.String())
+	assert.Equal(t, "3300", sold[1].CostPerShare.String())
+	assert.Equal(t, model.DisposalSale, sold[1].Reason)
+
+	// Selling more than is open takes only what the lots hold
+	sold, err = lotRepo.ConsumeLots(ctx, "u1", "TCS", decimal.NewFromInt(100), model.DisposalSale, "order-2", t0.AddDate(0, 1, 1))
+	require.NoError(t, err)
+	require.Len(t, sold, 1)
+	assert.Equal(t, "6", sold[0].Shares.String())
+	lots = f.lots(t, "u1")
+	assert.True(t, lots[c].RemainingShares.IsZero())
+}
+
+func TestTaxLots_SplitRestatesOpenLotsAtTheSameCost(t *testing.T) {
+	f := newHoldingsFixture(t)
+	ctx := context.Background()
+	t0 := time.Date(2025, 9, 1, 5, 0, 0, 0, time.UTC)
+	split := t0.AddDate(0, 0, 7)
+	f.exec(t, `INSERT INTO price_history (symbol, as_of, price, source) VALUES ('TCS', $1, 3000, 'test'), ('TCS', $2, 1550, 'test')`,
+		t0.Add(-time.Hour), split.Add(time.Hour))
+
+	held := f.reward(t, "u1", 10, t0)
+	reversed := f.reward(t, "u1", 2, t0)
+	// Consumes the older-recorded lot of the two acquired together
+	_, err := f.rewards.ReverseReward(ctx, reversed, "fraud")
+	require.NoError(t, err)
+	f.split(t, "2", split)
+	// Backdated before the applied split, and after it
+	backdated := f.reward(t, "u1", 4, t0)
+	later := f.reward(t, "u1", 3, split.AddDate(0, 0, 1))
+
+	lots := f.lots(t, "u1")
+	for id, want := range map[string]struct{ shares, remaining, cost string }{
+		held:      {"20", "16", "1500"},
+		reversed:  {"4", "4", "1500"},
+		backdated: {"8", "8", "1500"},
+		later:     {"3", "3", "1550"},
+	} {
+		lot := lots[id]
+		assert.Equal(t, want.shares, lot.Shares.String(), id)
+		assert.Equal(t, want.remaining, lot.RemainingShares.String(), id)
+		assert.Equal(t, want.cost, lot.CostPerShare.String(), id)
+	}
+
+	basis, err := (&repo.TaxLotRepositoryImpl{DB: f.db}).CostBasis(ctx, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, f.held(t, "u1"), basis["TCS"].Shares.String())
+	assert.Equal(t, "46650", basis["TCS"].Invested.String())
+}
+
+func TestTaxLots_BackfillReplaysReversalsOldestLotFirst(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	m, err := migrate.New(db)
+	require.NoError(t, err)
+	// Back to before tax lots, with rewards already on the books
+	require.NoError(t, m.Down(ctx, 7))
+
+	t0 := time.Date(2025, 9, 1, 5, 0, 0, 0, time.UTC)
+	active, reversed, unheld := uuid.NewString(), uuid.NewString(), uuid.NewString()
+	_, err = db.ExecContext(ctx, `INSERT INTO rewards (id, user_id, stock_symbol, shares, rewarded_at, unique_hash, status)
+		VALUES ($1, 'u1', 'TCS', 10, $4, md5(random()::text), 'active'), ($2, 'u1', 'TCS', 5, $5, md5(random()::text), 'reversed'),
+			($3, 'u2', 'TCS', 1, $4, md5(random()::text), 'reversed')`,
+		active, reversed, unheld, t0, t0.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `INSERT INTO price_history (symbol, as_of, price, source) VALUES ('TCS', $1, 3000, 'test')`, t0.Add(-time.Hour))
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `INSERT INTO corporate_actions (id, symbol, action_type, ratio, effective_at) VALUES ($1, 'TCS', 'split', 2, $2)`,
+		uuid.NewString(), t0.AddDate(0, 0, 7))
+	require.NoError(t, err)
+	require.NoError(t, m.Up(ctx))
+
+	lots := &repo.TaxLotRepositoryImpl{DB: db}
+	listed, err := lots.ListLots(ctx, "u1")
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+	byReward := map[string]model.TaxLot{listed[0].RewardID: listed[0], listed[1].RewardID: listed[1]}
+	// As at runtime: the reversal a day in took 5 of the older lot, and the
+	// split doubled what was left of both
+	assert.Equal(t, "20", byReward[active].Shares.String())
+	assert.Equal(t, "10", byReward[active].RemainingShares.String())
+	assert.Equal(t, "10", byReward[reversed].RemainingShares.String())
+	assert.Equal(t, "1500", byReward[reversed].CostPerShare.String())
+	basis, err := lots.CostBasis(ctx, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, "20", basis["TCS"].Shares.String())
+
+	var lotID, shares string
+	var disposedAt time.Time
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT lot_id, shares, disposed_at FROM tax_lot_disposals WHERE reference = $1`, reversed).
+		Scan(&lotID, &shares, &disposedAt))
+	assert.Equal(t, byReward[active].ID, lotID)
+	assert.Equal(t, "10", decimal.RequireFromString(shares).String())
+	assert.True(t, disposedAt.Equal(t0.AddDate(0, 0, 1)), disposedAt)
+
+	// A user whose only reward was reversed holds nothing
+	basis, err = lots.CostBasis(ctx, "u2")
+	require.NoError(t, err)
+	assert.Empty(t, basis)
+}
@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mhatrejeets/stocky-ms/internal/api"
+	"github.com/mhatrejeets/stocky-ms/internal/model"
+	"github.com/mhatrejeets/stocky-ms/internal/repo"
+	"github.com/mhatrejeets/stocky-ms/internal/service"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockLots struct {
+	mock.Mock
+}
+
+var _ repo.TaxLotRepository = (*MockLots)(nil)
+
+func (m *MockLots) ConsumeLots(ctx context.Context, userID, symbol string, shares decimal.Decimal, reason, reference string, at time.Time) ([]model.LotDisposal, error) {
+	args := m.Called(ctx, userID, symbol, shares, reason, reference, at)
+	disposals, _ := args.Get(0).([]model.LotDisposal)
+	return disposals, args.Error(1)
+}
+
+func (m *MockLots) CostBasis(ctx context.Context, userID string) (map[string]model.CostBasis, error) {
+	args := m.Called(ctx, userID)
+	basis, _ := args.Get(0).(map[string]model.CostBasis)
+	return basis, args.Error(1)
+}
+
+func (m *MockLots) ListLots(ctx context.Context, userID string) ([]model.TaxLot, error) {
+	args := m.Called(ctx, userID)
+	lots, _ := args.Get(0).([]model.TaxLot)
+	return lots, args.Error(1)
+}
+
+// costBasis serves basis for user-1.
+func costBasis(basis map[string]model.CostBasis, err error) *MockLots {
+	m := new(MockLots)
+	m.On("CostBasis", mock.Anything, "user-1").Return(basis, err)
+	return m
+}
+
+func TestRewardRepository_HoldingsCarryCostBasis(t *testing.T) {
+	r := newFXRewardRepo(fxRates(model.FXRate{Currency: model.CurrencyUSD, Rate: decimal.RequireFromString("83.5"), AsOf: time.Now().Add(-time.Hour)}))
+	r.Lots = costBasis(map[string]model.CostBasis{
+		"TCS": {Shares: decimal.NewFromInt(2), Invested: decimal.NewFromInt(7000), InvestedINR: decimal.NewFromInt(7000), Currency: model.CurrencyINR},
+		// Bought at 180 when a dollar was 80
+		"AAPL": {Shares: decimal.RequireFromString("0.5"), Invested: decimal.NewFromInt(90), InvestedINR: decimal.NewFromInt(7200), Currency: model.CurrencyUSD},
+		// A lot without a recorded price leaves the holding unpriced
+		"VOO": {Shares: decimal.NewFromInt(1), Invested: decimal.Zero, InvestedINR: decimal.Zero, Currency: model.CurrencyUSD, Unknown: true},
+	}, nil)
+
+	portfolio, err := r.GetPortfolio(context.Background(), "user-1")
+	require.NoError(t, err)
+	require.Len(t, portfolio.Holdings, 3)
+
+	aapl := portfolio.Holdings[0]
+	assert.Equal(t, "180", aapl.AverageCost.String())
+	assert.Equal(t, "90", aapl.InvestedValue.String())
+	assert.Equal(t, "7200", aapl.InvestedValueINR.String())
+	assert.Equal(t, "10", aapl.UnrealizedPnL.String())
+	// 8350 now against 7200 then; the rupee move counts too
+	assert.Equal(t, "1150", aapl.UnrealizedPnLINR.String())
+	assert.False(t, aapl.CostBasisUnavailable)
+
+	tcs := portfolio.Holdings[1]
+	assert.Equal(t, "3500", tcs.AverageCost.String())
+	assert.Equal(t, "1000", tcs.UnrealizedPnL.String())
+	assert.Equal(t, "1000", tcs.UnrealizedPnLINR.String())
+
+	voo := portfolio.Holdings[2]
+	assert.True(t, voo.CostBasisUnavailable)
+	assert.True(t, voo.InvestedValue.IsZero())
+	assert.True(t, voo.UnrealizedPnL.IsZero())
+
+	assert.Equal(t, "14200", portfolio.InvestedTotalINR.String())
+	assert.Equal(t, "2150", portfolio.UnrealizedPnLINR.String())
+	assert.False(t, portfolio.Degraded)
+}
+
+func TestRewardRepository_CostBasisMustCoverHolding(t *testing.T) {
+	r := newFXRewardRepo(fxRates())
+	r.Lots = costBasis(map[string]model.CostBasis{
+		// The projection has not caught up with a newer reward yet
+		"TCS": {Shares: decimal.NewFromInt(1), Invested: decimal.NewFromInt(3500), InvestedINR: decimal.NewFromInt(3500), Currency: model.CurrencyINR},
+	}, nil)
+
+	portfolio, err := r.GetPortfolio(context.Background(), "user-1")
+	require.NoError(t, err)
+	for _, h := range portfolio.Holdings {
+		assert.True(t, h.CostBasisUnavailable, h.Symbol)
+	}
+	assert.True(t, portfolio.InvestedTotalINR.IsZero())
+
+	// A failed lookup values the portfolio without cost basis
+	r.Lots = costBasis(nil, errors.New("db down"))
+	portfolio, err = r.GetPortfolio(context.Background(), "user-1")
+	require.NoError(t, err)
+	assert.True(t, portfolio.Holdings[1].CostBasisUnavailable)
+	assert.Equal(t, "8000", portfolio.Holdings[1].TotalValueINR.String())
+}
+
+func TestRewardHandler_ListsLots(t *testing.T) {
+	cost := decimal.NewFromInt(3500)
+	one := decimal.NewFromInt(1)
+	acquired := time.Date(2025, 9, 25, 4, 0, 0, 0, time.UTC)
+	lots := new(MockLots)
+	lots.On("ListLots", mock.Anything, "user-1").Return([]model.TaxLot{{
+		ID: "lot-1", RewardID: "reward-1", UserID: "user-1", Symbol: "TCS", AcquiredAt: acquired,
+		Shares: decimal.NewFromInt(2), RemainingShares: decimal.NewFromInt(2), Currency: model.CurrencyINR,
+		CostPerShare: &cost, FXRate: &one, CostSource: model.CostSourcePriceHistory,
+	}}, nil).Once()
+	gin.SetMode(gin.TestMode)
+	serve := func(svc *service.RewardService, user string) *httptest.ResponseRecorder {
+		router := gin.New()
+		rg := router.Group("/api/v1", func(c *gin.Context) {
+			c.Set("user_id", c.GetHeader("X-Test-User"))
+			c.Next()
+		})
+		(&api.RewardHandler{Service: svc}).RegisterRoutes(rg)
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/portfolio/user-1/lots", nil)
+		req.Header.Set("X-Test-User", user)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Another user's lots are not listed
+	assert.Equal(t, http.StatusForbidden, serve(&service.RewardService{Lots: lots}, "user-2").Code)
+
+	w := serve(&service.RewardService{Lots: lots}, "user-1")
+	require.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Lots []model.TaxLot `json:"lots"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.Lots, 1)
+	assert.Equal(t, "2", body.Lots[0].RemainingShares.String())
+	assert.Equal(t, "3500", body.Lots[0].CostPerShare.String())
+	assert.Equal(t, model.CostSourcePriceHistory, body.Lots[0].CostSource)
+	lots.AssertExpectations(t)
+
+	// Without a lot store the list is empty rather than null
+	assert.JSONEq(t, `{"lots":[]}`, serve(&service.RewardService{}, "user-1").Body.String())
+}